@@ -0,0 +1,78 @@
+// Package concurrency provides a client-side round-robin load balancer.
+//
+// This file demonstrates:
+// - Spreading requests evenly across replicas with round-robin selection
+// - Skipping replicas that fail their health check
+// - Decoupling from a health checking implementation with a small interface
+//
+// Grafana's services usually balance across replicas on the client side
+// (e.g. queriers picking a store-gateway), since a central load balancer
+// would be another hop and another point of failure.
+package concurrency
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrAllEndpointsUnhealthy is returned by RoundRobinBalancer.Next when no
+// endpoint passes its health check.
+var ErrAllEndpointsUnhealthy = errors.New("all endpoints are unhealthy")
+
+// HealthChecker reports whether an endpoint is currently healthy. It is
+// called on every Next, so implementations should return a cached result
+// from periodic background checks rather than probe the endpoint inline.
+type HealthChecker interface {
+	Healthy(endpoint string) bool
+}
+
+// HealthCheckerFunc adapts a function to the HealthChecker interface.
+type HealthCheckerFunc func(endpoint string) bool
+
+// Healthy calls f.
+func (f HealthCheckerFunc) Healthy(endpoint string) bool {
+	return f(endpoint)
+}
+
+// RoundRobinBalancer hands out endpoints in turn, skipping unhealthy ones.
+// It is safe for concurrent use.
+type RoundRobinBalancer struct {
+	endpoints []string
+	hc        HealthChecker
+	next      int // Index of the endpoint to try first on the next call
+	mu        sync.Mutex
+}
+
+// NewRoundRobinBalancer creates a balancer over endpoints. If hc is nil,
+// every endpoint is treated as healthy.
+func NewRoundRobinBalancer(endpoints []string, hc HealthChecker) *RoundRobinBalancer {
+	return &RoundRobinBalancer{
+		endpoints: append([]string(nil), endpoints...),
+		hc:        hc,
+	}
+}
+
+// Next returns the next healthy endpoint in round-robin order. Unhealthy
+// endpoints are skipped without using up a turn, so the healthy endpoints
+// still share the load evenly. It returns ErrAllEndpointsUnhealthy if no
+// endpoint is healthy.
+func (b *RoundRobinBalancer) Next() (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := len(b.endpoints)
+	for i := 0; i < n; i++ {
+		idx := (b.next + i) % n
+		endpoint := b.endpoints[idx]
+		if b.hc == nil || b.hc.Healthy(endpoint) {
+			b.next = (idx + 1) % n
+			return endpoint, nil
+		}
+	}
+	return "", ErrAllEndpointsUnhealthy
+}
+
+// Endpoints returns the endpoints the balancer chooses from.
+func (b *RoundRobinBalancer) Endpoints() []string {
+	return append([]string(nil), b.endpoints...)
+}
@@ -0,0 +1,81 @@
+package concurrency
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestRoundRobinBalancer_SkipsUnhealthy(t *testing.T) {
+	endpoints := []string{"ingester-0:9095", "ingester-1:9095", "ingester-2:9095"}
+	unhealthy := "ingester-1:9095"
+	b := NewRoundRobinBalancer(endpoints, HealthCheckerFunc(func(endpoint string) bool {
+		return endpoint != unhealthy
+	}))
+
+	counts := make(map[string]int)
+	for i := 0; i < 30; i++ {
+		endpoint, err := b.Next()
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		counts[endpoint]++
+	}
+
+	if counts[unhealthy] != 0 {
+		t.Errorf("unhealthy endpoint returned %d times", counts[unhealthy])
+	}
+	// The healthy endpoints share the load evenly
+	if counts["ingester-0:9095"] != 15 || counts["ingester-2:9095"] != 15 {
+		t.Errorf("counts = %v, want 15 each for the healthy endpoints", counts)
+	}
+}
+
+func TestRoundRobinBalancer_Order(t *testing.T) {
+	b := NewRoundRobinBalancer([]string{"a", "b", "c"}, nil)
+	for i, want := range []string{"a", "b", "c", "a", "b"} {
+		if got, _ := b.Next(); got != want {
+			t.Errorf("call %d: Next() = %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestRoundRobinBalancer_AllUnhealthy(t *testing.T) {
+	b := NewRoundRobinBalancer([]string{"a", "b"}, HealthCheckerFunc(func(string) bool { return false }))
+	if _, err := b.Next(); !errors.Is(err, ErrAllEndpointsUnhealthy) {
+		t.Errorf("Next() error = %v, want ErrAllEndpointsUnhealthy", err)
+	}
+
+	empty := NewRoundRobinBalancer(nil, nil)
+	if _, err := empty.Next(); !errors.Is(err, ErrAllEndpointsUnhealthy) {
+		t.Errorf("Next() on no endpoints = %v, want ErrAllEndpointsUnhealthy", err)
+	}
+}
+
+func TestRoundRobinBalancer_Concurrent(t *testing.T) {
+	b := NewRoundRobinBalancer([]string{"a", "b", "c"}, nil)
+
+	var mu sync.Mutex
+	counts := make(map[string]int)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 30; j++ {
+				endpoint, _ := b.Next()
+				mu.Lock()
+				counts[endpoint]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, endpoint := range []string{"a", "b", "c"} {
+		if counts[endpoint] != 100 {
+			t.Errorf("counts = %v, want 100 each", counts)
+			break
+		}
+	}
+}
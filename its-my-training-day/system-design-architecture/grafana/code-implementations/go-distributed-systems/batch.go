@@ -0,0 +1,172 @@
+// Package concurrency provides a generic batch processor that groups items
+// before handing them to a flush function.
+//
+// This file demonstrates:
+// - Flushing on batch size or on a timer, whichever comes first
+// - Synchronous flushes for graceful shutdown
+// - Preserving batch order with a dedicated flush mutex
+// - Retrying failed batches with backoff before dropping them
+//
+// Loki's distributors and Tempo's exporters batch writes the same way:
+// one request per batch amortises the network round trip over many items.
+package concurrency
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// BatchConfig configures a BatchProcessor.
+type BatchConfig[T any] struct {
+	// MaxSize is the number of items that triggers a flush
+	MaxSize int `default:"100" validate:"min=1"`
+	// FlushInterval is the longest an item waits before being flushed
+	FlushInterval time.Duration `default:"1s" validate:"min=1ms"`
+	// FlushFn receives each batch; the slice is not reused afterwards
+	FlushFn func(batch []T) error `validate:"required"`
+	// OnError, if set, receives errors from timer-triggered flushes,
+	// which have no caller to return them to
+	OnError func(err error)
+	// OnDropped, if set, receives each batch whose flush failed after any
+	// retries, along with the last error, so it can be logged or spooled
+	OnDropped func(batch []T, err error)
+}
+
+// BatchProcessor buffers items and passes them to FlushFn in batches.
+// Batches are delivered in the order they were cut, one at a time.
+type BatchProcessor[T any] struct {
+	config BatchConfig[T]
+
+	mu     sync.Mutex // Protects buffer and closed
+	buffer []T
+	closed bool
+
+	flushMu sync.Mutex // Serializes FlushFn calls and protects retryer
+	retryer *Retryer
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewBatchProcessor validates config, applies defaults, and starts the
+// interval flusher. Call Close to stop it and flush remaining items.
+func NewBatchProcessor[T any](config BatchConfig[T]) (*BatchProcessor[T], error) {
+	ApplyDefaults(&config)
+	if err := Validate(config); err != nil {
+		return nil, err
+	}
+
+	p := &BatchProcessor[T]{
+		config: config,
+		buffer: make([]T, 0, config.MaxSize),
+		done:   make(chan struct{}),
+	}
+
+	p.wg.Add(1)
+	go p.flushLoop()
+
+	return p, nil
+}
+
+// Add buffers item. If the buffer reaches MaxSize the batch is flushed
+// synchronously and any FlushFn error is returned.
+func (p *BatchProcessor[T]) Add(item T) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return errors.New("batch processor is closed")
+	}
+	p.buffer = append(p.buffer, item)
+	full := len(p.buffer) >= p.config.MaxSize
+	p.mu.Unlock()
+
+	if full {
+		return p.Flush()
+	}
+	return nil
+}
+
+// Flush synchronously hands every buffered item to FlushFn, regardless of
+// MaxSize or FlushInterval, and returns its error. Items added while
+// FlushFn runs go into the next batch. Flushing an empty buffer is a no-op.
+func (p *BatchProcessor[T]) Flush() error {
+	// Cut the batch while holding flushMu so batches reach FlushFn in order
+	p.flushMu.Lock()
+	defer p.flushMu.Unlock()
+
+	p.mu.Lock()
+	if len(p.buffer) == 0 {
+		p.mu.Unlock()
+		return nil
+	}
+	batch := p.buffer
+	p.buffer = make([]T, 0, p.config.MaxSize)
+	p.mu.Unlock()
+
+	var err error
+	if p.retryer != nil {
+		_, err = p.retryer.Do(func() error { return p.config.FlushFn(batch) })
+	} else {
+		err = p.config.FlushFn(batch)
+	}
+	if err != nil && p.config.OnDropped != nil {
+		p.config.OnDropped(batch, err)
+	}
+	return err
+}
+
+// WithRetry retries failed flushes with config's backoff before a batch is
+// dropped. The failing batch stays at the front of the queue: later
+// batches wait until it is delivered or dropped, so order is preserved,
+// and Add and Flush block during the backoff. It applies to batches
+// flushed after it returns.
+func (p *BatchProcessor[T]) WithRetry(config RetryConfig) *BatchProcessor[T] {
+	p.flushMu.Lock()
+	defer p.flushMu.Unlock()
+	p.retryer = NewRetryer(config)
+	return p
+}
+
+// Len returns the number of buffered items.
+func (p *BatchProcessor[T]) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.buffer)
+}
+
+// Close stops the interval flusher and flushes any remaining items.
+// Add returns an error after Close. Calling Close twice is a no-op.
+func (p *BatchProcessor[T]) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	p.mu.Unlock()
+
+	close(p.done)
+	p.wg.Wait()
+
+	return p.Flush()
+}
+
+// flushLoop flushes the buffer every FlushInterval until Close.
+func (p *BatchProcessor[T]) flushLoop() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			if err := p.Flush(); err != nil && p.config.OnError != nil {
+				p.config.OnError(err)
+			}
+		}
+	}
+}
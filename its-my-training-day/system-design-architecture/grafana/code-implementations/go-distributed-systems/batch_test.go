@@ -0,0 +1,215 @@
+package concurrency
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchRecorder collects every batch passed to FlushFn.
+type batchRecorder struct {
+	mu      sync.Mutex
+	batches [][]int
+}
+
+func (r *batchRecorder) flush(batch []int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, batch)
+	return nil
+}
+
+func (r *batchRecorder) snapshot() [][]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][]int(nil), r.batches...)
+}
+
+func TestBatchProcessor_Flush(t *testing.T) {
+	rec := &batchRecorder{}
+	p, err := NewBatchProcessor(BatchConfig[int]{
+		MaxSize:       10,
+		FlushInterval: time.Hour,
+		FlushFn:       rec.flush,
+	})
+	if err != nil {
+		t.Fatalf("NewBatchProcessor failed: %v", err)
+	}
+	defer p.Close()
+
+	for i := 1; i <= 3; i++ {
+		if err := p.Add(i); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if got := rec.snapshot(); len(got) != 0 {
+		t.Fatalf("expected no flush below MaxSize, got %v", got)
+	}
+
+	if err := p.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	want := [][]int{{1, 2, 3}}
+	if got := rec.snapshot(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected batches %v, got %v", want, got)
+	}
+	if p.Len() != 0 {
+		t.Errorf("expected empty buffer after Flush, got %d items", p.Len())
+	}
+
+	// Flushing an empty buffer does not call FlushFn
+	if err := p.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if got := rec.snapshot(); len(got) != 1 {
+		t.Errorf("expected empty Flush to be a no-op, got %d batches", len(got))
+	}
+}
+
+func TestBatchProcessor_FlushOnMaxSize(t *testing.T) {
+	rec := &batchRecorder{}
+	p, err := NewBatchProcessor(BatchConfig[int]{
+		MaxSize:       2,
+		FlushInterval: time.Hour,
+		FlushFn:       rec.flush,
+	})
+	if err != nil {
+		t.Fatalf("NewBatchProcessor failed: %v", err)
+	}
+
+	for i := 1; i <= 5; i++ {
+		if err := p.Add(i); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	want := [][]int{{1, 2}, {3, 4}, {5}}
+	if got := rec.snapshot(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected batches %v, got %v", want, got)
+	}
+	if err := p.Add(6); err == nil {
+		t.Error("expected Add after Close to fail")
+	}
+}
+
+func TestBatchProcessor_FlushInterval(t *testing.T) {
+	rec := &batchRecorder{}
+	p, err := NewBatchProcessor(BatchConfig[int]{
+		MaxSize:       100,
+		FlushInterval: 10 * time.Millisecond,
+		FlushFn:       rec.flush,
+	})
+	if err != nil {
+		t.Fatalf("NewBatchProcessor failed: %v", err)
+	}
+	defer p.Close()
+
+	p.Add(1)
+
+	deadline := time.Now().Add(time.Second)
+	for len(rec.snapshot()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := rec.snapshot(); !reflect.DeepEqual(got, [][]int{{1}}) {
+		t.Errorf("expected interval flush of [[1]], got %v", got)
+	}
+}
+
+func TestBatchProcessor_FlushReturnsError(t *testing.T) {
+	flushErr := errors.New("backend unavailable")
+	p, err := NewBatchProcessor(BatchConfig[int]{
+		MaxSize:       10,
+		FlushInterval: time.Hour,
+		FlushFn:       func([]int) error { return flushErr },
+	})
+	if err != nil {
+		t.Fatalf("NewBatchProcessor failed: %v", err)
+	}
+	defer p.Close()
+
+	p.Add(1)
+	if err := p.Flush(); !errors.Is(err, flushErr) {
+		t.Errorf("expected %v, got %v", flushErr, err)
+	}
+}
+
+func TestBatchProcessor_WithRetry(t *testing.T) {
+	rec := &batchRecorder{}
+	var attempts int
+	p, err := NewBatchProcessor(BatchConfig[int]{
+		MaxSize:       10,
+		FlushInterval: time.Hour,
+		FlushFn: func(batch []int) error {
+			attempts++
+			if attempts <= 2 {
+				return errors.New("network outage")
+			}
+			return rec.flush(batch)
+		},
+		OnDropped: func(batch []int, err error) {
+			t.Errorf("batch %v dropped: %v", batch, err)
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewBatchProcessor failed: %v", err)
+	}
+	p.WithRetry(RetryConfig{MaxRetries: 3, InitialBackoff: time.Millisecond})
+	defer p.Close()
+
+	for i := 1; i <= 5; i++ {
+		p.Add(i)
+	}
+	if err := p.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	if attempts != 3 {
+		t.Errorf("expected 3 flush attempts, got %d", attempts)
+	}
+	want := [][]int{{1, 2, 3, 4, 5}}
+	if got := rec.snapshot(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected batches %v, got %v", want, got)
+	}
+}
+
+func TestBatchProcessor_OnDropped(t *testing.T) {
+	flushErr := errors.New("backend unavailable")
+	var dropped [][]int
+	p, err := NewBatchProcessor(BatchConfig[int]{
+		MaxSize:       10,
+		FlushInterval: time.Hour,
+		FlushFn:       func([]int) error { return flushErr },
+		OnDropped: func(batch []int, err error) {
+			if !errors.Is(err, flushErr) {
+				t.Errorf("OnDropped got %v, want %v", err, flushErr)
+			}
+			dropped = append(dropped, batch)
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewBatchProcessor failed: %v", err)
+	}
+	p.WithRetry(RetryConfig{MaxRetries: 2, InitialBackoff: time.Millisecond})
+	defer p.Close()
+
+	p.Add(1)
+	p.Add(2)
+	if err := p.Flush(); !errors.Is(err, flushErr) {
+		t.Errorf("expected %v, got %v", flushErr, err)
+	}
+	if want := [][]int{{1, 2}}; !reflect.DeepEqual(dropped, want) {
+		t.Errorf("expected dropped batches %v, got %v", want, dropped)
+	}
+}
+
+func TestNewBatchProcessor_RequiresFlushFn(t *testing.T) {
+	if _, err := NewBatchProcessor(BatchConfig[int]{}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig, got %v", err)
+	}
+}
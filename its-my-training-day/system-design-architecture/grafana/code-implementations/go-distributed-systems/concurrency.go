@@ -12,10 +12,17 @@
 package concurrency
 
 import (
+	"container/heap"
+	"container/list"
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -138,15 +145,67 @@ type WorkerPool struct {
 	ctx        context.Context
 	cancel     context.CancelFunc
 	started    bool
-	mu         sync.Mutex
+	tracer     Tracer
+	// pending holds the IDs of queued jobs when deduplication is enabled
+	dedup      bool
+	pending    sync.Map
+	dedupDrops atomic.Int64
+	// quit holds one stop channel per running worker, used by Resize
+	quit         []chan struct{}
+	nextWorkerID int
+	mu           sync.Mutex
+	// scheduled holds jobs submitted with SubmitAfter until they are due
+	scheduled    jobHeap
+	scheduleSeq  uint64
+	scheduleWake chan struct{} // Signals the scheduler that a job was added
+	scheduleMu   sync.Mutex
+	scheduler    sync.WaitGroup
+	// backPressure signals producers once each time the queue fills past
+	// backPressureThreshold of its capacity
+	backPressure          chan struct{}
+	backPressureThreshold float64
+	backPressured         atomic.Bool
+	// draining is closed by DrainWithContext. queueMu is held for reading
+	// while sending on jobQueue, so the queue is never closed mid-send
+	draining     chan struct{}
+	drainOnce    sync.Once
+	queueMu      sync.RWMutex
+	queueClosed  sync.Once
+	resultsClose sync.Once
+	// active counts workers currently running a job's handler
+	active atomic.Int32
 }
 
+// errSubmitTimeout is returned by enqueue when its timeout fires.
+var errSubmitTimeout = errors.New("submit timed out")
+
 // Job represents work to be processed by the worker pool.
 type Job struct {
 	ID      int
 	Payload interface{}
 	// Handler is the function that processes this job
 	Handler func(ctx context.Context, payload interface{}) (interface{}, error)
+	// Ctx is the submitter's context (optional). Its values, such as the
+	// active trace span, are passed to Handler; cancelling it or stopping
+	// the pool cancels the handler's context.
+	Ctx context.Context
+}
+
+// Tracer starts spans for instrumented components in this package.
+// It is a small subset of a tracing API so that any backend can be plugged
+// in with a thin adapter (e.g., over OpenTelemetry's trace.Tracer).
+type Tracer interface {
+	// Start creates a span as a child of any span in ctx and returns a
+	// context carrying the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is an in-progress trace span.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	// End finishes the span and hands it to the exporter.
+	End()
 }
 
 // JobResult contains the outcome of processing a job.
@@ -176,9 +235,86 @@ func NewWorkerPool(numWorkers, queueSize int) *WorkerPool {
 		results:    make(chan JobResult, queueSize),
 		ctx:        ctx,
 		cancel:     cancel,
+
+		scheduleWake: make(chan struct{}, 1),
+
+		backPressure:          make(chan struct{}, 1),
+		backPressureThreshold: 0.8,
+
+		draining: make(chan struct{}),
+	}
+}
+
+// WithTracer makes each worker start a child span named "job.<ID>" around
+// the job handler, parented to the span in Job.Ctx. Must be called before Start.
+func (wp *WorkerPool) WithTracer(tracer Tracer) *WorkerPool {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+	wp.tracer = tracer
+	return wp
+}
+
+// WithDeduplication makes Submit drop a job whose ID matches a job that is
+// still waiting in the queue, e.g. a config reload triggered by several
+// watchers at once. An ID can be submitted again once a worker has picked
+// up the earlier job. Must be called before Start.
+func (wp *WorkerPool) WithDeduplication() *WorkerPool {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+	wp.dedup = true
+	return wp
+}
+
+// WithBackPressureThreshold sets the fraction of the job queue's capacity
+// above which BackPressureChan signals (default 0.8). Must be called before Start.
+func (wp *WorkerPool) WithBackPressureThreshold(threshold float64) *WorkerPool {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+	wp.backPressureThreshold = threshold
+	return wp
+}
+
+// BackPressureChan returns a channel that receives a value when the job
+// queue fills past the back-pressure threshold, so producers can slow down
+// before Submit starts blocking. It signals once per crossing rather than
+// continuously: the signal is re-armed only after workers drain the queue
+// back to or below the threshold.
+func (wp *WorkerPool) BackPressureChan() <-chan struct{} {
+	return wp.backPressure
+}
+
+// aboveBackPressureThreshold reports whether the queue is past the threshold.
+func (wp *WorkerPool) aboveBackPressureThreshold() bool {
+	return float64(len(wp.jobQueue)) > float64(cap(wp.jobQueue))*wp.backPressureThreshold
+}
+
+// checkBackPressure is called after a job is queued and signals
+// BackPressureChan if this job crossed the threshold.
+func (wp *WorkerPool) checkBackPressure() {
+	if !wp.aboveBackPressureThreshold() || !wp.backPressured.CompareAndSwap(false, true) {
+		return
+	}
+	select {
+	case wp.backPressure <- struct{}{}:
+	default:
+		// A signal is already pending for the producer
+	}
+}
+
+// rearmBackPressure is called after a worker dequeues a job and re-arms
+// the signal once the queue is back at or below the threshold.
+func (wp *WorkerPool) rearmBackPressure() {
+	if wp.backPressured.Load() && !wp.aboveBackPressureThreshold() {
+		wp.backPressured.Store(false)
 	}
 }
 
+// DeduplicationDrops returns the number of jobs dropped as duplicates
+// (exported as deduplication_drops_total).
+func (wp *WorkerPool) DeduplicationDrops() int64 {
+	return wp.dedupDrops.Load()
+}
+
 // Start launches the worker goroutines. Must be called before submitting jobs.
 func (wp *WorkerPool) Start() {
 	wp.mu.Lock()
@@ -191,43 +327,106 @@ func (wp *WorkerPool) Start() {
 
 	// Launch worker goroutines
 	for i := 0; i < wp.numWorkers; i++ {
-		wp.wg.Add(1)
-		go wp.worker(i)
+		wp.startWorker()
+	}
+
+	// Launch the scheduler for SubmitAfter
+	wp.scheduler.Add(1)
+	go wp.runScheduler()
+}
+
+// startWorker launches one worker goroutine. Caller must hold wp.mu.
+func (wp *WorkerPool) startWorker() {
+	quit := make(chan struct{})
+	wp.quit = append(wp.quit, quit)
+	wp.wg.Add(1)
+	go wp.worker(wp.nextWorkerID, quit)
+	wp.nextWorkerID++
+}
+
+// Resize changes the number of workers at runtime. Growing starts new
+// workers immediately; shrinking signals the excess workers to exit after
+// their current job. Queued jobs are picked up by the remaining workers.
+// If the pool has not been started, Resize only sets the initial worker count.
+func (wp *WorkerPool) Resize(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("worker count must be positive, got %d", n)
 	}
+
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
+	if wp.ctx.Err() != nil {
+		return errors.New("worker pool is shutting down")
+	}
+	wp.numWorkers = n
+	if !wp.started {
+		return nil
+	}
+
+	for len(wp.quit) < n {
+		wp.startWorker()
+	}
+	for len(wp.quit) > n {
+		last := len(wp.quit) - 1
+		close(wp.quit[last])
+		wp.quit = wp.quit[:last]
+	}
+	return nil
+}
+
+// Workers returns the current number of workers.
+func (wp *WorkerPool) Workers() int {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+	return wp.numWorkers
+}
+
+// QueueDepth returns the number of submitted jobs waiting for a worker.
+func (wp *WorkerPool) QueueDepth() int {
+	return len(wp.jobQueue)
+}
+
+// ActiveJobs returns the number of workers currently running a handler.
+// Together with QueueDepth it shows whether a stuck pool is saturated by
+// slow handlers or idle with nothing queued.
+func (wp *WorkerPool) ActiveJobs() int {
+	return int(wp.active.Load())
 }
 
 // worker is the main loop for each worker goroutine.
-func (wp *WorkerPool) worker(workerID int) {
+// It exits when the pool stops or when quit is closed by Resize.
+func (wp *WorkerPool) worker(workerID int, quit <-chan struct{}) {
 	defer wp.wg.Done()
 
 	for {
+		// Prefer quitting over picking up another job
+		select {
+		case <-quit:
+			return
+		default:
+		}
+
 		select {
 		case <-wp.ctx.Done():
 			return
 
+		case <-quit:
+			return
+
 		case job, ok := <-wp.jobQueue:
 			if !ok {
 				return // Channel closed, exit worker
 			}
+			if wp.dedup {
+				wp.pending.Delete(job.ID)
+			}
+			wp.rearmBackPressure()
 
 			start := time.Now()
-			var result interface{}
-			var err error
-
-			// Execute the job handler with panic recovery
-			func() {
-				defer func() {
-					if r := recover(); r != nil {
-						err = fmt.Errorf("panic in job %d: %v", job.ID, r)
-					}
-				}()
-
-				if job.Handler != nil {
-					result, err = job.Handler(wp.ctx, job.Payload)
-				} else {
-					err = errors.New("job handler is nil")
-				}
-			}()
+			wp.active.Add(1)
+			result, err := wp.runJob(job)
+			wp.active.Add(-1)
 
 			// Send result (non-blocking with select to handle shutdown)
 			select {
@@ -245,27 +444,259 @@ func (wp *WorkerPool) worker(workerID int) {
 	}
 }
 
+// runJob executes the job handler with panic recovery, inside a child span
+// if a tracer is configured.
+func (wp *WorkerPool) runJob(job Job) (result interface{}, err error) {
+	ctx := wp.ctx
+	if job.Ctx != nil {
+		// Keep the submitter's values but also stop when the pool stops
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(job.Ctx)
+		defer cancel()
+		stop := context.AfterFunc(wp.ctx, cancel)
+		defer stop()
+	}
+
+	if wp.tracer != nil {
+		var span Span
+		ctx, span = wp.tracer.Start(ctx, fmt.Sprintf("job.%d", job.ID))
+		span.SetAttribute("job.id", job.ID)
+		defer func() {
+			span.RecordError(err)
+			span.End()
+		}()
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic in job %d: %v", job.ID, r)
+		}
+	}()
+
+	if job.Handler == nil {
+		return nil, errors.New("job handler is nil")
+	}
+	return job.Handler(ctx, job.Payload)
+}
+
 // Submit adds a job to the queue. Blocks if the queue is full.
-// Returns an error if the pool is shutting down.
+// Returns an error if the pool is shutting down. With deduplication
+// enabled, a duplicate of a queued job is dropped and nil is returned.
 func (wp *WorkerPool) Submit(job Job) error {
-	select {
-	case <-wp.ctx.Done():
-		return errors.New("worker pool is shutting down")
-	case wp.jobQueue <- job:
+	if !wp.claimPending(job.ID) {
 		return nil
 	}
+	if err := wp.enqueue(job, nil); err != nil {
+		wp.releasePending(job.ID)
+		return err
+	}
+	return nil
 }
 
 // SubmitWithTimeout adds a job to the queue with a timeout.
 // Returns an error if the timeout expires before the job is queued.
 func (wp *WorkerPool) SubmitWithTimeout(job Job, timeout time.Duration) error {
+	if !wp.claimPending(job.ID) {
+		return nil
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	err := wp.enqueue(job, timer.C)
+	if err == nil {
+		return nil
+	}
+	wp.releasePending(job.ID)
+	if errors.Is(err, errSubmitTimeout) {
+		return fmt.Errorf("timeout submitting job %d after %v", job.ID, timeout)
+	}
+	return err
+}
+
+// enqueue sends job to the queue, blocking until there is room, the pool
+// stops or drains, or expired fires (a nil expired never fires).
+func (wp *WorkerPool) enqueue(job Job, expired <-chan time.Time) error {
+	wp.queueMu.RLock()
+	defer wp.queueMu.RUnlock()
+
+	if wp.isDraining() {
+		return errors.New("worker pool is draining")
+	}
 	select {
 	case <-wp.ctx.Done():
 		return errors.New("worker pool is shutting down")
+	case <-wp.draining:
+		return errors.New("worker pool is draining")
 	case wp.jobQueue <- job:
+		wp.checkBackPressure()
 		return nil
-	case <-time.After(timeout):
-		return fmt.Errorf("timeout submitting job %d after %v", job.ID, timeout)
+	case <-expired:
+		return errSubmitTimeout
+	}
+}
+
+// isDraining reports whether DrainWithContext has been called.
+func (wp *WorkerPool) isDraining() bool {
+	select {
+	case <-wp.draining:
+		return true
+	default:
+		return false
+	}
+}
+
+// SubmitAfter queues job once delay has passed, e.g. to retry a failed
+// flush later without holding a worker while waiting. Jobs are held in a
+// min-heap ordered by due time; jobs due at the same time keep submission
+// order. The pool must be started for scheduled jobs to run. Stop discards
+// jobs that are not yet due.
+func (wp *WorkerPool) SubmitAfter(job Job, delay time.Duration) error {
+	wp.scheduleMu.Lock()
+	// Checked under scheduleMu so a job cannot slip in after Stop drained the heap
+	if wp.ctx.Err() != nil {
+		wp.scheduleMu.Unlock()
+		return errors.New("worker pool is shutting down")
+	}
+	if wp.isDraining() {
+		wp.scheduleMu.Unlock()
+		return errors.New("worker pool is draining")
+	}
+	if !wp.claimPending(job.ID) {
+		wp.scheduleMu.Unlock()
+		return nil
+	}
+	heap.Push(&wp.scheduled, scheduledJob{
+		job: job,
+		at:  time.Now().Add(delay),
+		seq: wp.scheduleSeq,
+	})
+	wp.scheduleSeq++
+	wp.scheduleMu.Unlock()
+
+	// Wake the scheduler in case this job is due before the one it waits for
+	select {
+	case wp.scheduleWake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// runScheduler moves scheduled jobs onto the job queue as they become due,
+// until the pool stops.
+func (wp *WorkerPool) runScheduler() {
+	defer wp.scheduler.Done()
+
+	for {
+		wp.scheduleMu.Lock()
+		now := time.Now()
+		var due []Job
+		for len(wp.scheduled) > 0 && !wp.scheduled[0].at.After(now) {
+			due = append(due, heap.Pop(&wp.scheduled).(scheduledJob).job)
+		}
+		wait := time.Duration(-1)
+		if len(wp.scheduled) > 0 {
+			wait = wp.scheduled[0].at.Sub(now)
+		}
+		wp.scheduleMu.Unlock()
+
+		if len(due) > 0 {
+			for i, job := range due {
+				if err := wp.enqueue(job, nil); err != nil {
+					for _, dropped := range due[i:] {
+						wp.releasePending(dropped.ID)
+					}
+					return
+				}
+			}
+			// Sending may have blocked on a full queue; recompute the wait
+			continue
+		}
+
+		// A nil channel blocks forever, so with an empty heap only a new
+		// job or Stop wakes the scheduler
+		var timer *time.Timer
+		var fire <-chan time.Time
+		if wait >= 0 {
+			timer = time.NewTimer(wait)
+			fire = timer.C
+		}
+		select {
+		case <-fire:
+		case <-wp.scheduleWake:
+		case <-wp.ctx.Done():
+		case <-wp.draining:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if wp.ctx.Err() != nil || wp.isDraining() {
+			return
+		}
+	}
+}
+
+// stopScheduler waits for the scheduler to exit and discards the jobs that
+// were not yet due. The pool context must already be cancelled, or the
+// pool draining.
+func (wp *WorkerPool) stopScheduler() {
+	wp.scheduler.Wait()
+
+	wp.scheduleMu.Lock()
+	defer wp.scheduleMu.Unlock()
+	for _, s := range wp.scheduled {
+		wp.releasePending(s.job.ID)
+	}
+	wp.scheduled = nil
+}
+
+// scheduledJob is a job held by SubmitAfter until it is due.
+type scheduledJob struct {
+	job Job
+	at  time.Time
+	seq uint64 // Submission order, to keep FIFO among jobs due together
+}
+
+// jobHeap is a min-heap of scheduled jobs ordered by due time, implementing
+// container/heap.Interface.
+type jobHeap []scheduledJob
+
+func (h jobHeap) Len() int { return len(h) }
+
+func (h jobHeap) Less(i, j int) bool {
+	if !h[i].at.Equal(h[j].at) {
+		return h[i].at.Before(h[j].at)
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h jobHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *jobHeap) Push(x interface{}) { *h = append(*h, x.(scheduledJob)) }
+
+func (h *jobHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// claimPending records id as queued and reports whether the job should be
+// submitted. It always returns true when deduplication is disabled.
+func (wp *WorkerPool) claimPending(id int) bool {
+	if !wp.dedup {
+		return true
+	}
+	if _, loaded := wp.pending.LoadOrStore(id, struct{}{}); loaded {
+		wp.dedupDrops.Add(1)
+		return false
+	}
+	return true
+}
+
+// releasePending forgets id after a submission failed.
+func (wp *WorkerPool) releasePending(id int) {
+	if wp.dedup {
+		wp.pending.Delete(id)
 	}
 }
 
@@ -277,17 +708,42 @@ func (wp *WorkerPool) Results() <-chan JobResult {
 // Stop gracefully shuts down the worker pool.
 // It stops accepting new jobs and waits for in-flight jobs to complete.
 func (wp *WorkerPool) Stop() {
-	wp.cancel()        // Signal workers to stop
-	close(wp.jobQueue) // Close job queue
+	wp.signalStop()    // Signal workers to stop
+	wp.stopScheduler() // Discard jobs that are not yet due
+	wp.closeJobQueue() // Close job queue
 	wp.wg.Wait()       // Wait for all workers to finish
-	close(wp.results)  // Close results channel
+	wp.closeResults()  // Close results channel
+}
+
+// closeJobQueue closes the job queue once no Submit is sending on it. It
+// is safe to call more than once, e.g. Stop after DrainWithContext.
+func (wp *WorkerPool) closeJobQueue() {
+	wp.queueClosed.Do(func() {
+		wp.queueMu.Lock()
+		defer wp.queueMu.Unlock()
+		close(wp.jobQueue)
+	})
+}
+
+// closeResults closes the results channel; it is safe to call more than once.
+func (wp *WorkerPool) closeResults() {
+	wp.resultsClose.Do(func() { close(wp.results) })
+}
+
+// signalStop cancels the pool context. Holding wp.mu ensures a concurrent
+// Resize cannot add a worker after Stop has started waiting for them.
+func (wp *WorkerPool) signalStop() {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+	wp.cancel()
 }
 
 // StopWithTimeout attempts graceful shutdown with a timeout.
 // If workers don't finish in time, it returns an error.
 func (wp *WorkerPool) StopWithTimeout(timeout time.Duration) error {
-	wp.cancel()
-	close(wp.jobQueue)
+	wp.signalStop()
+	wp.stopScheduler()
+	wp.closeJobQueue()
 
 	done := make(chan struct{})
 	go func() {
@@ -297,13 +753,298 @@ func (wp *WorkerPool) StopWithTimeout(timeout time.Duration) error {
 
 	select {
 	case <-done:
-		close(wp.results)
+		wp.closeResults()
 		return nil
 	case <-time.After(timeout):
 		return errors.New("timeout waiting for workers to finish")
 	}
 }
 
+// DrainWithContext shuts the pool down gracefully: it stops accepting
+// jobs, discards jobs scheduled with SubmitAfter that are not yet due, and
+// waits for the workers to finish every job already queued. Unlike Stop,
+// it does not cancel the jobs' contexts, so handlers run to completion.
+//
+// If ctx ends first, DrainWithContext returns ctx.Err() and the workers
+// keep running; call Stop to cancel them. Once the workers are done the
+// Results channel is closed, so keep consuming it while draining, or
+// workers block on a full results buffer.
+func (wp *WorkerPool) DrainWithContext(ctx context.Context) error {
+	wp.drainOnce.Do(func() { close(wp.draining) })
+	wp.stopScheduler()
+	wp.closeJobQueue()
+
+	done := make(chan struct{})
+	go func() {
+		wp.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		wp.closeResults()
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TypedWorkerPool is a type-safe wrapper around WorkerPool. Handlers receive
+// an In and return an Out, so neither callers nor handlers need type
+// assertions on interface{} payloads and results.
+type TypedWorkerPool[In, Out any] struct {
+	pool      *WorkerPool
+	results   chan TypedResult[In, Out]
+	done      chan struct{}
+	startOnce sync.Once
+}
+
+// TypedJob is a unit of work for a TypedWorkerPool.
+type TypedJob[In, Out any] struct {
+	ID      int
+	Payload In
+	Handler func(ctx context.Context, payload In) (Out, error)
+	// Ctx is the submitter's context (optional); see Job.Ctx.
+	Ctx context.Context
+}
+
+// TypedResult is the outcome of a TypedJob.
+type TypedResult[In, Out any] struct {
+	JobID    int
+	Input    In
+	Result   Out
+	Error    error
+	Duration time.Duration
+	WorkerID int
+}
+
+// typedOutput is what an adapted handler returns to the untyped pool, so
+// the input travels with the result.
+type typedOutput[In, Out any] struct {
+	input  In
+	result Out
+}
+
+// NewTypedWorkerPool creates a typed pool with the given number of workers
+// and queue size. See NewWorkerPool.
+func NewTypedWorkerPool[In, Out any](numWorkers, queueSize int) *TypedWorkerPool[In, Out] {
+	pool := NewWorkerPool(numWorkers, queueSize)
+	return &TypedWorkerPool[In, Out]{
+		pool:    pool,
+		results: make(chan TypedResult[In, Out], cap(pool.results)),
+		done:    make(chan struct{}),
+	}
+}
+
+// Pool returns the underlying WorkerPool, e.g. to call Resize or WithTracer.
+func (p *TypedWorkerPool[In, Out]) Pool() *WorkerPool {
+	return p.pool
+}
+
+// Start launches the workers and the goroutine that converts results.
+func (p *TypedWorkerPool[In, Out]) Start() {
+	p.startOnce.Do(func() {
+		p.pool.Start()
+		go p.forward()
+	})
+}
+
+// forward converts untyped results into TypedResults until the underlying
+// results channel is closed by Stop.
+func (p *TypedWorkerPool[In, Out]) forward() {
+	defer close(p.done)
+	defer close(p.results)
+
+	for r := range p.pool.Results() {
+		out, _ := r.Result.(typedOutput[In, Out])
+		tr := TypedResult[In, Out]{
+			JobID:    r.JobID,
+			Input:    out.input,
+			Result:   out.result,
+			Error:    r.Error,
+			Duration: r.Duration,
+			WorkerID: r.WorkerID,
+		}
+		select {
+		case p.results <- tr:
+			continue
+		default:
+		}
+		// Results is full; block until there is room unless the pool is
+		// stopping, in which case the result is dropped like in WorkerPool
+		select {
+		case p.results <- tr:
+		case <-p.pool.ctx.Done():
+		}
+	}
+}
+
+// Submit adds a job to the queue. Blocks if the queue is full.
+// Returns an error if the pool is shutting down.
+func (p *TypedWorkerPool[In, Out]) Submit(job TypedJob[In, Out]) error {
+	return p.pool.Submit(adaptTypedJob(job))
+}
+
+// adaptTypedJob wraps a typed job so the untyped pool can run it. Panics are
+// recovered here rather than in the worker so the input is still reported.
+func adaptTypedJob[In, Out any](job TypedJob[In, Out]) Job {
+	return Job{
+		ID:      job.ID,
+		Payload: job.Payload,
+		Ctx:     job.Ctx,
+		Handler: func(ctx context.Context, _ interface{}) (res interface{}, err error) {
+			out := typedOutput[In, Out]{input: job.Payload}
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("panic in job %d: %v", job.ID, r)
+				}
+				res = out
+			}()
+
+			if job.Handler == nil {
+				return nil, errors.New("job handler is nil")
+			}
+			out.result, err = job.Handler(ctx, job.Payload)
+			return nil, err
+		},
+	}
+}
+
+// Results returns the channel of typed job results.
+func (p *TypedWorkerPool[In, Out]) Results() <-chan TypedResult[In, Out] {
+	return p.results
+}
+
+// Stop shuts down the pool, waits for in-flight jobs, and closes Results.
+func (p *TypedWorkerPool[In, Out]) Stop() {
+	p.startOnce.Do(func() { close(p.results); close(p.done) })
+	p.pool.Stop()
+	<-p.done
+}
+
+// WorkerPoolConfig holds the settings for creating a WorkerPool.
+type WorkerPoolConfig struct {
+	// Workers is the number of worker goroutines
+	Workers int `default:"4" validate:"min=1"`
+	// QueueSize is the number of jobs buffered before Submit blocks
+	QueueSize int `default:"100" validate:"min=1"`
+}
+
+// TenantJobResult is a JobResult tagged with the tenant that submitted it.
+type TenantJobResult struct {
+	TenantID string
+	JobResult
+}
+
+// TenantWorkerPoolRouter gives each registered tenant its own WorkerPool so
+// a busy tenant cannot starve the others: its jobs queue up behind each
+// other, not in front of another tenant's. Tenants that were not registered
+// share a default pool.
+//
+// This is the same isolation Loki's query scheduler provides with per-tenant
+// queues. Results from every pool are merged into a single channel, which
+// must be drained or workers will block.
+type TenantWorkerPoolRouter struct {
+	pools       map[string]*WorkerPool
+	defaultPool *WorkerPool
+	results     chan TenantJobResult
+	forwarders  sync.WaitGroup
+	stopped     bool
+	mu          sync.RWMutex
+}
+
+// defaultTenantID labels results from the shared default pool.
+const defaultTenantID = ""
+
+// NewTenantWorkerPoolRouter creates a router whose default pool, used for
+// unregistered tenants, is configured by defaultConfig.
+func NewTenantWorkerPoolRouter(defaultConfig WorkerPoolConfig) *TenantWorkerPoolRouter {
+	if defaultConfig.QueueSize <= 0 {
+		defaultConfig.QueueSize = 100
+	}
+	r := &TenantWorkerPoolRouter{
+		pools:   make(map[string]*WorkerPool),
+		results: make(chan TenantJobResult, defaultConfig.QueueSize),
+	}
+	r.defaultPool = r.startPool(defaultTenantID, defaultConfig)
+	return r
+}
+
+// Register creates a dedicated pool for tenantID.
+func (r *TenantWorkerPoolRouter) Register(tenantID string, config WorkerPoolConfig) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stopped {
+		return errors.New("tenant router is stopped")
+	}
+	if _, exists := r.pools[tenantID]; exists {
+		return fmt.Errorf("tenant %q is already registered", tenantID)
+	}
+	r.pools[tenantID] = r.startPool(tenantID, config)
+	return nil
+}
+
+// startPool creates and starts a pool and forwards its results.
+func (r *TenantWorkerPoolRouter) startPool(tenantID string, config WorkerPoolConfig) *WorkerPool {
+	pool := NewWorkerPool(config.Workers, config.QueueSize)
+	pool.Start()
+
+	r.forwarders.Add(1)
+	go func() {
+		defer r.forwarders.Done()
+		for result := range pool.Results() {
+			r.results <- TenantJobResult{TenantID: tenantID, JobResult: result}
+		}
+	}()
+	return pool
+}
+
+// Submit queues job on tenantID's pool, or on the default pool if the
+// tenant is not registered. It blocks while that pool's queue is full.
+func (r *TenantWorkerPoolRouter) Submit(tenantID string, job Job) error {
+	// Holding the read lock keeps Stop from closing the queue mid-send
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.stopped {
+		return errors.New("tenant router is stopped")
+	}
+	pool, ok := r.pools[tenantID]
+	if !ok {
+		pool = r.defaultPool
+	}
+	return pool.Submit(job)
+}
+
+// Results returns the merged results of every tenant's pool. Results from
+// the default pool have TenantID set to "".
+func (r *TenantWorkerPoolRouter) Results() <-chan TenantJobResult {
+	return r.results
+}
+
+// Stop stops every pool, waits for in-flight jobs, and closes Results.
+func (r *TenantWorkerPoolRouter) Stop() {
+	r.mu.Lock()
+	if r.stopped {
+		r.mu.Unlock()
+		return
+	}
+	r.stopped = true
+	pools := make([]*WorkerPool, 0, len(r.pools)+1)
+	pools = append(pools, r.defaultPool)
+	for _, pool := range r.pools {
+		pools = append(pools, pool)
+	}
+	r.mu.Unlock()
+
+	for _, pool := range pools {
+		pool.Stop()
+	}
+	r.forwarders.Wait()
+	close(r.results)
+}
+
 // =============================================================================
 // SECTION 3: Fan-Out/Fan-In Pattern
 // =============================================================================
@@ -343,6 +1084,14 @@ type ProcessResult struct {
 // Process distributes items across workers and collects results.
 // Results are returned in the order they complete, not input order.
 func (f *FanOutFanIn) Process(ctx context.Context, items []interface{}, processor ProcessFunc) []ProcessResult {
+	return f.ProcessWithProgress(ctx, items, processor, nil)
+}
+
+// ProcessWithProgress is like Process but calls onProgress after each result
+// is collected, with the number of completed items and the total. Calls are
+// made from the collecting goroutine, so onProgress is never run concurrently
+// and done increases by one on every call. onProgress may be nil.
+func (f *FanOutFanIn) ProcessWithProgress(ctx context.Context, items []interface{}, processor ProcessFunc, onProgress func(done, total int)) []ProcessResult {
 	if len(items) == 0 {
 		return nil
 	}
@@ -383,11 +1132,58 @@ func (f *FanOutFanIn) Process(ctx context.Context, items []interface{}, processo
 	results := make([]ProcessResult, 0, len(items))
 	for result := range resultChan {
 		results = append(results, result)
+		if onProgress != nil {
+			onProgress(len(results), len(items))
+		}
 	}
 
 	return results
 }
 
+// First processes items in parallel like Process, but returns as soon as
+// any item succeeds and cancels the context passed to the workers still
+// running, so processors should watch ctx to stop early. If every item
+// fails, First returns a *MultiError with one error per item; if ctx is
+// cancelled before any item succeeds, it returns ctx.Err().
+//
+// This suits hedged reads, e.g. asking every replica of a chunk and using
+// whichever answers first.
+func (f *FanOutFanIn) First(ctx context.Context, items []interface{}, processor ProcessFunc) (ProcessResult, error) {
+	if len(items) == 0 {
+		return ProcessResult{}, errors.New("no items to process")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Queue every item up front; both channels are buffered so workers
+	// never block once First has returned.
+	inputChan := make(chan indexedItem, len(items))
+	for i, item := range items {
+		inputChan <- indexedItem{index: i, item: item}
+	}
+	close(inputChan)
+	resultChan := make(chan ProcessResult, len(items))
+
+	for i := 0; i < f.numWorkers; i++ {
+		go f.worker(ctx, inputChan, resultChan, processor)
+	}
+
+	var errs []error
+	for range items {
+		select {
+		case result := <-resultChan:
+			if result.Error == nil {
+				return result, nil
+			}
+			errs = append(errs, fmt.Errorf("item %d: %w", result.Index, result.Error))
+		case <-ctx.Done():
+			return ProcessResult{}, ctx.Err()
+		}
+	}
+	return ProcessResult{}, &MultiError{Errors: errs}
+}
+
 // ProcessOrdered is like Process but returns results in input order.
 // This is useful when result ordering matters.
 func (f *FanOutFanIn) ProcessOrdered(ctx context.Context, items []interface{}, processor ProcessFunc) []ProcessResult {
@@ -404,6 +1200,25 @@ func (f *FanOutFanIn) ProcessOrdered(ctx context.Context, items []interface{}, p
 	return ordered
 }
 
+// ProcessAndReduce runs Process and combines the results with reducer,
+// e.g. summing counts or merging sorted slices. reducer receives every
+// result in input order, including those with an error, so it can decide
+// how to treat partial failures. If ctx is cancelled before every item has
+// been processed, reducer still runs on the results collected so far and
+// ctx.Err() is returned alongside its value.
+func (f *FanOutFanIn) ProcessAndReduce(ctx context.Context, items []interface{}, processor ProcessFunc, reducer func([]ProcessResult) interface{}) (interface{}, error) {
+	results := f.Process(ctx, items, processor)
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Index < results[j].Index
+	})
+
+	reduced := reducer(results)
+	if len(results) < len(items) {
+		return reduced, ctx.Err()
+	}
+	return reduced, nil
+}
+
 // indexedItem wraps an item with its original index for ordered processing.
 type indexedItem struct {
 	index int
@@ -422,79 +1237,764 @@ func (f *FanOutFanIn) worker(ctx context.Context, input <-chan indexedItem, outp
 				return
 			}
 
-			start := time.Now()
-			result, err := f.safeProcess(ctx, item.item, processor)
+			start := time.Now()
+			result, err := f.safeProcess(ctx, item.item, processor)
+
+			select {
+			case output <- ProcessResult{
+				Index:    item.index,
+				Input:    item.item,
+				Output:   result,
+				Error:    err,
+				Duration: time.Since(start),
+			}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// safeProcess wraps the processor with panic recovery.
+func (f *FanOutFanIn) safeProcess(ctx context.Context, item interface{}, processor ProcessFunc) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic during processing: %v", r)
+		}
+	}()
+
+	return processor(ctx, item)
+}
+
+// =============================================================================
+// SECTION 4: Pipeline Pattern
+// =============================================================================
+
+// Pipeline implements a multi-stage processing pipeline where each stage
+// runs concurrently. This pattern is used in Loki for:
+// - Log ingestion: Parse -> Label -> Index -> Store
+// - Query execution: Fetch -> Filter -> Aggregate -> Format
+//
+// Each stage is a goroutine that reads from input channel and writes to output.
+type Pipeline struct {
+	stages []PipelineStage
+	out    <-chan interface{}    // Output of the last Run, for WaitDone
+	errs   chan error            // Created by Errors; nil if item errors are not diverted
+	dlq    chan<- DeadLetterItem // Set by WithDeadLetter
+	mu     sync.Mutex
+
+	deadLetterDrops atomic.Int64
+
+	// Pause state, used when WithPauseResume is enabled. pauseChanged is
+	// closed and replaced whenever paused changes.
+	pausable     bool
+	paused       bool
+	pauseChanged chan struct{}
+}
+
+// DeadLetterItem is an item that failed permanently in a pipeline stage.
+type DeadLetterItem struct {
+	// Item is the input the stage failed on, or nil if the stage emitted
+	// a plain error rather than a StageError
+	Item interface{}
+	// StageIndex is the position of the failing stage in the pipeline
+	StageIndex int
+	// Error is the error item the stage emitted
+	Error error
+	// Attempts is the number of times the stage tried the item (0 if unknown)
+	Attempts int
+}
+
+// StageError is emitted by MapStage in place of an item that failed, so
+// the item itself is not lost and can be sent to a dead-letter channel.
+type StageError struct {
+	Stage    string
+	Item     interface{}
+	Err      error
+	Attempts int
+}
+
+// Error returns "stage <name>: <error>".
+func (e *StageError) Error() string {
+	return fmt.Sprintf("stage %s: %v", e.Stage, e.Err)
+}
+
+// Unwrap returns the error returned by the stage function.
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// PipelineStage represents a single stage in the pipeline.
+type PipelineStage struct {
+	Name    string
+	Process func(ctx context.Context, in <-chan interface{}) <-chan interface{}
+	tracer  Tracer // Set by WithTracing
+}
+
+// WithFallback returns a copy of the stage that, instead of emitting an
+// error for an item, logs a warning and emits defaultValue, or the original
+// input if defaultValue is nil. Use it for non-critical stages, such as
+// enrichment, where passing an item through unchanged beats dropping it.
+//
+// Only failures of this stage are replaced: the stage must report them as
+// a *StageError carrying its own Name, as MapStage does. Error items from
+// earlier stages are forwarded unchanged.
+func (s PipelineStage) WithFallback(defaultValue interface{}) PipelineStage {
+	process := s.Process
+	name := s.Name
+	s.Process = func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+		out := make(chan interface{})
+		go func() {
+			defer close(out)
+			for item := range process(ctx, in) {
+				var stageErr *StageError
+				if err, ok := item.(error); ok && errors.As(err, &stageErr) && stageErr.Stage == name {
+					log.Printf("pipeline: warning: stage %s failed, emitting fallback: %v", name, stageErr.Err)
+					item = defaultValue
+					if item == nil {
+						item = stageErr.Item
+					}
+				}
+				select {
+				case out <- item:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return out
+	}
+	return s
+}
+
+// NewPipeline creates a new pipeline with the given stages.
+func NewPipeline(stages ...PipelineStage) *Pipeline {
+	return &Pipeline{stages: stages}
+}
+
+// Run executes the pipeline, connecting all stages.
+// Returns a channel that emits final results.
+//
+// If Errors has been called, any item that is an error value (such as those
+// emitted by MapStage) is sent to the Errors channel instead of the next stage.
+func (p *Pipeline) Run(ctx context.Context, input <-chan interface{}) <-chan interface{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Chain stages together
+	current := input
+	if p.pausable {
+		current = p.gate(ctx, current)
+	}
+	var diverters sync.WaitGroup
+	for i, stage := range p.stages {
+		current = stage.traced(i)(ctx, current)
+		if p.errs != nil || p.dlq != nil {
+			diverters.Add(1)
+			current = p.divertErrors(ctx, current, i, &diverters)
+		}
+	}
+	if p.errs != nil {
+		errs := p.errs
+		go func() {
+			diverters.Wait()
+			close(errs)
+		}()
+	}
+
+	p.out = current
+	return current
+}
+
+// divertErrors forwards items from in, sending error items emitted by the
+// stage at stageIndex to the dead-letter channel and to p.errs.
+func (p *Pipeline) divertErrors(ctx context.Context, in <-chan interface{}, stageIndex int, wg *sync.WaitGroup) <-chan interface{} {
+	out := make(chan interface{})
+	errs, dlq := p.errs, p.dlq
+	go func() {
+		defer wg.Done()
+		defer close(out)
+		for item := range in {
+			if err, ok := item.(error); ok {
+				if dlq != nil {
+					p.deadLetter(dlq, err, stageIndex)
+				}
+				if errs == nil {
+					continue
+				}
+				select {
+				case errs <- err:
+				case <-ctx.Done():
+					return
+				}
+				continue
+			}
+			select {
+			case out <- item:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// deadLetter sends a failed item to dlq without blocking the pipeline. If
+// dlq is full the item is dropped and logged.
+func (p *Pipeline) deadLetter(dlq chan<- DeadLetterItem, err error, stageIndex int) {
+	item := DeadLetterItem{StageIndex: stageIndex, Error: err}
+	var stageErr *StageError
+	if errors.As(err, &stageErr) {
+		item.Item, item.Attempts = stageErr.Item, stageErr.Attempts
+	}
+
+	select {
+	case dlq <- item:
+	default:
+		p.deadLetterDrops.Add(1)
+		log.Printf("pipeline: dead-letter channel full, dropping item from stage %d: %v", stageIndex, err)
+	}
+}
+
+// WithDeadLetter sends every item that fails permanently in a stage to dlq
+// instead of passing the error on to the next stage. Sends never block: if
+// dlq is full the item is dropped and logged, and counted in
+// DeadLetterDrops. If Errors is also enabled, the error is sent to both.
+// Must be called before Run.
+func (p *Pipeline) WithDeadLetter(dlq chan<- DeadLetterItem) *Pipeline {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.dlq = dlq
+	return p
+}
+
+// DeadLetterDrops returns the number of failed items dropped because the
+// dead-letter channel was full.
+func (p *Pipeline) DeadLetterDrops() int64 {
+	return p.deadLetterDrops.Load()
+}
+
+// Errors returns a channel of item errors and enables error diversion for
+// subsequent calls to Run. It must be called before Run. The channel is
+// closed once every stage has finished, so a pipeline with Errors enabled
+// can only be Run once. Drain it alongside the output, or a stage emitting
+// errors will block.
+func (p *Pipeline) Errors() <-chan error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.errs == nil {
+		p.errs = make(chan error, len(p.stages))
+	}
+	return p.errs
+}
+
+// WithPauseResume enables Pause and Resume for subsequent calls to Run.
+// Run then reads the input through a gate in front of the first stage,
+// which stops reading while the pipeline is paused. Must be called before Run.
+func (p *Pipeline) WithPauseResume() *Pipeline {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pausable = true
+	if p.pauseChanged == nil {
+		p.pauseChanged = make(chan struct{})
+	}
+	return p
+}
+
+// Pause stops the pipeline from reading its input, e.g. while a slow
+// downstream consumer catches up. Items already read keep flowing through
+// the stages, but once Pause returns no further item enters the first
+// stage, so senders on the input channel block and back-pressure reaches
+// the producer. Pause has no effect without WithPauseResume.
+func (p *Pipeline) Pause() {
+	p.setPaused(true)
+}
+
+// Resume lets a paused pipeline read its input again.
+func (p *Pipeline) Resume() {
+	p.setPaused(false)
+}
+
+// Paused reports whether the pipeline is paused.
+func (p *Pipeline) Paused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused
+}
+
+// setPaused changes the pause state and wakes the gate.
+func (p *Pipeline) setPaused(paused bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.pausable || p.paused == paused {
+		return
+	}
+	p.paused = paused
+	close(p.pauseChanged)
+	p.pauseChanged = make(chan struct{})
+}
+
+// pauseState returns whether the pipeline is paused and a channel that is
+// closed the next time that changes.
+func (p *Pipeline) pauseState() (bool, <-chan struct{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused, p.pauseChanged
+}
+
+// gate forwards items from in while the pipeline is not paused.
+func (p *Pipeline) gate(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		for {
+			paused, changed := p.pauseState()
+			if paused {
+				select {
+				case <-changed:
+					continue
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			var item interface{}
+			select {
+			case <-changed:
+				continue
+			case v, ok := <-in:
+				if !ok {
+					return
+				}
+				item = v
+			case <-ctx.Done():
+				return
+			}
+
+			// Hold an item that raced with Pause until the pipeline resumes
+			for paused, changed = p.pauseState(); paused; paused, changed = p.pauseState() {
+				select {
+				case <-changed:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case out <- item:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// WaitDone drains the output of the most recent Run, discarding items, and
+// returns nil once the pipeline has shut down. It returns ctx.Err() if ctx
+// is done first. Use it for pipelines whose last stage has side effects
+// (writing to storage, for example) rather than producing results.
+func (p *Pipeline) WaitDone(ctx context.Context) error {
+	p.mu.Lock()
+	out := p.out
+	p.mu.Unlock()
+	if out == nil {
+		return errors.New("pipeline is not running")
+	}
+
+	for {
+		select {
+		case _, ok := <-out:
+			if !ok {
+				return nil
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// MapStage returns a stage that applies fn to each item. When fn fails, a
+// *StageError is emitted in place of the item so a single bad item does not
+// stop the pipeline; see Pipeline.Errors and Pipeline.WithDeadLetter. Error
+// items from earlier stages are forwarded unchanged.
+func MapStage(name string, fn func(ctx context.Context, item interface{}) (interface{}, error)) PipelineStage {
+	return MapStageWithRetry(name, 1, fn)
+}
+
+// MapStageWithRetry is MapStage, but calls fn up to attempts times for each
+// item before giving up on it. Use it for stages calling flaky downstream
+// services; the StageError records how many attempts were made.
+func MapStageWithRetry(name string, attempts int, fn func(ctx context.Context, item interface{}) (interface{}, error)) PipelineStage {
+	if attempts < 1 {
+		attempts = 1
+	}
+	return PipelineStage{
+		Name: name,
+		Process: func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+			out := make(chan interface{})
+			go func() {
+				defer close(out)
+				for item := range in {
+					result := item
+					if _, isErr := item.(error); !isErr {
+						var err error
+						tried := 0
+						for tried < attempts {
+							tried++
+							result, err = fn(ctx, item)
+							if err == nil || ctx.Err() != nil {
+								break
+							}
+						}
+						if err != nil {
+							result = &StageError{Stage: name, Item: item, Err: err, Attempts: tried}
+						}
+					}
+					select {
+					case out <- result:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+			return out
+		},
+	}
+}
+
+// WithTracing returns a copy of the stage that starts a child span of the
+// span in the Run context for every item, named "pipeline.stage.<name>"
+// with attributes pipeline.stage.index and pipeline.item.index. The span
+// ends when the stage emits the item's result and records the result if it
+// is an error. Error items from earlier stages pass through untraced.
+//
+// Results are matched to items in order, so the stage must emit exactly
+// one result per item, as MapStage does. The stage index is supplied by
+// Pipeline.Run, so the stage is only traced when run by a Pipeline.
+func (s PipelineStage) WithTracing(tracer Tracer) PipelineStage {
+	s.tracer = tracer
+	return s
+}
+
+// traced returns the stage's Process function, wrapped to trace each item
+// if WithTracing was used, for the stage at stageIndex in a pipeline.
+func (s PipelineStage) traced(stageIndex int) func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+	if s.tracer == nil {
+		return s.Process
+	}
+	process, tracer, spanName := s.Process, s.tracer, "pipeline.stage."+s.Name
+	return func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+		// open holds one entry per item inside the stage, oldest first;
+		// entries for untraced error items are nil. Once done is set, the
+		// output goroutine has ended every open span and exited.
+		var mu sync.Mutex
+		var open []Span
+		done := false
+
+		stageIn := make(chan interface{})
+		go func() {
+			defer close(stageIn)
+			itemIndex := 0
+			for item := range in {
+				var span Span
+				if _, isErr := item.(error); !isErr {
+					_, span = tracer.Start(ctx, spanName)
+					span.SetAttribute("pipeline.stage.index", stageIndex)
+					span.SetAttribute("pipeline.item.index", itemIndex)
+				}
+				itemIndex++
+				mu.Lock()
+				if done {
+					mu.Unlock()
+					if span != nil {
+						endUnfinished(ctx, span)
+					}
+					return
+				}
+				open = append(open, span)
+				mu.Unlock()
+
+				select {
+				case stageIn <- item:
+				case <-ctx.Done():
+					// The output goroutine ends the item's span
+					return
+				}
+			}
+		}()
+
+		out := make(chan interface{})
+		go func() {
+			defer close(out)
+			defer func() {
+				// End spans for items the stage never emitted
+				mu.Lock()
+				defer mu.Unlock()
+				for _, span := range open {
+					if span != nil {
+						endUnfinished(ctx, span)
+					}
+				}
+				open, done = nil, true
+			}()
+			for result := range process(ctx, stageIn) {
+				mu.Lock()
+				var span Span
+				if len(open) > 0 {
+					span, open = open[0], open[1:]
+				}
+				mu.Unlock()
+				if span != nil {
+					if err, ok := result.(error); ok {
+						span.RecordError(err)
+					}
+					span.End()
+				}
+
+				select {
+				case out <- result:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return out
+	}
+}
+
+// endUnfinished ends the span of an item the stage never emitted, recording
+// the context error if the pipeline was cancelled.
+func endUnfinished(ctx context.Context, span Span) {
+	if err := ctx.Err(); err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
 
-			select {
-			case output <- ProcessResult{
-				Index:    item.index,
-				Input:    item.item,
-				Output:   result,
-				Error:    err,
-				Duration: time.Since(start),
-			}:
-			case <-ctx.Done():
-				return
+// CompoundStage groups stages into a single reusable stage, so a sequence
+// such as parse -> enrich -> validate can be defined once and dropped into
+// several pipelines:
+//
+//	parseAndEnrich := CompoundStage("parse-and-enrich", parse, enrich, validate)
+//	ingest := NewPipeline(parseAndEnrich, store)
+//
+// The sub-stages are connected directly, so an item flows through them in
+// order exactly as if they were listed in the pipeline. Pipeline.Errors only
+// sees error items once they leave the compound stage.
+func CompoundStage(name string, stages ...PipelineStage) PipelineStage {
+	return PipelineStage{
+		Name: name,
+		Process: func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+			current := in
+			for _, stage := range stages {
+				current = stage.Process(ctx, current)
 			}
-		}
+			return current
+		},
 	}
 }
 
-// safeProcess wraps the processor with panic recovery.
-func (f *FanOutFanIn) safeProcess(ctx context.Context, item interface{}, processor ProcessFunc) (result interface{}, err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			err = fmt.Errorf("panic during processing: %v", r)
-		}
-	}()
+// FanOutStage returns a stage that scatters each input item to every
+// sub-stage and emits a []interface{} holding each sub-stage's output, in
+// sub-stage order, once all of them have finished with that item.
+//
+// Each item is run through fresh instances of the sub-stages concurrently,
+// so outputs can never be matched to the wrong item. A sub-stage that emits
+// nothing for an item (e.g., a filter) leaves a nil in its slot; any extra
+// outputs beyond the first are discarded.
+func FanOutStage(subStages []PipelineStage) PipelineStage {
+	return PipelineStage{
+		Name: "fan-out",
+		Process: func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+			out := make(chan interface{})
+			go func() {
+				defer close(out)
+				for item := range in {
+					gathered := scatter(ctx, subStages, item)
+					select {
+					case out <- gathered:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+			return out
+		},
+	}
+}
 
-	return processor(ctx, item)
+// scatter runs item through every sub-stage concurrently and collects the
+// first output of each.
+func scatter(ctx context.Context, subStages []PipelineStage, item interface{}) []interface{} {
+	gathered := make([]interface{}, len(subStages))
+	var wg sync.WaitGroup
+	for i, stage := range subStages {
+		wg.Add(1)
+		go func(i int, stage PipelineStage) {
+			defer wg.Done()
+			in := make(chan interface{}, 1)
+			in <- item
+			close(in)
+
+			first := true
+			for v := range stage.Process(ctx, in) {
+				if first {
+					gathered[i] = v
+					first = false
+				}
+			}
+		}(i, stage)
+	}
+	wg.Wait()
+	return gathered
+}
+
+// FanInStage returns a stage that reduces each gathered []interface{} (as
+// emitted by FanOutStage) to a single value. Items that are not slices are
+// passed to reducer as a one-element slice.
+func FanInStage(reducer func([]interface{}) interface{}) PipelineStage {
+	return PipelineStage{
+		Name: "fan-in",
+		Process: func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+			out := make(chan interface{})
+			go func() {
+				defer close(out)
+				for item := range in {
+					values, ok := item.([]interface{})
+					if !ok {
+						values = []interface{}{item}
+					}
+					select {
+					case out <- reducer(values):
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+			return out
+		},
+	}
 }
 
 // =============================================================================
-// SECTION 4: Pipeline Pattern
+// SECTION 5: Error Group Pattern
 // =============================================================================
 
-// Pipeline implements a multi-stage processing pipeline where each stage
-// runs concurrently. This pattern is used in Loki for:
-// - Log ingestion: Parse -> Label -> Index -> Store
-// - Query execution: Fetch -> Filter -> Aggregate -> Format
-//
-// Each stage is a goroutine that reads from input channel and writes to output.
-type Pipeline struct {
-	stages []PipelineStage
+// MultiError combines several errors into one while keeping each of them
+// inspectable. errors.Is and errors.As match if any contained error matches.
+type MultiError struct {
+	Errors []error
 }
 
-// PipelineStage represents a single stage in the pipeline.
-type PipelineStage struct {
-	Name    string
-	Process func(ctx context.Context, in <-chan interface{}) <-chan interface{}
+// Error lists every contained error on its own line, by index:
+//
+//	2 errors occurred:
+//	  [0]: chunk 1: timeout
+//	  [1]: chunk 7: not found
+func (m *MultiError) Error() string {
+	var b strings.Builder
+	m.writeHeader(&b)
+	for i, err := range m.Errors {
+		fmt.Fprintf(&b, "\n  [%d]: %v", i, err)
+	}
+	return b.String()
 }
 
-// NewPipeline creates a new pipeline with the given stages.
-func NewPipeline(stages ...PipelineStage) *Pipeline {
-	return &Pipeline{stages: stages}
+// Format supports the fmt verbs: %s and %q format the Error text, %v is a
+// single-line summary for log lines, and %+v is the Error layout with each
+// error's full wrap chain underneath it, so context added at every level
+// (such as trace IDs) is visible. Errors that implement fmt.Formatter are
+// themselves formatted with %+v.
+func (m *MultiError) Format(f fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && f.Flag('+'):
+		m.writeHeader(f)
+		for i, err := range m.Errors {
+			fmt.Fprintf(f, "\n  [%d]: %+v", i, err)
+			for cause := errors.Unwrap(err); cause != nil; cause = errors.Unwrap(cause) {
+				fmt.Fprintf(f, "\n      caused by: %+v", cause)
+			}
+		}
+	case verb == 'v':
+		m.writeHeader(f)
+		for i, err := range m.Errors {
+			if i > 0 {
+				io.WriteString(f, ";")
+			}
+			fmt.Fprintf(f, " [%d] %v", i, err)
+		}
+	case verb == 'q':
+		fmt.Fprintf(f, "%q", m.Error())
+	default:
+		io.WriteString(f, m.Error())
+	}
 }
 
-// Run executes the pipeline, connecting all stages.
-// Returns a channel that emits final results.
-func (p *Pipeline) Run(ctx context.Context, input <-chan interface{}) <-chan interface{} {
-	if len(p.stages) == 0 {
-		return input
+// writeHeader writes the "N errors occurred:" line.
+func (m *MultiError) writeHeader(w io.Writer) {
+	if len(m.Errors) == 1 {
+		io.WriteString(w, "1 error occurred:")
+		return
 	}
+	fmt.Fprintf(w, "%d errors occurred:", len(m.Errors))
+}
 
-	// Chain stages together
-	current := input
-	for _, stage := range p.stages {
-		current = stage.Process(ctx, current)
+// Unwrap returns the contained errors so errors.Is and errors.As can
+// inspect each of them.
+func (m *MultiError) Unwrap() []error {
+	return m.Errors
+}
+
+// Is reports whether any contained error matches target.
+func (m *MultiError) Is(target error) bool {
+	for _, err := range m.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
 	}
+	return false
+}
 
-	return current
+// WalkErrors calls fn for err and every error it wraps, depth first in the
+// order errors.Is checks them: a single-error Unwrap is followed down, and
+// each error of a multi-error Unwrap (such as MultiError's) is walked in
+// turn with its own chain. Returning false from fn stops the walk.
+func WalkErrors(err error, fn func(err error) bool) {
+	walkErrors(err, fn)
 }
 
-// =============================================================================
-// SECTION 5: Error Group Pattern
-// =============================================================================
+// walkErrors reports whether the walk should continue.
+func walkErrors(err error, fn func(err error) bool) bool {
+	for err != nil {
+		if !fn(err) {
+			return false
+		}
+		switch e := err.(type) {
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		case interface{ Unwrap() []error }:
+			for _, inner := range e.Unwrap() {
+				if !walkErrors(inner, fn) {
+					return false
+				}
+			}
+			return true
+		default:
+			return true
+		}
+	}
+	return true
+}
+
+// CollectErrors returns err and every error it wraps, in WalkErrors order.
+func CollectErrors(err error) []error {
+	var errs []error
+	WalkErrors(err, func(err error) bool {
+		errs = append(errs, err)
+		return true
+	})
+	return errs
+}
 
 // ErrorGroup manages a group of goroutines and collects their errors.
 // Unlike sync.WaitGroup, it captures errors from goroutines.
@@ -530,6 +2030,17 @@ func (eg *ErrorGroup) Go(f func(ctx context.Context) error) {
 	}()
 }
 
+// GoN launches fn in n goroutines, passing each its index in [0, n).
+// It is shorthand for calling Go in a loop over homogeneous workers.
+func (eg *ErrorGroup) GoN(n int, fn func(ctx context.Context, i int) error) {
+	for i := 0; i < n; i++ {
+		i := i
+		eg.Go(func(ctx context.Context) error {
+			return fn(ctx, i)
+		})
+	}
+}
+
 // GoWithCancel launches a goroutine that cancels the group on error.
 // This is useful when any failure should stop all goroutines.
 func (eg *ErrorGroup) GoWithCancel(f func(ctx context.Context) error) {
@@ -546,7 +2057,22 @@ func (eg *ErrorGroup) GoWithCancel(f func(ctx context.Context) error) {
 	}()
 }
 
+// GoWithTimeout launches a goroutine whose context expires after timeout,
+// independently of the other goroutines in the group. Use it for fan-out
+// requests that should each be bounded, e.g. querying several ingesters
+// where one slow replica should not hold up the rest. The goroutine's error,
+// including context.DeadlineExceeded, is collected like with Go.
+func (eg *ErrorGroup) GoWithTimeout(timeout time.Duration, f func(ctx context.Context) error) {
+	eg.Go(func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return f(ctx)
+	})
+}
+
 // Wait blocks until all goroutines complete and returns combined errors.
+// A single error is returned as-is; multiple errors are returned as a
+// *MultiError.
 func (eg *ErrorGroup) Wait() error {
 	eg.wg.Wait()
 
@@ -561,7 +2087,9 @@ func (eg *ErrorGroup) Wait() error {
 		return eg.errors[0]
 	}
 
-	return fmt.Errorf("multiple errors: %v", eg.errors)
+	errs := make([]error, len(eg.errors))
+	copy(errs, eg.errors)
+	return &MultiError{Errors: errs}
 }
 
 // Errors returns all collected errors.
@@ -585,8 +2113,51 @@ func (eg *ErrorGroup) Context() context.Context {
 
 // Semaphore limits concurrent access to a resource.
 // This is useful for rate limiting or bounding parallelism.
+//
+// Blocked Acquire calls are served in FIFO order: each waiter parks on its
+// own channel at the tail of a queue and Release hands its slot directly to
+// the head, so no goroutine can be starved under high contention.
 type Semaphore struct {
-	sem chan struct{}
+	mu       sync.Mutex
+	capacity int
+	inUse    int
+	waiters  list.List         // One chan struct{} per blocked Acquire, oldest first
+	metrics  *semaphoreMetrics // Set by RegisterMetrics; nil if not instrumented
+}
+
+// semaphoreMetrics holds the metrics registered by RegisterMetrics.
+// Its methods are no-ops on a nil receiver.
+type semaphoreMetrics struct {
+	inUse        Gauge
+	waitDuration Histogram
+	acquisitions Counter
+	rejections   Counter
+}
+
+// acquired records a successful acquisition after waiting for wait.
+func (m *semaphoreMetrics) acquired(wait time.Duration, inUse int) {
+	if m == nil {
+		return
+	}
+	m.acquisitions.Add(1)
+	m.waitDuration.Observe(wait.Seconds())
+	m.inUse.Set(float64(inUse))
+}
+
+// rejected records an acquisition that gave up.
+func (m *semaphoreMetrics) rejected() {
+	if m == nil {
+		return
+	}
+	m.rejections.Add(1)
+}
+
+// released records a release.
+func (m *semaphoreMetrics) released(inUse int) {
+	if m == nil {
+		return
+	}
+	m.inUse.Set(float64(inUse))
 }
 
 // NewSemaphore creates a semaphore with the given capacity.
@@ -594,17 +2165,66 @@ func NewSemaphore(capacity int) *Semaphore {
 	if capacity <= 0 {
 		capacity = 1
 	}
-	return &Semaphore{
-		sem: make(chan struct{}, capacity),
+	return &Semaphore{capacity: capacity}
+}
+
+// RegisterMetrics creates the semaphore's metrics on registry, labelled
+// semaphore=name:
+//   - semaphore_in_use: slots currently acquired
+//   - semaphore_wait_duration_seconds: time spent blocked in Acquire
+//   - semaphore_acquisitions_total: successful Acquire and TryAcquire calls
+//   - semaphore_rejections_total: TryAcquire calls that found no free slot
+//     and Acquire calls whose context ended first
+//
+// Must be called before the semaphore is used.
+func (s *Semaphore) RegisterMetrics(registry MetricRegistry, name string) {
+	labels := map[string]string{"semaphore": name}
+	s.metrics = &semaphoreMetrics{
+		inUse: registry.NewGauge("semaphore_in_use",
+			"Number of semaphore slots currently acquired.", labels),
+		waitDuration: registry.NewHistogram("semaphore_wait_duration_seconds",
+			"Time spent waiting in Acquire for a free slot.", DefaultWaitBuckets, labels),
+		acquisitions: registry.NewCounter("semaphore_acquisitions_total",
+			"Total number of semaphore slots acquired.", labels),
+		rejections: registry.NewCounter("semaphore_rejections_total",
+			"Total number of acquisitions that gave up without a slot.", labels),
 	}
 }
 
 // Acquire blocks until a slot is available or context is cancelled.
+// Blocked callers are granted slots in the order they called Acquire.
 func (s *Semaphore) Acquire(ctx context.Context) error {
+	start := time.Now()
+
+	s.mu.Lock()
+	if inUse, ok := s.tryAcquireLocked(); ok {
+		s.mu.Unlock()
+		s.metrics.acquired(time.Since(start), inUse)
+		return nil
+	}
+	ready := make(chan struct{})
+	elem := s.waiters.PushBack(ready)
+	s.mu.Unlock()
+
 	select {
-	case s.sem <- struct{}{}:
+	case <-ready:
+		// Release transferred its slot to us without touching inUse
+		s.mu.Lock()
+		inUse := s.inUse
+		s.mu.Unlock()
+		s.metrics.acquired(time.Since(start), inUse)
 		return nil
 	case <-ctx.Done():
+		s.mu.Lock()
+		select {
+		case <-ready:
+			// The slot was handed over as the context ended; pass it on
+			s.releaseLocked()
+		default:
+			s.waiters.Remove(elem)
+		}
+		s.mu.Unlock()
+		s.metrics.rejected()
 		return ctx.Err()
 	}
 }
@@ -612,25 +2232,451 @@ func (s *Semaphore) Acquire(ctx context.Context) error {
 // TryAcquire attempts to acquire without blocking.
 // Returns true if acquired, false otherwise.
 func (s *Semaphore) TryAcquire() bool {
-	select {
-	case s.sem <- struct{}{}:
-		return true
-	default:
+	s.mu.Lock()
+	inUse, ok := s.tryAcquireLocked()
+	s.mu.Unlock()
+	if !ok {
+		s.metrics.rejected()
 		return false
 	}
+	s.metrics.acquired(0, inUse)
+	return true
+}
+
+// tryAcquireLocked takes a free slot if there is one and nobody is queued
+// for it, so the fast path never jumps ahead of a blocked Acquire. It
+// returns the number of slots in use afterwards. s.mu must be held.
+func (s *Semaphore) tryAcquireLocked() (int, bool) {
+	if s.inUse >= s.capacity || s.waiters.Len() > 0 {
+		return s.inUse, false
+	}
+	s.inUse++
+	return s.inUse, true
 }
 
-// Release releases a slot back to the semaphore.
+// Release releases a slot back to the semaphore, handing it to the
+// longest-waiting Acquire if there is one.
 func (s *Semaphore) Release() {
-	select {
-	case <-s.sem:
-	default:
+	s.mu.Lock()
+	if s.inUse == 0 {
+		s.mu.Unlock()
 		// Semaphore was empty, this is a programming error
 		panic("semaphore: release without acquire")
 	}
+	s.releaseLocked()
+	inUse := s.inUse
+	s.mu.Unlock()
+	s.metrics.released(inUse)
+}
+
+// releaseLocked wakes the head waiter, transferring the slot to it, or
+// frees the slot if nobody is waiting. s.mu must be held.
+func (s *Semaphore) releaseLocked() {
+	if head := s.waiters.Front(); head != nil {
+		s.waiters.Remove(head)
+		close(head.Value.(chan struct{}))
+		return
+	}
+	s.inUse--
+}
+
+// Lock acquires a slot and returns a function that releases it, so the
+// release can be deferred right after the error check:
+//
+//	release, err := sem.Lock(ctx)
+//	if err != nil {
+//	    return err
+//	}
+//	defer release()
+//
+// The release function must be called exactly once; a second call panics.
+func (s *Semaphore) Lock(ctx context.Context) (func(), error) {
+	if err := s.Acquire(ctx); err != nil {
+		return nil, err
+	}
+	var released atomic.Bool
+	return func() {
+		if released.Swap(true) {
+			panic("semaphore: release function called twice")
+		}
+		s.Release()
+	}, nil
 }
 
 // Available returns the number of available slots.
 func (s *Semaphore) Available() int {
-	return cap(s.sem) - len(s.sem)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.capacity - s.inUse
+}
+
+// =============================================================================
+// SECTION 7: Debouncer Pattern
+// =============================================================================
+
+// Debouncer delays a function call until the caller has been idle for a
+// configurable duration. Every Trigger resets the timer, so a burst of
+// triggers results in a single call once the burst is over.
+//
+// This is useful for hot paths that would otherwise cause expensive work:
+// - Reloading configuration after a flurry of file change events
+// - Rebuilding caches after a series of schema updates
+// - Recomputing ring membership after several gossip messages
+//
+// In leading-edge mode (see TriggerImmediate) the first trigger of a burst
+// calls fn at once instead, for cases where the first event should be
+// acted on without waiting.
+type Debouncer struct {
+	delay   time.Duration
+	fn      func()
+	leading bool // Set by NewDebouncerWithLeading; Trigger acts like TriggerImmediate
+	timer   *time.Timer
+	mu      sync.Mutex
+
+	// Leading-edge state: windowOpen is true from an immediate call until
+	// delay has passed without triggers; pending records triggers that
+	// arrived in the meantime. gen invalidates superseded window timers.
+	windowOpen bool
+	pending    bool
+	gen        int
+}
+
+// NewDebouncer creates a debouncer that calls fn once delay has elapsed
+// without another call to Trigger.
+func NewDebouncer(delay time.Duration, fn func()) *Debouncer {
+	if delay < 0 {
+		delay = 0
+	}
+	return &Debouncer{
+		delay: delay,
+		fn:    fn,
+	}
+}
+
+// NewDebouncerWithLeading creates a debouncer whose Trigger behaves like
+// TriggerImmediate: fn runs on the first trigger of a burst and, if more
+// triggers follow, once more after delay has elapsed without a trigger.
+func NewDebouncerWithLeading(delay time.Duration, fn func()) *Debouncer {
+	d := NewDebouncer(delay, fn)
+	d.leading = true
+	return d
+}
+
+// Trigger schedules fn to run after the debounce delay, cancelling any
+// previously scheduled call.
+func (d *Debouncer) Trigger() {
+	if d.leading {
+		d.TriggerImmediate()
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	// A trailing call replaces any leading-edge window in progress
+	d.windowOpen, d.pending = false, false
+	d.gen++
+	d.timer = time.AfterFunc(d.delay, d.fn)
+}
+
+// TriggerImmediate calls fn right away if no burst is in progress, then
+// suppresses further calls until delay has elapsed without a trigger. If
+// any triggers were suppressed, fn runs once more at the end of that quiet
+// period, so the last trigger of a burst is never lost. fn runs on the
+// caller's goroutine for the immediate call.
+func (d *Debouncer) TriggerImmediate() {
+	d.mu.Lock()
+	callNow := !d.windowOpen
+	if callNow {
+		d.windowOpen = true
+	} else {
+		d.pending = true
+	}
+
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.gen++
+	gen := d.gen
+	d.timer = time.AfterFunc(d.delay, func() { d.closeWindow(gen) })
+	d.mu.Unlock()
+
+	if callNow {
+		d.fn()
+	}
+}
+
+// closeWindow ends the leading-edge window started under generation gen,
+// calling fn if triggers were suppressed during it.
+func (d *Debouncer) closeWindow(gen int) {
+	d.mu.Lock()
+	if gen != d.gen {
+		// A later trigger restarted the window
+		d.mu.Unlock()
+		return
+	}
+	pending := d.pending
+	d.windowOpen, d.pending = false, false
+	d.mu.Unlock()
+
+	if pending {
+		d.fn()
+	}
+}
+
+// Stop cancels any pending call. A call that has already started is not
+// interrupted.
+func (d *Debouncer) Stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	d.windowOpen, d.pending = false, false
+	d.gen++
+}
+
+// =============================================================================
+// SECTION 8: Typed Event Bus
+// =============================================================================
+
+// EventBus is a typed publish/subscribe bus for decoupling internal event
+// flows such as health status changes, config reloads, and circuit breaker
+// trips. Each subscriber gets its own buffered channel and goroutine, so a
+// slow subscriber never blocks the publisher or other subscribers.
+//
+// Delivery semantics:
+// - Publish never blocks
+// - Events are delivered to each subscriber in publish order
+// - If a subscriber's buffer is full, the event is dropped for that
+//   subscriber and counted (see Dropped)
+type EventBus[T any] struct {
+	bufferSize  int
+	subscribers map[uint64]*subscription[T]
+	nextID      uint64
+	dropped     uint64 // Atomic: events dropped due to slow consumers
+	mu          sync.RWMutex
+}
+
+// subscription is a single subscriber's delivery queue.
+type subscription[T any] struct {
+	events chan busEvent[T]
+	done   chan struct{}
+}
+
+// busEvent carries an event together with the publisher's context.
+type busEvent[T any] struct {
+	ctx   context.Context
+	event T
+}
+
+// NewEventBus creates an event bus where each subscriber buffers up to
+// bufferSize undelivered events.
+func NewEventBus[T any](bufferSize int) *EventBus[T] {
+	if bufferSize <= 0 {
+		bufferSize = 64
+	}
+	return &EventBus[T]{
+		bufferSize:  bufferSize,
+		subscribers: make(map[uint64]*subscription[T]),
+	}
+}
+
+// Subscribe registers a handler and returns a function that unsubscribes it.
+// The handler runs on a dedicated goroutine. Events still buffered when the
+// subscriber unsubscribes are discarded.
+func (b *EventBus[T]) Subscribe(handler func(ctx context.Context, event T)) func() {
+	sub := &subscription[T]{
+		events: make(chan busEvent[T], b.bufferSize),
+		done:   make(chan struct{}),
+	}
+
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	b.subscribers[id] = sub
+	b.mu.Unlock()
+
+	go func() {
+		for {
+			select {
+			case <-sub.done:
+				return
+			case e := <-sub.events:
+				handler(e.ctx, e.event)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, id)
+			b.mu.Unlock()
+			close(sub.done)
+		})
+	}
+}
+
+// Publish delivers the event to all current subscribers without blocking.
+func (b *EventBus[T]) Publish(ctx context.Context, event T) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, sub := range b.subscribers {
+		select {
+		case sub.events <- busEvent[T]{ctx: ctx, event: event}:
+		default:
+			// Slow consumer: drop rather than block the publisher
+			atomic.AddUint64(&b.dropped, 1)
+		}
+	}
+}
+
+// Dropped returns the total number of events dropped because a
+// subscriber's buffer was full.
+func (b *EventBus[T]) Dropped() uint64 {
+	return atomic.LoadUint64(&b.dropped)
+}
+
+// Subscribers returns the current number of subscribers.
+func (b *EventBus[T]) Subscribers() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subscribers)
+}
+
+// =============================================================================
+// SECTION 9: Graceful Shutdown Coordinator
+// =============================================================================
+
+// ShutdownCoordinator stops a service's components in a defined order.
+// A typical service shuts down in this order:
+// 1. HTTP server: stop accepting new requests
+// 2. Worker pool: drain in-flight jobs
+// 3. Tracer: flush buffered spans
+// 4. Logger: flush buffered log lines
+//
+// Components with a lower priority are stopped first, so give the logger
+// the highest priority to keep it available while everything else logs
+// its own shutdown.
+type ShutdownCoordinator struct {
+	components []shutdownComponent
+	timeout    time.Duration // Per-component budget; 0 means derive from ctx
+	once       sync.Once
+	err        error
+	mu         sync.Mutex
+}
+
+// shutdownComponent is a registered stop function.
+type shutdownComponent struct {
+	name     string
+	priority int
+	stop     func(ctx context.Context) error
+}
+
+// NewShutdownCoordinator creates an empty shutdown coordinator.
+func NewShutdownCoordinator() *ShutdownCoordinator {
+	return &ShutdownCoordinator{}
+}
+
+// WithComponentTimeout gives each component up to d to stop, independently
+// of the others. Without it, each component gets the time remaining on the
+// Shutdown context when Shutdown was called. Must be called before Shutdown.
+func (sc *ShutdownCoordinator) WithComponentTimeout(d time.Duration) *ShutdownCoordinator {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.timeout = d
+	return sc
+}
+
+// Register adds a component to be stopped during Shutdown.
+// Components with equal priority are stopped in registration order.
+func (sc *ShutdownCoordinator) Register(name string, priority int, stop func(ctx context.Context) error) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.components = append(sc.components, shutdownComponent{
+		name:     name,
+		priority: priority,
+		stop:     stop,
+	})
+}
+
+// Shutdown stops all registered components in priority order and returns
+// the combined errors, each prefixed with the component name.
+//
+// Every component is asked to stop even if an earlier one fails, and each
+// gets its own budget: the WithComponentTimeout duration, or by default the
+// time remaining on ctx when Shutdown is called (no limit if ctx has no
+// deadline). A component that overruns its budget is reported as timed out
+// and abandoned, and the next one starts with a fresh budget, so one hung
+// component cannot leave the rest with an expired context. Stop functions
+// receive ctx's values but not its cancellation.
+// Calling Shutdown more than once returns the result of the first call.
+func (sc *ShutdownCoordinator) Shutdown(ctx context.Context) error {
+	sc.once.Do(func() {
+		sc.mu.Lock()
+		components := make([]shutdownComponent, len(sc.components))
+		copy(components, sc.components)
+		budget := sc.timeout
+		sc.mu.Unlock()
+
+		if deadline, ok := ctx.Deadline(); ok && budget <= 0 {
+			budget = time.Until(deadline)
+		}
+		base := context.WithoutCancel(ctx)
+
+		sort.SliceStable(components, func(i, j int) bool {
+			return components[i].priority < components[j].priority
+		})
+
+		var errs []error
+		for _, c := range components {
+			if err := stopComponent(base, budget, c); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", c.name, err))
+			}
+		}
+		sc.err = errors.Join(errs...)
+	})
+	return sc.err
+}
+
+// stopComponent runs a single stop function with its own budget (none if
+// budget is 0), giving up when the budget expires so that a hung component
+// cannot block the rest of the shutdown.
+func stopComponent(base context.Context, budget time.Duration, c shutdownComponent) error {
+	ctx, cancel := base, context.CancelFunc(func() {})
+	if budget > 0 {
+		ctx, cancel = context.WithTimeout(base, budget)
+	}
+	defer cancel()
+
+	done := make(chan error, 1) // Buffered so an abandoned stop can still finish
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic during shutdown: %v", r)
+			}
+		}()
+		done <- c.stop(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		// Prefer a result that arrived at the same time as the deadline
+		select {
+		case err := <-done:
+			return err
+		default:
+			return ctx.Err()
+		}
+	}
 }
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -255,20 +257,27 @@ func TestWorkerPool_SubmitWithTimeout(t *testing.T) {
 	pool.Start()
 	defer pool.Stop()
 
-	// Fill the queue with a slow job
-	slowHandler := func(ctx context.Context, payload interface{}) (interface{}, error) {
-		time.Sleep(100 * time.Millisecond)
+	release := make(chan struct{})
+	defer close(release)
+	blocking := func(ctx context.Context, payload interface{}) (interface{}, error) {
+		<-release
 		return nil, nil
 	}
 
-	// First job should succeed
-	err := pool.Submit(Job{ID: 1, Payload: "first", Handler: slowHandler})
-	if err != nil {
+	// Occupy the worker, then fill the one queue slot
+	if err := pool.Submit(Job{ID: 1, Payload: "first", Handler: blocking}); err != nil {
 		t.Fatalf("first submit failed: %v", err)
 	}
+	deadline := time.Now().Add(time.Second)
+	for pool.ActiveJobs() != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if err := pool.Submit(Job{ID: 2, Payload: "second", Handler: blocking}); err != nil {
+		t.Fatalf("second submit failed: %v", err)
+	}
 
-	// Second job should timeout (queue is full, worker is busy)
-	err = pool.SubmitWithTimeout(Job{ID: 2, Payload: "second", Handler: slowHandler}, 10*time.Millisecond)
+	// Third job should timeout (queue is full, worker is busy)
+	err := pool.SubmitWithTimeout(Job{ID: 3, Payload: "third", Handler: blocking}, 10*time.Millisecond)
 	if err == nil {
 		t.Error("expected timeout error, got nil")
 	}
@@ -343,217 +352,1624 @@ func TestWorkerPool_ConcurrentSubmit(t *testing.T) {
 	}
 }
 
-// =============================================================================
-// SECTION 3: Fan-Out/Fan-In Tests
-// =============================================================================
+// fakeSpanKey is the context key under which fakeTracer stores the active span.
+type fakeSpanKey struct{}
 
-func TestFanOutFanIn_Process(t *testing.T) {
-	fanout := NewFanOutFanIn(3)
+// fakeTracer records every span it starts, for asserting parent/child links.
+type fakeTracer struct {
+	mu    sync.Mutex
+	spans []*fakeSpan
+}
 
-	items := []interface{}{1, 2, 3, 4, 5}
-	processor := func(ctx context.Context, item interface{}) (interface{}, error) {
-		num := item.(int)
-		return num * 2, nil
-	}
+type fakeSpan struct {
+	mu       sync.Mutex
+	id       string
+	parentID string
+	name     string
+	attrs    map[string]interface{}
+	err      error
+	ended    bool
+}
 
-	results := fanout.Process(context.Background(), items, processor)
+func (t *fakeTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-	if len(results) != len(items) {
-		t.Errorf("expected %d results, got %d", len(items), len(results))
+	span := &fakeSpan{
+		id:    fmt.Sprintf("span-%d", len(t.spans)+1),
+		name:  name,
+		attrs: make(map[string]interface{}),
 	}
+	if parent, ok := ctx.Value(fakeSpanKey{}).(*fakeSpan); ok {
+		span.parentID = parent.id
+	}
+	t.spans = append(t.spans, span)
+	return context.WithValue(ctx, fakeSpanKey{}, span), span
+}
 
-	// Verify all items were processed
-	for _, r := range results {
-		if r.Error != nil {
-			t.Errorf("unexpected error for item %v: %v", r.Input, r.Error)
-		}
-		expected := r.Input.(int) * 2
-		if r.Output != expected {
-			t.Errorf("expected output %d, got %v", expected, r.Output)
+func (t *fakeTracer) find(name string) *fakeSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, s := range t.spans {
+		if s.name == name {
+			return s
 		}
 	}
+	return nil
 }
 
-func TestFanOutFanIn_ProcessOrdered(t *testing.T) {
-	fanout := NewFanOutFanIn(3)
+func (s *fakeSpan) SetAttribute(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs[key] = value
+}
 
-	items := []interface{}{1, 2, 3, 4, 5}
-	processor := func(ctx context.Context, item interface{}) (interface{}, error) {
-		return item.(int) * 2, nil
+func (s *fakeSpan) RecordError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.err = err
 	}
+}
 
-	results := fanout.ProcessOrdered(context.Background(), items, processor)
+func (s *fakeSpan) End() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ended = true
+}
 
-	if len(results) != len(items) {
-		t.Errorf("expected %d results, got %d", len(items), len(results))
+func TestWorkerPool_WithTracer(t *testing.T) {
+	tracer := &fakeTracer{}
+	pool := NewWorkerPool(2, 10).WithTracer(tracer)
+	pool.Start()
+	defer pool.Stop()
+
+	// The submitter has an active request span
+	parentCtx, parent := tracer.Start(context.Background(), "HTTP GET /api/query")
+
+	var handlerSpan *fakeSpan
+	err := pool.Submit(Job{
+		ID:  7,
+		Ctx: parentCtx,
+		Handler: func(ctx context.Context, payload interface{}) (interface{}, error) {
+			handlerSpan, _ = ctx.Value(fakeSpanKey{}).(*fakeSpan)
+			return nil, errors.New("chunk fetch failed")
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to submit job: %v", err)
 	}
 
-	// Verify order is preserved
-	for i, r := range results {
-		if r.Index != i {
-			t.Errorf("expected index %d, got %d", i, r.Index)
-		}
+	select {
+	case <-pool.Results():
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for result")
+	}
+
+	child := tracer.find("job.7")
+	if child == nil {
+		t.Fatal("expected a job.7 span")
+	}
+	if child.parentID != parent.(*fakeSpan).id {
+		t.Errorf("expected parent span ID %q, got %q", parent.(*fakeSpan).id, child.parentID)
+	}
+	if handlerSpan != child {
+		t.Error("handler context should carry the job span")
+	}
+
+	child.mu.Lock()
+	defer child.mu.Unlock()
+	if !child.ended {
+		t.Error("job span should be ended after the handler returns")
+	}
+	if child.err == nil {
+		t.Error("job span should record the handler error")
+	}
+	if child.attrs["job.id"] != 7 {
+		t.Errorf("expected job.id attribute 7, got %v", child.attrs["job.id"])
 	}
 }
 
-func TestFanOutFanIn_EmptyInput(t *testing.T) {
-	fanout := NewFanOutFanIn(3)
+func TestWorkerPool_JobContextCancelledOnStop(t *testing.T) {
+	pool := NewWorkerPool(1, 10)
+	pool.Start()
 
-	results := fanout.Process(context.Background(), []interface{}{}, nil)
+	started := make(chan struct{})
+	stopped := make(chan error, 1)
+	pool.Submit(Job{
+		ID:  1,
+		Ctx: context.Background(),
+		Handler: func(ctx context.Context, payload interface{}) (interface{}, error) {
+			close(started)
+			<-ctx.Done()
+			stopped <- ctx.Err()
+			return nil, ctx.Err()
+		},
+	})
 
-	if results != nil {
-		t.Errorf("expected nil for empty input, got %v", results)
+	<-started
+	pool.Stop()
+
+	select {
+	case err := <-stopped:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("job context was not cancelled when the pool stopped")
 	}
 }
 
-func TestFanOutFanIn_ErrorHandling(t *testing.T) {
-	fanout := NewFanOutFanIn(2)
+func TestWorkerPool_Resize(t *testing.T) {
+	pool := NewWorkerPool(3, 20)
+	pool.Start()
 
-	items := []interface{}{1, 2, 3}
-	expectedErr := errors.New("processing error")
-	processor := func(ctx context.Context, item interface{}) (interface{}, error) {
-		if item.(int) == 2 {
-			return nil, expectedErr
+	if err := pool.Resize(5); err != nil {
+		t.Fatalf("Resize(5): %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		err := pool.Submit(Job{
+			ID: i,
+			Handler: func(ctx context.Context, payload interface{}) (interface{}, error) {
+				time.Sleep(10 * time.Millisecond)
+				return payload, nil
+			},
+			Payload: i,
+		})
+		if err != nil {
+			t.Fatalf("Submit(%d): %v", i, err)
 		}
-		return item, nil
 	}
 
-	results := fanout.Process(context.Background(), items, processor)
+	if err := pool.Resize(2); err != nil {
+		t.Fatalf("Resize(2): %v", err)
+	}
+	if got := pool.Workers(); got != 2 {
+		t.Errorf("Workers() = %d, want 2", got)
+	}
 
-	errorCount := 0
-	for _, r := range results {
-		if r.Error != nil {
-			errorCount++
+	seen := make(map[int]bool)
+	for i := 0; i < 10; i++ {
+		select {
+		case r := <-pool.Results():
+			if r.Error != nil {
+				t.Errorf("job %d failed: %v", r.JobID, r.Error)
+			}
+			seen[r.JobID] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out after %d results", i)
 		}
 	}
+	if len(seen) != 10 {
+		t.Errorf("expected 10 distinct jobs, got %d", len(seen))
+	}
 
-	if errorCount != 1 {
-		t.Errorf("expected 1 error, got %d", errorCount)
+	if err := pool.Resize(0); err == nil {
+		t.Error("expected error resizing to 0 workers")
+	}
+
+	pool.Stop()
+	if err := pool.Resize(4); err == nil {
+		t.Error("expected error resizing a stopped pool")
 	}
 }
 
-func TestFanOutFanIn_PanicRecovery(t *testing.T) {
-	fanout := NewFanOutFanIn(2)
+func TestWorkerPool_SubmitAfter(t *testing.T) {
+	pool := NewWorkerPool(1, 10)
+	pool.Start()
+	defer pool.Stop()
 
-	items := []interface{}{1, 2}
-	processor := func(ctx context.Context, item interface{}) (interface{}, error) {
-		if item.(int) == 2 {
-			panic("intentional panic")
+	handler := func(ctx context.Context, payload interface{}) (interface{}, error) {
+		return nil, nil
+	}
+	delays := map[int]time.Duration{1: 50 * time.Millisecond, 2: 20 * time.Millisecond, 3: 10 * time.Millisecond}
+	start := time.Now()
+	for id := 1; id <= 3; id++ {
+		if err := pool.SubmitAfter(Job{ID: id, Handler: handler}, delays[id]); err != nil {
+			t.Fatalf("SubmitAfter: %v", err)
 		}
-		return item, nil
 	}
 
-	results := fanout.Process(context.Background(), items, processor)
+	var order []int
+	for i := 0; i < 3; i++ {
+		select {
+		case r := <-pool.Results():
+			order = append(order, r.JobID)
+			if elapsed := time.Since(start); elapsed < delays[r.JobID] {
+				t.Errorf("job %d ran after %v, before its %v delay", r.JobID, elapsed, delays[r.JobID])
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out after %d results", i)
+		}
+	}
 
-	if len(results) != 2 {
-		t.Errorf("expected 2 results, got %d", len(results))
+	if fmt.Sprint(order) != "[3 2 1]" {
+		t.Errorf("execution order = %v, want [3 2 1]", order)
 	}
+}
 
-	// Find the panicked result
-	var panicResult *ProcessResult
-	for i := range results {
-		if results[i].Input == 2 {
-			panicResult = &results[i]
-			break
+func TestWorkerPool_SubmitAfterSameTimeIsFIFO(t *testing.T) {
+	pool := NewWorkerPool(1, 10)
+	handler := func(ctx context.Context, payload interface{}) (interface{}, error) {
+		return nil, nil
+	}
+	// Submitted before Start, with no delay, so all are due together
+	for id := 1; id <= 5; id++ {
+		pool.SubmitAfter(Job{ID: id, Handler: handler}, 0)
+	}
+	pool.Start()
+	defer pool.Stop()
+
+	for want := 1; want <= 5; want++ {
+		select {
+		case r := <-pool.Results():
+			if r.JobID != want {
+				t.Errorf("got job %d, want %d", r.JobID, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for results")
 		}
 	}
+}
 
-	if panicResult == nil {
-		t.Fatal("could not find result for item 2")
+func TestWorkerPool_StopDiscardsScheduledJobs(t *testing.T) {
+	pool := NewWorkerPool(1, 10)
+	pool.Start()
+
+	var executed atomic.Bool
+	pool.SubmitAfter(Job{ID: 1, Handler: func(ctx context.Context, payload interface{}) (interface{}, error) {
+		executed.Store(true)
+		return nil, nil
+	}}, time.Hour)
+
+	done := make(chan struct{})
+	go func() {
+		pool.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Stop blocked on a scheduled job")
 	}
 
-	if panicResult.Error == nil {
-		t.Error("expected error from panic recovery, got nil")
+	if executed.Load() {
+		t.Error("scheduled job ran after Stop")
+	}
+	if err := pool.SubmitAfter(Job{ID: 2}, time.Millisecond); err == nil {
+		t.Error("SubmitAfter after Stop should return an error")
 	}
 }
 
-func TestFanOutFanIn_ContextCancellation(t *testing.T) {
-	fanout := NewFanOutFanIn(2)
-
-	ctx, cancel := context.WithCancel(context.Background())
+func TestWorkerPool_DrainWithContext(t *testing.T) {
+	pool := NewWorkerPool(2, 10)
+	pool.Start()
 
-	items := []interface{}{1, 2, 3, 4, 5}
-	processor := func(ctx context.Context, item interface{}) (interface{}, error) {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case <-time.After(100 * time.Millisecond):
-			return item, nil
+	var completed atomic.Int32
+	for i := 1; i <= 5; i++ {
+		err := pool.Submit(Job{ID: i, Handler: func(ctx context.Context, payload interface{}) (interface{}, error) {
+			select {
+			case <-time.After(50 * time.Millisecond):
+				completed.Add(1)
+				return nil, nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}})
+		if err != nil {
+			t.Fatalf("Submit: %v", err)
 		}
 	}
 
-	// Cancel after a short delay
-	go func() {
-		time.Sleep(50 * time.Millisecond)
-		cancel()
-	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := pool.DrainWithContext(ctx); err != nil {
+		t.Fatalf("DrainWithContext: %v", err)
+	}
+	if got := completed.Load(); got != 5 {
+		t.Errorf("%d jobs completed, want all 5", got)
+	}
 
-	results := fanout.Process(ctx, items, processor)
+	var results int
+	for r := range pool.Results() {
+		if r.Error != nil {
+			t.Errorf("job %d: %v", r.JobID, r.Error)
+		}
+		results++
+	}
+	if results != 5 {
+		t.Errorf("got %d results before Results closed, want 5", results)
+	}
 
-	// Some results may have errors due to cancellation
-	// This is expected behavior
-	t.Logf("Got %d results after cancellation", len(results))
+	if err := pool.Submit(Job{ID: 6}); err == nil {
+		t.Error("Submit after DrainWithContext should return an error")
+	}
+	pool.Stop() // Safe after draining
 }
 
-// =============================================================================
-// SECTION 4: Error Group Tests
-// =============================================================================
+func TestWorkerPool_DrainWithContextDeadline(t *testing.T) {
+	pool := NewWorkerPool(1, 10)
+	pool.Start()
 
-func TestErrorGroup_NoErrors(t *testing.T) {
-	eg := NewErrorGroup(context.Background())
+	release := make(chan struct{})
+	pool.Submit(Job{ID: 1, Handler: func(ctx context.Context, payload interface{}) (interface{}, error) {
+		<-release
+		return nil, ctx.Err()
+	}})
 
-	eg.Go(func(ctx context.Context) error {
-		return nil
-	})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := pool.DrainWithContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("DrainWithContext = %v, want DeadlineExceeded", err)
+	}
 
-	eg.Go(func(ctx context.Context) error {
-		return nil
-	})
+	// The job was left running with its context intact
+	close(release)
+	if r := <-pool.Results(); r.Error != nil {
+		t.Errorf("job context was cancelled by the drain: %v", r.Error)
+	}
+	pool.Stop()
+}
 
-	err := eg.Wait()
-	if err != nil {
-		t.Errorf("expected no error, got: %v", err)
+func TestWorkerPool_QueueDepthAndActiveJobs(t *testing.T) {
+	pool := NewWorkerPool(3, 10)
+	pool.Start()
+	release := make(chan struct{})
+	slow := func(ctx context.Context, payload interface{}) (interface{}, error) {
+		<-release
+		return nil, nil
+	}
+
+	for i := 1; i <= 5; i++ {
+		if err := pool.Submit(Job{ID: i, Handler: slow}); err != nil {
+			t.Fatalf("Submit failed: %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for (pool.ActiveJobs() != 3 || pool.QueueDepth() != 2) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := pool.ActiveJobs(); got != 3 {
+		t.Errorf("ActiveJobs() = %d, want 3", got)
+	}
+	if got := pool.QueueDepth(); got != 2 {
+		t.Errorf("QueueDepth() = %d, want 2", got)
+	}
+
+	close(release)
+	for i := 0; i < 5; i++ {
+		<-pool.Results()
 	}
+	if got := pool.ActiveJobs(); got != 0 {
+		t.Errorf("ActiveJobs() after completion = %d, want 0", got)
+	}
+	pool.Stop()
 }
 
-func TestErrorGroup_SingleError(t *testing.T) {
-	eg := NewErrorGroup(context.Background())
+func TestWorkerPool_BackPressureChan(t *testing.T) {
+	pool := NewWorkerPool(1, 10)
+	gate := make(chan struct{})
+	blocking := func(ctx context.Context, payload interface{}) (interface{}, error) {
+		select {
+		case <-gate:
+		case <-ctx.Done():
+		}
+		return nil, nil
+	}
+	expectSignal := func(want bool) {
+		t.Helper()
+		select {
+		case <-pool.BackPressureChan():
+			if !want {
+				t.Error("unexpected back-pressure signal")
+			}
+		case <-time.After(50 * time.Millisecond):
+			if want {
+				t.Error("no back-pressure signal within 50ms")
+			}
+		}
+	}
 
-	expectedErr := errors.New("test error")
+	// 8 of 10 slots is not past the 80% threshold
+	for i := 1; i <= 8; i++ {
+		pool.Submit(Job{ID: i, Handler: blocking})
+	}
+	expectSignal(false)
+	pool.Submit(Job{ID: 9, Handler: blocking})
+	expectSignal(true)
+	// Still above the threshold: no repeated signal
+	pool.Submit(Job{ID: 10, Handler: blocking})
+	expectSignal(false)
 
-	eg.Go(func(ctx context.Context) error {
-		return expectedErr
+	// Draining the queue re-arms the signal for the next crossing
+	pool.Start()
+	defer pool.Stop()
+	for i := 0; i < 10; i++ {
+		gate <- struct{}{}
+		<-pool.Results()
+	}
+	for i := 11; i <= 20; i++ {
+		pool.Submit(Job{ID: i, Handler: blocking})
+	}
+	expectSignal(true)
+}
+
+func TestWorkerPool_Deduplication(t *testing.T) {
+	pool := NewWorkerPool(1, 10).WithDeduplication()
+	pool.Start()
+	defer pool.Stop()
+
+	// Occupy the only worker so the duplicates stay queued
+	release := make(chan struct{})
+	pool.Submit(Job{
+		ID: 0,
+		Handler: func(ctx context.Context, payload interface{}) (interface{}, error) {
+			<-release
+			return nil, nil
+		},
 	})
 
-	eg.Go(func(ctx context.Context) error {
-		return nil
+	var executions int32
+	reload := Job{
+		ID: 1,
+		Handler: func(ctx context.Context, payload interface{}) (interface{}, error) {
+			atomic.AddInt32(&executions, 1)
+			return nil, nil
+		},
+	}
+	for i := 0; i < 5; i++ {
+		if err := pool.Submit(reload); err != nil {
+			t.Fatalf("Submit: %v", err)
+		}
+	}
+	close(release)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-pool.Results():
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out after %d results", i)
+		}
+	}
+
+	if got := atomic.LoadInt32(&executions); got != 1 {
+		t.Errorf("duplicate job executed %d times, want 1", got)
+	}
+	if got := pool.DeduplicationDrops(); got != 4 {
+		t.Errorf("DeduplicationDrops() = %d, want 4", got)
+	}
+
+	// Once dequeued, the same ID can be submitted again
+	pool.Submit(reload)
+	select {
+	case <-pool.Results():
+	case <-time.After(5 * time.Second):
+		t.Fatal("resubmitted job did not run")
+	}
+	if got := atomic.LoadInt32(&executions); got != 2 {
+		t.Errorf("resubmitted job executed %d times in total, want 2", got)
+	}
+}
+
+func TestTypedWorkerPool_WordCount(t *testing.T) {
+	pool := NewTypedWorkerPool[string, int](3, 10)
+	pool.Start()
+
+	wordCount := func(ctx context.Context, line string) (int, error) {
+		return len(strings.Fields(line)), nil
+	}
+	lines := []string{"a b c", "hello world", "", "one two three four"}
+	for i, line := range lines {
+		if err := pool.Submit(TypedJob[string, int]{ID: i, Payload: line, Handler: wordCount}); err != nil {
+			t.Fatalf("Submit(%d): %v", i, err)
+		}
+	}
+	if err := pool.Submit(TypedJob[string, int]{
+		ID:      len(lines),
+		Payload: "boom",
+		Handler: func(ctx context.Context, line string) (int, error) { panic(line) },
+	}); err != nil {
+		t.Fatalf("Submit(panic): %v", err)
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i <= len(lines); i++ {
+		r := <-pool.Results()
+		if r.Input == "boom" {
+			if r.Error == nil || !strings.Contains(r.Error.Error(), "panic") {
+				t.Errorf("expected panic error for input %q, got %v", r.Input, r.Error)
+			}
+			continue
+		}
+		if r.Error != nil {
+			t.Errorf("job %d failed: %v", r.JobID, r.Error)
+		}
+		// r.Result is an int; no type assertion needed
+		counts[r.Input] = r.Result
+	}
+	pool.Stop()
+
+	want := map[string]int{"a b c": 3, "hello world": 2, "": 0, "one two three four": 4}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("word counts = %v, want %v", counts, want)
+	}
+	if _, ok := <-pool.Results(); ok {
+		t.Error("Results should be closed after Stop")
+	}
+}
+
+func TestTenantWorkerPoolRouter_Isolation(t *testing.T) {
+	router := NewTenantWorkerPoolRouter(WorkerPoolConfig{Workers: 1, QueueSize: 10})
+	if err := router.Register("tenant-a", WorkerPoolConfig{Workers: 1, QueueSize: 20}); err != nil {
+		t.Fatalf("Register(tenant-a): %v", err)
+	}
+	if err := router.Register("tenant-b", WorkerPoolConfig{Workers: 1, QueueSize: 5}); err != nil {
+		t.Fatalf("Register(tenant-b): %v", err)
+	}
+	if err := router.Register("tenant-a", WorkerPoolConfig{}); err == nil {
+		t.Error("expected error registering tenant-a twice")
+	}
+
+	// Tenant A's jobs block until tenant B has finished
+	release := make(chan struct{})
+	slow := func(ctx context.Context, payload interface{}) (interface{}, error) {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return payload, nil
+	}
+	fast := func(ctx context.Context, payload interface{}) (interface{}, error) {
+		return payload, nil
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			if err := router.Submit("tenant-a", Job{ID: i, Handler: slow}); err != nil {
+				t.Errorf("Submit(tenant-a): %v", err)
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 5; i++ {
+			if err := router.Submit("tenant-b", Job{ID: i, Handler: fast}); err != nil {
+				t.Errorf("Submit(tenant-b): %v", err)
+			}
+		}
+	}()
+	wg.Wait()
+
+	timeout := time.After(5 * time.Second)
+	for done := 0; done < 5; {
+		select {
+		case r := <-router.Results():
+			if r.TenantID != "tenant-b" {
+				t.Fatalf("got result from %q while tenant-a should be blocked", r.TenantID)
+			}
+			done++
+		case <-timeout:
+			t.Fatalf("tenant-b completed only %d jobs while tenant-a was busy", done)
+		}
+	}
+
+	close(release)
+	for done := 0; done < 20; done++ {
+		if r := <-router.Results(); r.TenantID != "tenant-a" {
+			t.Errorf("expected tenant-a result, got %q", r.TenantID)
+		}
+	}
+
+	// Unregistered tenants use the default pool
+	if err := router.Submit("tenant-c", Job{ID: 1, Handler: fast}); err != nil {
+		t.Fatalf("Submit(tenant-c): %v", err)
+	}
+	if r := <-router.Results(); r.TenantID != "" || r.Error != nil {
+		t.Errorf("expected a successful default-pool result, got %+v", r)
+	}
+
+	router.Stop()
+	if err := router.Submit("tenant-b", Job{Handler: fast}); err == nil {
+		t.Error("expected Submit to fail after Stop")
+	}
+	if _, ok := <-router.Results(); ok {
+		t.Error("Results should be closed after Stop")
+	}
+}
+
+// =============================================================================
+// SECTION 3: Fan-Out/Fan-In Tests
+// =============================================================================
+
+func TestFanOutFanIn_Process(t *testing.T) {
+	fanout := NewFanOutFanIn(3)
+
+	items := []interface{}{1, 2, 3, 4, 5}
+	processor := func(ctx context.Context, item interface{}) (interface{}, error) {
+		num := item.(int)
+		return num * 2, nil
+	}
+
+	results := fanout.Process(context.Background(), items, processor)
+
+	if len(results) != len(items) {
+		t.Errorf("expected %d results, got %d", len(items), len(results))
+	}
+
+	// Verify all items were processed
+	for _, r := range results {
+		if r.Error != nil {
+			t.Errorf("unexpected error for item %v: %v", r.Input, r.Error)
+		}
+		expected := r.Input.(int) * 2
+		if r.Output != expected {
+			t.Errorf("expected output %d, got %v", expected, r.Output)
+		}
+	}
+}
+
+func TestFanOutFanIn_ProcessOrdered(t *testing.T) {
+	fanout := NewFanOutFanIn(3)
+
+	items := []interface{}{1, 2, 3, 4, 5}
+	processor := func(ctx context.Context, item interface{}) (interface{}, error) {
+		return item.(int) * 2, nil
+	}
+
+	results := fanout.ProcessOrdered(context.Background(), items, processor)
+
+	if len(results) != len(items) {
+		t.Errorf("expected %d results, got %d", len(items), len(results))
+	}
+
+	// Verify order is preserved
+	for i, r := range results {
+		if r.Index != i {
+			t.Errorf("expected index %d, got %d", i, r.Index)
+		}
+	}
+}
+
+func TestFanOutFanIn_EmptyInput(t *testing.T) {
+	fanout := NewFanOutFanIn(3)
+
+	results := fanout.Process(context.Background(), []interface{}{}, nil)
+
+	if results != nil {
+		t.Errorf("expected nil for empty input, got %v", results)
+	}
+}
+
+func TestFanOutFanIn_ErrorHandling(t *testing.T) {
+	fanout := NewFanOutFanIn(2)
+
+	items := []interface{}{1, 2, 3}
+	expectedErr := errors.New("processing error")
+	processor := func(ctx context.Context, item interface{}) (interface{}, error) {
+		if item.(int) == 2 {
+			return nil, expectedErr
+		}
+		return item, nil
+	}
+
+	results := fanout.Process(context.Background(), items, processor)
+
+	errorCount := 0
+	for _, r := range results {
+		if r.Error != nil {
+			errorCount++
+		}
+	}
+
+	if errorCount != 1 {
+		t.Errorf("expected 1 error, got %d", errorCount)
+	}
+}
+
+func TestFanOutFanIn_PanicRecovery(t *testing.T) {
+	fanout := NewFanOutFanIn(2)
+
+	items := []interface{}{1, 2}
+	processor := func(ctx context.Context, item interface{}) (interface{}, error) {
+		if item.(int) == 2 {
+			panic("intentional panic")
+		}
+		return item, nil
+	}
+
+	results := fanout.Process(context.Background(), items, processor)
+
+	if len(results) != 2 {
+		t.Errorf("expected 2 results, got %d", len(results))
+	}
+
+	// Find the panicked result
+	var panicResult *ProcessResult
+	for i := range results {
+		if results[i].Input == 2 {
+			panicResult = &results[i]
+			break
+		}
+	}
+
+	if panicResult == nil {
+		t.Fatal("could not find result for item 2")
+	}
+
+	if panicResult.Error == nil {
+		t.Error("expected error from panic recovery, got nil")
+	}
+}
+
+func TestFanOutFanIn_ContextCancellation(t *testing.T) {
+	fanout := NewFanOutFanIn(2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	items := []interface{}{1, 2, 3, 4, 5}
+	processor := func(ctx context.Context, item interface{}) (interface{}, error) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+			return item, nil
+		}
+	}
+
+	// Cancel after a short delay
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	results := fanout.Process(ctx, items, processor)
+
+	// Some results may have errors due to cancellation
+	// This is expected behavior
+	t.Logf("Got %d results after cancellation", len(results))
+}
+
+func TestFanOutFanIn_ProcessAndReduce(t *testing.T) {
+	fanout := NewFanOutFanIn(4)
+	items := make([]interface{}, 100)
+	for i := range items {
+		items[i] = i + 1
+	}
+	processor := func(ctx context.Context, item interface{}) (interface{}, error) {
+		n := item.(int)
+		if n == 50 {
+			return nil, errors.New("corrupt chunk")
+		}
+		return n * 2, nil
+	}
+
+	var seen, failed int
+	sum, err := fanout.ProcessAndReduce(context.Background(), items, processor, func(results []ProcessResult) interface{} {
+		total := 0
+		for i, r := range results {
+			if r.Index != i {
+				t.Errorf("result %d has index %d, want input order", i, r.Index)
+			}
+			seen++
+			if r.Error != nil {
+				failed++
+				continue
+			}
+			total += r.Output.(int)
+		}
+		return total
+	})
+
+	if err != nil {
+		t.Fatalf("ProcessAndReduce: %v", err)
+	}
+	if seen != 100 || failed != 1 {
+		t.Errorf("reducer saw %d results with %d errors, want 100 with 1", seen, failed)
+	}
+	// 2 * (1 + ... + 100) minus the failed item
+	if want := 2*5050 - 100; sum != want {
+		t.Errorf("sum = %v, want %d", sum, want)
+	}
+}
+
+func TestFanOutFanIn_First(t *testing.T) {
+	fanout := NewFanOutFanIn(5)
+
+	cancelled := make(chan int, 2)
+	items := []interface{}{1, 2, 3, 4, 5}
+	processor := func(ctx context.Context, item interface{}) (interface{}, error) {
+		n := item.(int)
+		if n <= 2 {
+			select {
+			case <-ctx.Done():
+				cancelled <- n
+				return nil, ctx.Err()
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+		return n, nil
+	}
+
+	start := time.Now()
+	result, err := fanout.First(context.Background(), items, processor)
+	elapsed := time.Since(start)
+
+	if err != nil {
+		t.Fatalf("First: %v", err)
+	}
+	if elapsed >= 20*time.Millisecond {
+		t.Errorf("First took %v, want < 20ms", elapsed)
+	}
+	if n := result.Output.(int); n < 3 || n > 5 {
+		t.Errorf("result = %d, want one of the fast items 3-5", n)
+	}
+
+	// The slow workers are cancelled rather than left to finish
+	for i := 0; i < 2; i++ {
+		select {
+		case <-cancelled:
+		case <-time.After(50 * time.Millisecond):
+			t.Fatal("slow worker was not cancelled")
+		}
+	}
+}
+
+func TestFanOutFanIn_FirstAllFail(t *testing.T) {
+	fanout := NewFanOutFanIn(3)
+	errBoom := errors.New("boom")
+
+	_, err := fanout.First(context.Background(), []interface{}{1, 2, 3},
+		func(ctx context.Context, item interface{}) (interface{}, error) {
+			return nil, errBoom
+		})
+
+	var multiErr *MultiError
+	if !errors.As(err, &multiErr) {
+		t.Fatalf("expected *MultiError, got %T (%v)", err, err)
+	}
+	if len(multiErr.Errors) != 3 {
+		t.Errorf("got %d errors, want 3", len(multiErr.Errors))
+	}
+	if !errors.Is(err, errBoom) {
+		t.Error("errors.Is(err, errBoom) = false")
+	}
+
+	if _, err := fanout.First(context.Background(), nil, nil); err == nil {
+		t.Error("First with no items should return an error")
+	}
+}
+
+func TestFanOutFanIn_ProcessWithProgress(t *testing.T) {
+	fanout := NewFanOutFanIn(8)
+
+	items := make([]interface{}, 100)
+	for i := range items {
+		items[i] = i
+	}
+
+	var progress []int
+	results := fanout.ProcessWithProgress(context.Background(), items,
+		func(ctx context.Context, item interface{}) (interface{}, error) {
+			return item.(int) * 2, nil
+		},
+		func(done, total int) {
+			if total != 100 {
+				t.Errorf("total = %d, want 100", total)
+			}
+			progress = append(progress, done)
+		})
+
+	if len(results) != 100 {
+		t.Fatalf("expected 100 results, got %d", len(results))
+	}
+	if len(progress) != 100 {
+		t.Fatalf("expected 100 progress calls, got %d", len(progress))
+	}
+	for i, done := range progress {
+		if done != i+1 {
+			t.Fatalf("progress[%d] = %d, want %d", i, done, i+1)
+		}
+	}
+}
+
+// =============================================================================
+// SECTION 4: Pipeline Tests
+// =============================================================================
+
+// multiplyStage returns a stage that multiplies each int by factor.
+func multiplyStage(factor int) PipelineStage {
+	return PipelineStage{
+		Name: fmt.Sprintf("x%d", factor),
+		Process: func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+			out := make(chan interface{})
+			go func() {
+				defer close(out)
+				for v := range in {
+					out <- v.(int) * factor
+				}
+			}()
+			return out
+		},
+	}
+}
+
+func TestPipeline_ScatterGather(t *testing.T) {
+	ctx := context.Background()
+	pipeline := NewPipeline(
+		FanOutStage([]PipelineStage{multiplyStage(1), multiplyStage(2), multiplyStage(3)}),
+	)
+
+	input := make(chan interface{})
+	go func() {
+		defer close(input)
+		for n := 1; n <= 5; n++ {
+			input <- n
+		}
+	}()
+
+	n := 1
+	for v := range pipeline.Run(ctx, input) {
+		got, ok := v.([]interface{})
+		if !ok {
+			t.Fatalf("expected []interface{}, got %T", v)
+		}
+		want := []interface{}{n, 2 * n, 3 * n}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("item %d: gathered %v, want %v", n, got, want)
+		}
+		n++
+	}
+	if n != 6 {
+		t.Errorf("expected 5 gathered results, got %d", n-1)
+	}
+}
+
+func TestPipeline_FanInReducesGatheredResults(t *testing.T) {
+	ctx := context.Background()
+	sum := func(values []interface{}) interface{} {
+		total := 0
+		for _, v := range values {
+			total += v.(int)
+		}
+		return total
+	}
+	pipeline := NewPipeline(
+		FanOutStage([]PipelineStage{multiplyStage(1), multiplyStage(2), multiplyStage(3)}),
+		FanInStage(sum),
+	)
+
+	input := make(chan interface{}, 1)
+	input <- 7
+	close(input)
+
+	var results []interface{}
+	for v := range pipeline.Run(ctx, input) {
+		results = append(results, v)
+	}
+	if len(results) != 1 || results[0] != 42 {
+		t.Errorf("expected [42], got %v", results)
+	}
+}
+
+func TestPipeline_WaitDone(t *testing.T) {
+	var mu sync.Mutex
+	var received []int
+
+	pipeline := NewPipeline(
+		MapStage("double", func(ctx context.Context, item interface{}) (interface{}, error) {
+			return item.(int) * 2, nil
+		}),
+		MapStage("increment", func(ctx context.Context, item interface{}) (interface{}, error) {
+			return item.(int) + 1, nil
+		}),
+		MapStage("store", func(ctx context.Context, item interface{}) (interface{}, error) {
+			mu.Lock()
+			received = append(received, item.(int))
+			mu.Unlock()
+			return item, nil
+		}),
+	)
+
+	if err := pipeline.WaitDone(context.Background()); err == nil {
+		t.Error("expected WaitDone to fail before Run")
+	}
+
+	input := make(chan interface{})
+	go func() {
+		defer close(input)
+		for i := 0; i < 10; i++ {
+			input <- i
+		}
+	}()
+	pipeline.Run(context.Background(), input)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := pipeline.WaitDone(ctx); err != nil {
+		t.Fatalf("WaitDone: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 10 {
+		t.Fatalf("expected 10 items, got %d", len(received))
+	}
+	for i, v := range received {
+		if v != i*2+1 {
+			t.Errorf("received[%d] = %d, want %d", i, v, i*2+1)
+		}
+	}
+}
+
+func TestPipeline_WaitDoneContextExpires(t *testing.T) {
+	pipeline := NewPipeline()
+	pipeline.Run(context.Background(), make(chan interface{})) // never closed
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := pipeline.WaitDone(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitDone error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestPipeline_Errors(t *testing.T) {
+	errOdd := errors.New("odd number")
+	pipeline := NewPipeline(
+		MapStage("even-only", func(ctx context.Context, item interface{}) (interface{}, error) {
+			if item.(int)%2 == 1 {
+				return nil, errOdd
+			}
+			return item, nil
+		}),
+		multiplyStage(10),
+	)
+	errs := pipeline.Errors()
+
+	input := make(chan interface{}, 6)
+	for i := 0; i < 6; i++ {
+		input <- i
+	}
+	close(input)
+	out := pipeline.Run(context.Background(), input)
+
+	var results []interface{}
+	var errCount int
+	for out != nil || errs != nil {
+		select {
+		case v, ok := <-out:
+			if !ok {
+				out = nil
+				continue
+			}
+			results = append(results, v)
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			if !errors.Is(err, errOdd) || !strings.Contains(err.Error(), "even-only") {
+				t.Errorf("unexpected error: %v", err)
+			}
+			errCount++
+		}
+	}
+
+	if !reflect.DeepEqual(results, []interface{}{0, 20, 40}) {
+		t.Errorf("results = %v, want [0 20 40]", results)
+	}
+	if errCount != 3 {
+		t.Errorf("expected 3 item errors, got %d", errCount)
+	}
+}
+
+func TestPipeline_DeadLetter(t *testing.T) {
+	errDown := errors.New("storage unavailable")
+	calls := 0
+	pipeline := NewPipeline(
+		multiplyStage(10),
+		MapStageWithRetry("store", 3, func(ctx context.Context, item interface{}) (interface{}, error) {
+			calls++
+			return nil, errDown
+		}),
+	)
+	dlq := make(chan DeadLetterItem, 5)
+	pipeline.WithDeadLetter(dlq)
+
+	input := make(chan interface{}, 5)
+	for i := 0; i < 5; i++ {
+		input <- i
+	}
+	close(input)
+
+	if err := func() error {
+		pipeline.Run(context.Background(), input)
+		return pipeline.WaitDone(context.Background())
+	}(); err != nil {
+		t.Fatalf("WaitDone: %v", err)
+	}
+	close(dlq)
+
+	var items []interface{}
+	for dl := range dlq {
+		if dl.StageIndex != 1 {
+			t.Errorf("StageIndex = %d, want 1", dl.StageIndex)
+		}
+		if !errors.Is(dl.Error, errDown) {
+			t.Errorf("Error = %v, want %v", dl.Error, errDown)
+		}
+		if dl.Attempts != 3 {
+			t.Errorf("Attempts = %d, want 3", dl.Attempts)
+		}
+		items = append(items, dl.Item)
+	}
+	if !reflect.DeepEqual(items, []interface{}{0, 10, 20, 30, 40}) {
+		t.Errorf("dead-lettered items = %v, want every input item", items)
+	}
+	if calls != 15 {
+		t.Errorf("stage called %d times, want 3 attempts for each of 5 items", calls)
+	}
+}
+
+func TestPipeline_DeadLetterFull(t *testing.T) {
+	pipeline := NewPipeline(MapStage("fail", func(ctx context.Context, item interface{}) (interface{}, error) {
+		return nil, errors.New("fail")
+	}))
+	dlq := make(chan DeadLetterItem, 1)
+	pipeline.WithDeadLetter(dlq)
+
+	input := make(chan interface{}, 3)
+	for i := 0; i < 3; i++ {
+		input <- i
+	}
+	close(input)
+
+	// A full dead-letter channel must not stall the pipeline
+	pipeline.Run(context.Background(), input)
+	if err := pipeline.WaitDone(context.Background()); err != nil {
+		t.Fatalf("WaitDone: %v", err)
+	}
+	if len(dlq) != 1 || pipeline.DeadLetterDrops() != 2 {
+		t.Errorf("got %d dead letters and %d drops, want 1 and 2", len(dlq), pipeline.DeadLetterDrops())
+	}
+}
+
+func TestPipelineStage_WithFallback(t *testing.T) {
+	enrich := func(ctx context.Context, item interface{}) (interface{}, error) {
+		if item.(int)%2 == 1 {
+			return nil, errors.New("odd number")
+		}
+		return item.(int) * 10, nil
+	}
+
+	tests := []struct {
+		name     string
+		fallback interface{}
+		want     []interface{}
+	}{
+		{"default value", -1, []interface{}{0, -1, 20, -1, 40, -1}},
+		{"original input", nil, []interface{}{0, 1, 20, 3, 40, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := NewPipeline(MapStage("enrich", enrich).WithFallback(tt.fallback))
+			errs := pipeline.Errors()
+
+			input := make(chan interface{}, 6)
+			for i := 0; i < 6; i++ {
+				input <- i
+			}
+			close(input)
+
+			var got []interface{}
+			for v := range pipeline.Run(context.Background(), input) {
+				got = append(got, v)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if err, ok := <-errs; ok {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestPipelineStage_WithFallbackForwardsEarlierErrors(t *testing.T) {
+	errUpstream := errors.New("upstream")
+	pipeline := NewPipeline(
+		MapStage("parse", func(ctx context.Context, item interface{}) (interface{}, error) {
+			return nil, errUpstream
+		}),
+		MapStage("enrich", func(ctx context.Context, item interface{}) (interface{}, error) {
+			return item, nil
+		}).WithFallback(-1),
+	)
+
+	input := make(chan interface{}, 1)
+	input <- 1
+	close(input)
+
+	for v := range pipeline.Run(context.Background(), input) {
+		if err, ok := v.(error); !ok || !errors.Is(err, errUpstream) {
+			t.Errorf("got %v, want the upstream error forwarded", v)
+		}
+	}
+}
+
+func TestPipeline_PauseResume(t *testing.T) {
+	var processed atomic.Int32
+	pipeline := NewPipeline(MapStage("count", func(ctx context.Context, item interface{}) (interface{}, error) {
+		processed.Add(1)
+		return item, nil
+	})).WithPauseResume()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	input := make(chan interface{}, 10)
+	pipeline.Pause()
+	out := pipeline.Run(ctx, input)
+	for i := 0; i < 10; i++ {
+		input <- i
+	}
+	close(input)
+
+	time.Sleep(50 * time.Millisecond)
+	if got := processed.Load(); got != 0 {
+		t.Fatalf("%d items processed while paused, want 0", got)
+	}
+	if len(input) != 10 {
+		t.Errorf("%d items left in input while paused, want 10", len(input))
+	}
+
+	pipeline.Resume()
+	var results []interface{}
+	for v := range out {
+		results = append(results, v)
+	}
+	if len(results) != 10 || processed.Load() != 10 {
+		t.Errorf("got %d results and %d processed after Resume, want 10", len(results), processed.Load())
+	}
+}
+
+func TestPipeline_PauseMidStream(t *testing.T) {
+	pipeline := NewPipeline(multiplyStage(1)).WithPauseResume()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	input := make(chan interface{})
+	out := pipeline.Run(ctx, input)
+
+	input <- 1
+	<-out
+	pipeline.Pause()
+	if !pipeline.Paused() {
+		t.Fatal("Paused() = false after Pause")
+	}
+
+	// The producer blocks: a paused pipeline does not read its input
+	select {
+	case input <- 2:
+		t.Fatal("paused pipeline accepted an item")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	pipeline.Resume()
+	input <- 2
+	if v := <-out; v != 2 {
+		t.Errorf("got %v after Resume, want 2", v)
+	}
+	close(input)
+}
+
+func TestPipelineStage_WithTracing(t *testing.T) {
+	tracer := &fakeTracer{}
+	double := MapStage("double", func(ctx context.Context, item interface{}) (interface{}, error) {
+		return item.(int) * 2, nil
+	}).WithTracing(tracer)
+	validate := MapStage("validate", func(ctx context.Context, item interface{}) (interface{}, error) {
+		if item.(int) > 4 {
+			return nil, errors.New("too large")
+		}
+		return item, nil
+	}).WithTracing(tracer)
+
+	ctx, parent := tracer.Start(context.Background(), "ingest batch")
+	input := make(chan interface{}, 3)
+	for i := 1; i <= 3; i++ {
+		input <- i
+	}
+	close(input)
+	for range NewPipeline(double, validate).Run(ctx, input) {
+	}
+
+	tracer.mu.Lock()
+	spans := tracer.spans[1:]
+	tracer.mu.Unlock()
+	if len(spans) != 6 {
+		t.Fatalf("got %d stage spans, want 6", len(spans))
+	}
+	counts := make(map[string]int)
+	for _, span := range spans {
+		span.mu.Lock()
+		counts[span.name]++
+		if span.parentID != parent.(*fakeSpan).id {
+			t.Errorf("%s: parent = %q, want the Run context's span", span.name, span.parentID)
+		}
+		if !span.ended {
+			t.Errorf("%s for item %v was not ended", span.name, span.attrs["pipeline.item.index"])
+		}
+		wantStage := map[string]int{"pipeline.stage.double": 0, "pipeline.stage.validate": 1}[span.name]
+		if span.attrs["pipeline.stage.index"] != wantStage {
+			t.Errorf("%s: pipeline.stage.index = %v, want %d", span.name, span.attrs["pipeline.stage.index"], wantStage)
+		}
+		// Only the third item (doubled to 6) fails validation
+		failed := span.name == "pipeline.stage.validate" && span.attrs["pipeline.item.index"] == 2
+		if (span.err != nil) != failed {
+			t.Errorf("%s for item %v: err = %v", span.name, span.attrs["pipeline.item.index"], span.err)
+		}
+		span.mu.Unlock()
+	}
+	if counts["pipeline.stage.double"] != 3 || counts["pipeline.stage.validate"] != 3 {
+		t.Errorf("span counts = %v, want 3 per stage", counts)
+	}
+}
+
+func TestPipeline_CompoundStage(t *testing.T) {
+	parseAndEnrich := CompoundStage("parse-and-enrich",
+		MapStage("parse", func(ctx context.Context, item interface{}) (interface{}, error) {
+			return len(item.(string)), nil
+		}),
+		multiplyStage(10),
+		MapStage("label", func(ctx context.Context, item interface{}) (interface{}, error) {
+			return fmt.Sprintf("len*10=%d", item.(int)), nil
+		}),
+	)
+	if parseAndEnrich.Name != "parse-and-enrich" {
+		t.Errorf("Name = %q, want parse-and-enrich", parseAndEnrich.Name)
+	}
+
+	// Reuse the same compound stage in two pipelines
+	for _, pipeline := range []*Pipeline{
+		NewPipeline(parseAndEnrich),
+		NewPipeline(parseAndEnrich, MapStage("upper", func(ctx context.Context, item interface{}) (interface{}, error) {
+			return strings.ToUpper(item.(string)), nil
+		})),
+	} {
+		input := make(chan interface{})
+		go func() {
+			defer close(input)
+			for _, s := range []string{"a", "bb", "ccc"} {
+				input <- s
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		var got []string
+		for v := range pipeline.Run(ctx, input) {
+			got = append(got, strings.ToLower(v.(string)))
+		}
+		cancel()
+
+		want := []string{"len*10=10", "len*10=20", "len*10=30"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+}
+
+// =============================================================================
+// SECTION 5: Error Group Tests
+// =============================================================================
+
+func TestErrorGroup_NoErrors(t *testing.T) {
+	eg := NewErrorGroup(context.Background())
+
+	eg.Go(func(ctx context.Context) error {
+		return nil
+	})
+
+	eg.Go(func(ctx context.Context) error {
+		return nil
+	})
+
+	err := eg.Wait()
+	if err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+}
+
+func TestErrorGroup_SingleError(t *testing.T) {
+	eg := NewErrorGroup(context.Background())
+
+	expectedErr := errors.New("test error")
+
+	eg.Go(func(ctx context.Context) error {
+		return expectedErr
+	})
+
+	eg.Go(func(ctx context.Context) error {
+		return nil
+	})
+
+	err := eg.Wait()
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestErrorGroup_MultipleErrors(t *testing.T) {
+	eg := NewErrorGroup(context.Background())
+
+	eg.Go(func(ctx context.Context) error {
+		return errors.New("error 1")
+	})
+
+	eg.Go(func(ctx context.Context) error {
+		return errors.New("error 2")
+	})
+
+	err := eg.Wait()
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	errs := eg.Errors()
+	if len(errs) != 2 {
+		t.Errorf("expected 2 errors, got %d", len(errs))
+	}
+}
+
+func TestErrorGroup_MultiError(t *testing.T) {
+	eg := NewErrorGroup(context.Background())
+	errTimeout := errors.New("ingester timeout")
+
+	eg.Go(func(ctx context.Context) error {
+		return fmt.Errorf("querying ingester-1: %w", errTimeout)
+	})
+	eg.Go(func(ctx context.Context) error {
+		return errors.New("store unavailable")
+	})
+
+	err := eg.Wait()
+
+	var multiErr *MultiError
+	if !errors.As(err, &multiErr) {
+		t.Fatalf("expected *MultiError, got %T", err)
+	}
+	if len(multiErr.Errors) != 2 {
+		t.Errorf("expected 2 errors, got %d", len(multiErr.Errors))
+	}
+	if !errors.Is(err, errTimeout) {
+		t.Error("errors.Is should match a wrapped element")
+	}
+	if errors.Is(err, context.Canceled) {
+		t.Error("errors.Is should not match an absent error")
+	}
+
+	msg := err.Error()
+	for _, want := range []string{"ingester timeout", "store unavailable"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("expected message to contain %q, got %q", want, msg)
+		}
+	}
+}
+
+// tracedError mirrors observability.ObservabilityError: it prefixes the
+// wrapped error with the trace it occurred in.
+type tracedError struct {
+	traceID   string
+	operation string
+	err       error
+}
+
+func (e *tracedError) Error() string {
+	return fmt.Sprintf("[trace_id=%s] %s: %v", e.traceID, e.operation, e.err)
+}
+
+func (e *tracedError) Unwrap() error {
+	return e.err
+}
+
+func TestMultiError_Format(t *testing.T) {
+	errTimeout := errors.New("ingester timeout")
+	err := &MultiError{Errors: []error{
+		fmt.Errorf("shard 0: %w", &tracedError{traceID: "aaa111", operation: "query", err: errTimeout}),
+		fmt.Errorf("shard 1: %w", &tracedError{traceID: "bbb222", operation: "query", err: errTimeout}),
+		&tracedError{traceID: "ccc333", operation: "merge", err: errors.New("out of memory")},
+	}}
+
+	want := "3 errors occurred:\n" +
+		"  [0]: shard 0: [trace_id=aaa111] query: ingester timeout\n" +
+		"  [1]: shard 1: [trace_id=bbb222] query: ingester timeout\n" +
+		"  [2]: [trace_id=ccc333] merge: out of memory"
+	if got := err.Error(); got != want {
+		t.Errorf("Error() =\n%s\nwant\n%s", got, want)
+	}
+	if got := fmt.Sprintf("%s", err); got != want {
+		t.Errorf("%%s = %q, want the Error text", got)
+	}
+
+	short := fmt.Sprintf("%v", err)
+	if strings.Contains(short, "\n") || !strings.HasPrefix(short, "3 errors occurred: [0] shard 0:") {
+		t.Errorf("%%v = %q, want a single-line summary", short)
+	}
+
+	verbose := fmt.Sprintf("%+v", err)
+	for _, want := range []string{
+		"  [0]: shard 0: [trace_id=aaa111] query: ingester timeout\n      caused by: [trace_id=aaa111] query: ingester timeout\n      caused by: ingester timeout",
+		"caused by: [trace_id=bbb222] query: ingester timeout",
+		"  [2]: [trace_id=ccc333] merge: out of memory\n      caused by: out of memory",
+	} {
+		if !strings.Contains(verbose, want) {
+			t.Errorf("%%+v output missing %q:\n%s", want, verbose)
+		}
+	}
+
+	if got := (&MultiError{Errors: []error{errTimeout}}).Error(); got != "1 error occurred:\n  [0]: ingester timeout" {
+		t.Errorf("single error = %q", got)
+	}
+}
+
+func TestCollectErrors(t *testing.T) {
+	root := errors.New("connection refused")
+	traced := &tracedError{traceID: "aaa111", operation: "query", err: root}
+	multi := &MultiError{Errors: []error{traced}}
+	top := fmt.Errorf("query shards: %w", multi)
+
+	got := CollectErrors(top)
+	want := []error{top, multi, traced, root}
+	if len(got) != len(want) {
+		t.Fatalf("CollectErrors returned %d errors, want %d: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("level %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	var visited []error
+	WalkErrors(top, func(err error) bool {
+		visited = append(visited, err)
+		return err != multi
 	})
+	if len(visited) != 2 || visited[1] != multi {
+		t.Errorf("WalkErrors visited %v, want to stop at the MultiError", visited)
+	}
 
-	err := eg.Wait()
-	if err == nil {
-		t.Error("expected error, got nil")
+	if errs := CollectErrors(nil); len(errs) != 0 {
+		t.Errorf("CollectErrors(nil) = %v, want empty", errs)
 	}
 }
 
-func TestErrorGroup_MultipleErrors(t *testing.T) {
+func TestErrorGroup_GoN(t *testing.T) {
 	eg := NewErrorGroup(context.Background())
 
-	eg.Go(func(ctx context.Context) error {
-		return errors.New("error 1")
-	})
-
-	eg.Go(func(ctx context.Context) error {
-		return errors.New("error 2")
+	var started [5]atomic.Bool
+	errThree := errors.New("worker 3 failed")
+	eg.GoN(5, func(ctx context.Context, i int) error {
+		started[i].Store(true)
+		if i == 3 {
+			return errThree
+		}
+		return nil
 	})
 
-	err := eg.Wait()
-	if err == nil {
-		t.Error("expected error, got nil")
+	if err := eg.Wait(); !errors.Is(err, errThree) {
+		t.Errorf("Wait() = %v, want %v", err, errThree)
 	}
-
-	errs := eg.Errors()
-	if len(errs) != 2 {
-		t.Errorf("expected 2 errors, got %d", len(errs))
+	if errs := eg.Errors(); len(errs) != 1 {
+		t.Errorf("expected exactly 1 error, got %d: %v", len(errs), errs)
+	}
+	for i := range started {
+		if !started[i].Load() {
+			t.Errorf("worker %d was not started", i)
+		}
 	}
 }
 
@@ -593,8 +2009,41 @@ func TestErrorGroup_GoWithCancel(t *testing.T) {
 	}
 }
 
+func TestErrorGroup_GoWithTimeout(t *testing.T) {
+	eg := NewErrorGroup(context.Background())
+
+	results := make([]error, 3)
+	for i, work := range []time.Duration{0, 200 * time.Millisecond, 0} {
+		i, work := i, work
+		eg.GoWithTimeout(50*time.Millisecond, func(ctx context.Context) error {
+			select {
+			case <-ctx.Done():
+				results[i] = ctx.Err()
+			case <-time.After(work):
+			}
+			return results[i]
+		})
+	}
+
+	err := eg.Wait()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait() = %v, want context.DeadlineExceeded", err)
+	}
+	if results[0] != nil || results[2] != nil {
+		t.Errorf("fast goroutines returned %v and %v, want nil", results[0], results[2])
+	}
+	if !errors.Is(results[1], context.DeadlineExceeded) {
+		t.Errorf("slow goroutine returned %v, want context.DeadlineExceeded", results[1])
+	}
+
+	// The deadline is per goroutine; the group's context is not cancelled
+	if eg.Context().Err() != nil {
+		t.Errorf("group context cancelled: %v", eg.Context().Err())
+	}
+}
+
 // =============================================================================
-// SECTION 5: Semaphore Tests
+// SECTION 6: Semaphore Tests
 // =============================================================================
 
 func TestSemaphore_BasicOperation(t *testing.T) {
@@ -719,6 +2168,494 @@ func TestSemaphore_ReleasePanic(t *testing.T) {
 	sem.Release()
 }
 
+func TestSemaphore_Lock(t *testing.T) {
+	sem := NewSemaphore(1)
+
+	release, err := sem.Lock(context.Background())
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	if sem.Available() != 0 {
+		t.Errorf("Available() = %d after Lock, want 0", sem.Available())
+	}
+
+	// A full semaphore makes Lock wait for the context
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if r, err := sem.Lock(ctx); err == nil || r != nil {
+		t.Error("expected Lock on a full semaphore to fail with a nil release func")
+	}
+
+	release()
+	if sem.Available() != 1 {
+		t.Errorf("Available() = %d after release, want 1", sem.Available())
+	}
+
+	// A second call panics even if another holder has taken the slot
+	if !sem.TryAcquire() {
+		t.Fatal("TryAcquire should succeed after release")
+	}
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected panic when calling release twice")
+		}
+		if sem.Available() != 0 {
+			t.Error("double release must not free another holder's slot")
+		}
+	}()
+	release()
+}
+
+func TestSemaphore_Metrics(t *testing.T) {
+	registry := newTestRegistry()
+	sem := NewSemaphore(1)
+	sem.RegisterMetrics(registry, "uploads")
+
+	if err := sem.Acquire(context.Background()); err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	if got := registry.gauge("semaphore_in_use").Value(); got != 1 {
+		t.Errorf("semaphore_in_use = %v, want 1", got)
+	}
+
+	// A second goroutine blocks on the full semaphore until it is released
+	acquired := make(chan struct{})
+	go func() {
+		if err := sem.Acquire(context.Background()); err != nil {
+			t.Errorf("Acquire: %v", err)
+		}
+		close(acquired)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	sem.Release()
+	<-acquired
+
+	if sem.TryAcquire() {
+		t.Fatal("TryAcquire should fail on a full semaphore")
+	}
+	sem.Release()
+
+	waits := registry.histogram("semaphore_wait_duration_seconds").Observations()
+	if len(waits) != 2 {
+		t.Fatalf("expected 2 wait observations, got %v", waits)
+	}
+	if waits[1] < 0.015 {
+		t.Errorf("blocked Acquire waited %vs, want about 0.02s", waits[1])
+	}
+	if got := registry.counter("semaphore_acquisitions_total").Value(); got != 2 {
+		t.Errorf("semaphore_acquisitions_total = %v, want 2", got)
+	}
+	if got := registry.counter("semaphore_rejections_total").Value(); got != 1 {
+		t.Errorf("semaphore_rejections_total = %v, want 1", got)
+	}
+	if got := registry.gauge("semaphore_in_use").Value(); got != 0 {
+		t.Errorf("semaphore_in_use = %v after releasing, want 0", got)
+	}
+	if got := registry.labels["semaphore_in_use"]["semaphore"]; got != "uploads" {
+		t.Errorf("semaphore label = %q, want uploads", got)
+	}
+}
+
+func TestSemaphore_FIFOFairness(t *testing.T) {
+	const goroutines = 100
+	sem := NewSemaphore(1)
+	if err := sem.Acquire(context.Background()); err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	queued := func() int {
+		sem.mu.Lock()
+		defer sem.mu.Unlock()
+		return sem.waiters.Len()
+	}
+
+	calledAt := make([]time.Time, goroutines)
+	acquiredAt := make([]time.Time, goroutines)
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		calledAt[i] = time.Now()
+		go func(i int) {
+			defer wg.Done()
+			if err := sem.Acquire(context.Background()); err != nil {
+				t.Errorf("Acquire: %v", err)
+				return
+			}
+			acquiredAt[i] = time.Now()
+			time.Sleep(time.Microsecond)
+			sem.Release()
+		}(i)
+		// Wait until goroutine i is queued so call order is well defined
+		for queued() != i+1 {
+			time.Sleep(time.Microsecond)
+		}
+	}
+	sem.Release()
+	wg.Wait()
+
+	for i := 1; i < goroutines; i++ {
+		if !calledAt[i].After(calledAt[i-1]) {
+			t.Fatalf("Acquire call %d not after call %d", i, i-1)
+		}
+		if acquiredAt[i].Before(acquiredAt[i-1]) {
+			t.Errorf("goroutine %d acquired at %v, before goroutine %d at %v",
+				i, acquiredAt[i], i-1, acquiredAt[i-1])
+		}
+	}
+	if sem.Available() != 1 {
+		t.Errorf("Available() = %d after all releases, want 1", sem.Available())
+	}
+}
+
+func TestSemaphore_CancelledWaiterLeavesQueue(t *testing.T) {
+	sem := NewSemaphore(1)
+	if err := sem.Acquire(context.Background()); err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := sem.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire = %v, want DeadlineExceeded", err)
+	}
+
+	// The abandoned waiter must not swallow the released slot
+	sem.Release()
+	if !sem.TryAcquire() {
+		t.Error("TryAcquire should succeed once the cancelled waiter has left the queue")
+	}
+}
+
+// =============================================================================
+// SECTION 7: Debouncer Tests
+// =============================================================================
+
+func TestDebouncer_CollapsesBurst(t *testing.T) {
+	var calls int32
+	var calledAt atomic.Value
+
+	d := NewDebouncer(50*time.Millisecond, func() {
+		atomic.AddInt32(&calls, 1)
+		calledAt.Store(time.Now())
+	})
+
+	var lastTrigger time.Time
+	for i := 0; i < 10; i++ {
+		d.Trigger()
+		lastTrigger = time.Now()
+		time.Sleep(time.Millisecond)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("expected fn to be called once, got %d", got)
+	}
+
+	delay := calledAt.Load().(time.Time).Sub(lastTrigger)
+	if delay < 45*time.Millisecond || delay > 100*time.Millisecond {
+		t.Errorf("expected fn ~50ms after last trigger, got %v", delay)
+	}
+}
+
+func TestDebouncer_Stop(t *testing.T) {
+	var calls int32
+	d := NewDebouncer(20*time.Millisecond, func() {
+		atomic.AddInt32(&calls, 1)
+	})
+
+	d.Trigger()
+	d.Stop()
+
+	time.Sleep(50 * time.Millisecond)
+
+	if got := atomic.LoadInt32(&calls); got != 0 {
+		t.Errorf("expected no calls after Stop, got %d", got)
+	}
+}
+
+func TestDebouncer_Leading(t *testing.T) {
+	var mu sync.Mutex
+	var calls []time.Time
+	d := NewDebouncerWithLeading(50*time.Millisecond, func() {
+		mu.Lock()
+		calls = append(calls, time.Now())
+		mu.Unlock()
+	})
+
+	start := time.Now()
+	var lastTrigger time.Time
+	for i := 0; i < 10; i++ {
+		d.TriggerImmediate()
+		lastTrigger = time.Now()
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(150 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 calls (leading and trailing), got %d", len(calls))
+	}
+	if lead := calls[0].Sub(start); lead > 5*time.Millisecond {
+		t.Errorf("leading call came %v after the first trigger, want immediately", lead)
+	}
+	if trail := calls[1].Sub(lastTrigger); trail < 45*time.Millisecond || trail > 100*time.Millisecond {
+		t.Errorf("trailing call came %v after the last trigger, want ~50ms", trail)
+	}
+}
+
+func TestDebouncer_LeadingSingleTrigger(t *testing.T) {
+	var calls int32
+	d := NewDebouncerWithLeading(20*time.Millisecond, func() {
+		atomic.AddInt32(&calls, 1)
+	})
+
+	// A lone trigger runs once, with no trailing call
+	d.Trigger()
+	time.Sleep(50 * time.Millisecond)
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("expected 1 call, got %d", got)
+	}
+
+	// Once the window has closed, the next trigger runs immediately again
+	d.Trigger()
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("expected an immediate call after the window closed, got %d calls", got)
+	}
+}
+
+// =============================================================================
+// SECTION 8: Event Bus Tests
+// =============================================================================
+
+func TestEventBus_FanOut(t *testing.T) {
+	bus := NewEventBus[string](10)
+
+	var wg sync.WaitGroup
+	received := make([][]string, 3)
+	var mu sync.Mutex
+
+	for i := 0; i < 3; i++ {
+		idx := i
+		wg.Add(2)
+		bus.Subscribe(func(ctx context.Context, event string) {
+			mu.Lock()
+			received[idx] = append(received[idx], event)
+			mu.Unlock()
+			wg.Done()
+		})
+	}
+
+	bus.Publish(context.Background(), "config.reloaded")
+	bus.Publish(context.Background(), "breaker.opened")
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for subscribers to receive events")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i, events := range received {
+		if len(events) != 2 || events[0] != "config.reloaded" || events[1] != "breaker.opened" {
+			t.Errorf("subscriber %d received %v, want [config.reloaded breaker.opened]", i, events)
+		}
+	}
+}
+
+func TestEventBus_Unsubscribe(t *testing.T) {
+	bus := NewEventBus[int](10)
+
+	var count int32
+	unsubscribe := bus.Subscribe(func(ctx context.Context, event int) {
+		atomic.AddInt32(&count, 1)
+	})
+
+	bus.Publish(context.Background(), 1)
+	time.Sleep(20 * time.Millisecond)
+
+	unsubscribe()
+	unsubscribe() // Safe to call more than once
+
+	bus.Publish(context.Background(), 2)
+	time.Sleep(20 * time.Millisecond)
+
+	if got := atomic.LoadInt32(&count); got != 1 {
+		t.Errorf("expected 1 delivered event, got %d", got)
+	}
+	if got := bus.Subscribers(); got != 0 {
+		t.Errorf("expected 0 subscribers after unsubscribe, got %d", got)
+	}
+}
+
+func TestEventBus_DropsForSlowConsumer(t *testing.T) {
+	bus := NewEventBus[int](1)
+
+	block := make(chan struct{})
+	started := make(chan struct{}, 1)
+	unsubscribe := bus.Subscribe(func(ctx context.Context, event int) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-block
+	})
+	defer unsubscribe()
+
+	// First event occupies the handler
+	bus.Publish(context.Background(), 1)
+	<-started
+
+	// Second event fills the buffer, the rest are dropped
+	for i := 2; i <= 5; i++ {
+		bus.Publish(context.Background(), i)
+	}
+	close(block)
+
+	if got := bus.Dropped(); got != 3 {
+		t.Errorf("expected 3 dropped events, got %d", got)
+	}
+}
+
+// =============================================================================
+// SECTION 9: Shutdown Coordinator Tests
+// =============================================================================
+
+func TestShutdownCoordinator_Order(t *testing.T) {
+	sc := NewShutdownCoordinator()
+
+	var mu sync.Mutex
+	var order []string
+	stopper := func(name string) func(context.Context) error {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			return nil
+		}
+	}
+
+	sc.Register("logger", 100, stopper("logger"))
+	sc.Register("http", 0, stopper("http"))
+	sc.Register("tracer", 50, stopper("tracer"))
+	sc.Register("workers", 10, stopper("workers"))
+	sc.Register("metrics", 50, stopper("metrics"))
+
+	if err := sc.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"http", "workers", "tracer", "metrics", "logger"}
+	if fmt.Sprint(order) != fmt.Sprint(expected) {
+		t.Errorf("expected order %v, got %v", expected, order)
+	}
+
+	// A second call must not stop components again
+	sc.Shutdown(context.Background())
+	if len(order) != len(expected) {
+		t.Errorf("expected components to stop once, got %v", order)
+	}
+}
+
+func TestShutdownCoordinator_CollectsErrors(t *testing.T) {
+	sc := NewShutdownCoordinator()
+	errFlush := errors.New("flush failed")
+
+	var loggerStopped bool
+	sc.Register("tracer", 1, func(ctx context.Context) error { return errFlush })
+	sc.Register("logger", 2, func(ctx context.Context) error {
+		loggerStopped = true
+		return nil
+	})
+
+	err := sc.Shutdown(context.Background())
+	if !errors.Is(err, errFlush) {
+		t.Errorf("expected error to wrap errFlush, got %v", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "tracer") {
+		t.Errorf("expected error to name the component, got %v", err)
+	}
+	if !loggerStopped {
+		t.Error("logger should stop even though tracer failed")
+	}
+}
+
+func TestShutdownCoordinator_TimeoutDoesNotBlockOthers(t *testing.T) {
+	sc := NewShutdownCoordinator()
+
+	release := make(chan struct{})
+	defer close(release)
+	loggerStopped := make(chan struct{}, 1)
+
+	// A component that ignores its context and hangs
+	sc.Register("workers", 1, func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+	// A component that honours its context
+	sc.Register("logger", 2, func(ctx context.Context) error {
+		loggerStopped <- struct{}{}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Millisecond):
+			return nil
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := sc.Shutdown(ctx)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Shutdown blocked on hung component for %v", elapsed)
+	}
+
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "workers") {
+		t.Errorf("expected workers to be reported as timed out, got %v", err)
+	}
+	if err != nil && strings.Contains(err.Error(), "logger") {
+		t.Errorf("logger should stop within its own budget, got %v", err)
+	}
+
+	select {
+	case <-loggerStopped:
+	case <-time.After(time.Second):
+		t.Error("logger was not asked to stop after workers timed out")
+	}
+}
+
+func TestShutdownCoordinator_WithComponentTimeout(t *testing.T) {
+	sc := NewShutdownCoordinator().WithComponentTimeout(20 * time.Millisecond)
+
+	hung := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	sc.Register("tracer", 1, hung)
+	sc.Register("logger", 2, hung)
+
+	start := time.Now()
+	err := sc.Shutdown(context.Background())
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Shutdown took %v with a 20ms component timeout", elapsed)
+	}
+	for _, name := range []string{"tracer", "logger"} {
+		if err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("expected %s to be reported as timed out, got %v", name, err)
+		}
+	}
+}
+
 // =============================================================================
 // Benchmarks
 // =============================================================================
@@ -0,0 +1,257 @@
+// Package concurrency provides struct-tag driven validation and defaults for
+// the configuration structs in this package.
+//
+// This file demonstrates:
+// - Reading struct tags with reflection
+// - Applying `default:"..."` values to zero fields
+// - Validating fields with a small set of `validate:"..."` rules
+//
+// Supported validation rules (comma-separated, e.g. `validate:"required,min=1"`):
+// - required: the field must not be its zero value
+// - min=N: numbers must be >= N; strings, slices, and maps need length >= N
+// - max=N: numbers must be <= N; strings, slices, and maps need length <= N
+// - url: the string must be an absolute URL with a scheme and host
+//
+// For time.Duration fields, N may be written as a duration ("5m") or as
+// nanoseconds.
+//
+// Libraries such as go-playground/validator offer many more rules; these four
+// cover what the configs in this package need.
+package concurrency
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidConfig is wrapped by every error returned from Validate.
+var ErrInvalidConfig = errors.New("invalid config")
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Validate checks cfg against its `validate` struct tags.
+// cfg may be a struct or a pointer to a struct. All violations are returned
+// together so a misconfigured service reports every problem at once.
+//
+// Example:
+//
+//	type ServerConfig struct {
+//	    Endpoint string        `validate:"required,url"`
+//	    Workers  int           `default:"4" validate:"min=1,max=64"`
+//	    Timeout  time.Duration `default:"30s" validate:"min=1,max=5m"`
+//	}
+func Validate[T any](cfg T) error {
+	v := reflect.ValueOf(cfg)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return fmt.Errorf("%w: nil config", ErrInvalidConfig)
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("%w: expected struct, got %s", ErrInvalidConfig, v.Kind())
+	}
+	return errors.Join(validateStruct(v, "")...)
+}
+
+// validateStruct validates every field of v, recursing into nested structs.
+func validateStruct(v reflect.Value, prefix string) []error {
+	var errs []error
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := prefix + field.Name
+		fv := v.Field(i)
+
+		if tag, ok := field.Tag.Lookup("validate"); ok {
+			for _, rule := range strings.Split(tag, ",") {
+				if err := checkRule(fv, strings.TrimSpace(rule)); err != nil {
+					errs = append(errs, fmt.Errorf("%w: %s %v", ErrInvalidConfig, name, err))
+				}
+			}
+		}
+
+		if fv.Kind() == reflect.Struct {
+			errs = append(errs, validateStruct(fv, name+".")...)
+		}
+	}
+	return errs
+}
+
+// checkRule applies a single validation rule to a field value.
+func checkRule(v reflect.Value, rule string) error {
+	name, arg, _ := strings.Cut(rule, "=")
+
+	switch name {
+	case "":
+		return nil
+
+	case "required":
+		if v.IsZero() {
+			return errors.New("is required")
+		}
+		return nil
+
+	case "min", "max":
+		limit, err := parseLimit(v, arg)
+		if err != nil {
+			return fmt.Errorf("has malformed rule %q", rule)
+		}
+		n, ok := numericValue(v)
+		if !ok {
+			return fmt.Errorf("does not support rule %q", rule)
+		}
+		if name == "min" && n < limit {
+			return fmt.Errorf("must be at least %s, got %s", arg, formatValue(v, n))
+		}
+		if name == "max" && n > limit {
+			return fmt.Errorf("must be at most %s, got %s", arg, formatValue(v, n))
+		}
+		return nil
+
+	case "url":
+		if v.Kind() != reflect.String {
+			return fmt.Errorf("does not support rule %q", rule)
+		}
+		if v.String() == "" {
+			// Use "required" to reject empty values
+			return nil
+		}
+		u, err := url.Parse(v.String())
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("must be an absolute URL, got %q", v.String())
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("has unknown rule %q", rule)
+	}
+}
+
+// numericValue returns the value compared by min/max: the number itself for
+// numeric kinds, or the length for strings, slices, and maps.
+func numericValue(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.String, reflect.Slice, reflect.Map:
+		return float64(v.Len()), true
+	}
+	return 0, false
+}
+
+// parseLimit parses a min/max argument. Duration fields also accept
+// time.ParseDuration syntax.
+func parseLimit(v reflect.Value, arg string) (float64, error) {
+	if v.Type() == durationType {
+		if d, err := time.ParseDuration(arg); err == nil {
+			return float64(d), nil
+		}
+	}
+	return strconv.ParseFloat(arg, 64)
+}
+
+// formatValue renders a compared value for error messages.
+func formatValue(v reflect.Value, n float64) string {
+	if v.Type() == durationType {
+		return time.Duration(n).String()
+	}
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+// ApplyDefaults sets every zero-valued field of *cfg that has a `default`
+// struct tag. Fields that are already set are left untouched. Durations use
+// time.ParseDuration syntax (e.g. "30s").
+//
+// A malformed default tag is a programming error and causes a panic.
+func ApplyDefaults[T any](cfg *T) {
+	if cfg == nil {
+		return
+	}
+	v := reflect.ValueOf(cfg).Elem()
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	applyStructDefaults(v)
+}
+
+// applyStructDefaults fills defaults for v, recursing into nested structs.
+func applyStructDefaults(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+
+		if fv.Kind() == reflect.Struct {
+			applyStructDefaults(fv)
+			continue
+		}
+
+		def, ok := field.Tag.Lookup("default")
+		if !ok || !fv.IsZero() {
+			continue
+		}
+		if err := setFromString(fv, def); err != nil {
+			panic(fmt.Sprintf("config: invalid default %q for field %s: %v", def, field.Name, err))
+		}
+	}
+}
+
+// setFromString parses s into the field according to its type.
+func setFromString(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported kind %s", v.Kind())
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package concurrency
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testServiceConfig struct {
+	Endpoint string        `validate:"required,url"`
+	Workers  int           `default:"4" validate:"min=1,max=64"`
+	Timeout  time.Duration `default:"30s" validate:"max=5m"`
+	Tenant   string        `default:"anonymous"`
+	Retry    RetryConfig
+}
+
+func TestValidate_Rules(t *testing.T) {
+	valid := testServiceConfig{
+		Endpoint: "http://loki:3100",
+		Workers:  8,
+		Timeout:  time.Minute,
+		Retry:    DefaultRetryConfig(),
+	}
+
+	tests := []struct {
+		name    string
+		mutate  func(c *testServiceConfig)
+		wantErr string
+	}{
+		{name: "valid", mutate: func(c *testServiceConfig) {}},
+		{name: "missing required", mutate: func(c *testServiceConfig) { c.Endpoint = "" }, wantErr: "Endpoint is required"},
+		{name: "invalid url", mutate: func(c *testServiceConfig) { c.Endpoint = "loki:3100/api" }, wantErr: "Endpoint must be an absolute URL"},
+		{name: "below min", mutate: func(c *testServiceConfig) { c.Workers = 0 }, wantErr: "Workers must be at least 1"},
+		{name: "above max", mutate: func(c *testServiceConfig) { c.Workers = 100 }, wantErr: "Workers must be at most 64"},
+		{name: "duration above max", mutate: func(c *testServiceConfig) { c.Timeout = time.Hour }, wantErr: "Timeout must be at most 5m, got 1h0m0s"},
+		{name: "nested struct", mutate: func(c *testServiceConfig) { c.Retry.JitterFraction = 2 }, wantErr: "Retry.JitterFraction must be at most 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.mutate(&cfg)
+
+			err := Validate(cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("expected ErrInvalidConfig, got %v", err)
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidate_ReportsAllErrors(t *testing.T) {
+	err := Validate(&testServiceConfig{Retry: DefaultRetryConfig()})
+	if err == nil {
+		t.Fatal("expected errors for empty config")
+	}
+	for _, want := range []string{"Endpoint is required", "Workers must be at least 1"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to contain %q, got %v", want, err)
+		}
+	}
+}
+
+func TestApplyDefaults_RetryConfig(t *testing.T) {
+	cfg := RetryConfig{MaxRetries: 5, MaxBackoff: time.Second}
+	ApplyDefaults(&cfg)
+
+	if cfg.MaxRetries != 5 {
+		t.Errorf("expected MaxRetries to stay 5, got %d", cfg.MaxRetries)
+	}
+	if cfg.InitialBackoff != 100*time.Millisecond {
+		t.Errorf("expected InitialBackoff 100ms, got %v", cfg.InitialBackoff)
+	}
+	if cfg.MaxBackoff != time.Second {
+		t.Errorf("expected MaxBackoff to stay 1s, got %v", cfg.MaxBackoff)
+	}
+	if cfg.BackoffMultiplier != 2.0 {
+		t.Errorf("expected BackoffMultiplier 2.0, got %v", cfg.BackoffMultiplier)
+	}
+	if cfg.JitterFraction != 0 {
+		t.Errorf("expected JitterFraction to stay 0 (no jitter), got %v", cfg.JitterFraction)
+	}
+	if err := Validate(cfg); err != nil {
+		t.Errorf("expected defaulted config to be valid, got %v", err)
+	}
+}
+
+func TestApplyDefaults_CircuitBreakerConfig(t *testing.T) {
+	var cfg CircuitBreakerConfig
+	if err := Validate(cfg); err == nil {
+		t.Error("expected zero CircuitBreakerConfig to be invalid")
+	}
+
+	ApplyDefaults(&cfg)
+
+	defaults := DefaultCircuitBreakerConfig()
+	if cfg.FailureThreshold != defaults.FailureThreshold {
+		t.Errorf("expected FailureThreshold %d, got %d", defaults.FailureThreshold, cfg.FailureThreshold)
+	}
+	if cfg.SuccessThreshold != defaults.SuccessThreshold {
+		t.Errorf("expected SuccessThreshold %d, got %d", defaults.SuccessThreshold, cfg.SuccessThreshold)
+	}
+	if cfg.Timeout != defaults.Timeout {
+		t.Errorf("expected Timeout %v, got %v", defaults.Timeout, cfg.Timeout)
+	}
+	if err := Validate(cfg); err != nil {
+		t.Errorf("expected defaulted config to be valid, got %v", err)
+	}
+}
+
+func TestApplyDefaults_Nested(t *testing.T) {
+	var cfg testServiceConfig
+	ApplyDefaults(&cfg)
+
+	if cfg.Workers != 4 || cfg.Timeout != 30*time.Second || cfg.Tenant != "anonymous" {
+		t.Errorf("unexpected top-level defaults: %+v", cfg)
+	}
+	if cfg.Retry.InitialBackoff != 100*time.Millisecond {
+		t.Errorf("expected nested defaults to be applied, got %+v", cfg.Retry)
+	}
+}
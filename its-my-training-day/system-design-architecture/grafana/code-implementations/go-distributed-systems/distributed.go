@@ -12,6 +12,7 @@
 package concurrency
 
 import (
+	"container/list"
 	"context"
 	"errors"
 	"fmt"
@@ -26,6 +27,21 @@ import (
 // SECTION 1: Token Bucket Rate Limiter
 // =============================================================================
 
+// Clock tells the time. Components that accept one use time.Now by
+// default; tests and offline simulations inject a clock they advance by
+// hand, so time-dependent behavior is checked without sleeping.
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock is the Clock backed by time.Now.
+type realClock struct{}
+
+// Now returns the current time.
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
 // TokenBucketRateLimiter implements the token bucket algorithm for rate limiting.
 // This pattern is used extensively in Grafana services for:
 // - Limiting API request rates per tenant
@@ -47,7 +63,14 @@ type TokenBucketRateLimiter struct {
 	tokens     float64       // Current token count
 	refillRate float64       // Tokens added per second
 	lastRefill time.Time     // Last time tokens were added
+	clock      Clock         // Source of the current time for refills
 	mu         sync.Mutex    // Protects token state
+
+	// Warm-up ramp; warmUpDuration is 0 when not warming up
+	warmUpStart    time.Time
+	warmUpDuration time.Duration
+
+	metrics *limiterMetrics // Set by RegisterMetrics; nil if not instrumented
 }
 
 // NewTokenBucketRateLimiter creates a new rate limiter with the specified capacity
@@ -72,9 +95,38 @@ func NewTokenBucketRateLimiter(capacity, refillRate float64) *TokenBucketRateLim
 		tokens:     capacity, // Start with full bucket
 		refillRate: refillRate,
 		lastRefill: time.Now(),
+		clock:      realClock{},
 	}
 }
 
+// WithClock makes the limiter refill according to clock instead of
+// time.Now. The bucket is full as of clock's current time. Wait and WaitN
+// still poll in real time, so the clock must be advanced concurrently for
+// them to succeed. Must be called before the limiter is used.
+func (rl *TokenBucketRateLimiter) WithClock(clock Clock) *TokenBucketRateLimiter {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.clock = clock
+	rl.lastRefill = clock.Now()
+	return rl
+}
+
+// RegisterMetrics creates the limiter's metrics on registry, labelled
+// limiter=name:
+//   - rate_limiter_allowed_total: Allow, AllowN, Wait and WaitN calls that
+//     got their tokens
+//   - rate_limiter_rejected_total: Allow and AllowN calls that were refused,
+//     and Wait and WaitN calls whose context ended first
+//   - rate_limiter_wait_duration_seconds: time spent in Wait and WaitN
+//
+// Must be called before the limiter is used.
+func (rl *TokenBucketRateLimiter) RegisterMetrics(registry MetricRegistry, name string) {
+	metrics := newLimiterMetrics(registry, name)
+	metrics.waitDuration = registry.NewHistogram("rate_limiter_wait_duration_seconds",
+		"Time spent waiting in Wait for tokens.", DefaultWaitBuckets, map[string]string{"limiter": name})
+	rl.metrics = metrics
+}
+
 // Allow checks if a request should be allowed and consumes a token if so.
 // Returns true if the request is allowed, false if rate limited.
 // This is a non-blocking operation.
@@ -85,6 +137,29 @@ func (rl *TokenBucketRateLimiter) Allow() bool {
 // AllowN checks if n tokens are available and consumes them if so.
 // Useful for requests that consume different amounts of resources.
 func (rl *TokenBucketRateLimiter) AllowN(n float64) bool {
+	allowed := rl.take(n)
+	rl.metrics.record(allowed)
+	return allowed
+}
+
+// AllowAt is Allow as of time t rather than the clock's current time, for
+// replaying recorded traffic through the limiter. Calls must use
+// non-decreasing times; a t before the last refill adds no tokens.
+func (rl *TokenBucketRateLimiter) AllowAt(t time.Time) bool {
+	rl.mu.Lock()
+	rl.refillAt(t)
+	allowed := rl.tokens >= 1
+	if allowed {
+		rl.tokens--
+	}
+	rl.mu.Unlock()
+
+	rl.metrics.record(allowed)
+	return allowed
+}
+
+// take consumes n tokens if they are available, without recording metrics.
+func (rl *TokenBucketRateLimiter) take(n float64) bool {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
@@ -105,8 +180,11 @@ func (rl *TokenBucketRateLimiter) Wait(ctx context.Context) error {
 
 // WaitN blocks until n tokens are available or context is cancelled.
 func (rl *TokenBucketRateLimiter) WaitN(ctx context.Context, n float64) error {
+	start := time.Now()
+
 	// Fast path: try to acquire immediately
-	if rl.AllowN(n) {
+	if rl.take(n) {
+		rl.metrics.waited(0, true)
 		return nil
 	}
 
@@ -117,9 +195,11 @@ func (rl *TokenBucketRateLimiter) WaitN(ctx context.Context, n float64) error {
 	for {
 		select {
 		case <-ctx.Done():
+			rl.metrics.waited(time.Since(start), false)
 			return ctx.Err()
 		case <-ticker.C:
-			if rl.AllowN(n) {
+			if rl.take(n) {
+				rl.metrics.waited(time.Since(start), true)
 				return nil
 			}
 		}
@@ -129,12 +209,24 @@ func (rl *TokenBucketRateLimiter) WaitN(ctx context.Context, n float64) error {
 // refill adds tokens based on elapsed time since last refill.
 // Must be called with mutex held.
 func (rl *TokenBucketRateLimiter) refill() {
-	now := time.Now()
+	rl.refillAt(rl.clock.Now())
+}
+
+// refillAt adds the tokens earned between the last refill and now. Times
+// before the last refill are ignored. Must be called with mutex held.
+func (rl *TokenBucketRateLimiter) refillAt(now time.Time) {
+	if !now.After(rl.lastRefill) {
+		return
+	}
 	elapsed := now.Sub(rl.lastRefill).Seconds()
-	rl.lastRefill = now
 
 	// Add tokens based on elapsed time
-	rl.tokens += elapsed * rl.refillRate
+	if rl.warmUpDuration > 0 {
+		rl.tokens += rl.warmUpTokens(rl.lastRefill, now)
+	} else {
+		rl.tokens += elapsed * rl.refillRate
+	}
+	rl.lastRefill = now
 
 	// Cap at capacity
 	if rl.tokens > rl.capacity {
@@ -142,6 +234,43 @@ func (rl *TokenBucketRateLimiter) refill() {
 	}
 }
 
+// WarmUp empties the bucket and ramps the refill rate linearly from 0 to
+// the configured rate over duration, after which the limiter behaves
+// normally. Use it when a freshly started instance should not take full
+// traffic before its caches and connection pools are warm.
+func (rl *TokenBucketRateLimiter) WarmUp(duration time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.clock.Now()
+	rl.tokens = 0
+	rl.lastRefill = now
+	if duration > 0 {
+		rl.warmUpStart = now
+		rl.warmUpDuration = duration
+	}
+}
+
+// warmUpTokens returns the tokens earned between from and to while the
+// rate ramps up: the integral of refillRate * min(1, t/duration) dt.
+// It ends the warm-up once to is past the ramp. Must be called with mutex held.
+func (rl *TokenBucketRateLimiter) warmUpTokens(from, to time.Time) float64 {
+	d := rl.warmUpDuration.Seconds()
+	a := from.Sub(rl.warmUpStart).Seconds()
+	b := to.Sub(rl.warmUpStart).Seconds()
+
+	var tokens float64
+	if a < d {
+		rampEnd := math.Min(b, d)
+		tokens += rl.refillRate * (rampEnd*rampEnd - a*a) / (2 * d)
+	}
+	if b > d {
+		tokens += rl.refillRate * (b - math.Max(a, d))
+		rl.warmUpDuration = 0
+	}
+	return tokens
+}
+
 // Tokens returns the current number of available tokens.
 // Useful for monitoring and debugging.
 func (rl *TokenBucketRateLimiter) Tokens() float64 {
@@ -160,6 +289,49 @@ func (rl *TokenBucketRateLimiter) SetRate(newRate float64) {
 	rl.refillRate = newRate
 }
 
+// limiterMetrics holds the metrics registered by a rate limiter's
+// RegisterMetrics. Its methods are no-ops on a nil receiver.
+type limiterMetrics struct {
+	allowed      Counter
+	rejected     Counter
+	waitDuration Histogram // nil for limiters without Wait
+}
+
+// newLimiterMetrics creates the counters shared by every rate limiter,
+// labelled limiter=name.
+func newLimiterMetrics(registry MetricRegistry, name string) *limiterMetrics {
+	labels := map[string]string{"limiter": name}
+	return &limiterMetrics{
+		allowed: registry.NewCounter("rate_limiter_allowed_total",
+			"Total number of requests the rate limiter allowed.", labels),
+		rejected: registry.NewCounter("rate_limiter_rejected_total",
+			"Total number of requests the rate limiter rejected.", labels),
+	}
+}
+
+// record counts one Allow decision.
+func (m *limiterMetrics) record(allowed bool) {
+	if m == nil {
+		return
+	}
+	if allowed {
+		m.allowed.Add(1)
+	} else {
+		m.rejected.Add(1)
+	}
+}
+
+// waited records a Wait call that ended after wait, successfully or not.
+func (m *limiterMetrics) waited(wait time.Duration, allowed bool) {
+	if m == nil {
+		return
+	}
+	m.record(allowed)
+	if m.waitDuration != nil {
+		m.waitDuration.Observe(wait.Seconds())
+	}
+}
+
 // =============================================================================
 // SECTION 2: Circuit Breaker Pattern
 // =============================================================================
@@ -193,13 +365,38 @@ func (s CircuitState) String() string {
 // CircuitBreakerConfig holds configuration for the circuit breaker.
 type CircuitBreakerConfig struct {
 	// FailureThreshold is the number of failures before opening the circuit
-	FailureThreshold int
+	FailureThreshold int `default:"5" validate:"min=1"`
 	// SuccessThreshold is the number of successes in half-open state to close
-	SuccessThreshold int
+	SuccessThreshold int `default:"2" validate:"min=1"`
 	// Timeout is how long to wait before transitioning from open to half-open
-	Timeout time.Duration
+	Timeout time.Duration `default:"30s" validate:"min=1"`
 	// MaxConcurrent limits concurrent requests in half-open state (0 = no limit)
-	MaxConcurrent int
+	MaxConcurrent int `validate:"min=0"`
+	// MaxEventLog is the number of state transitions kept for EventLog
+	MaxEventLog int `default:"100" validate:"min=1"`
+	// MinimumRequestCount is the number of requests that must be seen within
+	// the current RequestWindow before the circuit may open (0 = no minimum).
+	// This stops a handful of failures after a quiet period from tripping
+	// the breaker.
+	MinimumRequestCount int `validate:"min=0"`
+	// RequestWindow is the fixed window MinimumRequestCount is counted in;
+	// the count starts over each window and whenever the circuit closes
+	RequestWindow time.Duration `default:"1m" validate:"min=0"`
+	// TimeoutMultiplier grows Timeout after each consecutive failed
+	// half-open attempt: the n-th retry waits Timeout * TimeoutMultiplier^n.
+	// Values <= 1 keep the timeout fixed.
+	TimeoutMultiplier float64 `validate:"min=0"`
+	// MaxTimeout caps the grown timeout (0 = no cap)
+	MaxTimeout time.Duration `validate:"min=0"`
+}
+
+// CircuitBreakerEvent records a single state transition.
+type CircuitBreakerEvent struct {
+	Timestamp time.Time
+	From      CircuitState
+	To        CircuitState
+	// Failures is the consecutive failure count at the time of the transition
+	Failures int
 }
 
 // DefaultCircuitBreakerConfig returns sensible defaults for most use cases.
@@ -209,6 +406,7 @@ func DefaultCircuitBreakerConfig() CircuitBreakerConfig {
 		SuccessThreshold: 2,
 		Timeout:          30 * time.Second,
 		MaxConcurrent:    1,
+		MaxEventLog:      100,
 	}
 }
 
@@ -238,8 +436,19 @@ type CircuitBreaker struct {
 	successes       int32     // Atomic: consecutive success count in half-open
 	lastFailureTime time.Time // Time of last failure
 	halfOpenCount   int32     // Atomic: current requests in half-open state
+	reopenCount     int32     // Atomic: consecutive half-open failures
+	executions      int64     // Atomic: requests run since creation
+
+	// requests counts closed-state requests since requestWindowStart
+	requests           int64
+	requestWindowStart time.Time
+
+	// events is a circular buffer of the most recent state transitions;
+	// eventStart is the index of the oldest event once the buffer is full
+	events     []CircuitBreakerEvent
+	eventStart int
 
-	mu sync.RWMutex // Protects lastFailureTime
+	mu sync.RWMutex // Protects lastFailureTime, the request window and events
 
 	// Callbacks for monitoring
 	onStateChange func(from, to CircuitState)
@@ -256,10 +465,17 @@ func NewCircuitBreaker(config CircuitBreakerConfig) *CircuitBreaker {
 	if config.Timeout <= 0 {
 		config.Timeout = 30 * time.Second
 	}
+	if config.MaxEventLog <= 0 {
+		config.MaxEventLog = 100
+	}
+	if config.RequestWindow <= 0 {
+		config.RequestWindow = time.Minute
+	}
 
 	return &CircuitBreaker{
 		config: config,
 		state:  int32(CircuitClosed),
+		events: make([]CircuitBreakerEvent, 0, config.MaxEventLog),
 	}
 }
 
@@ -313,7 +529,7 @@ func (cb *CircuitBreaker) beforeRequest() error {
 		lastFailure := cb.lastFailureTime
 		cb.mu.RUnlock()
 
-		if time.Since(lastFailure) >= cb.config.Timeout {
+		if time.Since(lastFailure) >= cb.openTimeout() {
 			// Transition to half-open
 			if atomic.CompareAndSwapInt32(&cb.state, int32(CircuitOpen), int32(CircuitHalfOpen)) {
 				atomic.StoreInt32(&cb.successes, 0)
@@ -342,6 +558,7 @@ func (cb *CircuitBreaker) beforeRequest() error {
 // afterRequest records the result and updates state.
 func (cb *CircuitBreaker) afterRequest(err error) {
 	state := CircuitState(atomic.LoadInt32(&cb.state))
+	atomic.AddInt64(&cb.executions, 1)
 
 	// Decrement half-open counter if applicable
 	if state == CircuitHalfOpen && cb.config.MaxConcurrent > 0 {
@@ -355,18 +572,51 @@ func (cb *CircuitBreaker) afterRequest(err error) {
 	}
 }
 
+// openTimeout returns how long the circuit stays open before the next
+// half-open attempt, backing off exponentially after failed attempts.
+func (cb *CircuitBreaker) openTimeout() time.Duration {
+	timeout := cb.config.Timeout
+	reopens := atomic.LoadInt32(&cb.reopenCount)
+	if cb.config.TimeoutMultiplier <= 1 || reopens == 0 {
+		return timeout
+	}
+
+	grown := float64(timeout) * math.Pow(cb.config.TimeoutMultiplier, float64(reopens))
+	if cb.config.MaxTimeout > 0 && grown > float64(cb.config.MaxTimeout) {
+		return cb.config.MaxTimeout
+	}
+	if grown > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(grown)
+}
+
+// RecordFailure records a failure observed outside Execute, such as an
+// unhealthy result from an external health check, so that a health signal
+// can open the breaker without it making real requests.
+func (cb *CircuitBreaker) RecordFailure() {
+	cb.recordFailure()
+}
+
+// RecordSuccess records a success observed outside Execute.
+func (cb *CircuitBreaker) RecordSuccess() {
+	cb.recordSuccess()
+}
+
 // recordFailure handles a failed request.
 func (cb *CircuitBreaker) recordFailure() {
 	state := CircuitState(atomic.LoadInt32(&cb.state))
+	now := time.Now()
 
 	cb.mu.Lock()
-	cb.lastFailureTime = time.Now()
+	cb.lastFailureTime = now
 	cb.mu.Unlock()
 
 	switch state {
 	case CircuitClosed:
+		requests := cb.countRequest(now)
 		failures := atomic.AddInt32(&cb.failures, 1)
-		if int(failures) >= cb.config.FailureThreshold {
+		if int(failures) >= cb.config.FailureThreshold && requests >= int64(cb.config.MinimumRequestCount) {
 			if atomic.CompareAndSwapInt32(&cb.state, int32(CircuitClosed), int32(CircuitOpen)) {
 				cb.notifyStateChange(CircuitClosed, CircuitOpen)
 			}
@@ -376,6 +626,7 @@ func (cb *CircuitBreaker) recordFailure() {
 		// Any failure in half-open goes back to open
 		if atomic.CompareAndSwapInt32(&cb.state, int32(CircuitHalfOpen), int32(CircuitOpen)) {
 			atomic.StoreInt32(&cb.failures, int32(cb.config.FailureThreshold))
+			atomic.AddInt32(&cb.reopenCount, 1)
 			cb.notifyStateChange(CircuitHalfOpen, CircuitOpen)
 		}
 	}
@@ -387,6 +638,7 @@ func (cb *CircuitBreaker) recordSuccess() {
 
 	switch state {
 	case CircuitClosed:
+		cb.countRequest(time.Now())
 		// Reset failure count on success
 		atomic.StoreInt32(&cb.failures, 0)
 
@@ -396,14 +648,61 @@ func (cb *CircuitBreaker) recordSuccess() {
 			if atomic.CompareAndSwapInt32(&cb.state, int32(CircuitHalfOpen), int32(CircuitClosed)) {
 				atomic.StoreInt32(&cb.failures, 0)
 				atomic.StoreInt32(&cb.successes, 0)
+				atomic.StoreInt32(&cb.reopenCount, 0)
+				cb.resetRequestWindow()
 				cb.notifyStateChange(CircuitHalfOpen, CircuitClosed)
 			}
 		}
 	}
 }
 
-// notifyStateChange calls the state change callback if set.
+// countRequest adds a closed-state request to the current request window,
+// starting a new window if the last one has elapsed, and returns the
+// window's request count.
+func (cb *CircuitBreaker) countRequest(now time.Time) int64 {
+	if cb.config.MinimumRequestCount == 0 {
+		return 0
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if now.Sub(cb.requestWindowStart) >= cb.config.RequestWindow {
+		cb.requestWindowStart = now
+		cb.requests = 0
+	}
+	cb.requests++
+	return cb.requests
+}
+
+// resetRequestWindow discards the requests counted so far.
+func (cb *CircuitBreaker) resetRequestWindow() {
+	cb.mu.Lock()
+	cb.requests = 0
+	cb.requestWindowStart = time.Time{}
+	cb.mu.Unlock()
+}
+
+// notifyStateChange records the transition and calls the state change
+// callback if set.
 func (cb *CircuitBreaker) notifyStateChange(from, to CircuitState) {
+	event := CircuitBreakerEvent{
+		Timestamp: time.Now(),
+		From:      from,
+		To:        to,
+		Failures:  int(atomic.LoadInt32(&cb.failures)),
+	}
+
+	cb.mu.Lock()
+	if len(cb.events) < cb.config.MaxEventLog {
+		cb.events = append(cb.events, event)
+	} else {
+		// Buffer is full: overwrite the oldest event
+		cb.events[cb.eventStart] = event
+		cb.eventStart = (cb.eventStart + 1) % len(cb.events)
+	}
+	cb.mu.Unlock()
+
 	if cb.onStateChange != nil {
 		cb.onStateChange(from, to)
 	}
@@ -425,12 +724,53 @@ func (cb *CircuitBreaker) Failures() int {
 	return int(atomic.LoadInt32(&cb.failures))
 }
 
+// CircuitBreakerMetrics is a point-in-time snapshot of a circuit breaker,
+// for monitoring systems that poll rather than register callbacks.
+type CircuitBreakerMetrics struct {
+	State            CircuitState
+	Failures         int       // Consecutive failures
+	Successes        int       // Consecutive successes in half-open state
+	HalfOpenInFlight int       // Requests running in half-open state (tracked when MaxConcurrent > 0)
+	LastFailure      time.Time // Zero if no request has failed
+	TotalExecutions  int64     // Requests run through the breaker, excluding rejected ones
+}
+
+// Metrics returns a snapshot of the breaker's current state and counters.
+func (cb *CircuitBreaker) Metrics() CircuitBreakerMetrics {
+	cb.mu.RLock()
+	lastFailure := cb.lastFailureTime
+	cb.mu.RUnlock()
+
+	return CircuitBreakerMetrics{
+		State:            CircuitState(atomic.LoadInt32(&cb.state)),
+		Failures:         int(atomic.LoadInt32(&cb.failures)),
+		Successes:        int(atomic.LoadInt32(&cb.successes)),
+		HalfOpenInFlight: int(atomic.LoadInt32(&cb.halfOpenCount)),
+		LastFailure:      lastFailure,
+		TotalExecutions:  atomic.LoadInt64(&cb.executions),
+	}
+}
+
+// EventLog returns the most recent state transitions, oldest first.
+// At most MaxEventLog events are kept.
+func (cb *CircuitBreaker) EventLog() []CircuitBreakerEvent {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	events := make([]CircuitBreakerEvent, 0, len(cb.events))
+	events = append(events, cb.events[cb.eventStart:]...)
+	events = append(events, cb.events[:cb.eventStart]...)
+	return events
+}
+
 // Reset manually resets the circuit breaker to closed state.
 // Use with caution - typically for administrative purposes.
 func (cb *CircuitBreaker) Reset() {
 	oldState := CircuitState(atomic.SwapInt32(&cb.state, int32(CircuitClosed)))
 	atomic.StoreInt32(&cb.failures, 0)
 	atomic.StoreInt32(&cb.successes, 0)
+	atomic.StoreInt32(&cb.reopenCount, 0)
+	cb.resetRequestWindow()
 	if oldState != CircuitClosed {
 		cb.notifyStateChange(oldState, CircuitClosed)
 	}
@@ -443,23 +783,38 @@ func (cb *CircuitBreaker) Reset() {
 // RetryConfig holds configuration for retry behavior.
 type RetryConfig struct {
 	// MaxRetries is the maximum number of retry attempts (0 = no retries)
-	MaxRetries int
+	MaxRetries int `validate:"min=0"`
 	// InitialBackoff is the delay before the first retry
-	InitialBackoff time.Duration
+	InitialBackoff time.Duration `default:"100ms" validate:"min=1"`
 	// MaxBackoff caps the maximum delay between retries
-	MaxBackoff time.Duration
+	MaxBackoff time.Duration `default:"30s" validate:"min=1"`
 	// BackoffMultiplier increases delay exponentially (typically 2.0)
-	BackoffMultiplier float64
+	BackoffMultiplier float64 `default:"2.0" validate:"min=1"`
 	// JitterFraction adds randomness to prevent thundering herd (0.0-1.0)
-	JitterFraction float64
+	JitterFraction float64 `validate:"min=0,max=1"`
+	// MaxDuration bounds the total time spent across all attempts and
+	// backoff waits (0 = no limit). Attempts run with a context that
+	// expires at the deadline, and no retry starts after it
+	MaxDuration time.Duration `validate:"min=0"`
 	// RetryableErrors defines which errors should trigger a retry
 	// If nil, all errors are retryable
 	RetryableErrors []error
 	// IsRetryable is a custom function to determine if an error is retryable
 	// Takes precedence over RetryableErrors if set
 	IsRetryable func(error) bool
+	// SuccessPredicate reports whether a result returned with a nil error is
+	// actually a success. Used by DoWithResult; results that fail the
+	// predicate are retried as ErrPredicateNotMet
+	SuccessPredicate func(result interface{}) bool
+	// Budget caps retries across all Retryers sharing it (nil = no cap).
+	// Once it is exhausted, failed attempts are no longer retried
+	Budget *RetryBudget
 }
 
+// ErrPredicateNotMet is returned by DoWithResult when the function kept
+// succeeding but its result never satisfied RetryConfig.SuccessPredicate.
+var ErrPredicateNotMet = errors.New("retry: result did not satisfy success predicate")
+
 // DefaultRetryConfig returns sensible defaults for most use cases.
 func DefaultRetryConfig() RetryConfig {
 	return RetryConfig{
@@ -539,11 +894,20 @@ func (r *Retryer) DoWithContext(ctx context.Context, fn func(context.Context) er
 	start := time.Now()
 	result := RetryResult{}
 
+	attemptCtx := ctx
+	var deadline time.Time
+	if r.config.MaxDuration > 0 {
+		deadline = start.Add(r.config.MaxDuration)
+		var cancel context.CancelFunc
+		attemptCtx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+
 	for attempt := 0; attempt <= r.config.MaxRetries; attempt++ {
 		result.Attempts = attempt + 1
 
 		// Execute the function
-		err := fn(ctx)
+		err := fn(attemptCtx)
 		if err == nil {
 			result.Duration = time.Since(start)
 			return result, nil
@@ -563,6 +927,16 @@ func (r *Retryer) DoWithContext(ctx context.Context, fn func(context.Context) er
 		// Calculate backoff with jitter
 		backoff := r.calculateBackoff(attempt)
 
+		// Give up if the next attempt would start after the deadline
+		if !deadline.IsZero() && time.Now().Add(backoff).After(deadline) {
+			break
+		}
+
+		// Shed the retry if the shared budget has run out
+		if r.config.Budget != nil && !r.config.Budget.spend() {
+			break
+		}
+
 		// Wait for backoff or context cancellation
 		select {
 		case <-ctx.Done():
@@ -578,12 +952,48 @@ func (r *Retryer) DoWithContext(ctx context.Context, fn func(context.Context) er
 	return result, result.LastError
 }
 
+// DoWithResult executes fn with retry logic and returns its last result.
+// If fn returns a nil error but the result fails RetryConfig.SuccessPredicate,
+// the attempt is retried as a failure. This handles protocols that report
+// errors in the response body, e.g. an HTTP 200 with {"status": "pending"}.
+//
+// DoWithResult is a function rather than a method because Go methods
+// cannot have type parameters.
+func DoWithResult[T any](r *Retryer, fn func() (T, error)) (T, RetryResult, error) {
+	return DoWithResultContext(r, context.Background(), func(ctx context.Context) (T, error) {
+		return fn()
+	})
+}
+
+// DoWithResultContext is DoWithResult with context support. The context is
+// passed to fn and cancels the backoff waits, as in DoWithContext.
+func DoWithResultContext[T any](r *Retryer, ctx context.Context, fn func(context.Context) (T, error)) (T, RetryResult, error) {
+	var last T
+	result, err := r.DoWithContext(ctx, func(ctx context.Context) error {
+		value, err := fn(ctx)
+		last = value
+		if err != nil {
+			return err
+		}
+		if r.config.SuccessPredicate != nil && !r.config.SuccessPredicate(value) {
+			return ErrPredicateNotMet
+		}
+		return nil
+	})
+	return last, result, err
+}
+
 // isRetryable determines if an error should trigger a retry.
 func (r *Retryer) isRetryable(err error) bool {
 	if err == nil {
 		return false
 	}
 
+	// Unmet success predicates are always retried
+	if errors.Is(err, ErrPredicateNotMet) {
+		return true
+	}
+
 	// Custom function takes precedence
 	if r.config.IsRetryable != nil {
 		return r.config.IsRetryable(err)
@@ -624,6 +1034,306 @@ func (r *Retryer) calculateBackoff(attempt int) time.Duration {
 	return time.Duration(backoff)
 }
 
+// RetryBudget caps the number of retries across every Retryer that shares
+// it, so that a widespread outage doesn't multiply load on the failing
+// dependency by MaxRetries. Each retry spends one token; the budget refills
+// to its full size at the start of every window.
+type RetryBudget struct {
+	max         int
+	window      time.Duration
+	tokens      int
+	windowStart time.Time
+	onExhausted func()
+	mu          sync.Mutex
+}
+
+// NewRetryBudget creates a budget allowing maxRetries retries per window.
+func NewRetryBudget(maxRetries int, window time.Duration) *RetryBudget {
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	if window <= 0 {
+		window = time.Minute
+	}
+	return &RetryBudget{
+		max:         maxRetries,
+		window:      window,
+		tokens:      maxRetries,
+		windowStart: time.Now(),
+	}
+}
+
+// OnExhausted registers fn to be called when the budget drops from one
+// token to zero, e.g. to log or alert that retries are being shed. It is
+// called at most once per window, outside the budget's lock.
+func (b *RetryBudget) OnExhausted(fn func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onExhausted = fn
+}
+
+// Remaining returns the number of retries left in the current window.
+func (b *RetryBudget) Remaining() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(time.Now())
+	return b.tokens
+}
+
+// spend takes a token for one retry, returning false if none are left.
+func (b *RetryBudget) spend() bool {
+	b.mu.Lock()
+	b.refill(time.Now())
+	if b.tokens == 0 {
+		b.mu.Unlock()
+		return false
+	}
+	b.tokens--
+	var exhausted func()
+	if b.tokens == 0 {
+		exhausted = b.onExhausted
+	}
+	b.mu.Unlock()
+
+	if exhausted != nil {
+		exhausted()
+	}
+	return true
+}
+
+// refill resets the tokens once the current window has passed.
+// b.mu must be held.
+func (b *RetryBudget) refill(now time.Time) {
+	if now.Sub(b.windowStart) >= b.window {
+		b.tokens = b.max
+		b.windowStart = now
+	}
+}
+
+// AdaptiveRetryConfig configures an AdaptiveRetryer.
+type AdaptiveRetryConfig struct {
+	// Retry is the configuration used while the error rate is healthy
+	Retry RetryConfig
+	// Window is the sliding window over which the error rate is measured
+	Window time.Duration `default:"1m" validate:"min=1"`
+	// HighErrorRate is the error rate above which retries are reduced
+	HighErrorRate float64 `default:"0.5" validate:"min=0,max=1"`
+	// RecoveryErrorRate is the error rate below which the original
+	// MaxRetries is restored. Keep it below HighErrorRate to avoid flapping
+	RecoveryErrorRate float64 `default:"0.2" validate:"min=0,max=1"`
+	// ReducedMaxRetries is the retry limit while the error rate is high
+	ReducedMaxRetries int `default:"1" validate:"min=0"`
+	// MinRequests is the number of attempts in the window required before
+	// the error rate is acted on
+	MinRequests int `default:"10" validate:"min=1"`
+}
+
+// DefaultAdaptiveRetryConfig returns a config that drops to a single retry
+// when more than half of the attempts in the last minute failed, and
+// restores DefaultRetryConfig once fewer than 20% fail.
+func DefaultAdaptiveRetryConfig() AdaptiveRetryConfig {
+	return AdaptiveRetryConfig{
+		Retry:             DefaultRetryConfig(),
+		Window:            time.Minute,
+		HighErrorRate:     0.5,
+		RecoveryErrorRate: 0.2,
+		ReducedMaxRetries: 1,
+		MinRequests:       10,
+	}
+}
+
+// AdaptiveRetryStats is a snapshot of an AdaptiveRetryer's state.
+type AdaptiveRetryStats struct {
+	// ErrorRate is the fraction of failed attempts in the window
+	ErrorRate float64
+	// Attempts is the number of attempts in the window
+	Attempts int
+	// EffectiveMaxRetries is the retry limit currently applied
+	EffectiveMaxRetries int
+	// Reduced is true while retries are reduced because of a high error rate
+	Reduced bool
+}
+
+// AdaptiveRetryer wraps a Retryer and lowers MaxRetries while the
+// downstream error rate is high. Fixed retry counts multiply load on a
+// service that is already overloaded: with MaxRetries=3, a fully failing
+// backend receives four times its normal traffic. Retries are a good
+// investment for isolated transient failures, not for widespread ones.
+//
+// The error rate is measured per attempt over a sliding window, and the two
+// thresholds give hysteresis so the limit does not flap around one value.
+// The window is split into adaptiveRetryBuckets fixed buckets with running
+// totals, so recording an attempt takes constant time and memory however
+// much traffic passes through.
+type AdaptiveRetryer struct {
+	config  AdaptiveRetryConfig
+	normal  *Retryer
+	reduced *Retryer
+
+	buckets     [adaptiveRetryBuckets]outcomeBucket // Ring of sub-windows
+	bucketWidth time.Duration
+	attempts    int // Attempts across all buckets
+	failed      int // Failed attempts across all buckets
+	isReduced   bool
+	mu          sync.Mutex
+}
+
+// adaptiveRetryBuckets is the number of buckets the window is split into.
+const adaptiveRetryBuckets = 10
+
+// outcomeBucket counts the attempts made in one sub-window.
+type outcomeBucket struct {
+	start    time.Time
+	attempts int
+	failed   int
+}
+
+// NewAdaptiveRetryer creates an adaptive retryer.
+func NewAdaptiveRetryer(config AdaptiveRetryConfig) *AdaptiveRetryer {
+	if config.Window <= 0 {
+		config.Window = time.Minute
+	}
+	if config.HighErrorRate <= 0 || config.HighErrorRate > 1 {
+		config.HighErrorRate = 0.5
+	}
+	if config.RecoveryErrorRate < 0 || config.RecoveryErrorRate > config.HighErrorRate {
+		config.RecoveryErrorRate = config.HighErrorRate
+	}
+	if config.ReducedMaxRetries < 0 {
+		config.ReducedMaxRetries = 0
+	}
+	if config.MinRequests <= 0 {
+		config.MinRequests = 1
+	}
+
+	normal := NewRetryer(config.Retry)
+	reducedConfig := normal.config
+	if config.ReducedMaxRetries < reducedConfig.MaxRetries {
+		reducedConfig.MaxRetries = config.ReducedMaxRetries
+	}
+
+	bucketWidth := config.Window / adaptiveRetryBuckets
+	if bucketWidth <= 0 {
+		bucketWidth = 1
+	}
+
+	return &AdaptiveRetryer{
+		config:      config,
+		normal:      normal,
+		reduced:     NewRetryer(reducedConfig),
+		bucketWidth: bucketWidth,
+	}
+}
+
+// Do executes fn with the currently effective retry limit.
+func (a *AdaptiveRetryer) Do(fn func() error) (RetryResult, error) {
+	return a.DoWithContext(context.Background(), func(ctx context.Context) error {
+		return fn()
+	})
+}
+
+// DoWithContext executes fn with the currently effective retry limit and
+// records the outcome of every attempt. The limit is chosen when the call
+// starts and is not changed mid-call.
+func (a *AdaptiveRetryer) DoWithContext(ctx context.Context, fn func(context.Context) error) (RetryResult, error) {
+	a.mu.Lock()
+	retryer := a.normal
+	if a.isReduced {
+		retryer = a.reduced
+	}
+	a.mu.Unlock()
+
+	return retryer.DoWithContext(ctx, func(ctx context.Context) error {
+		err := fn(ctx)
+		a.record(err != nil)
+		return err
+	})
+}
+
+// record adds an attempt outcome and re-evaluates the retry limit.
+func (a *AdaptiveRetryer) record(failed bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	a.prune(now)
+
+	b := a.bucketFor(now)
+	b.attempts++
+	a.attempts++
+	if failed {
+		b.failed++
+		a.failed++
+	}
+
+	if a.attempts < a.config.MinRequests {
+		return
+	}
+	rate := a.errorRate()
+	switch {
+	case !a.isReduced && rate > a.config.HighErrorRate:
+		a.isReduced = true
+	case a.isReduced && rate < a.config.RecoveryErrorRate:
+		a.isReduced = false
+	}
+}
+
+// bucketFor returns the bucket covering now, clearing it first if it still
+// holds a previous sub-window's counts. Caller must hold a.mu.
+func (a *AdaptiveRetryer) bucketFor(now time.Time) *outcomeBucket {
+	start := now.Truncate(a.bucketWidth)
+	b := &a.buckets[(start.UnixNano()/int64(a.bucketWidth))%adaptiveRetryBuckets]
+	if !b.start.Equal(start) {
+		a.clearBucket(b)
+		b.start = start
+	}
+	return b
+}
+
+// prune clears buckets that have left the window. Caller must hold a.mu.
+func (a *AdaptiveRetryer) prune(now time.Time) {
+	for i := range a.buckets {
+		b := &a.buckets[i]
+		if !b.start.IsZero() && now.Sub(b.start) >= a.config.Window {
+			a.clearBucket(b)
+		}
+	}
+}
+
+// clearBucket removes a bucket's counts from the running totals.
+// Caller must hold a.mu.
+func (a *AdaptiveRetryer) clearBucket(b *outcomeBucket) {
+	a.attempts -= b.attempts
+	a.failed -= b.failed
+	*b = outcomeBucket{}
+}
+
+// errorRate returns the fraction of failed attempts. Caller must hold a.mu.
+func (a *AdaptiveRetryer) errorRate() float64 {
+	if a.attempts == 0 {
+		return 0
+	}
+	return float64(a.failed) / float64(a.attempts)
+}
+
+// Stats returns the current error rate and effective retry limit.
+func (a *AdaptiveRetryer) Stats() AdaptiveRetryStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.prune(time.Now())
+	stats := AdaptiveRetryStats{
+		ErrorRate:           a.errorRate(),
+		Attempts:            a.attempts,
+		EffectiveMaxRetries: a.normal.config.MaxRetries,
+		Reduced:             a.isReduced,
+	}
+	if a.isReduced {
+		stats.EffectiveMaxRetries = a.reduced.config.MaxRetries
+	}
+	return stats
+}
+
 // =============================================================================
 // SECTION 4: Combined Resilience Pattern
 // =============================================================================
@@ -779,6 +1489,8 @@ type SlidingWindowRateLimiter struct {
 	maxRequests int
 	requests   []time.Time
 	mu         sync.Mutex
+
+	metrics *limiterMetrics // Set by RegisterMetrics; nil if not instrumented
 }
 
 // NewSlidingWindowRateLimiter creates a new sliding window rate limiter.
@@ -799,8 +1511,22 @@ func NewSlidingWindowRateLimiter(windowSize time.Duration, maxRequests int) *Sli
 	}
 }
 
+// RegisterMetrics creates the limiter's rate_limiter_allowed_total and
+// rate_limiter_rejected_total counters on registry, labelled limiter=name,
+// counting Allow decisions. Must be called before the limiter is used.
+func (rl *SlidingWindowRateLimiter) RegisterMetrics(registry MetricRegistry, name string) {
+	rl.metrics = newLimiterMetrics(registry, name)
+}
+
 // Allow checks if a request should be allowed.
 func (rl *SlidingWindowRateLimiter) Allow() bool {
+	allowed := rl.allow()
+	rl.metrics.record(allowed)
+	return allowed
+}
+
+// allow records a request if the window has room.
+func (rl *SlidingWindowRateLimiter) allow() bool {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
@@ -830,8 +1556,24 @@ func (rl *SlidingWindowRateLimiter) Allow() bool {
 func (rl *SlidingWindowRateLimiter) RequestsInWindow() int {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
+	return rl.countInWindow(time.Now())
+}
 
-	now := time.Now()
+// WouldAllow reports whether Allow would accept a request right now,
+// without recording one. Useful for dashboards and for load balancers that
+// want to skip a backend that is already at its limit.
+//
+// The answer is only a snapshot: a concurrent Allow may consume the last
+// slot between WouldAllow and a subsequent Allow.
+func (rl *SlidingWindowRateLimiter) WouldAllow() bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.countInWindow(time.Now()) < rl.maxRequests
+}
+
+// countInWindow counts requests newer than now - windowSize.
+// Caller must hold rl.mu.
+func (rl *SlidingWindowRateLimiter) countInWindow(now time.Time) int {
 	windowStart := now.Add(-rl.windowSize)
 
 	count := 0
@@ -842,3 +1584,122 @@ func (rl *SlidingWindowRateLimiter) RequestsInWindow() int {
 	}
 	return count
 }
+
+// =============================================================================
+// SECTION 7: Composite Rate Limiter (Per-Tenant + Global)
+// =============================================================================
+
+// DefaultMaxTenants is the number of per-tenant buckets a
+// CompositeRateLimiter keeps before evicting the least recently used one.
+const DefaultMaxTenants = 10000
+
+// CompositeRateLimiter combines a token bucket per tenant (fairness) with a
+// global token bucket (resource protection). A request is allowed only when
+// both the tenant's bucket and the global bucket have a token; if either
+// refuses, neither is charged.
+//
+// This mirrors how Loki and Mimir apply per-tenant ingestion limits beneath
+// a cluster-wide ceiling.
+//
+// Tenant buckets are created on first use and evicted in LRU order once
+// there are more than maxTenants. An evicted tenant starts again with a full
+// bucket, so the limit should be large enough to cover all active tenants.
+type CompositeRateLimiter struct {
+	global            *TokenBucketRateLimiter
+	perTenantCapacity float64
+	perTenantRate     float64
+	maxTenants        int
+
+	tenants map[string]*list.Element // Values are *tenantBucket
+	lru     *list.List               // Front is most recently used
+	mu      sync.Mutex
+}
+
+// tenantBucket is an entry in the CompositeRateLimiter LRU list.
+type tenantBucket struct {
+	tenantID string
+	limiter  *TokenBucketRateLimiter
+}
+
+// NewCompositeRateLimiter creates a limiter with a global bucket and
+// lazily created per-tenant buckets. Capacities and rates follow
+// NewTokenBucketRateLimiter.
+func NewCompositeRateLimiter(globalCapacity, globalRate, perTenantCapacity, perTenantRate float64) *CompositeRateLimiter {
+	return &CompositeRateLimiter{
+		global:            NewTokenBucketRateLimiter(globalCapacity, globalRate),
+		perTenantCapacity: perTenantCapacity,
+		perTenantRate:     perTenantRate,
+		maxTenants:        DefaultMaxTenants,
+		tenants:           make(map[string]*list.Element),
+		lru:               list.New(),
+	}
+}
+
+// WithMaxTenants sets how many tenant buckets are kept before LRU eviction.
+func (c *CompositeRateLimiter) WithMaxTenants(n int) *CompositeRateLimiter {
+	if n <= 0 {
+		n = 1
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxTenants = n
+	c.evict()
+	return c
+}
+
+// Allow reports whether a request from tenantID is allowed, consuming one
+// token from both the tenant's bucket and the global bucket if so.
+func (c *CompositeRateLimiter) Allow(tenantID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tenant := c.tenantLimiter(tenantID)
+
+	tenant.mu.Lock()
+	defer tenant.mu.Unlock()
+	c.global.mu.Lock()
+	defer c.global.mu.Unlock()
+
+	tenant.refill()
+	c.global.refill()
+	if tenant.tokens < 1 || c.global.tokens < 1 {
+		return false
+	}
+	tenant.tokens--
+	c.global.tokens--
+	return true
+}
+
+// Tenants returns the number of tenant buckets currently tracked.
+func (c *CompositeRateLimiter) Tenants() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// tenantLimiter returns the bucket for tenantID, creating it if needed and
+// marking it most recently used. Caller must hold c.mu.
+func (c *CompositeRateLimiter) tenantLimiter(tenantID string) *TokenBucketRateLimiter {
+	if elem, ok := c.tenants[tenantID]; ok {
+		c.lru.MoveToFront(elem)
+		return elem.Value.(*tenantBucket).limiter
+	}
+
+	bucket := &tenantBucket{
+		tenantID: tenantID,
+		limiter:  NewTokenBucketRateLimiter(c.perTenantCapacity, c.perTenantRate),
+	}
+	c.tenants[tenantID] = c.lru.PushFront(bucket)
+	c.evict()
+	return bucket.limiter
+}
+
+// evict removes least recently used tenants beyond maxTenants.
+// Caller must hold c.mu.
+func (c *CompositeRateLimiter) evict() {
+	for c.lru.Len() > c.maxTenants {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.tenants, oldest.Value.(*tenantBucket).tenantID)
+	}
+}
@@ -3,7 +3,9 @@ package concurrency
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"math"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -48,6 +50,121 @@ func TestTokenBucketRateLimiter_Refill(t *testing.T) {
 	}
 }
 
+// fakeClock is a Clock that only moves when advanced.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestTokenBucketRateLimiter_WithClock(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewTokenBucketRateLimiter(10, 2).WithClock(clock) // 2 tokens/sec
+
+	for i := 0; i < 10; i++ {
+		rl.Allow()
+	}
+	if rl.Allow() {
+		t.Fatal("Expected bucket to be empty")
+	}
+
+	clock.Advance(500 * time.Millisecond)
+	if got := rl.Tokens(); got != 1 {
+		t.Errorf("Expected 1 token after 500ms, got %f", got)
+	}
+
+	clock.Advance(time.Hour)
+	if got := rl.Tokens(); got != 10 {
+		t.Errorf("Expected refill to cap at 10 tokens, got %f", got)
+	}
+}
+
+func TestTokenBucketRateLimiter_AllowAt(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rl := NewTokenBucketRateLimiter(2, 1).WithClock(newFakeClock()) // 1 token/sec
+
+	tests := []struct {
+		at   time.Duration
+		want bool
+	}{
+		{0, true},
+		{0, true},
+		{0, false}, // Burst of 2 used up
+		{500 * time.Millisecond, false},
+		{time.Second, true}, // One token earned
+		{time.Second, false},
+		{3 * time.Second, true},
+		{3 * time.Second, true}, // Two more earned, up to capacity
+		{3 * time.Second, false},
+	}
+	for i, tt := range tests {
+		if got := rl.AllowAt(start.Add(tt.at)); got != tt.want {
+			t.Errorf("request %d at %v: AllowAt = %v, want %v", i, tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestTokenBucketRateLimiter_Metrics(t *testing.T) {
+	registry := newTestRegistry()
+	rl := NewTokenBucketRateLimiter(10, 1)
+	rl.RegisterMetrics(registry, "push")
+
+	for i := 0; i < 15; i++ {
+		rl.Allow()
+	}
+
+	if got := registry.counter("rate_limiter_allowed_total").Value(); got != 10 {
+		t.Errorf("rate_limiter_allowed_total = %v, want 10", got)
+	}
+	if got := registry.counter("rate_limiter_rejected_total").Value(); got != 5 {
+		t.Errorf("rate_limiter_rejected_total = %v, want 5", got)
+	}
+	if got := registry.labels["rate_limiter_allowed_total"]["limiter"]; got != "push" {
+		t.Errorf("limiter label = %q, want push", got)
+	}
+	if got := registry.histogram("rate_limiter_wait_duration_seconds").Observations(); len(got) != 0 {
+		t.Errorf("Allow should not observe wait durations, got %v", got)
+	}
+
+	// Wait records how long it blocked, and counts one decision however
+	// many times it polled the bucket
+	rl.SetRate(50)
+	if err := rl.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if err := rl.WaitN(ctx, 100); err == nil {
+		t.Fatal("WaitN for more than capacity should time out")
+	}
+
+	waits := registry.histogram("rate_limiter_wait_duration_seconds").Observations()
+	if len(waits) != 2 {
+		t.Fatalf("expected 2 wait observations, got %v", waits)
+	}
+	if got := registry.counter("rate_limiter_allowed_total").Value(); got != 11 {
+		t.Errorf("rate_limiter_allowed_total = %v, want 11", got)
+	}
+	if got := registry.counter("rate_limiter_rejected_total").Value(); got != 6 {
+		t.Errorf("rate_limiter_rejected_total = %v, want 6", got)
+	}
+}
+
 func TestTokenBucketRateLimiter_Wait(t *testing.T) {
 	rl := NewTokenBucketRateLimiter(1, 100) // 1 capacity, 100 tokens/sec
 
@@ -129,6 +246,49 @@ func TestTokenBucketRateLimiter_Concurrent(t *testing.T) {
 	}
 }
 
+func TestTokenBucketRateLimiter_WarmUp(t *testing.T) {
+	const rate = 1000.0
+	const warmUp = time.Second
+	rl := NewTokenBucketRateLimiter(1e6, rate)
+	rl.WarmUp(warmUp)
+
+	if rl.Allow() {
+		t.Error("Expected bucket to be empty at the start of warm-up")
+	}
+
+	// grantRate rewinds the ramp so that a refill now accrues the tokens
+	// earned in a 20ms window centred on the given point of the warm-up.
+	grantRate := func(at time.Duration) float64 {
+		const window = 20 * time.Millisecond
+		rl.mu.Lock()
+		defer rl.mu.Unlock()
+
+		now := time.Now()
+		rl.tokens = 0
+		rl.warmUpDuration = warmUp
+		rl.warmUpStart = now.Add(-(at + window/2))
+		rl.lastRefill = rl.warmUpStart.Add(at - window/2)
+		rl.refill()
+		return rl.tokens / window.Seconds()
+	}
+
+	for _, frac := range []float64{0.25, 0.50, 0.75} {
+		got := grantRate(time.Duration(frac * float64(warmUp)))
+		want := frac * rate
+		if math.Abs(got-want) > 0.05*rate {
+			t.Errorf("At %.0f%% of warm-up: expected rate ~%.0f, got %.1f", frac*100, want, got)
+		}
+	}
+
+	// Past the ramp the limiter refills at the full rate
+	if got := grantRate(2 * warmUp); math.Abs(got-rate) > 0.05*rate {
+		t.Errorf("After warm-up: expected rate ~%.0f, got %.1f", rate, got)
+	}
+	if rl.warmUpDuration != 0 {
+		t.Error("Expected warm-up to end once the ramp has elapsed")
+	}
+}
+
 // =============================================================================
 // Circuit Breaker Tests
 // =============================================================================
@@ -177,6 +337,124 @@ func TestCircuitBreaker_OpensOnFailures(t *testing.T) {
 	}
 }
 
+func TestCircuitBreaker_MinimumRequestCount(t *testing.T) {
+	config := CircuitBreakerConfig{
+		FailureThreshold:    3,
+		SuccessThreshold:    1,
+		Timeout:             time.Hour,
+		MinimumRequestCount: 10,
+	}
+	cb := NewCircuitBreaker(config)
+
+	testErr := errors.New("test error")
+	run := func(outcomes ...error) {
+		for _, err := range outcomes {
+			err := err
+			cb.Execute(func() error { return err })
+		}
+	}
+
+	// 4 requests, 3 consecutive failures: over FailureThreshold but
+	// below the minimum volume
+	run(nil, testErr, testErr, testErr)
+	if cb.State() != CircuitClosed {
+		t.Fatalf("Expected CLOSED below MinimumRequestCount, got %s", cb.State())
+	}
+
+	// 10 more requests, 8 of them failures
+	run(nil, nil, testErr, testErr, testErr, testErr, testErr, testErr, testErr, testErr)
+	if cb.State() != CircuitOpen {
+		t.Errorf("Expected OPEN once MinimumRequestCount is reached, got %s", cb.State())
+	}
+
+	// The request count starts over when the circuit closes again
+	cb.Reset()
+	run(testErr, testErr, testErr)
+	if cb.State() != CircuitClosed {
+		t.Errorf("Expected CLOSED after Reset below MinimumRequestCount, got %s", cb.State())
+	}
+}
+
+func TestCircuitBreaker_RequestWindow(t *testing.T) {
+	cb := NewCircuitBreaker(CircuitBreakerConfig{
+		FailureThreshold:    2,
+		Timeout:             time.Hour,
+		MinimumRequestCount: 5,
+		RequestWindow:       50 * time.Millisecond,
+	})
+	testErr := errors.New("test error")
+
+	// Traffic from an earlier window must not count toward the minimum
+	for i := 0; i < 10; i++ {
+		cb.Execute(func() error { return nil })
+	}
+	time.Sleep(60 * time.Millisecond)
+
+	cb.Execute(func() error { return testErr })
+	cb.Execute(func() error { return testErr })
+	if cb.State() != CircuitClosed {
+		t.Fatalf("Expected CLOSED with 2 requests in the current window, got %s", cb.State())
+	}
+
+	for i := 0; i < 3; i++ {
+		cb.Execute(func() error { return testErr })
+	}
+	if cb.State() != CircuitOpen {
+		t.Errorf("Expected OPEN with 5 requests in the current window, got %s", cb.State())
+	}
+}
+
+func TestCircuitBreaker_HalfOpenBackoff(t *testing.T) {
+	config := CircuitBreakerConfig{
+		FailureThreshold:  1,
+		SuccessThreshold:  1,
+		Timeout:           20 * time.Millisecond,
+		TimeoutMultiplier: 3,
+		MaxTimeout:        time.Second,
+	}
+	cb := NewCircuitBreaker(config)
+	testErr := errors.New("still down")
+
+	// waitForHalfOpen measures how long the circuit rejects requests, then
+	// fails the half-open attempt so the circuit reopens.
+	waitForHalfOpen := func() time.Duration {
+		start := time.Now()
+		for time.Since(start) < 2*time.Second {
+			err := cb.Execute(func() error { return testErr })
+			if !errors.Is(err, ErrCircuitOpen) {
+				return time.Since(start)
+			}
+			time.Sleep(2 * time.Millisecond)
+		}
+		t.Fatal("circuit never went half-open")
+		return 0
+	}
+
+	cb.Execute(func() error { return testErr }) // trip the breaker
+	first := waitForHalfOpen()                  // ~20ms, then 1st half-open failure
+	waitForHalfOpen()                           // ~60ms, then 2nd half-open failure
+	third := waitForHalfOpen()                  // ~180ms
+
+	if third <= first*3 {
+		t.Errorf("wait before third half-open attempt = %v, want well over first wait %v", third, first)
+	}
+	if got := cb.openTimeout(); got != 540*time.Millisecond {
+		t.Errorf("openTimeout after 3 half-open failures = %v, want 540ms", got)
+	}
+
+	// The timeout is capped by MaxTimeout
+	atomic.StoreInt32(&cb.reopenCount, 10)
+	if got := cb.openTimeout(); got != time.Second {
+		t.Errorf("openTimeout = %v, want MaxTimeout 1s", got)
+	}
+
+	// Closing the circuit resets the backoff
+	cb.Reset()
+	if got := cb.openTimeout(); got != config.Timeout {
+		t.Errorf("openTimeout after Reset = %v, want %v", got, config.Timeout)
+	}
+}
+
 func TestCircuitBreaker_TransitionsToHalfOpen(t *testing.T) {
 	config := CircuitBreakerConfig{
 		FailureThreshold: 2,
@@ -294,6 +572,128 @@ func TestCircuitBreaker_StateChangeCallback(t *testing.T) {
 	}
 }
 
+func TestCircuitBreaker_EventLog(t *testing.T) {
+	config := CircuitBreakerConfig{
+		FailureThreshold: 2,
+		SuccessThreshold: 1,
+		Timeout:          1 * time.Hour,
+	}
+	cb := NewCircuitBreaker(config)
+
+	// Three open/close cycles
+	for cycle := 0; cycle < 3; cycle++ {
+		for i := 0; i < 2; i++ {
+			cb.Execute(func() error { return errors.New("fail") })
+		}
+		cb.Reset()
+	}
+
+	events := cb.EventLog()
+	if len(events) != 6 {
+		t.Fatalf("Expected 6 events, got %d: %v", len(events), events)
+	}
+
+	for i, event := range events {
+		wantFrom, wantTo := CircuitClosed, CircuitOpen
+		if i%2 == 1 {
+			wantFrom, wantTo = CircuitOpen, CircuitClosed
+		}
+		if event.From != wantFrom || event.To != wantTo {
+			t.Errorf("Event %d: expected %s->%s, got %s->%s", i, wantFrom, wantTo, event.From, event.To)
+		}
+		if i > 0 && event.Timestamp.Before(events[i-1].Timestamp) {
+			t.Errorf("Event %d is out of chronological order", i)
+		}
+	}
+
+	if events[0].Failures != 2 {
+		t.Errorf("Expected 2 failures recorded when opening, got %d", events[0].Failures)
+	}
+}
+
+func TestCircuitBreaker_EventLogWrapsAround(t *testing.T) {
+	config := CircuitBreakerConfig{
+		FailureThreshold: 1,
+		SuccessThreshold: 1,
+		Timeout:          1 * time.Hour,
+		MaxEventLog:      3,
+	}
+	cb := NewCircuitBreaker(config)
+
+	// Produce 4 events: open, close, open, close
+	for cycle := 0; cycle < 2; cycle++ {
+		cb.Execute(func() error { return errors.New("fail") })
+		cb.Reset()
+	}
+
+	events := cb.EventLog()
+	if len(events) != 3 {
+		t.Fatalf("Expected log capped at 3 events, got %d", len(events))
+	}
+
+	// The oldest CLOSED->OPEN event was overwritten
+	expected := []CircuitState{CircuitOpen, CircuitClosed, CircuitOpen}
+	for i, event := range events {
+		if event.From != expected[i] {
+			t.Errorf("Event %d: expected From=%s, got %s", i, expected[i], event.From)
+		}
+	}
+}
+
+func TestCircuitBreaker_Metrics(t *testing.T) {
+	cb := NewCircuitBreaker(CircuitBreakerConfig{
+		FailureThreshold: 5,
+		SuccessThreshold: 1,
+		Timeout:          1 * time.Hour,
+	})
+	if m := cb.Metrics(); !m.LastFailure.IsZero() || m.TotalExecutions != 0 {
+		t.Errorf("Metrics() on a new breaker = %+v, want zero values", m)
+	}
+
+	before := time.Now()
+	for i := 0; i < 4; i++ {
+		cb.Execute(func() error { return errors.New("fail") })
+	}
+
+	m := cb.Metrics()
+	if m.Failures != 4 {
+		t.Errorf("Failures = %d, want 4", m.Failures)
+	}
+	if m.State != CircuitClosed {
+		t.Errorf("State = %s, want CLOSED", m.State)
+	}
+	if m.TotalExecutions != 4 {
+		t.Errorf("TotalExecutions = %d, want 4", m.TotalExecutions)
+	}
+	if m.LastFailure.Before(before) {
+		t.Errorf("LastFailure = %v, want after %v", m.LastFailure, before)
+	}
+
+	// The returned struct is a snapshot, not a live view
+	cb.Execute(func() error { return errors.New("fail") })
+	if m.Failures != 4 || cb.Metrics().State != CircuitOpen {
+		t.Errorf("snapshot changed or breaker did not open: %+v", cb.Metrics())
+	}
+}
+
+func TestCircuitBreaker_RecordFailureFromHealthSignal(t *testing.T) {
+	cb := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 3, Timeout: time.Hour})
+
+	// Three unhealthy check cycles open the breaker without any requests
+	for i := 0; i < 3; i++ {
+		if cb.State() != CircuitClosed {
+			t.Fatalf("breaker opened after %d failures, want 3", i)
+		}
+		cb.RecordFailure()
+	}
+	if cb.State() != CircuitOpen {
+		t.Errorf("State = %s after 3 recorded failures, want OPEN", cb.State())
+	}
+	if err := cb.Execute(func() error { return nil }); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Execute = %v, want ErrCircuitOpen", err)
+	}
+}
+
 // =============================================================================
 // Retryer Tests
 // =============================================================================
@@ -381,6 +781,35 @@ func TestRetryer_RespectsContext(t *testing.T) {
 	}
 }
 
+func TestRetryer_MaxDuration(t *testing.T) {
+	r := NewRetryer(RetryConfig{
+		MaxRetries:        100,
+		InitialBackoff:    time.Millisecond,
+		MaxBackoff:        time.Millisecond,
+		BackoffMultiplier: 1,
+		MaxDuration:       50 * time.Millisecond,
+	})
+	errSlow := errors.New("slow upstream")
+
+	start := time.Now()
+	result, err := r.DoWithContext(context.Background(), func(ctx context.Context) error {
+		select {
+		case <-time.After(20 * time.Millisecond):
+			return errSlow
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	elapsed := time.Since(start)
+
+	if elapsed > 60*time.Millisecond {
+		t.Errorf("retries took %v, want at most 60ms", elapsed)
+	}
+	if err == nil || result.Attempts > 3 {
+		t.Errorf("got %d attempts and error %v, want the last error after at most 3 attempts", result.Attempts, err)
+	}
+}
+
 func TestRetryer_CustomRetryableCheck(t *testing.T) {
 	permanentErr := errors.New("permanent error")
 	transientErr := errors.New("transient error")
@@ -415,6 +844,220 @@ func TestRetryer_CustomRetryableCheck(t *testing.T) {
 	}
 }
 
+func TestRetryer_SuccessPredicate(t *testing.T) {
+	type statusResponse struct {
+		Status string `json:"status"`
+	}
+
+	config := RetryConfig{
+		MaxRetries:        5,
+		InitialBackoff:    1 * time.Millisecond,
+		MaxBackoff:        10 * time.Millisecond,
+		BackoffMultiplier: 2.0,
+		SuccessPredicate: func(result interface{}) bool {
+			return result.(statusResponse).Status == "done"
+		},
+	}
+	r := NewRetryer(config)
+
+	// The endpoint answers 200 with {"status": "pending"} until the job finishes
+	bodies := []string{`{"status": "pending"}`, `{"status": "pending"}`, `{"status": "done"}`}
+	calls := 0
+	resp, result, err := DoWithResult(r, func() (statusResponse, error) {
+		var body statusResponse
+		err := json.Unmarshal([]byte(bodies[calls]), &body)
+		calls++
+		return body, err
+	})
+
+	if err != nil {
+		t.Fatalf("Expected success, got: %v", err)
+	}
+	if resp.Status != "done" {
+		t.Errorf("Expected final status 'done', got %q", resp.Status)
+	}
+	if result.Attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", result.Attempts)
+	}
+}
+
+func TestRetryer_SuccessPredicateNeverMet(t *testing.T) {
+	config := RetryConfig{
+		MaxRetries:     2,
+		InitialBackoff: 1 * time.Millisecond,
+		// Only explicitly listed errors are retryable; predicate failures still are
+		RetryableErrors: []error{context.DeadlineExceeded},
+		SuccessPredicate: func(result interface{}) bool {
+			return result.(string) == "done"
+		},
+	}
+	r := NewRetryer(config)
+
+	value, result, err := DoWithResult(r, func() (string, error) {
+		return "pending", nil
+	})
+
+	if !errors.Is(err, ErrPredicateNotMet) {
+		t.Errorf("Expected ErrPredicateNotMet, got: %v", err)
+	}
+	if value != "pending" {
+		t.Errorf("Expected last result to be returned, got %q", value)
+	}
+	if result.Attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", result.Attempts)
+	}
+}
+
+func TestDoWithResultContext_TypedResult(t *testing.T) {
+	r := NewRetryer(RetryConfig{MaxRetries: 3, InitialBackoff: 1 * time.Millisecond})
+
+	calls := 0
+	value, result, err := DoWithResultContext(r, context.Background(), func(ctx context.Context) (int, error) {
+		calls++
+		if calls < 3 {
+			return 0, errors.New("transient")
+		}
+		return 42, nil
+	})
+
+	if err != nil {
+		t.Fatalf("Expected success, got: %v", err)
+	}
+	if value != 42 {
+		t.Errorf("Expected 42, got %d", value)
+	}
+	if result.Attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", result.Attempts)
+	}
+}
+
+func TestRetryBudget_SharedAcrossRetryers(t *testing.T) {
+	budget := NewRetryBudget(5, time.Minute)
+	var exhausted atomic.Int32
+	budget.OnExhausted(func() { exhausted.Add(1) })
+
+	config := RetryConfig{
+		MaxRetries:     1,
+		InitialBackoff: 1 * time.Millisecond,
+		Budget:         budget,
+	}
+	fail := func() error { return errors.New("unavailable") }
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if result, _ := NewRetryer(config).Do(fail); result.Attempts != 2 {
+				t.Errorf("Expected 2 attempts within budget, got %d", result.Attempts)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := budget.Remaining(); got != 0 {
+		t.Errorf("Remaining() = %d, want 0", got)
+	}
+	if got := exhausted.Load(); got != 1 {
+		t.Errorf("OnExhausted called %d times, want 1", got)
+	}
+
+	// An exhausted budget sheds further retries
+	result, _ := NewRetryer(config).Do(fail)
+	if result.Attempts != 1 {
+		t.Errorf("Expected 1 attempt once the budget is exhausted, got %d", result.Attempts)
+	}
+	if got := exhausted.Load(); got != 1 {
+		t.Errorf("OnExhausted called %d times after shedding, want 1", got)
+	}
+}
+
+func TestAdaptiveRetryer_ReducesRetriesUnderHighErrorRate(t *testing.T) {
+	config := DefaultAdaptiveRetryConfig()
+	config.Retry = RetryConfig{
+		MaxRetries:     3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+	}
+	config.Window = 100 * time.Millisecond
+	config.MinRequests = 4
+	ar := NewAdaptiveRetryer(config)
+
+	if got := ar.Stats().EffectiveMaxRetries; got != 3 {
+		t.Fatalf("EffectiveMaxRetries = %d initially, want 3", got)
+	}
+
+	failing := errors.New("overloaded")
+	result, _ := ar.Do(func() error { return failing })
+	if result.Attempts != 4 {
+		t.Errorf("first call made %d attempts, want 4", result.Attempts)
+	}
+
+	stats := ar.Stats()
+	if !stats.Reduced || stats.EffectiveMaxRetries != 1 {
+		t.Fatalf("expected retries reduced to 1, got %+v", stats)
+	}
+	if stats.ErrorRate != 1 {
+		t.Errorf("ErrorRate = %v, want 1", stats.ErrorRate)
+	}
+
+	result, _ = ar.Do(func() error { return failing })
+	if result.Attempts != 2 {
+		t.Errorf("call under high error rate made %d attempts, want 2", result.Attempts)
+	}
+
+	// Once the failures leave the window, healthy traffic restores the limit
+	time.Sleep(120 * time.Millisecond)
+	for i := 0; i < 4; i++ {
+		if _, err := ar.Do(func() error { return nil }); err != nil {
+			t.Fatalf("Do: %v", err)
+		}
+	}
+	stats = ar.Stats()
+	if stats.Reduced || stats.EffectiveMaxRetries != 3 {
+		t.Errorf("expected original limit restored, got %+v", stats)
+	}
+}
+
+func TestAdaptiveRetryer_Hysteresis(t *testing.T) {
+	config := DefaultAdaptiveRetryConfig()
+	config.Retry.InitialBackoff = time.Millisecond
+	config.Retry.MaxRetries = 0 // one attempt per call, so outcomes are exact
+	config.ReducedMaxRetries = 0
+	config.MinRequests = 10
+	ar := NewAdaptiveRetryer(config)
+
+	fail := func() error { return errors.New("fail") }
+	ok := func() error { return nil }
+
+	// 6 of 10 failed: above the 50% threshold
+	for i := 0; i < 6; i++ {
+		ar.Do(fail)
+	}
+	for i := 0; i < 4; i++ {
+		ar.Do(ok)
+	}
+	if !ar.Stats().Reduced {
+		t.Fatalf("expected reduced at 60%% errors, got %+v", ar.Stats())
+	}
+
+	// 6 of 20 (30%) is below HighErrorRate but above RecoveryErrorRate
+	for i := 0; i < 10; i++ {
+		ar.Do(ok)
+	}
+	if !ar.Stats().Reduced {
+		t.Errorf("expected to stay reduced at 30%% errors, got %+v", ar.Stats())
+	}
+
+	// 6 of 31 (~19%) drops below RecoveryErrorRate
+	for i := 0; i < 11; i++ {
+		ar.Do(ok)
+	}
+	if ar.Stats().Reduced {
+		t.Errorf("expected recovery below 20%% errors, got %+v", ar.Stats())
+	}
+}
+
 // =============================================================================
 // Resilient Client Tests
 // =============================================================================
@@ -515,6 +1158,23 @@ func TestSlidingWindowRateLimiter_Allow(t *testing.T) {
 	}
 }
 
+func TestSlidingWindowRateLimiter_Metrics(t *testing.T) {
+	registry := newTestRegistry()
+	rl := NewSlidingWindowRateLimiter(time.Minute, 10)
+	rl.RegisterMetrics(registry, "api")
+
+	for i := 0; i < 15; i++ {
+		rl.Allow()
+	}
+
+	if got := registry.counter("rate_limiter_allowed_total").Value(); got != 10 {
+		t.Errorf("rate_limiter_allowed_total = %v, want 10", got)
+	}
+	if got := registry.counter("rate_limiter_rejected_total").Value(); got != 5 {
+		t.Errorf("rate_limiter_rejected_total = %v, want 5", got)
+	}
+}
+
 func TestSlidingWindowRateLimiter_WindowSlides(t *testing.T) {
 	rl := NewSlidingWindowRateLimiter(50*time.Millisecond, 3)
 
@@ -532,6 +1192,117 @@ func TestSlidingWindowRateLimiter_WindowSlides(t *testing.T) {
 	}
 }
 
+func TestSlidingWindowRateLimiter_WouldAllow(t *testing.T) {
+	rl := NewSlidingWindowRateLimiter(50*time.Millisecond, 2)
+
+	if !rl.WouldAllow() {
+		t.Error("Expected WouldAllow() to return true when empty")
+	}
+
+	// Peeking must not consume a slot
+	for i := 0; i < 5; i++ {
+		rl.WouldAllow()
+	}
+	if got := rl.RequestsInWindow(); got != 0 {
+		t.Errorf("Expected WouldAllow() not to record requests, got %d in window", got)
+	}
+
+	rl.Allow()
+	rl.Allow()
+
+	if rl.WouldAllow() {
+		t.Error("Expected WouldAllow() to return false at capacity")
+	}
+	if got := rl.RequestsInWindow(); got != 2 {
+		t.Errorf("Expected 2 requests in window, got %d", got)
+	}
+
+	// Wait for window to slide
+	time.Sleep(60 * time.Millisecond)
+
+	if !rl.WouldAllow() {
+		t.Error("Expected WouldAllow() to return true after window slides")
+	}
+	if got := rl.RequestsInWindow(); got != 0 {
+		t.Errorf("Expected empty window, got %d", got)
+	}
+}
+
+// =============================================================================
+// Composite Rate Limiter Tests
+// =============================================================================
+
+func TestCompositeRateLimiter_TenantIsolation(t *testing.T) {
+	// Negligible refill so the test only sees the initial burst
+	rl := NewCompositeRateLimiter(100, 0.001, 3, 0.001)
+
+	for i := 0; i < 3; i++ {
+		if !rl.Allow("tenant-a") {
+			t.Fatalf("request %d for tenant-a should be allowed", i)
+		}
+	}
+	if rl.Allow("tenant-a") {
+		t.Error("tenant-a should be limited after exhausting its quota")
+	}
+
+	if !rl.Allow("tenant-b") {
+		t.Error("tenant-b should not be affected by tenant-a's quota")
+	}
+}
+
+func TestCompositeRateLimiter_GlobalCeiling(t *testing.T) {
+	rl := NewCompositeRateLimiter(5, 0.001, 4, 0.001)
+
+	allowed := 0
+	for i := 0; i < 4; i++ {
+		if rl.Allow("tenant-a") {
+			allowed++
+		}
+		if rl.Allow("tenant-b") {
+			allowed++
+		}
+	}
+	if allowed != 5 {
+		t.Errorf("expected the global cap of 5 requests, got %d", allowed)
+	}
+
+	// A tenant rejected by the global bucket is not charged
+	rl = NewCompositeRateLimiter(1, 0.001, 2, 0.001)
+	if !rl.Allow("tenant-a") {
+		t.Fatal("first request should be allowed")
+	}
+	if rl.Allow("tenant-b") {
+		t.Fatal("second request should exceed the global cap")
+	}
+	tenantB := rl.tenants["tenant-b"].Value.(*tenantBucket).limiter
+	if got := tenantB.Tokens(); got < 1.99 {
+		t.Errorf("tenant-b should not be charged when the global bucket refuses, has %.2f tokens", got)
+	}
+}
+
+func TestCompositeRateLimiter_LRUEviction(t *testing.T) {
+	rl := NewCompositeRateLimiter(100, 0.001, 1, 0.001).WithMaxTenants(2)
+
+	rl.Allow("a")
+	rl.Allow("b")
+	rl.Allow("a") // a is now most recently used
+	rl.Allow("c") // evicts b
+
+	if got := rl.Tenants(); got != 2 {
+		t.Errorf("Tenants() = %d, want 2", got)
+	}
+	if _, ok := rl.tenants["b"]; ok {
+		t.Error("expected least recently used tenant b to be evicted")
+	}
+	if rl.Allow("a") {
+		t.Error("tenant a was kept and should still be exhausted")
+	}
+	// b starts over with a full bucket
+	if !rl.Allow("b") {
+		t.Error("evicted tenant should get a fresh bucket")
+	}
+}
+
 // =============================================================================
 // Error Wrapper Tests
 // =============================================================================
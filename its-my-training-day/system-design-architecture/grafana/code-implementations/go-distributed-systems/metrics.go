@@ -0,0 +1,45 @@
+// Package concurrency provides the metrics interfaces instrumented
+// components in this package report to.
+//
+// This file demonstrates:
+// - Consumer-side interfaces, so the package does not depend on a metrics library
+// - Constant labels to tell several instances of a component apart
+//
+// Like Tracer, MetricRegistry is the small subset of a metrics API these
+// components need. An adapter over Prometheus' client_golang, or over the
+// observability package's MetricRegistry, is a few lines:
+//
+//	func (r promRegistry) NewCounter(name, help string, labels map[string]string) Counter {
+//	    c := prometheus.NewCounter(prometheus.CounterOpts{Name: name, Help: help, ConstLabels: labels})
+//	    r.MustRegister(c)
+//	    return c
+//	}
+package concurrency
+
+// MetricRegistry creates and registers metrics. Each call registers a new
+// series; constLabels identify the component instance (e.g. which
+// semaphore) and may be nil.
+type MetricRegistry interface {
+	NewCounter(name, help string, constLabels map[string]string) Counter
+	NewGauge(name, help string, constLabels map[string]string) Gauge
+	NewHistogram(name, help string, buckets []float64, constLabels map[string]string) Histogram
+}
+
+// Counter is a monotonically increasing metric.
+type Counter interface {
+	Add(delta float64)
+}
+
+// Gauge is a metric that can go up and down.
+type Gauge interface {
+	Set(value float64)
+}
+
+// Histogram samples observations into buckets.
+type Histogram interface {
+	Observe(value float64)
+}
+
+// DefaultWaitBuckets are histogram buckets, in seconds, for time spent
+// waiting on a semaphore or rate limiter: 1ms to 10s.
+var DefaultWaitBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10}
@@ -0,0 +1,95 @@
+package concurrency
+
+import (
+	"sync"
+)
+
+// testRegistry is an in-memory MetricRegistry that records every value
+// reported, keyed by metric name.
+type testRegistry struct {
+	mu         sync.Mutex
+	counters   map[string]*testMetric
+	gauges     map[string]*testMetric
+	histograms map[string]*testMetric
+	labels     map[string]map[string]string
+}
+
+func newTestRegistry() *testRegistry {
+	return &testRegistry{
+		counters:   make(map[string]*testMetric),
+		gauges:     make(map[string]*testMetric),
+		histograms: make(map[string]*testMetric),
+		labels:     make(map[string]map[string]string),
+	}
+}
+
+func (r *testRegistry) NewCounter(name, help string, constLabels map[string]string) Counter {
+	return r.register(r.counters, name, constLabels)
+}
+
+func (r *testRegistry) NewGauge(name, help string, constLabels map[string]string) Gauge {
+	return r.register(r.gauges, name, constLabels)
+}
+
+func (r *testRegistry) NewHistogram(name, help string, buckets []float64, constLabels map[string]string) Histogram {
+	return r.register(r.histograms, name, constLabels)
+}
+
+func (r *testRegistry) register(into map[string]*testMetric, name string, constLabels map[string]string) *testMetric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m := &testMetric{}
+	into[name] = m
+	r.labels[name] = constLabels
+	return m
+}
+
+func (r *testRegistry) counter(name string) *testMetric   { return r.get(r.counters, name) }
+func (r *testRegistry) gauge(name string) *testMetric     { return r.get(r.gauges, name) }
+func (r *testRegistry) histogram(name string) *testMetric { return r.get(r.histograms, name) }
+
+func (r *testRegistry) get(from map[string]*testMetric, name string) *testMetric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if m := from[name]; m != nil {
+		return m
+	}
+	return &testMetric{}
+}
+
+// testMetric implements Counter, Gauge and Histogram.
+type testMetric struct {
+	mu           sync.Mutex
+	value        float64
+	observations []float64
+}
+
+func (m *testMetric) Add(delta float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.value += delta
+}
+
+func (m *testMetric) Set(value float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.value = value
+}
+
+func (m *testMetric) Observe(value float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observations = append(m.observations, value)
+}
+
+func (m *testMetric) Value() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.value
+}
+
+func (m *testMetric) Observations() []float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]float64(nil), m.observations...)
+}
@@ -0,0 +1,158 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestRedis starts an in-memory Redis server for the test and returns a
+// go-redis client wired in through RedisEvalFunc, so the Lua script itself
+// runs on every call.
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, RedisEvaler) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return server, RedisEvalFunc(func(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+		return client.Eval(ctx, script, keys, args...).Result()
+	})
+}
+
+// mustNewRedisLimiter creates a limiter or fails the test.
+func mustNewRedisLimiter(t *testing.T, client RedisEvaler, capacity, refillRate float64) *RedisTokenBucketRateLimiter {
+	t.Helper()
+	rl, err := NewRedisTokenBucketRateLimiter(client, capacity, refillRate)
+	if err != nil {
+		t.Fatalf("NewRedisTokenBucketRateLimiter failed: %v", err)
+	}
+	return rl
+}
+
+func TestRedisTokenBucketRateLimiter_SharedAcrossProcesses(t *testing.T) {
+	_, client := newTestRedis(t)
+	const capacity, rate = 10.0, 100.0
+
+	// Two replicas of a service, each with its own limiter
+	replicas := []DistributedRateLimiter{
+		mustNewRedisLimiter(t, client, capacity, rate),
+		mustNewRedisLimiter(t, client, capacity, rate),
+	}
+
+	var allowed int64
+	var wg sync.WaitGroup
+	ctx := context.Background()
+	start := time.Now()
+	deadline := start.Add(200 * time.Millisecond)
+
+	for _, rl := range replicas {
+		rl := rl
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for time.Now().Before(deadline) {
+					ok, err := rl.Allow(ctx, "tenant-a")
+					if err != nil {
+						t.Errorf("Allow failed: %v", err)
+						return
+					}
+					if ok {
+						atomic.AddInt64(&allowed, 1)
+					}
+					time.Sleep(time.Millisecond)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	// The combined grants must match one bucket, not one per replica
+	elapsed := time.Since(start).Seconds()
+	limit := capacity + rate*elapsed
+	if got := float64(atomic.LoadInt64(&allowed)); got > limit+1 || got < limit*0.7 {
+		t.Errorf("allowed %v requests across replicas, want about %.0f", got, limit)
+	}
+}
+
+func TestRedisTokenBucketRateLimiter_AllowN(t *testing.T) {
+	server, client := newTestRedis(t)
+	rl := mustNewRedisLimiter(t, client, 5, 0.001)
+	ctx := context.Background()
+
+	if ok, _ := rl.AllowN(ctx, "key", 6); ok {
+		t.Error("AllowN above capacity should be denied")
+	}
+	if ok, _ := rl.AllowN(ctx, "key", 5); !ok {
+		t.Error("AllowN at capacity should be allowed")
+	}
+	if ok, _ := rl.Allow(ctx, "key"); ok {
+		t.Error("Allow on an empty bucket should be denied")
+	}
+	if ok, _ := rl.Allow(ctx, "other"); !ok {
+		t.Error("Each key should have its own bucket")
+	}
+
+	// The bucket is stored as a hash with a TTL so idle keys are cleaned up
+	if tokens := server.HGet("key", "tokens"); tokens == "" {
+		t.Error("expected the bucket's tokens in the Redis hash")
+	}
+	if ttl := server.TTL("key"); ttl <= 0 {
+		t.Errorf("expected a TTL on the bucket key, got %v", ttl)
+	}
+}
+
+func TestRedisTokenBucketRateLimiter_InvalidConfig(t *testing.T) {
+	_, client := newTestRedis(t)
+	for _, tt := range []struct{ capacity, rate float64 }{
+		{0, 1},
+		{-1, 1},
+		{10, 0},
+		{10, -5},
+	} {
+		if _, err := NewRedisTokenBucketRateLimiter(client, tt.capacity, tt.rate); err == nil {
+			t.Errorf("NewRedisTokenBucketRateLimiter(%v, %v) should fail", tt.capacity, tt.rate)
+		}
+	}
+}
+
+func TestRedisTokenBucketRateLimiter_Error(t *testing.T) {
+	redisErr := errors.New("connection refused")
+	rl := mustNewRedisLimiter(t, RedisEvalFunc(
+		func(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+			return nil, redisErr
+		}), 10, 1)
+
+	ok, err := rl.Allow(context.Background(), "key")
+	if ok || !errors.Is(err, redisErr) {
+		t.Errorf("Allow() = %v, %v; want false, %v", ok, err, redisErr)
+	}
+}
+
+func TestParseRedisAllowed(t *testing.T) {
+	tests := []struct {
+		reply   interface{}
+		want    bool
+		wantErr bool
+	}{
+		{int64(1), true, false},
+		{int64(0), false, false},
+		{1, true, false},
+		{"1", true, false},
+		{"yes", false, true},
+		{nil, false, true},
+	}
+	for _, tt := range tests {
+		got, err := parseRedisAllowed(tt.reply)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("parseRedisAllowed(%#v) = %v, %v", tt.reply, got, err)
+		}
+	}
+}
@@ -19,6 +19,8 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
@@ -49,6 +51,22 @@ type SampleDatasourceSettings struct {
 
 	// EnableDebug enables verbose logging
 	EnableDebug bool `json:"enableDebug"`
+
+	// VariableMetrics lists the metric names offered in variable dropdowns
+	VariableMetrics []string `json:"variableMetrics"`
+}
+
+// defaultVariableMetrics are offered by variable queries when the settings
+// do not list any. They match the frontend's metricFindQuery samples.
+var defaultVariableMetrics = []string{
+	"cpu_usage",
+	"memory_usage",
+	"disk_io",
+	"network_bytes",
+	"request_count",
+	"error_rate",
+	"latency_p50",
+	"latency_p99",
 }
 
 // SampleQuery represents a query from the frontend.
@@ -60,13 +78,14 @@ type SampleQuery struct {
 	// QueryText is the query expression
 	QueryText string `json:"queryText"`
 
-	// Metric is the metric name to query
+	// Metric is the metric name to query. A comma-separated list such as
+	// "cpu,memory,disk" returns one frame per metric
 	Metric string `json:"metric"`
 
 	// Labels are key-value pairs for filtering
 	Labels map[string]string `json:"labels"`
 
-	// Format determines the output format (time_series or table)
+	// Format determines the output format (time_series, table, or variable)
 	Format string `json:"format"`
 
 	// MaxDataPoints is the maximum number of data points to return
@@ -74,6 +93,10 @@ type SampleQuery struct {
 
 	// IntervalMs is the suggested interval between data points
 	IntervalMs int64 `json:"intervalMs"`
+
+	// MetaFields are merged into each frame's Meta.Custom, passing
+	// plugin-specific metadata such as annotation thresholds to the panel
+	MetaFields map[string]interface{} `json:"metaFields,omitempty"`
 }
 
 // SampleDatasource is the backend implementation of the data source.
@@ -81,6 +104,10 @@ type SampleQuery struct {
 type SampleDatasource struct {
 	settings SampleDatasourceSettings
 	logger   log.Logger
+
+	// listMetrics, if set, supplies metric names for variable queries
+	// dynamically (e.g. from the upstream API) instead of the settings
+	listMetrics func(ctx context.Context) ([]string, error)
 }
 
 // NewSampleDatasource creates a new instance of the data source.
@@ -145,23 +172,21 @@ func (d *SampleDatasource) QueryData(ctx context.Context, req *backend.QueryData
 
 	// Process each query
 	for _, q := range req.Queries {
-		res := d.processQuery(ctx, req.PluginContext, q)
-		response.Responses[q.RefID] = res
+		frames, err := d.processQuery(ctx, req.PluginContext, q)
+		response.Responses[q.RefID] = backend.DataResponse{Frames: frames, Error: err}
 	}
 
 	return response, nil
 }
 
-// processQuery handles a single query and returns a DataResponse.
-func (d *SampleDatasource) processQuery(ctx context.Context, pCtx backend.PluginContext, query backend.DataQuery) backend.DataResponse {
-	var response backend.DataResponse
-
+// processQuery handles a single query and returns its frames: one per
+// metric for time series and table queries, or the variable options frame.
+func (d *SampleDatasource) processQuery(ctx context.Context, pCtx backend.PluginContext, query backend.DataQuery) ([]*data.Frame, error) {
 	// Parse the query JSON
 	var q SampleQuery
 	if err := json.Unmarshal(query.JSON, &q); err != nil {
 		d.logger.Error("Failed to parse query", "error", err)
-		response.Error = fmt.Errorf("failed to parse query: %w", err)
-		return response
+		return nil, fmt.Errorf("failed to parse query: %w", err)
 	}
 
 	// Set defaults from the DataQuery
@@ -180,25 +205,84 @@ func (d *SampleDatasource) processQuery(ctx context.Context, pCtx backend.Plugin
 		"timeRange", fmt.Sprintf("%v - %v", query.TimeRange.From, query.TimeRange.To),
 	)
 
-	// Generate data based on format
-	var frame *data.Frame
-	var err error
+	// Variable queries list metrics rather than querying them
+	if q.Format == "variable" {
+		frame, err := d.handleVariableQuery(ctx, q)
+		if err != nil {
+			d.logger.Error("Failed to create frame", "error", err)
+			return nil, err
+		}
+		applyMetaFields(frame, q.MetaFields)
+		return []*data.Frame{frame}, nil
+	}
+
+	// Generate one frame per metric, based on format. With several metrics
+	// each frame gets its own RefID, e.g. A_cpu, so they stay distinguishable
+	metrics := splitMetrics(q.Metric)
+	frames := make([]*data.Frame, 0, len(metrics))
+	for _, metric := range metrics {
+		mq := q
+		mq.Metric = metric
+		if len(metrics) > 1 {
+			mq.RefID = q.RefID + "_" + metric
+		}
 
-	switch q.Format {
-	case "table":
-		frame, err = d.createTableFrame(ctx, q)
-	default:
-		frame, err = d.createTimeSeriesFrame(ctx, q, query.TimeRange)
+		var frame *data.Frame
+		var err error
+		switch q.Format {
+		case "table":
+			frame, err = d.createTableFrame(ctx, mq)
+		default:
+			frame, err = d.createTimeSeriesFrame(ctx, mq, query.TimeRange)
+		}
+		if err != nil {
+			d.logger.Error("Failed to create frame", "metric", metric, "error", err)
+			return nil, err
+		}
+		applyMetaFields(frame, q.MetaFields)
+		frames = append(frames, frame)
 	}
+	return frames, nil
+}
 
-	if err != nil {
-		d.logger.Error("Failed to create frame", "error", err)
-		response.Error = err
-		return response
+// applyMetaFields merges fields into frame.Meta.Custom, which the SDK
+// serializes as JSON for the frontend. Keys already in a map-valued Custom
+// are overwritten; any other Custom value is replaced.
+func applyMetaFields(frame *data.Frame, fields map[string]interface{}) {
+	if len(fields) == 0 {
+		return
+	}
+	if frame.Meta == nil {
+		frame.Meta = &data.FrameMeta{}
+	}
+	custom, ok := frame.Meta.Custom.(map[string]interface{})
+	if !ok {
+		custom = make(map[string]interface{}, len(fields))
 	}
+	for k, v := range fields {
+		custom[k] = v
+	}
+	frame.Meta.Custom = custom
+}
 
-	response.Frames = append(response.Frames, frame)
-	return response
+// splitMetrics splits a comma-separated metric list, trimming spaces and
+// dropping empty and repeated names. A query without a metric yields a
+// single unnamed metric.
+func splitMetrics(metric string) []string {
+	var metrics []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(metric, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		metrics = append(metrics, name)
+	}
+	if len(metrics) == 0 {
+		return []string{""}
+	}
+	return metrics
 }
 
 // createTimeSeriesFrame generates time series data.
@@ -299,6 +383,37 @@ func (d *SampleDatasource) createTableFrame(ctx context.Context, q SampleQuery)
 	return frame, nil
 }
 
+// handleVariableQuery returns the metric names for a template variable
+// dropdown. Grafana reads variable options from a frame's __value__ field
+// (and __text__, if present, for display names).
+func (d *SampleDatasource) handleVariableQuery(ctx context.Context, q SampleQuery) (*data.Frame, error) {
+	names, err := d.metricNames(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list metrics: %w", err)
+	}
+
+	frame := data.NewFrame("variable",
+		data.NewField("__value__", nil, names),
+	)
+	frame.RefID = q.RefID
+	return frame, nil
+}
+
+// metricNames returns the metrics available to variable queries: from
+// listMetrics if set, otherwise the configured VariableMetrics, falling
+// back to defaultVariableMetrics.
+func (d *SampleDatasource) metricNames(ctx context.Context) ([]string, error) {
+	if d.listMetrics != nil {
+		return d.listMetrics(ctx)
+	}
+	names := d.settings.VariableMetrics
+	if len(names) == 0 {
+		names = defaultVariableMetrics
+	}
+	// Copy so the frame does not share the settings' backing array
+	return append([]string(nil), names...), nil
+}
+
 // CheckHealth handles health check requests from Grafana.
 // This is called when users click "Save & Test" in the data source settings.
 //
@@ -310,13 +425,8 @@ func (d *SampleDatasource) createTableFrame(ctx context.Context, q SampleQuery)
 func (d *SampleDatasource) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
 	d.logger.Info("CheckHealth called")
 
-	// In a real plugin, you would:
-	// 1. Make a lightweight API call to verify connectivity
-	// 2. Check authentication
-	// 3. Verify permissions
-
-	// Simulate a health check
-	// In production, replace this with actual connectivity check
+	// A lightweight call to the data source's health endpoint verifies
+	// connectivity; a real plugin would also check credentials here
 	if d.settings.URL == "" {
 		return &backend.CheckHealthResult{
 			Status:  backend.HealthStatusError,
@@ -324,35 +434,49 @@ func (d *SampleDatasource) CheckHealth(ctx context.Context, req *backend.CheckHe
 		}, nil
 	}
 
-	// Example: Check if URL is reachable
-	// In a real plugin, you would make an HTTP request here
-	/*
-		client := &http.Client{Timeout: time.Duration(d.settings.Timeout) * time.Second}
-		resp, err := client.Get(d.settings.URL + "/health")
-		if err != nil {
-			return &backend.CheckHealthResult{
-				Status:  backend.HealthStatusError,
-				Message: fmt.Sprintf("Failed to connect: %v", err),
-			}, nil
-		}
-		defer resp.Body.Close()
+	client := &http.Client{Timeout: time.Duration(d.settings.Timeout) * time.Second}
+	healthURL := strings.TrimRight(d.settings.URL, "/") + "/health"
 
-		if resp.StatusCode != http.StatusOK {
-			return &backend.CheckHealthResult{
-				Status:  backend.HealthStatusError,
-				Message: fmt.Sprintf("Unexpected status code: %d", resp.StatusCode),
-			}, nil
-		}
-	*/
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, healthURL, nil)
+	if err != nil {
+		return &backend.CheckHealthResult{
+			Status:  backend.HealthStatusError,
+			Message: fmt.Sprintf("Invalid URL: %v", err),
+		}, nil
+	}
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		d.logger.Warn("Health check failed", "url", healthURL, "error", err)
+		return &backend.CheckHealthResult{
+			Status:  backend.HealthStatusError,
+			Message: fmt.Sprintf("Failed to connect: %v", err),
+		}, nil
+	}
+	defer resp.Body.Close()
+
+	details, err := json.Marshal(map[string]interface{}{
+		"version":    "1.0.0",
+		"database":   d.settings.DefaultDatabase,
+		"url":        d.settings.URL,
+		"statusCode": resp.StatusCode,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode health details: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return &backend.CheckHealthResult{
+			Status:      backend.HealthStatusError,
+			Message:     fmt.Sprintf("Unexpected status code: %d", resp.StatusCode),
+			JSONDetails: details,
+		}, nil
+	}
 
 	return &backend.CheckHealthResult{
-		Status:  backend.HealthStatusOk,
-		Message: "Data source is working",
-		JSONDetails: []byte(fmt.Sprintf(`{
-			"version": "1.0.0",
-			"database": "%s",
-			"url": "%s"
-		}`, d.settings.DefaultDatabase, d.settings.URL)),
+		Status:      backend.HealthStatusOk,
+		Message:     "Data source is working",
+		JSONDetails: details,
 	}, nil
 }
 
@@ -0,0 +1,248 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// newTestDatasource returns a data source with the given settings and the
+// SDK's default logger.
+func newTestDatasource(settings SampleDatasourceSettings) *SampleDatasource {
+	return &SampleDatasource{
+		settings: settings,
+		logger:   log.DefaultLogger,
+	}
+}
+
+func TestProcessQuery_Variable(t *testing.T) {
+	d := newTestDatasource(SampleDatasourceSettings{})
+
+	frames, err := d.processQuery(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(`{"format": "variable"}`),
+	})
+	if err != nil {
+		t.Fatalf("processQuery failed: %v", err)
+	}
+	if len(frames) != 1 {
+		t.Fatalf("expected 1 frame, got %d", len(frames))
+	}
+
+	frame := frames[0]
+	if frame.RefID != "A" {
+		t.Errorf("RefID = %q, want A", frame.RefID)
+	}
+	if len(frame.Fields) != 1 {
+		t.Fatalf("expected 1 field, got %d", len(frame.Fields))
+	}
+	field := frame.Fields[0]
+	if field.Name != "__value__" {
+		t.Errorf("field name = %q, want __value__", field.Name)
+	}
+	if field.Type() != data.FieldTypeString {
+		t.Errorf("field type = %s, want string", field.Type())
+	}
+	if field.Len() < 3 {
+		t.Errorf("expected at least 3 rows, got %d", field.Len())
+	}
+}
+
+func TestQueryData_MultipleMetrics(t *testing.T) {
+	d := newTestDatasource(SampleDatasourceSettings{})
+	now := time.Now()
+
+	resp, err := d.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{
+			RefID:     "A",
+			JSON:      []byte(`{"metric": "a, b,c"}`),
+			TimeRange: backend.TimeRange{From: now.Add(-time.Hour), To: now},
+			Interval:  time.Minute,
+		}},
+	})
+	if err != nil {
+		t.Fatalf("QueryData failed: %v", err)
+	}
+
+	response := resp.Responses["A"]
+	if response.Error != nil {
+		t.Fatalf("query failed: %v", response.Error)
+	}
+	if len(response.Frames) != 3 {
+		t.Fatalf("expected 3 frames, got %d", len(response.Frames))
+	}
+	names := make(map[string]bool)
+	refIDs := make(map[string]bool)
+	for _, frame := range response.Frames {
+		names[frame.Name] = true
+		refIDs[frame.RefID] = true
+	}
+	if !names["a"] || !names["b"] || !names["c"] {
+		t.Errorf("frame names = %v, want a, b and c", names)
+	}
+	if len(refIDs) != 3 {
+		t.Errorf("frame RefIDs = %v, want 3 distinct", refIDs)
+	}
+}
+
+func TestQueryData_MetaFields(t *testing.T) {
+	d := newTestDatasource(SampleDatasourceSettings{})
+	now := time.Now()
+
+	resp, err := d.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{
+			RefID:     "A",
+			JSON:      []byte(`{"metric": "cpu", "metaFields": {"threshold": 42.0}}`),
+			TimeRange: backend.TimeRange{From: now.Add(-time.Hour), To: now},
+			Interval:  time.Minute,
+		}},
+	})
+	if err != nil {
+		t.Fatalf("QueryData failed: %v", err)
+	}
+
+	response := resp.Responses["A"]
+	if response.Error != nil {
+		t.Fatalf("query failed: %v", response.Error)
+	}
+	frame := response.Frames[0]
+	custom, ok := frame.Meta.Custom.(map[string]interface{})
+	if !ok {
+		t.Fatalf("Meta.Custom = %T, want map[string]interface{}", frame.Meta.Custom)
+	}
+	if got := custom["threshold"]; got != 42.0 {
+		t.Errorf("Meta.Custom[threshold] = %v, want 42", got)
+	}
+	if frame.Meta.PreferredVisualization != data.VisTypeGraph {
+		t.Errorf("PreferredVisualization = %q, want it kept", frame.Meta.PreferredVisualization)
+	}
+}
+
+func TestHandleVariableQuery_Sources(t *testing.T) {
+	ctx := context.Background()
+
+	configured := newTestDatasource(SampleDatasourceSettings{
+		VariableMetrics: []string{"up", "scrape_duration_seconds", "go_goroutines"},
+	})
+	frame, err := configured.handleVariableQuery(ctx, SampleQuery{RefID: "A"})
+	if err != nil {
+		t.Fatalf("handleVariableQuery failed: %v", err)
+	}
+	if got := frame.Fields[0].At(1); got != "scrape_duration_seconds" {
+		t.Errorf("row 1 = %v, want scrape_duration_seconds", got)
+	}
+
+	dynamic := newTestDatasource(SampleDatasourceSettings{})
+	dynamic.listMetrics = func(ctx context.Context) ([]string, error) {
+		return []string{"from_api"}, nil
+	}
+	frame, err = dynamic.handleVariableQuery(ctx, SampleQuery{RefID: "A"})
+	if err != nil {
+		t.Fatalf("handleVariableQuery failed: %v", err)
+	}
+	if frame.Fields[0].Len() != 1 || frame.Fields[0].At(0) != "from_api" {
+		t.Errorf("expected the dynamic source's metrics, got %d rows", frame.Fields[0].Len())
+	}
+
+	failing := newTestDatasource(SampleDatasourceSettings{})
+	upstreamErr := errors.New("upstream unavailable")
+	failing.listMetrics = func(ctx context.Context) ([]string, error) {
+		return nil, upstreamErr
+	}
+	if _, err := failing.handleVariableQuery(ctx, SampleQuery{}); !errors.Is(err, upstreamErr) {
+		t.Errorf("expected upstream error, got %v", err)
+	}
+}
+
+func TestCheckHealth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	d := newTestDatasource(SampleDatasourceSettings{URL: server.URL, Timeout: 5})
+	result, err := d.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+	if err != nil {
+		t.Fatalf("CheckHealth failed: %v", err)
+	}
+	if result.Status != backend.HealthStatusOk {
+		t.Errorf("status = %v, want OK (message %q)", result.Status, result.Message)
+	}
+	if result.Message != "Data source is working" {
+		t.Errorf("message = %q", result.Message)
+	}
+
+	var details struct {
+		StatusCode int `json:"statusCode"`
+	}
+	if err := json.Unmarshal(result.JSONDetails, &details); err != nil {
+		t.Fatalf("invalid JSONDetails: %v", err)
+	}
+	if details.StatusCode != http.StatusOK {
+		t.Errorf("JSONDetails statusCode = %d, want 200", details.StatusCode)
+	}
+}
+
+func TestCheckHealth_UnexpectedStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	d := newTestDatasource(SampleDatasourceSettings{URL: server.URL, Timeout: 5})
+	result, err := d.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+	if err != nil {
+		t.Fatalf("CheckHealth failed: %v", err)
+	}
+	if result.Status != backend.HealthStatusError {
+		t.Errorf("status = %v, want Error", result.Status)
+	}
+	if result.Message != "Unexpected status code: 503" {
+		t.Errorf("message = %q", result.Message)
+	}
+	if !strings.Contains(string(result.JSONDetails), `"statusCode":503`) {
+		t.Errorf("JSONDetails = %s, want statusCode 503", result.JSONDetails)
+	}
+}
+
+func TestCheckHealth_ConnectionError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close() // nothing is listening any more
+
+	d := newTestDatasource(SampleDatasourceSettings{URL: url, Timeout: 5})
+	result, err := d.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+	if err != nil {
+		t.Fatalf("CheckHealth failed: %v", err)
+	}
+	if result.Status != backend.HealthStatusError {
+		t.Errorf("status = %v, want Error", result.Status)
+	}
+	if !strings.HasPrefix(result.Message, "Failed to connect:") {
+		t.Errorf("message = %q, want a connection error", result.Message)
+	}
+}
+
+func TestCheckHealth_MissingURL(t *testing.T) {
+	d := newTestDatasource(SampleDatasourceSettings{})
+	result, err := d.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+	if err != nil {
+		t.Fatalf("CheckHealth failed: %v", err)
+	}
+	if result.Status != backend.HealthStatusError || result.Message != "URL is not configured" {
+		t.Errorf("got %v %q, want Error \"URL is not configured\"", result.Status, result.Message)
+	}
+}
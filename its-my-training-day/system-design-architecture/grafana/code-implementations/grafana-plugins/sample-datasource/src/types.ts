@@ -51,8 +51,9 @@ export interface SampleQuery extends DataQuery {
    * Query format - determines how results are processed.
    * - 'time_series': Returns time-indexed data for graphs
    * - 'table': Returns tabular data for table panels
+   * - 'variable': Returns metric names for template variable dropdowns
    */
-  format?: 'time_series' | 'table';
+  format?: 'time_series' | 'table' | 'variable';
 
   /**
    * Maximum number of data points to return.
@@ -65,6 +66,12 @@ export interface SampleQuery extends DataQuery {
    * Useful for determining appropriate data resolution.
    */
   intervalMs?: number;
+
+  /**
+   * Plugin-specific metadata the backend merges into each frame's
+   * meta.custom (e.g., {threshold: 42} for annotation panels).
+   */
+  metaFields?: Record<string, unknown>;
 }
 
 /**
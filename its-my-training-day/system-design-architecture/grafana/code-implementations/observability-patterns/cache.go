@@ -0,0 +1,244 @@
+// Package observability provides an instrumented LRU cache.
+//
+// This file demonstrates:
+// - A generic least-recently-used cache backed by a map and doubly linked list
+// - Exposing cache hit, miss, and eviction counters for Prometheus
+// - Loading missing entries on demand with the cache-aside pattern
+//
+// Cache hit ratio is one of the first things to check when a query path gets
+// slower; rate(cache_hits_total) / (rate(cache_hits_total) + rate(cache_misses_total))
+// makes it visible on a dashboard.
+package observability
+
+import (
+	"context"
+	"sync"
+)
+
+// lruEntry is a node in the cache's recency list.
+type lruEntry[K comparable, V any] struct {
+	key   K
+	value V
+	prev  *lruEntry[K, V]
+	next  *lruEntry[K, V]
+}
+
+// LRUCache is a fixed-capacity cache that evicts the least recently used entry.
+// All methods are safe for concurrent use.
+type LRUCache[K comparable, V any] struct {
+	capacity int
+	items    map[K]*lruEntry[K, V]
+	head     *lruEntry[K, V] // Most recently used
+	tail     *lruEntry[K, V] // Least recently used
+	mu       sync.Mutex
+
+	hits      *Counter
+	misses    *Counter
+	evictions *Counter
+}
+
+// NewLRUCache creates a cache that holds at most capacity entries.
+func NewLRUCache[K comparable, V any](capacity int) *LRUCache[K, V] {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &LRUCache[K, V]{
+		capacity: capacity,
+		items:    make(map[K]*lruEntry[K, V], capacity),
+	}
+}
+
+// RegisterMetrics creates the cache's hit, miss, and eviction counters and
+// registers them with registry. The name is used as the metric namespace, so
+// a cache named "dashboard" exposes dashboard_cache_hits_total and so on.
+// Only operations after registration are counted. Like MustRegister, it
+// panics if registry already has metrics with these names, such as from
+// another cache registered under the same name.
+func (c *LRUCache[K, V]) RegisterMetrics(registry *MetricRegistry, name string) {
+	hits := NewCounter(MetricOpts{
+		Namespace: name,
+		Name:      "cache_hits_total",
+		Help:      "Total number of cache lookups that found an entry",
+	})
+	misses := NewCounter(MetricOpts{
+		Namespace: name,
+		Name:      "cache_misses_total",
+		Help:      "Total number of cache lookups that found no entry",
+	})
+	evictions := NewCounter(MetricOpts{
+		Namespace: name,
+		Name:      "cache_evictions_total",
+		Help:      "Total number of entries evicted to make room for new ones",
+	})
+
+	registry.MustRegister(hits, misses, evictions)
+
+	c.mu.Lock()
+	c.hits, c.misses, c.evictions = hits, misses, evictions
+	c.mu.Unlock()
+}
+
+// Get returns the value for key and marks it as most recently used.
+func (c *LRUCache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.items[key]
+	if !ok {
+		if c.misses != nil {
+			c.misses.Inc()
+		}
+		var zero V
+		return zero, false
+	}
+
+	if c.hits != nil {
+		c.hits.Inc()
+	}
+	c.moveToFront(entry)
+	return entry.value, true
+}
+
+// lookup is Get without counting a hit or miss, for callers that have
+// already counted the lookup.
+func (c *LRUCache[K, V]) lookup(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	c.moveToFront(entry)
+	return entry.value, true
+}
+
+// Set adds or updates key, evicting the least recently used entry if the
+// cache is full.
+func (c *LRUCache[K, V]) Set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, ok := c.items[key]; ok {
+		entry.value = value
+		c.moveToFront(entry)
+		return
+	}
+
+	if len(c.items) >= c.capacity {
+		oldest := c.tail
+		c.unlink(oldest)
+		delete(c.items, oldest.key)
+		if c.evictions != nil {
+			c.evictions.Inc()
+		}
+	}
+
+	entry := &lruEntry[K, V]{key: key, value: value}
+	c.pushFront(entry)
+	c.items[key] = entry
+}
+
+// Delete removes key from the cache. Deleting a missing key is a no-op.
+func (c *LRUCache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, ok := c.items[key]; ok {
+		c.unlink(entry)
+		delete(c.items, key)
+	}
+}
+
+// Len returns the number of entries in the cache.
+func (c *LRUCache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+// Cap returns the maximum number of entries the cache holds.
+func (c *LRUCache[K, V]) Cap() int {
+	return c.capacity
+}
+
+// moveToFront marks entry as most recently used. Caller must hold c.mu.
+func (c *LRUCache[K, V]) moveToFront(entry *lruEntry[K, V]) {
+	if c.head == entry {
+		return
+	}
+	c.unlink(entry)
+	c.pushFront(entry)
+}
+
+// pushFront inserts entry at the head of the list. Caller must hold c.mu.
+func (c *LRUCache[K, V]) pushFront(entry *lruEntry[K, V]) {
+	entry.prev = nil
+	entry.next = c.head
+	if c.head != nil {
+		c.head.prev = entry
+	}
+	c.head = entry
+	if c.tail == nil {
+		c.tail = entry
+	}
+}
+
+// unlink removes entry from the list. Caller must hold c.mu.
+func (c *LRUCache[K, V]) unlink(entry *lruEntry[K, V]) {
+	if entry.prev != nil {
+		entry.prev.next = entry.next
+	} else {
+		c.head = entry.next
+	}
+	if entry.next != nil {
+		entry.next.prev = entry.prev
+	} else {
+		c.tail = entry.prev
+	}
+	entry.prev, entry.next = nil, nil
+}
+
+// CacheAside loads values into an LRUCache on a miss. Concurrent misses for
+// the same key share one loader call, so an expired hot key does not send
+// a burst of identical requests to the backend.
+type CacheAside[K comparable, V any] struct {
+	cache    *LRUCache[K, V]
+	loader   func(ctx context.Context, key K) (V, error)
+	inflight *Coalescer[K, V]
+}
+
+// NewCacheAside wraps cache, calling loader to fill missing keys.
+func NewCacheAside[K comparable, V any](cache *LRUCache[K, V], loader func(ctx context.Context, key K) (V, error)) *CacheAside[K, V] {
+	return &CacheAside[K, V]{
+		cache:    cache,
+		loader:   loader,
+		inflight: NewCoalescer[K, V](),
+	}
+}
+
+// Get returns the cached value for key, calling the loader and caching its
+// result on a miss. Loader errors are returned but not cached, so the next
+// Get tries again. When misses are coalesced, the loader runs with the ctx
+// of the caller that started it.
+func (c *CacheAside[K, V]) Get(ctx context.Context, key K) (V, error) {
+	if v, ok := c.cache.Get(key); ok {
+		return v, nil
+	}
+
+	v, err, _ := c.inflight.Do(key, func() (V, error) {
+		// A load that finished between the lookup above and Do has
+		// already cached the value
+		if v, ok := c.cache.lookup(key); ok {
+			return v, nil
+		}
+		v, err := c.loader(ctx, key)
+		if err != nil {
+			return v, err
+		}
+		c.cache.Set(key, v)
+		return v, nil
+	})
+	return v, err
+}
@@ -0,0 +1,219 @@
+// Package observability provides tests for the instrumented LRU cache.
+package observability
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLRUCache_EvictionOrder(t *testing.T) {
+	cache := NewLRUCache[string, int](2)
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+
+	// Touch "a" so "b" becomes least recently used
+	if v, ok := cache.Get("a"); !ok || v != 1 {
+		t.Fatalf("Get(a) = %d, %v; want 1, true", v, ok)
+	}
+
+	cache.Set("c", 3)
+
+	if _, ok := cache.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	if _, ok := cache.Get("a"); !ok {
+		t.Error("expected a to remain cached")
+	}
+	if _, ok := cache.Get("c"); !ok {
+		t.Error("expected c to be cached")
+	}
+	if cache.Len() != 2 {
+		t.Errorf("expected Len() = 2, got %d", cache.Len())
+	}
+	if cache.Cap() != 2 {
+		t.Errorf("expected Cap() = 2, got %d", cache.Cap())
+	}
+}
+
+func TestLRUCache_UpdateAndDelete(t *testing.T) {
+	cache := NewLRUCache[string, int](2)
+
+	cache.Set("a", 1)
+	cache.Set("a", 10)
+	if v, _ := cache.Get("a"); v != 10 {
+		t.Errorf("expected updated value 10, got %d", v)
+	}
+	if cache.Len() != 1 {
+		t.Errorf("expected Len() = 1 after update, got %d", cache.Len())
+	}
+
+	cache.Delete("a")
+	cache.Delete("a") // deleting a missing key is a no-op
+	if cache.Len() != 0 {
+		t.Errorf("expected empty cache, got Len() = %d", cache.Len())
+	}
+
+	// The list must still be usable after removing the only entry
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+	cache.Set("d", 4)
+	if _, ok := cache.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+}
+
+func TestLRUCache_Concurrent(t *testing.T) {
+	cache := NewLRUCache[int, int](50)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := (g*1000 + i) % 100
+				cache.Set(key, i)
+				cache.Get(key)
+				if i%10 == 0 {
+					cache.Delete(key)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if cache.Len() > cache.Cap() {
+		t.Errorf("Len() = %d exceeds Cap() = %d", cache.Len(), cache.Cap())
+	}
+}
+
+func TestLRUCache_Metrics(t *testing.T) {
+	registry := NewMetricRegistry()
+	cache := NewLRUCache[string, string](2)
+
+	cache.RegisterMetrics(registry, "dashboard")
+
+	cache.Set("a", "1")
+	cache.Set("b", "2")
+	cache.Get("a")      // hit
+	cache.Get("a")      // hit
+	cache.Get("x")      // miss
+	cache.Set("c", "3") // evicts b
+	cache.Get("b")      // miss
+	cache.Set("d", "4") // evicts a
+
+	if got := cache.hits.Value(); got != 2 {
+		t.Errorf("expected 2 hits, got %v", got)
+	}
+	if got := cache.misses.Value(); got != 2 {
+		t.Errorf("expected 2 misses, got %v", got)
+	}
+	if got := cache.evictions.Value(); got != 2 {
+		t.Errorf("expected 2 evictions, got %v", got)
+	}
+
+	rec := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		"# TYPE dashboard_cache_hits_total counter",
+		"dashboard_cache_hits_total 2",
+		"dashboard_cache_misses_total 2",
+		"dashboard_cache_evictions_total 2",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected exposition to contain %q, got:\n%s", want, body)
+		}
+	}
+
+	// Registering a second cache under the same name must panic
+	defer func() {
+		if recover() == nil {
+			t.Error("expected duplicate registration to panic")
+		}
+	}()
+	NewLRUCache[string, string](1).RegisterMetrics(registry, "dashboard")
+}
+
+func TestCacheAside_HitSkipsLoader(t *testing.T) {
+	cache := NewLRUCache[string, int](10)
+	cache.Set("a", 1)
+
+	var calls atomic.Int32
+	aside := NewCacheAside(cache, func(ctx context.Context, key string) (int, error) {
+		calls.Add(1)
+		return 2, nil
+	})
+
+	if v, err := aside.Get(context.Background(), "a"); err != nil || v != 1 {
+		t.Errorf("Get(a) = %d, %v; want 1, nil", v, err)
+	}
+	if calls.Load() != 0 {
+		t.Errorf("loader called %d times on a hit", calls.Load())
+	}
+
+	// A miss loads and caches the value
+	if v, err := aside.Get(context.Background(), "b"); err != nil || v != 2 {
+		t.Errorf("Get(b) = %d, %v; want 2, nil", v, err)
+	}
+	if v, ok := cache.Get("b"); !ok || v != 2 {
+		t.Errorf("cache.Get(b) = %d, %v; want 2, true", v, ok)
+	}
+}
+
+func TestCacheAside_ConcurrentMissesLoadOnce(t *testing.T) {
+	var calls atomic.Int32
+	aside := NewCacheAside(NewLRUCache[string, int](10), func(ctx context.Context, key string) (int, error) {
+		calls.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		return 42, nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := aside.Get(context.Background(), "hot"); err != nil || v != 42 {
+				t.Errorf("Get(hot) = %d, %v; want 42, nil", v, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("loader called %d times, want 1", got)
+	}
+}
+
+func TestCacheAside_ErrorsNotCached(t *testing.T) {
+	cache := NewLRUCache[string, int](10)
+	errBackend := errors.New("backend down")
+	fail := true
+	aside := NewCacheAside(cache, func(ctx context.Context, key string) (int, error) {
+		if fail {
+			return 0, errBackend
+		}
+		return 3, nil
+	})
+
+	if _, err := aside.Get(context.Background(), "a"); !errors.Is(err, errBackend) {
+		t.Fatalf("Get(a) error = %v, want errBackend", err)
+	}
+	if cache.Len() != 0 {
+		t.Errorf("failed load was cached")
+	}
+
+	fail = false
+	if v, err := aside.Get(context.Background(), "a"); err != nil || v != 3 {
+		t.Errorf("Get(a) after recovery = %d, %v; want 3, nil", v, err)
+	}
+}
@@ -0,0 +1,167 @@
+// Package observability provides request coalescing for expensive loads.
+//
+// This file demonstrates:
+// - Collapsing concurrent calls for the same key into a single call
+// - Sharing one result, including its error, with every waiting caller
+// - Caching successful results for a TTL in a lock-striped map
+//
+// When a popular dashboard's cache entry expires, every panel refresh misses
+// at once; coalescing turns that thundering herd into one backend query.
+package observability
+
+import (
+	"fmt"
+	"hash/maphash"
+	"sync"
+	"time"
+)
+
+// coalescedCall is an in-flight call whose result is shared by every caller
+// that asked for the same key while it ran.
+type coalescedCall[V any] struct {
+	done  chan struct{} // Closed once val and err are set
+	val   V
+	err   error
+	dupes int // Callers that waited on this call instead of starting one
+}
+
+// Coalescer collapses concurrent calls with the same key so only one runs;
+// the others wait for it and receive the same result. Results are not kept
+// once the call returns. It is safe for concurrent use.
+type Coalescer[K comparable, V any] struct {
+	mu    sync.Mutex
+	calls map[K]*coalescedCall[V]
+}
+
+// NewCoalescer creates an empty Coalescer.
+func NewCoalescer[K comparable, V any]() *Coalescer[K, V] {
+	return &Coalescer[K, V]{calls: make(map[K]*coalescedCall[V])}
+}
+
+// Do calls fn unless a call for key is already running, in which case it
+// waits for that call and returns its result. shared reports whether the
+// result was given to more than one caller. If fn panics, the panic
+// propagates to the caller that ran it and the waiting callers get an error.
+func (c *Coalescer[K, V]) Do(key K, fn func() (V, error)) (v V, err error, shared bool) {
+	c.mu.Lock()
+	if call, ok := c.calls[key]; ok {
+		call.dupes++
+		c.mu.Unlock()
+		<-call.done
+		return call.val, call.err, true
+	}
+	call := &coalescedCall[V]{
+		done: make(chan struct{}),
+		err:  fmt.Errorf("coalesced call for %v panicked", key),
+	}
+	c.calls[key] = call
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.calls, key)
+		shared = call.dupes > 0
+		c.mu.Unlock()
+		close(call.done)
+	}()
+
+	call.val, call.err = fn()
+	return call.val, call.err, false
+}
+
+// ttlStripes is the number of independently locked shards in a
+// TTLCoalescer's result cache, so lookups for different keys rarely contend.
+const ttlStripes = 16
+
+// ttlEntry is a cached result and the time it stops being served.
+type ttlEntry[V any] struct {
+	val     V
+	expires time.Time
+}
+
+// ttlStripe is one shard of a TTLCoalescer's result cache.
+type ttlStripe[K comparable, V any] struct {
+	mu      sync.RWMutex
+	entries map[K]ttlEntry[V]
+}
+
+// TTLCoalescer is a Coalescer that also caches each successful result for
+// a fixed TTL, so calls within the TTL return it without calling fn at
+// all. Failed calls are not cached: the next call retries. It is safe for
+// concurrent use.
+type TTLCoalescer[K comparable, V any] struct {
+	ttl     time.Duration
+	seed    maphash.Seed
+	stripes [ttlStripes]ttlStripe[K, V]
+	calls   *Coalescer[K, V]
+}
+
+// NewTTLCoalescer creates a TTLCoalescer that caches results for ttl.
+func NewTTLCoalescer[K comparable, V any](ttl time.Duration) *TTLCoalescer[K, V] {
+	c := &TTLCoalescer[K, V]{
+		ttl:   ttl,
+		seed:  maphash.MakeSeed(),
+		calls: NewCoalescer[K, V](),
+	}
+	for i := range c.stripes {
+		c.stripes[i].entries = make(map[K]ttlEntry[V])
+	}
+	return c
+}
+
+// Get returns the cached result for key if it has not expired. Otherwise
+// it calls fn, sharing the call with concurrent callers for the same key
+// as Coalescer.Do does, and caches the result for the TTL if fn succeeds.
+func (c *TTLCoalescer[K, V]) Get(key K, fn func() (V, error)) (V, error) {
+	stripe := c.stripe(key)
+	if v, ok := stripe.get(key, time.Now()); ok {
+		return v, nil
+	}
+
+	v, err, _ := c.calls.Do(key, func() (V, error) {
+		// A call that finished between the lookup above and Do has
+		// already cached a fresh result
+		if v, ok := stripe.get(key, time.Now()); ok {
+			return v, nil
+		}
+		v, err := fn()
+		if err == nil {
+			stripe.set(key, v, time.Now().Add(c.ttl))
+		}
+		return v, err
+	})
+	return v, err
+}
+
+// stripe returns the shard that holds key. Equal keys format identically,
+// so hashing the formatted key always selects the same shard.
+func (c *TTLCoalescer[K, V]) stripe(key K) *ttlStripe[K, V] {
+	return &c.stripes[maphash.String(c.seed, fmt.Sprint(key))%ttlStripes]
+}
+
+// get returns the unexpired entry for key.
+func (s *ttlStripe[K, V]) get(key K, now time.Time) (V, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entry, ok := s.entries[key]
+	if !ok || !now.Before(entry.expires) {
+		var zero V
+		return zero, false
+	}
+	return entry.val, true
+}
+
+// set stores an entry and drops the stripe's expired entries, so keys
+// that are never requested again do not accumulate. Stores only follow a
+// call to fn, which dwarfs the cost of the sweep.
+func (s *ttlStripe[K, V]) set(key K, val V, expires time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for k, entry := range s.entries {
+		if !now.Before(entry.expires) {
+			delete(s.entries, k)
+		}
+	}
+	s.entries[key] = ttlEntry[V]{val: val, expires: expires}
+}
@@ -0,0 +1,128 @@
+// Package observability provides tests for request coalescing.
+package observability
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCoalescer_CollapsesConcurrentCalls(t *testing.T) {
+	c := NewCoalescer[string, int]()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fn := func() (int, error) {
+		calls.Add(1)
+		<-release
+		return 42, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]int, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _, _ = c.Do("key", fn)
+		}(i)
+	}
+
+	// Give every goroutine time to join the in-flight call
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("fn called %d times, want 1", got)
+	}
+	for i, v := range results {
+		if v != 42 {
+			t.Errorf("results[%d] = %d, want 42", i, v)
+		}
+	}
+}
+
+func TestCoalescer_ErrorsAndSequentialCalls(t *testing.T) {
+	c := NewCoalescer[string, int]()
+	errBackend := errors.New("backend down")
+
+	if _, err, shared := c.Do("key", func() (int, error) { return 0, errBackend }); !errors.Is(err, errBackend) || shared {
+		t.Errorf("Do() = %v, shared %v; want errBackend, not shared", err, shared)
+	}
+
+	// Results are not kept once the call returns
+	v, err, _ := c.Do("key", func() (int, error) { return 7, nil })
+	if err != nil || v != 7 {
+		t.Errorf("second Do() = %d, %v; want 7, nil", v, err)
+	}
+}
+
+func TestCoalescer_Panic(t *testing.T) {
+	c := NewCoalescer[string, int]()
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic to propagate")
+			}
+		}()
+		c.Do("key", func() (int, error) { panic("boom") })
+	}()
+
+	// The key is released after a panic
+	if v, err, _ := c.Do("key", func() (int, error) { return 1, nil }); err != nil || v != 1 {
+		t.Errorf("Do() after panic = %d, %v; want 1, nil", v, err)
+	}
+}
+
+func TestTTLCoalescer_CachesForTTL(t *testing.T) {
+	c := NewTTLCoalescer[string, int](100 * time.Millisecond)
+
+	var calls atomic.Int32
+	fn := func() (int, error) {
+		return int(calls.Add(1)), nil
+	}
+
+	for i := 0; i < 5; i++ {
+		v, err := c.Get("key", fn)
+		if err != nil || v != 1 {
+			t.Fatalf("Get #%d = %d, %v; want 1, nil", i, v, err)
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("fn called %d times within TTL, want 1", got)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if v, _ := c.Get("key", fn); v != 2 {
+		t.Errorf("Get after TTL = %d, want 2", v)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("fn called %d times after TTL, want 2", got)
+	}
+}
+
+func TestTTLCoalescer_DoesNotCacheErrors(t *testing.T) {
+	c := NewTTLCoalescer[string, int](time.Minute)
+
+	var calls atomic.Int32
+	fn := func() (int, error) {
+		if calls.Add(1) == 1 {
+			return 0, errors.New("backend down")
+		}
+		return 7, nil
+	}
+
+	if _, err := c.Get("key", fn); err == nil {
+		t.Fatal("expected error from first call")
+	}
+	if v, err := c.Get("key", fn); err != nil || v != 7 {
+		t.Errorf("Get after error = %d, %v; want 7, nil", v, err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("fn called %d times, want 2", got)
+	}
+}
@@ -0,0 +1,130 @@
+// Package observability provides feature flags for toggling instrumentation
+// behavior at runtime.
+//
+// This file demonstrates:
+// - A single FeatureFlag interface shared by all toggles
+// - Static, environment-variable, and in-memory flag backends
+// - Composing flags with AnyFlag and AllFlags
+//
+// Behaviors such as async logging, span stack capture, and query caching are
+// often toggled by config fields. Expressing them as FeatureFlag values lets
+// callers swap the backend (config, environment, remote flag service) without
+// touching the code that checks the flag.
+package observability
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync/atomic"
+)
+
+// FeatureFlag reports whether a feature is enabled.
+// The context allows backends to make per-request decisions (e.g., by tenant).
+type FeatureFlag interface {
+	IsEnabled(ctx context.Context) bool
+}
+
+// FlagFunc adapts an ordinary function to the FeatureFlag interface.
+type FlagFunc func(ctx context.Context) bool
+
+// IsEnabled calls f(ctx).
+func (f FlagFunc) IsEnabled(ctx context.Context) bool {
+	return f(ctx)
+}
+
+// StaticFlag returns a flag that always reports v.
+func StaticFlag(v bool) FeatureFlag {
+	return FlagFunc(func(ctx context.Context) bool {
+		return v
+	})
+}
+
+// EnvVarFlag returns a flag backed by the environment variable envKey.
+// The variable is read on every check, so changes are picked up without a
+// restart. Accepted values are those of strconv.ParseBool plus "on"/"off"
+// and "yes"/"no"; unset or unparsable values yield defaultValue.
+func EnvVarFlag(envKey string, defaultValue bool) FeatureFlag {
+	return FlagFunc(func(ctx context.Context) bool {
+		raw, ok := os.LookupEnv(envKey)
+		if !ok {
+			return defaultValue
+		}
+		if v, ok := parseFlagValue(raw); ok {
+			return v
+		}
+		return defaultValue
+	})
+}
+
+// parseFlagValue parses common boolean spellings used in environment variables.
+func parseFlagValue(raw string) (bool, bool) {
+	switch toLower(raw) {
+	case "on", "yes", "y", "enabled":
+		return true, true
+	case "off", "no", "n", "disabled":
+		return false, true
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, false
+	}
+	return v, true
+}
+
+// ToggleFlag is an in-memory flag that can be flipped at runtime.
+// It is primarily intended for tests and admin endpoints.
+type ToggleFlag struct {
+	enabled int32 // Atomic: 1 if enabled
+}
+
+// InMemoryFlag creates a ToggleFlag with the given initial value.
+func InMemoryFlag(initial bool) *ToggleFlag {
+	f := &ToggleFlag{}
+	f.Set(initial)
+	return f
+}
+
+// IsEnabled reports the current value of the flag.
+func (f *ToggleFlag) IsEnabled(ctx context.Context) bool {
+	return atomic.LoadInt32(&f.enabled) == 1
+}
+
+// Set changes the value of the flag.
+func (f *ToggleFlag) Set(v bool) {
+	var n int32
+	if v {
+		n = 1
+	}
+	atomic.StoreInt32(&f.enabled, n)
+}
+
+// AnyFlag returns a flag that is enabled if any of the given flags is enabled.
+// This is useful for "enabled globally OR for this tenant" style checks:
+//
+//	asyncLogging := AnyFlag(EnvVarFlag("ASYNC_LOGGING", false), tenantFlag)
+func AnyFlag(flags ...FeatureFlag) FeatureFlag {
+	return FlagFunc(func(ctx context.Context) bool {
+		for _, f := range flags {
+			if f.IsEnabled(ctx) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// AllFlags returns a flag that is enabled only if every given flag is enabled.
+// This is useful for an operator kill switch layered on top of a rollout flag:
+//
+//	queryCache := AllFlags(rolloutFlag, EnvVarFlag("QUERY_CACHE_ENABLED", true))
+func AllFlags(flags ...FeatureFlag) FeatureFlag {
+	return FlagFunc(func(ctx context.Context) bool {
+		for _, f := range flags {
+			if !f.IsEnabled(ctx) {
+				return false
+			}
+		}
+		return true
+	})
+}
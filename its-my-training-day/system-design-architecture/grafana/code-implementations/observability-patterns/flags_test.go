@@ -0,0 +1,98 @@
+// Package observability provides tests for feature flags.
+package observability
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStaticFlag(t *testing.T) {
+	ctx := context.Background()
+
+	if !StaticFlag(true).IsEnabled(ctx) {
+		t.Error("StaticFlag(true) should be enabled")
+	}
+	if StaticFlag(false).IsEnabled(ctx) {
+		t.Error("StaticFlag(false) should be disabled")
+	}
+}
+
+func TestEnvVarFlag(t *testing.T) {
+	const key = "OBSERVABILITY_TEST_FLAG"
+
+	tests := []struct {
+		name         string
+		value        string
+		set          bool
+		defaultValue bool
+		want         bool
+	}{
+		{name: "unset uses default true", set: false, defaultValue: true, want: true},
+		{name: "unset uses default false", set: false, defaultValue: false, want: false},
+		{name: "true", value: "true", set: true, want: true},
+		{name: "numeric one", value: "1", set: true, want: true},
+		{name: "on", value: "ON", set: true, want: true},
+		{name: "yes", value: "yes", set: true, want: true},
+		{name: "false", value: "false", set: true, defaultValue: true, want: false},
+		{name: "off", value: "off", set: true, defaultValue: true, want: false},
+		{name: "invalid uses default", value: "maybe", set: true, defaultValue: true, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.set {
+				t.Setenv(key, tt.value)
+			}
+			flag := EnvVarFlag(key, tt.defaultValue)
+			if got := flag.IsEnabled(context.Background()); got != tt.want {
+				t.Errorf("EnvVarFlag(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInMemoryFlag_Toggle(t *testing.T) {
+	ctx := context.Background()
+	flag := InMemoryFlag(false)
+
+	if flag.IsEnabled(ctx) {
+		t.Error("InMemoryFlag(false) should start disabled")
+	}
+
+	flag.Set(true)
+	if !flag.IsEnabled(ctx) {
+		t.Error("flag should be enabled after Set(true)")
+	}
+
+	flag.Set(false)
+	if flag.IsEnabled(ctx) {
+		t.Error("flag should be disabled after Set(false)")
+	}
+}
+
+func TestFlagComposition(t *testing.T) {
+	ctx := context.Background()
+	a := InMemoryFlag(false)
+	b := InMemoryFlag(true)
+
+	anyFlag := AnyFlag(a, b)
+	allFlags := AllFlags(a, b)
+
+	if !anyFlag.IsEnabled(ctx) {
+		t.Error("AnyFlag should be enabled when one flag is enabled")
+	}
+	if allFlags.IsEnabled(ctx) {
+		t.Error("AllFlags should be disabled when one flag is disabled")
+	}
+
+	a.Set(true)
+	if !allFlags.IsEnabled(ctx) {
+		t.Error("AllFlags should be enabled when all flags are enabled")
+	}
+
+	a.Set(false)
+	b.Set(false)
+	if anyFlag.IsEnabled(ctx) {
+		t.Error("AnyFlag should be disabled when no flag is enabled")
+	}
+}
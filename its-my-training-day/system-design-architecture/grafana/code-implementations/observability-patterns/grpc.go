@@ -0,0 +1,54 @@
+// Package observability provides trace context propagation over gRPC
+// metadata.
+//
+// This file demonstrates:
+// - Injecting the active trace into outgoing gRPC metadata
+// - Extracting a remote trace from incoming gRPC metadata
+// - Delegating to the carrier-agnostic InjectMap and ExtractMap
+//
+// gRPC metadata is a map of lowercase keys to string slices. GRPCMetadata has
+// the same underlying type as google.golang.org/grpc/metadata.MD, so the two
+// convert directly and this package does not depend on gRPC:
+//
+//	// Client interceptor
+//	md, _ := metadata.FromOutgoingContext(ctx)
+//	md = metadata.MD(InjectGRPCMetadata(ctx, GRPCMetadata(md)))
+//	ctx = metadata.NewOutgoingContext(ctx, md)
+//
+//	// Server interceptor
+//	md, _ := metadata.FromIncomingContext(ctx)
+//	ctx = ExtractGRPCMetadata(ctx, GRPCMetadata(md))
+package observability
+
+import "context"
+
+// GRPCMetadata mirrors google.golang.org/grpc/metadata.MD.
+type GRPCMetadata map[string][]string
+
+// InjectGRPCMetadata returns a copy of md with the trace context from ctx
+// (trace ID, current span ID, and sampled flag) stored under the
+// "traceparent" key. md is not modified and may be nil. If ctx carries no
+// trace, the copy is returned without a traceparent.
+func InjectGRPCMetadata(ctx context.Context, md GRPCMetadata) GRPCMetadata {
+	out := make(GRPCMetadata, len(md)+1)
+	for k, v := range md {
+		out[k] = append([]string(nil), v...)
+	}
+	carrier := make(map[string]string, 1)
+	InjectMap(ctx, carrier)
+	for k, v := range carrier {
+		out[k] = []string{v}
+	}
+	return out
+}
+
+// ExtractGRPCMetadata returns a context carrying the trace context found in
+// md, in the same form as the HTTP middleware: the remote span ID is stored
+// under ParentSpanIDKey. Missing or malformed metadata leaves ctx unchanged.
+func ExtractGRPCMetadata(ctx context.Context, md GRPCMetadata) context.Context {
+	values := md[traceparentKey]
+	if len(values) == 0 {
+		return ctx
+	}
+	return ExtractMap(ctx, map[string]string{traceparentKey: values[0]})
+}
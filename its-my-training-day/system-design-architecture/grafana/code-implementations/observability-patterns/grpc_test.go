@@ -0,0 +1,58 @@
+// Package observability provides tests for gRPC trace context propagation.
+package observability
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGRPCMetadata_RoundTrip(t *testing.T) {
+	tracer := NewTracer(TracerConfig{
+		ServiceName: "client",
+		Sampler:     &AlwaysSampler{},
+		Exporter:    NewInMemoryExporter(),
+	})
+	clientCtx, clientSpan := tracer.StartSpan(context.Background(), "client-call", SpanKindClient)
+
+	outgoing := GRPCMetadata{"x-scope-orgid": {"tenant-1"}}
+	md := InjectGRPCMetadata(clientCtx, outgoing)
+
+	if got := md["x-scope-orgid"]; len(got) != 1 || got[0] != "tenant-1" {
+		t.Errorf("existing metadata should be preserved, got %v", got)
+	}
+	if _, ok := outgoing["traceparent"]; ok {
+		t.Error("InjectGRPCMetadata should not modify its argument")
+	}
+
+	serverCtx := ExtractGRPCMetadata(context.Background(), md)
+
+	if got := serverCtx.Value(TraceIDKey); got != clientSpan.TraceID {
+		t.Errorf("server trace ID = %v, want %v", got, clientSpan.TraceID)
+	}
+	if got := serverCtx.Value(ParentSpanIDKey); got != clientSpan.SpanID {
+		t.Errorf("server parent span ID = %v, want %v", got, clientSpan.SpanID)
+	}
+	if sampled, _ := serverCtx.Value(SampledKey).(bool); !sampled {
+		t.Error("sampled flag should propagate")
+	}
+
+	// A server span continues the client's trace
+	_, serverSpan := tracer.StartSpan(serverCtx, "server-handle", SpanKindServer)
+	if serverSpan.TraceID != clientSpan.TraceID {
+		t.Errorf("server span trace ID = %v, want %v", serverSpan.TraceID, clientSpan.TraceID)
+	}
+}
+
+func TestGRPCMetadata_NoTrace(t *testing.T) {
+	md := InjectGRPCMetadata(context.Background(), nil)
+	if len(md) != 0 {
+		t.Errorf("expected empty metadata without a trace, got %v", md)
+	}
+
+	ctx := context.Background()
+	for _, md := range []GRPCMetadata{nil, {"traceparent": {"garbage"}}} {
+		if got := ExtractGRPCMetadata(ctx, md); got.Value(TraceIDKey) != nil {
+			t.Errorf("ExtractGRPCMetadata(%v) should not set a trace ID", md)
+		}
+	}
+}
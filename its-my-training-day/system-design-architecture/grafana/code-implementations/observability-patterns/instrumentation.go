@@ -19,11 +19,17 @@ package observability
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -77,6 +83,10 @@ type MetricOpts struct {
 	Labels []string
 	// Buckets are histogram bucket boundaries (for histograms only)
 	Buckets []float64
+	// MaxLabelCardinality caps the number of distinct label value
+	// combinations. Observations for new combinations beyond the cap are
+	// dropped and counted in CardinalityOverflowTotal. 0 means no limit.
+	MaxLabelCardinality int
 }
 
 // FullName returns the fully qualified metric name.
@@ -94,12 +104,38 @@ func (o MetricOpts) FullName() string {
 	return o.Name
 }
 
+// CardinalityOverflowTotal counts observations dropped because a metric
+// reached its MaxLabelCardinality, labelled by the metric's full name.
+// Register it to alert on a label that is leaking unbounded values such as
+// user or request IDs.
+var CardinalityOverflowTotal = NewCounter(MetricOpts{
+	Name:   "metric_cardinality_overflow_total",
+	Help:   "Total number of observations dropped because a metric exceeded its label cardinality limit",
+	Labels: []string{"metric"},
+})
+
+// admitSeries reports whether a metric that already has existing series
+// may create another one, counting the observation as an overflow if not.
+func (o MetricOpts) admitSeries(existing int) bool {
+	if o.MaxLabelCardinality <= 0 || existing < o.MaxLabelCardinality {
+		return true
+	}
+	CardinalityOverflowTotal.Inc(o.FullName())
+	return false
+}
+
 // DefaultHistogramBuckets provides sensible defaults for HTTP latency histograms.
 // These buckets cover typical web service latencies from 5ms to 10s.
 var DefaultHistogramBuckets = []float64{
 	0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10,
 }
 
+// DefaultSizeBuckets covers request and response sizes from 100B to 100MB,
+// in bytes, in steps of 10x.
+var DefaultSizeBuckets = []float64{
+	100, 1e3, 1e4, 1e5, 1e6, 1e7, 1e8,
+}
+
 // Counter represents a Prometheus counter metric.
 // Counters only increase and reset to zero on restart.
 //
@@ -108,16 +144,18 @@ var DefaultHistogramBuckets = []float64{
 // - Total errors encountered
 // - Total bytes processed
 type Counter struct {
-	opts   MetricOpts
-	values map[string]float64
-	mu     sync.RWMutex
+	opts    MetricOpts
+	values  map[string]float64
+	created map[string]time.Time // When each label combination was first seen
+	mu      sync.RWMutex
 }
 
 // NewCounter creates a new counter metric.
 func NewCounter(opts MetricOpts) *Counter {
 	return &Counter{
-		opts:   opts,
-		values: make(map[string]float64),
+		opts:    opts,
+		values:  make(map[string]float64),
+		created: make(map[string]time.Time),
 	}
 }
 
@@ -135,10 +173,33 @@ func (c *Counter) Add(value float64, labelValues ...string) {
 
 	key := c.labelKey(labelValues)
 	c.mu.Lock()
+	if _, exists := c.created[key]; !exists {
+		if !c.opts.admitSeries(len(c.created)) {
+			c.mu.Unlock()
+			return
+		}
+		c.created[key] = time.Now()
+	}
 	c.values[key] += value
 	c.mu.Unlock()
 }
 
+// IncIf increments the counter if condition is true, replacing
+// "if cond { c.Inc(...) }" in hot loops that count filtered events.
+func (c *Counter) IncIf(condition bool, labelValues ...string) {
+	if condition {
+		c.Add(1, labelValues...)
+	}
+}
+
+// AddIf adds value if condition is true. As with Add, negative values are
+// ignored.
+func (c *Counter) AddIf(condition bool, value float64, labelValues ...string) {
+	if condition {
+		c.Add(value, labelValues...)
+	}
+}
+
 // Value returns the current counter value for the given label values.
 func (c *Counter) Value(labelValues ...string) float64 {
 	key := c.labelKey(labelValues)
@@ -147,19 +208,29 @@ func (c *Counter) Value(labelValues ...string) float64 {
 	return c.values[key]
 }
 
+// Reset removes every series from the counter, so Value returns 0 for all
+// label values. It is intended for isolating tests that share a counter.
+// Do not call it in production: Prometheus sees the drop to zero as a
+// counter reset, which rate() and increase() treat as a discontinuity.
+func (c *Counter) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values = make(map[string]float64)
+	c.created = make(map[string]time.Time)
+}
+
+// labelKeySeparator joins label values into a series key. 0xff never
+// appears in valid UTF-8, so unlike a comma it cannot occur inside a value.
+const labelKeySeparator = "\xff"
+
+// joinLabelValues creates a unique series key from label values.
+func joinLabelValues(labelValues []string) string {
+	return strings.Join(labelValues, labelKeySeparator)
+}
+
 // labelKey creates a unique key from label values.
 func (c *Counter) labelKey(labelValues []string) string {
-	if len(labelValues) == 0 {
-		return ""
-	}
-	key := ""
-	for i, v := range labelValues {
-		if i > 0 {
-			key += ","
-		}
-		key += v
-	}
-	return key
+	return joinLabelValues(labelValues)
 }
 
 // Describe returns the metric description in Prometheus format.
@@ -193,10 +264,20 @@ func NewGauge(opts MetricOpts) *Gauge {
 func (g *Gauge) Set(value float64, labelValues ...string) {
 	key := g.labelKey(labelValues)
 	g.mu.Lock()
-	g.values[key] = value
+	if g.admitSeries(key) {
+		g.values[key] = value
+	}
 	g.mu.Unlock()
 }
 
+// SetToCurrentTime sets the gauge to the current Unix time in seconds.
+// Use it for "last success" gauges such as
+// last_successful_sync_timestamp_seconds, and alert on
+// time() - last_successful_sync_timestamp_seconds > threshold.
+func (g *Gauge) SetToCurrentTime(labelValues ...string) {
+	g.Set(float64(time.Now().Unix()), labelValues...)
+}
+
 // Inc increments the gauge by 1.
 func (g *Gauge) Inc(labelValues ...string) {
 	g.Add(1, labelValues...)
@@ -207,11 +288,28 @@ func (g *Gauge) Dec(labelValues ...string) {
 	g.Add(-1, labelValues...)
 }
 
+// Track increments the gauge and returns a function that decrements it,
+// so an in-flight gauge cannot be left raised by a forgotten Dec:
+//
+//	defer inflight.Track("GET", "/api")()
+//
+// Calling the returned function more than once is a no-op; only the first
+// call decrements.
+func (g *Gauge) Track(labelValues ...string) func() {
+	g.Inc(labelValues...)
+	var once sync.Once
+	return func() {
+		once.Do(func() { g.Dec(labelValues...) })
+	}
+}
+
 // Add adds the given value to the gauge (can be negative).
 func (g *Gauge) Add(value float64, labelValues ...string) {
 	key := g.labelKey(labelValues)
 	g.mu.Lock()
-	g.values[key] += value
+	if g.admitSeries(key) {
+		g.values[key] += value
+	}
 	g.mu.Unlock()
 }
 
@@ -223,19 +321,26 @@ func (g *Gauge) Value(labelValues ...string) float64 {
 	return g.values[key]
 }
 
+// Reset removes every series from the gauge. Like Counter.Reset, it is
+// intended only for testing.
+func (g *Gauge) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.values = make(map[string]float64)
+}
+
+// admitSeries reports whether key is an existing series or may be added
+// under the cardinality limit. Caller must hold g.mu.
+func (g *Gauge) admitSeries(key string) bool {
+	if _, exists := g.values[key]; exists {
+		return true
+	}
+	return g.opts.admitSeries(len(g.values))
+}
+
 // labelKey creates a unique key from label values.
 func (g *Gauge) labelKey(labelValues []string) string {
-	if len(labelValues) == 0 {
-		return ""
-	}
-	key := ""
-	for i, v := range labelValues {
-		if i > 0 {
-			key += ","
-		}
-		key += v
-	}
-	return key
+	return joinLabelValues(labelValues)
 }
 
 // Describe returns the metric description in Prometheus format.
@@ -257,11 +362,15 @@ type Histogram struct {
 	opts    MetricOpts
 	buckets []float64
 	counts  map[string]*histogramData
-	mu      sync.RWMutex
+	// overrides maps a label key to buckets used instead of the defaults
+	overrides map[string][]float64
+	mu        sync.RWMutex
 }
 
 // histogramData holds the internal state for a histogram.
 type histogramData struct {
+	created      time.Time // When this series was first observed
+	buckets      []float64 // Bucket boundaries this series was created with
 	bucketCounts []uint64  // Count per bucket
 	sum          float64   // Sum of all observed values
 	count        uint64    // Total number of observations
@@ -290,8 +399,17 @@ func (h *Histogram) Observe(value float64, labelValues ...string) {
 
 	data, exists := h.counts[key]
 	if !exists {
+		if !h.opts.admitSeries(len(h.counts)) {
+			return
+		}
+		buckets := h.buckets
+		if override, ok := h.overrides[key]; ok {
+			buckets = override
+		}
 		data = &histogramData{
-			bucketCounts: make([]uint64, len(h.buckets)+1), // +1 for +Inf bucket
+			created:      time.Now(),
+			buckets:      buckets,
+			bucketCounts: make([]uint64, len(buckets)+1), // +1 for +Inf bucket
 		}
 		h.counts[key] = data
 	}
@@ -301,13 +419,38 @@ func (h *Histogram) Observe(value float64, labelValues ...string) {
 	data.count++
 
 	// Update bucket counts
-	for i, bound := range h.buckets {
+	for i, bound := range data.buckets {
 		if value <= bound {
 			data.bucketCounts[i]++
 		}
 	}
 	// Always increment +Inf bucket
-	data.bucketCounts[len(h.buckets)]++
+	data.bucketCounts[len(data.buckets)]++
+}
+
+// WithBucketsForLabels overrides the bucket boundaries for one label
+// combination. Use it when series of the same histogram have very different
+// distributions, e.g. a fast /health endpoint and a slow /upload endpoint:
+//
+//	duration := NewHistogram(opts).
+//	    WithBucketsForLabels([]string{"/health"}, []float64{.001, .0025, .005, .01}).
+//	    WithBucketsForLabels([]string{"/upload"}, []float64{1, 5, 15, 30, 60})
+//
+// Overrides must be configured before the first observation for that label
+// combination; series that already exist keep their original buckets.
+func (h *Histogram) WithBucketsForLabels(labelValues []string, buckets []float64) *Histogram {
+	sorted := make([]float64, len(buckets))
+	copy(sorted, buckets)
+	sort.Float64s(sorted)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.overrides == nil {
+		h.overrides = make(map[string][]float64)
+	}
+	h.overrides[h.labelKey(labelValues)] = sorted
+	return h
 }
 
 // ObserveDuration is a convenience method for timing operations.
@@ -341,19 +484,44 @@ func (h *Histogram) Count(labelValues ...string) uint64 {
 	return 0
 }
 
-// labelKey creates a unique key from label values.
-func (h *Histogram) labelKey(labelValues []string) string {
-	if len(labelValues) == 0 {
-		return ""
+// Reset zeroes the count, sum and buckets of every observed series, giving
+// tests that share a package-level histogram a clean slate. The series
+// themselves are kept, with their bucket boundaries, and are exported as
+// zero until observed again. Like Counter.Reset, it is intended only for
+// testing; the _count and _sum series are counters to Prometheus.
+func (h *Histogram) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, data := range h.counts {
+		data.reset()
 	}
-	key := ""
-	for i, v := range labelValues {
-		if i > 0 {
-			key += ","
-		}
-		key += v
+}
+
+// ResetLabels zeroes the series for one label combination, as Reset does
+// for all of them. Resetting a series that was never observed is a no-op.
+func (h *Histogram) ResetLabels(labelValues ...string) {
+	key := h.labelKey(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if data, exists := h.counts[key]; exists {
+		data.reset()
+	}
+}
+
+// reset zeroes the series and restarts its created timestamp, which marks
+// the counter reset for Prometheus.
+func (d *histogramData) reset() {
+	d.created = time.Now()
+	for i := range d.bucketCounts {
+		d.bucketCounts[i] = 0
 	}
-	return key
+	d.sum = 0
+	d.count = 0
+}
+
+// labelKey creates a unique key from label values.
+func (h *Histogram) labelKey(labelValues []string) string {
+	return joinLabelValues(labelValues)
 }
 
 // Describe returns the metric description in Prometheus format.
@@ -388,6 +556,9 @@ type REDMetrics struct {
 	RequestDuration *Histogram
 	// InFlightRequests tracks currently processing requests (optional)
 	InFlightRequests *Gauge
+	// RequestBodySize tracks request body sizes in bytes (optional).
+	// Unusually large bodies can indicate misuse or an attack
+	RequestBodySize *Histogram
 }
 
 // NewREDMetrics creates a new set of RED metrics for a service.
@@ -424,7 +595,67 @@ func NewREDMetrics(namespace, subsystem string) *REDMetrics {
 			Help:      "Number of requests currently being processed",
 			Labels:    []string{"method", "endpoint"},
 		}),
+		RequestBodySize: NewHistogram(MetricOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "request_body_size_bytes",
+			Help:      "Size of request bodies read by handlers, in bytes",
+			Labels:    []string{"method", "endpoint"},
+			Buckets:   DefaultSizeBuckets,
+		}),
+	}
+}
+
+// WithNamespace returns a new REDMetrics with the same metrics renamed
+// under namespace, so a shared library can define one base RED set and
+// each service can export it under its own prefix. The new metrics start
+// empty; r is unchanged.
+func (r *REDMetrics) WithNamespace(namespace string) *REDMetrics {
+	return r.renamed(func(opts *MetricOpts) { opts.Namespace = namespace })
+}
+
+// WithSubsystem returns a new REDMetrics with the same metrics renamed
+// under subsystem, as WithNamespace does for the namespace.
+func (r *REDMetrics) WithSubsystem(subsystem string) *REDMetrics {
+	return r.renamed(func(opts *MetricOpts) { opts.Subsystem = subsystem })
+}
+
+// renamed builds empty copies of r's metrics with rename applied to their
+// options. Histogram bucket overrides are carried over.
+func (r *REDMetrics) renamed(rename func(opts *MetricOpts)) *REDMetrics {
+	requests, errs, inFlight := r.RequestsTotal.opts, r.RequestErrors.opts, r.InFlightRequests.opts
+	for _, opts := range []*MetricOpts{&requests, &errs, &inFlight} {
+		rename(opts)
+	}
+
+	return &REDMetrics{
+		RequestsTotal:    NewCounter(requests),
+		RequestErrors:    NewCounter(errs),
+		RequestDuration:  r.RequestDuration.renamed(rename),
+		InFlightRequests: NewGauge(inFlight),
+		RequestBodySize:  r.RequestBodySize.renamed(rename),
+	}
+}
+
+// renamed returns an empty copy of h with rename applied to its options
+// and the same bucket overrides. It returns nil for a nil histogram.
+func (h *Histogram) renamed(rename func(opts *MetricOpts)) *Histogram {
+	if h == nil {
+		return nil
+	}
+	opts := h.opts
+	rename(&opts)
+	copied := NewHistogram(opts)
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for key, buckets := range h.overrides {
+		if copied.overrides == nil {
+			copied.overrides = make(map[string][]float64)
+		}
+		copied.overrides[key] = buckets
 	}
+	return copied
 }
 
 // RecordRequest records metrics for a completed request.
@@ -453,6 +684,42 @@ func (r *REDMetrics) EndRequest(method, endpoint string) {
 	r.InFlightRequests.Dec(method, endpoint)
 }
 
+// P50 estimates the median request duration in seconds for method and
+// endpoint by interpolating within RequestDuration's buckets. It returns
+// NaN if no requests have been recorded.
+func (r *REDMetrics) P50(method, endpoint string) float64 {
+	return r.RequestDuration.EstimateQuantile(0.5, method, endpoint)
+}
+
+// P90 estimates the 90th percentile request duration in seconds.
+func (r *REDMetrics) P90(method, endpoint string) float64 {
+	return r.RequestDuration.EstimateQuantile(0.9, method, endpoint)
+}
+
+// P99 estimates the 99th percentile request duration in seconds.
+func (r *REDMetrics) P99(method, endpoint string) float64 {
+	return r.RequestDuration.EstimateQuantile(0.99, method, endpoint)
+}
+
+// RecordRequestBodySize records the number of body bytes a handler read.
+// It is a no-op if RequestBodySize is nil.
+func (r *REDMetrics) RecordRequestBodySize(method, endpoint string, size int64) {
+	if r.RequestBodySize != nil {
+		r.RequestBodySize.Observe(float64(size), method, endpoint)
+	}
+}
+
+// Reset clears all RED metrics. It is intended only for testing.
+func (r *REDMetrics) Reset() {
+	r.RequestsTotal.Reset()
+	r.RequestErrors.Reset()
+	r.RequestDuration.Reset()
+	r.InFlightRequests.Reset()
+	if r.RequestBodySize != nil {
+		r.RequestBodySize.Reset()
+	}
+}
+
 // categorizeError determines the error type for metrics labeling.
 // This helps with error analysis and alerting.
 func categorizeError(err error) string {
@@ -589,6 +856,12 @@ type LogEntry struct {
 	Message string `json:"message"`
 	// Service is the service name (used as Loki label)
 	Service string `json:"service"`
+	// Version and Env come from ServiceMetadata in the context
+	Version string `json:"version,omitempty"`
+	Env     string `json:"env,omitempty"`
+	// RequestID and TenantID come from RequestMetadata in the context
+	RequestID string `json:"request_id,omitempty"`
+	TenantID  string `json:"tenant_id,omitempty"`
 	// TraceID for correlation with distributed traces
 	TraceID string `json:"trace_id,omitempty"`
 	// SpanID for correlation with specific spans
@@ -609,13 +882,327 @@ type LogEntry struct {
 // 4. Use structured fields instead of string interpolation
 // 5. Include relevant context (user_id, request_id, etc.)
 type Logger struct {
-	service     string
-	level       LogLevel
-	output      io.Writer
-	encoder     *json.Encoder
-	mu          sync.Mutex
-	fields      map[string]interface{} // Default fields added to all logs
+	service       string
+	level         LogLevel
+	output        io.Writer
+	encoder       entryEncoder
+	mu            sync.Mutex
+	fields        map[string]interface{} // Default fields added to all logs
 	includeCaller bool
+	development   bool         // Set by WithDevelopmentMode
+	asyncBuffer   int          // Set by WithAsync; 0 means synchronous writes
+	async         *asyncWriter // Background writer, shared with derived loggers
+	schema        []LogField   // Declared with WithSchema
+	sinks         *sinkEncoder // Set by NewMultiSinkLogger
+}
+
+// asyncWriter encodes log entries on a background goroutine.
+// Sync requests travel through the same channel as entries, so by the time
+// one is answered every entry queued before it has been written.
+type asyncWriter struct {
+	items    chan asyncItem
+	done     chan struct{} // Closed once run has returned
+	closeErr error         // First write error since the last sync, set by run
+	mu       sync.RWMutex  // Held for reading while sending on items
+	closed   bool
+}
+
+// asyncItem is either a log entry or a sync request.
+type asyncItem struct {
+	entry LogEntry
+	sync  chan error // Non-nil for sync requests
+}
+
+// newAsyncWriter starts the background goroutine writing to encoder.
+func newAsyncWriter(encoder entryEncoder, bufferSize int) *asyncWriter {
+	w := &asyncWriter{
+		items: make(chan asyncItem, bufferSize),
+		done:  make(chan struct{}),
+	}
+	go w.run(encoder)
+	return w
+}
+
+// send queues an item. It reports false once the writer is closed.
+func (w *asyncWriter) send(item asyncItem) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return false
+	}
+	w.items <- item
+	return true
+}
+
+// close stops accepting items, waits for the queued entries to be written
+// and returns the first write error since the last sync.
+func (w *asyncWriter) close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.items)
+	w.mu.Unlock()
+
+	<-w.done
+	return w.closeErr
+}
+
+// run writes entries until the writer is closed, remembering the first
+// write error since the last sync.
+func (w *asyncWriter) run(encoder entryEncoder) {
+	defer close(w.done)
+
+	var firstErr error
+	for item := range w.items {
+		if item.sync != nil {
+			item.sync <- firstErr
+			firstErr = nil
+			continue
+		}
+		if err := encoder.Encode(item.entry); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to encode log entry: %w", err)
+		}
+	}
+	w.closeErr = firstErr
+}
+
+// entryEncoder writes one log entry. *json.Encoder is the production
+// implementation; devEncoder is used in development mode.
+type entryEncoder interface {
+	Encode(v interface{}) error
+}
+
+// ANSI escape sequences used to color the level in development mode.
+const (
+	ansiReset  = "\x1b[0m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiCyan   = "\x1b[36m"
+)
+
+// levelColors maps level names, as stored in LogEntry.Level, to colors.
+var levelColors = map[string]string{
+	"debug": ansiCyan,
+	"info":  ansiGreen,
+	"warn":  ansiYellow,
+	"error": ansiRed,
+	"fatal": ansiRed,
+}
+
+// devEncoder writes entries as colorized single lines for humans:
+//
+//	INFO 14:03:27 handler.go:42 request served method=GET status=200
+type devEncoder struct {
+	w io.Writer
+}
+
+// Encode writes entry, which must be a LogEntry, as one line.
+func (e *devEncoder) Encode(v interface{}) error {
+	entry, ok := v.(LogEntry)
+	if !ok {
+		return fmt.Errorf("development encoder: unexpected value %T", v)
+	}
+
+	var b strings.Builder
+	color := levelColors[entry.Level]
+	b.WriteString(color)
+	b.WriteString(strings.ToUpper(entry.Level))
+	if color != "" {
+		b.WriteString(ansiReset)
+	}
+
+	if ts, err := time.Parse(time.RFC3339Nano, entry.Timestamp); err == nil {
+		b.WriteString(" " + ts.Local().Format("15:04:05"))
+	}
+	if entry.Caller != "" {
+		b.WriteString(" " + filepath.Base(entry.Caller))
+	}
+	b.WriteString(" " + entry.Message)
+
+	// Context values first, then fields sorted by key so lines are stable
+	for _, kv := range [][2]string{
+		{"trace_id", entry.TraceID},
+		{"span_id", entry.SpanID},
+		{"request_id", entry.RequestID},
+		{"tenant_id", entry.TenantID},
+	} {
+		if kv[1] != "" {
+			writeDevField(&b, kv[0], kv[1])
+		}
+	}
+	keys := make([]string, 0, len(entry.Fields))
+	for k := range entry.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		writeDevField(&b, k, entry.Fields[k])
+	}
+	b.WriteByte('\n')
+
+	_, err := io.WriteString(e.w, b.String())
+	return err
+}
+
+// writeDevField appends " key=value", quoting values that contain spaces.
+func writeDevField(b *strings.Builder, key string, value interface{}) {
+	s := fmt.Sprint(value)
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		s = fmt.Sprintf("%q", s)
+	}
+	fmt.Fprintf(b, " %s=%s", key, s)
+}
+
+// MultiSinkLogger is a Logger that writes each entry to every sink whose
+// minimum level the entry meets, e.g. debug and above to a local file but
+// only errors to a Loki push endpoint. Its own output is unused.
+//
+// The filter covers the whole level range, FatalLevel included: fatal
+// entries go to every sink, since every minimum level is at or below
+// FatalLevel. A sink added at ErrorLevel therefore receives both error
+// and fatal entries.
+//
+// Options apply as for NewLogger, except that the level defaults to
+// DebugLevel so the sinks alone decide what is written; WithLevel still
+// sets a floor for all sinks. Loggers derived with With share the sinks.
+type MultiSinkLogger struct {
+	*Logger
+}
+
+// NewMultiSinkLogger creates a logger with no sinks. Add them with AddSink.
+func NewMultiSinkLogger(service string, opts ...LoggerOption) *MultiSinkLogger {
+	sinks := &sinkEncoder{}
+	opts = append([]LoggerOption{WithLevel(DebugLevel)}, opts...)
+	opts = append(opts, func(l *Logger) { l.sinks = sinks })
+	return &MultiSinkLogger{Logger: NewLogger(service, opts...)}
+}
+
+// AddSink writes entries at minLevel or above to w, as JSON or, in
+// development mode, as colorized lines. It is safe to call while logging.
+func (m *MultiSinkLogger) AddSink(w io.Writer, minLevel LogLevel) {
+	var encoder entryEncoder = json.NewEncoder(w)
+	if m.sinks.development {
+		encoder = &devEncoder{w: w}
+	}
+
+	m.sinks.mu.Lock()
+	defer m.sinks.mu.Unlock()
+	m.sinks.sinks = append(m.sinks.sinks, logSink{minLevel: minLevel, encoder: encoder})
+}
+
+// logSink is one output of a MultiSinkLogger.
+type logSink struct {
+	minLevel LogLevel
+	encoder  entryEncoder
+}
+
+// sinkEncoder is the entryEncoder of a MultiSinkLogger: it dispatches each
+// entry to the sinks whose level it meets.
+type sinkEncoder struct {
+	mu          sync.Mutex // Protects sinks and serializes writes to them
+	sinks       []logSink
+	development bool // Set once by NewLogger
+}
+
+// Encode writes entry, which must be a LogEntry, to the matching sinks. A
+// failing sink does not stop the others; their errors are joined.
+func (e *sinkEncoder) Encode(v interface{}) error {
+	entry, ok := v.(LogEntry)
+	if !ok {
+		return fmt.Errorf("sink encoder: unexpected value %T", v)
+	}
+	level := levelFromString(entry.Level)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var errs []error
+	for _, sink := range e.sinks {
+		if level < sink.minLevel {
+			continue
+		}
+		if err := sink.encoder.Encode(entry); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// levelFromString is the inverse of LogLevel.String. Unknown names map to
+// FatalLevel so that no sink filters them out.
+func levelFromString(name string) LogLevel {
+	for level := DebugLevel; level < FatalLevel; level++ {
+		if level.String() == name {
+			return level
+		}
+	}
+	return FatalLevel
+}
+
+// FieldType is the value type of a log field, as documented in a schema.
+type FieldType int
+
+const (
+	FieldString FieldType = iota
+	FieldInt
+	FieldFloat
+	FieldBool
+	FieldDuration
+	FieldBytes
+)
+
+// String returns the type name used in the JSON schema.
+func (t FieldType) String() string {
+	switch t {
+	case FieldString:
+		return "string"
+	case FieldInt:
+		return "int"
+	case FieldFloat:
+		return "float"
+	case FieldBool:
+		return "bool"
+	case FieldDuration:
+		return "duration"
+	case FieldBytes:
+		return "bytes"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText encodes the type by name, so schemas read "type": "int".
+func (t FieldType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// fieldTypeOf infers the FieldType of a default field value.
+func fieldTypeOf(v interface{}) FieldType {
+	switch v.(type) {
+	case bool:
+		return FieldBool
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return FieldInt
+	case float32, float64:
+		return FieldFloat
+	case time.Duration:
+		return FieldDuration
+	case []byte:
+		return FieldBytes
+	default:
+		return FieldString
+	}
+}
+
+// LogField documents one structured field a logger emits, so consumers
+// such as Loki pipeline stages know what to parse and how.
+type LogField struct {
+	Key         string    `json:"key"`
+	Type        FieldType `json:"type"`
+	Description string    `json:"description,omitempty"`
 }
 
 // LoggerOption is a function that configures a Logger.
@@ -650,6 +1237,45 @@ func WithCaller(include bool) LoggerOption {
 	}
 }
 
+// WithAsync makes the logger write entries on a background goroutine, so
+// logging never waits on a slow output. Up to bufferSize entries are queued;
+// once the buffer is full, logging blocks until there is room.
+//
+// Entries are encoded after the logging call returns, so the logger owns
+// the values in fields from then on: callers must not modify maps or
+// slices passed as field values once they are logged.
+//
+// Call Close (or Sync) before the process exits, or queued entries are lost.
+func WithAsync(bufferSize int) LoggerOption {
+	return func(l *Logger) {
+		if bufferSize <= 0 {
+			bufferSize = 1
+		}
+		l.asyncBuffer = bufferSize
+	}
+}
+
+// WithDevelopmentMode writes colorized, human-readable lines instead of
+// JSON, and always includes the caller:
+//
+//	INFO 14:03:27 handler.go:42 request served method=GET status=200
+//
+// It is meant for local runs and go test -v only. Loki cannot parse the
+// format, so never enable it in production.
+func WithDevelopmentMode() LoggerOption {
+	return func(l *Logger) {
+		l.development = true
+	}
+}
+
+// WithSchema declares the fields the logger emits, with their types and
+// descriptions, for Schema. Declaring a field does not add it to entries.
+func WithSchema(fields ...LogField) LoggerOption {
+	return func(l *Logger) {
+		l.schema = append(l.schema, fields...)
+	}
+}
+
 // NewLogger creates a new structured logger.
 func NewLogger(service string, opts ...LoggerOption) *Logger {
 	logger := &Logger{
@@ -665,9 +1291,51 @@ func NewLogger(service string, opts ...LoggerOption) *Logger {
 		opt(logger)
 	}
 
+	// Applied after all options so it wraps the final output
+	if logger.development {
+		logger.encoder = &devEncoder{w: logger.output}
+		logger.includeCaller = true
+	}
+
+	// Also applied after all options: sinks pick their own encoding
+	if logger.sinks != nil {
+		logger.sinks.development = logger.development
+		logger.encoder = logger.sinks
+	}
+
+	// Started after all options so it uses the final encoder
+	if logger.asyncBuffer > 0 {
+		logger.async = newAsyncWriter(logger.encoder, logger.asyncBuffer)
+	}
+
 	return logger
 }
 
+// Sync blocks until every entry logged before the call has been written and
+// returns the first write error since the previous Sync. For synchronous
+// loggers it is a no-op. Call it during shutdown, before os.Exit.
+func (l *Logger) Sync() error {
+	if l.async == nil {
+		return nil
+	}
+	done := make(chan error, 1)
+	if !l.async.send(asyncItem{sync: done}) {
+		return nil
+	}
+	return <-done
+}
+
+// Close writes every queued entry, stops the background goroutine started
+// by WithAsync and returns the first write error since the previous Sync.
+// It affects every logger derived with With. Entries logged after Close
+// are written synchronously. For synchronous loggers it is a no-op.
+func (l *Logger) Close() error {
+	if l.async == nil {
+		return nil
+	}
+	return l.async.close()
+}
+
 // Debug logs a debug message.
 func (l *Logger) Debug(ctx context.Context, msg string, fields map[string]interface{}) {
 	l.log(ctx, DebugLevel, msg, fields, nil)
@@ -720,6 +1388,17 @@ func (l *Logger) log(ctx context.Context, level LogLevel, msg string, fields map
 		}
 	}
 
+	// Extract service and request metadata from context
+	service := ServiceMetadataFromContext(ctx)
+	if entry.Service == "" {
+		entry.Service = service.Service
+	}
+	entry.Version = service.Version
+	entry.Env = service.Env
+	request := RequestMetadataFromContext(ctx)
+	entry.RequestID = request.RequestID
+	entry.TenantID = request.TenantID
+
 	// Add caller information if enabled
 	if l.includeCaller {
 		_, file, line, ok := runtime.Caller(2)
@@ -744,6 +1423,15 @@ func (l *Logger) log(ctx context.Context, level LogLevel, msg string, fields map
 		entry.Fields = mergedFields
 	}
 
+	if l.async != nil {
+		if l.async.send(asyncItem{entry: entry}) {
+			return
+		}
+		// Closed: wait for the background goroutine to finish with the
+		// encoder before writing synchronously
+		<-l.async.done
+	}
+
 	// Write log entry
 	l.mu.Lock()
 	defer l.mu.Unlock()
@@ -772,9 +1460,44 @@ func (l *Logger) With(fields map[string]interface{}) *Logger {
 		encoder:       l.encoder,
 		fields:        newFields,
 		includeCaller: l.includeCaller,
+		development:   l.development,
+		asyncBuffer:   l.asyncBuffer,
+		async:         l.async,
+		schema:        l.schema,
+		sinks:         l.sinks,
 	}
 }
 
+// Schema returns the fields the logger declares, sorted by key: those
+// registered with WithSchema plus any default fields from WithFields or
+// With, whose types are inferred from their values. Fields appear under
+// "fields" in each entry.
+func (l *Logger) Schema() []LogField {
+	byKey := make(map[string]LogField, len(l.schema)+len(l.fields))
+	for k, v := range l.fields {
+		byKey[k] = LogField{Key: k, Type: fieldTypeOf(v)}
+	}
+	// Declared fields take precedence over inferred ones
+	for _, f := range l.schema {
+		byKey[f.Key] = f
+	}
+
+	schema := make([]LogField, 0, len(byKey))
+	for _, f := range byKey {
+		schema = append(schema, f)
+	}
+	sort.Slice(schema, func(i, j int) bool { return schema[i].Key < schema[j].Key })
+	return schema
+}
+
+// SchemaJSON returns Schema as a JSON array, for use in Loki pipeline
+// configuration:
+//
+//	[{"key":"duration","type":"duration","description":"Request latency"}]
+func (l *Logger) SchemaJSON() ([]byte, error) {
+	return json.Marshal(l.Schema())
+}
+
 // =============================================================================
 // SECTION 4: OpenTelemetry Tracing Setup
 // =============================================================================
@@ -791,6 +1514,8 @@ const (
 	ParentSpanIDKey contextKey = "parent_span_id"
 	// SampledKey is the context key for sampling decision
 	SampledKey contextKey = "sampled"
+	// SpanKey is the context key for the active *Span
+	SpanKey contextKey = "span"
 )
 
 // SpanKind represents the type of span.
@@ -880,7 +1605,26 @@ type Span struct {
 	StatusMsg    string
 	Attributes   map[string]interface{}
 	Events       []SpanEvent
+	Links        []SpanLink
 	mu           sync.Mutex
+	// nonRecording marks the no-op spans returned for unsampled traces
+	nonRecording bool
+
+	// Limits copied from the tracer, and what was dropped because of them.
+	// tracerAttributes is the number of attributes StartSpan added, which
+	// do not count toward the attribute limit.
+	limits            spanLimits
+	tracerAttributes  int
+	droppedAttributes int
+	droppedEvents     int
+	droppedLinks      int
+}
+
+// spanLimits caps how much a span may accumulate (0 = no limit).
+type spanLimits struct {
+	attributes int
+	events     int
+	links      int
 }
 
 // SpanEvent represents an event that occurred during a span.
@@ -891,6 +1635,14 @@ type SpanEvent struct {
 	Attributes map[string]interface{}
 }
 
+// SpanLink points to a span in another trace that is causally related,
+// e.g. the producer span of each message in a consumer's batch.
+type SpanLink struct {
+	TraceID    string
+	SpanID     string
+	Attributes map[string]interface{}
+}
+
 // Tracer creates and manages spans for distributed tracing.
 // This implementation demonstrates the core concepts of OpenTelemetry tracing
 // that would be used with Grafana Tempo.
@@ -902,6 +1654,8 @@ type SpanEvent struct {
 type Tracer struct {
 	serviceName    string
 	serviceVersion string
+	resource       Resource
+	limits         spanLimits
 	sampler        Sampler
 	exporter       SpanExporter
 	spans          []*Span
@@ -953,7 +1707,42 @@ func (s *RatioSampler) ShouldSample(traceID string) bool {
 	for _, c := range traceID {
 		hash = hash*31 + uint64(c)
 	}
-	return float64(hash%1000)/1000.0 < s.ratio
+	return float64(hash%1000)/1000.0 < s.ratio
+}
+
+// ContextSampler is implemented by samplers that take the parent's
+// sampling decision into account. The tracer calls ShouldSampleContext
+// instead of ShouldSample when the sampler implements it.
+type ContextSampler interface {
+	Sampler
+	ShouldSampleContext(ctx context.Context, traceID string) bool
+}
+
+// CompositeSampler follows the parent's sampling decision when there is
+// one and falls back to ratio sampling for root spans, e.g. "always sample
+// if the parent was sampled, otherwise sample 10%".
+type CompositeSampler struct {
+	root *RatioSampler
+}
+
+// NewParentOrRatioSampler creates a CompositeSampler that samples root
+// traces at the given ratio.
+func NewParentOrRatioSampler(ratio float64) *CompositeSampler {
+	return &CompositeSampler{root: NewRatioSampler(ratio)}
+}
+
+// ShouldSample applies the ratio, as there is no parent decision to follow.
+func (s *CompositeSampler) ShouldSample(traceID string) bool {
+	return s.root.ShouldSample(traceID)
+}
+
+// ShouldSampleContext returns the SampledKey decision from ctx if present,
+// and otherwise applies the ratio.
+func (s *CompositeSampler) ShouldSampleContext(ctx context.Context, traceID string) bool {
+	if sampled, ok := ctx.Value(SampledKey).(bool); ok {
+		return sampled
+	}
+	return s.root.ShouldSample(traceID)
 }
 
 // ConsoleExporter exports spans to the console (for debugging).
@@ -980,12 +1769,163 @@ func (e *ConsoleExporter) Export(spans []*Span) error {
 	return nil
 }
 
+// InMemoryExporter keeps exported spans in memory.
+// It is intended for tests that assert on the spans a code path produces.
+type InMemoryExporter struct {
+	spans []*Span
+	mu    sync.Mutex
+}
+
+// NewInMemoryExporter creates an empty in-memory exporter.
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+// Export appends spans to the in-memory list.
+func (e *InMemoryExporter) Export(spans []*Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+// Spans returns a copy of the exported spans, in export order.
+func (e *InMemoryExporter) Spans() []*Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]*Span(nil), e.spans...)
+}
+
+// Reset discards all exported spans.
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = nil
+}
+
+// MultiSpanExporter sends every batch to several exporters at once, such as
+// an OTLP collector plus a ConsoleExporter while debugging. All exporters
+// receive the same slice, so they must not modify it.
+type MultiSpanExporter struct {
+	exporters []SpanExporter
+}
+
+// NewMultiSpanExporter creates an exporter that fans out to exporters.
+func NewMultiSpanExporter(exporters ...SpanExporter) *MultiSpanExporter {
+	return &MultiSpanExporter{exporters: exporters}
+}
+
+// Export calls every exporter concurrently and waits for all of them. A
+// failing exporter does not stop the others. If any fail, it returns a
+// *MultiError with one error per failed exporter, in exporter order.
+func (m *MultiSpanExporter) Export(spans []*Span) error {
+	return m.each(func(exporter SpanExporter) error {
+		return exporter.Export(spans)
+	})
+}
+
+// Shutdown concurrently shuts down every exporter that implements
+// Shutdown(context.Context) error, or else closes those that implement
+// io.Closer. Failures are reported as by Export. If ctx ends first it
+// returns ctx.Err() without waiting for the rest.
+func (m *MultiSpanExporter) Shutdown(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- m.each(func(exporter SpanExporter) error {
+			switch e := exporter.(type) {
+			case interface{ Shutdown(context.Context) error }:
+				return e.Shutdown(ctx)
+			case io.Closer:
+				return e.Close()
+			}
+			return nil
+		})
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// each runs fn for every exporter concurrently and returns a *MultiError
+// of the failures, each naming its exporter, or nil if none failed.
+func (m *MultiSpanExporter) each(fn func(exporter SpanExporter) error) error {
+	errs := make([]error, len(m.exporters))
+	var wg sync.WaitGroup
+	for i, exporter := range m.exporters {
+		wg.Add(1)
+		go func(i int, exporter SpanExporter) {
+			defer wg.Done()
+			if err := fn(exporter); err != nil {
+				errs[i] = fmt.Errorf("exporter %d (%T): %w", i, exporter, err)
+			}
+		}(i, exporter)
+	}
+	wg.Wait()
+
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return &MultiError{Errors: failed}
+}
+
+// Resource describes the process that produces telemetry, using
+// OpenTelemetry semantic convention keys such as "service.version",
+// "host.name", and "process.runtime.version". It is immutable.
+type Resource struct {
+	attrs map[string]interface{}
+}
+
+// NewResource creates a resource from a copy of attrs.
+func NewResource(attrs map[string]interface{}) Resource {
+	r := Resource{attrs: make(map[string]interface{}, len(attrs))}
+	for k, v := range attrs {
+		r.attrs[k] = v
+	}
+	return r
+}
+
+// Attributes returns a copy of the resource attributes.
+func (r Resource) Attributes() map[string]interface{} {
+	return NewResource(r.attrs).attrs
+}
+
+// Merge returns a resource with the attributes of both; other wins on
+// conflicting keys.
+func (r Resource) Merge(other Resource) Resource {
+	merged := NewResource(r.attrs)
+	for k, v := range other.attrs {
+		merged.attrs[k] = v
+	}
+	return merged
+}
+
 // TracerConfig holds configuration for the tracer.
 type TracerConfig struct {
 	ServiceName    string
 	ServiceVersion string
 	Sampler        Sampler
 	Exporter       SpanExporter
+	// Resource attributes are added to every span. ServiceName and
+	// ServiceVersion, when set, override service.name and service.version.
+	Resource Resource
+	// MaxAttributesPerSpan, MaxEventsPerSpan, and MaxLinksPerSpan bound
+	// memory use per span (0 = no limit). Additions beyond a limit are
+	// dropped and counted; see Span.DroppedCounts. The service, resource,
+	// and context metadata attributes the tracer adds are always kept and
+	// do not count toward MaxAttributesPerSpan.
+	MaxAttributesPerSpan int
+	MaxEventsPerSpan     int
+	MaxLinksPerSpan      int
 }
 
 // NewTracer creates a new tracer with the given configuration.
@@ -1000,30 +1940,57 @@ func NewTracer(config TracerConfig) *Tracer {
 		exporter = NewConsoleExporter(os.Stdout)
 	}
 
+	service := map[string]interface{}{}
+	if config.ServiceName != "" {
+		service["service.name"] = config.ServiceName
+	}
+	if config.ServiceVersion != "" {
+		service["service.version"] = config.ServiceVersion
+	}
+
 	return &Tracer{
 		serviceName:    config.ServiceName,
 		serviceVersion: config.ServiceVersion,
-		sampler:        sampler,
-		exporter:       exporter,
-		spans:          make([]*Span, 0),
+		resource:       config.Resource.Merge(NewResource(service)),
+		limits: spanLimits{
+			attributes: config.MaxAttributesPerSpan,
+			events:     config.MaxEventsPerSpan,
+			links:      config.MaxLinksPerSpan,
+		},
+		sampler:  sampler,
+		exporter: exporter,
+		spans:    make([]*Span, 0),
 	}
 }
 
+// Resource returns the attributes the tracer adds to every span.
+func (t *Tracer) Resource() Resource {
+	return t.resource
+}
+
 // StartSpan creates a new span and returns a context with the span.
 // The span should be ended by calling span.End() when the operation completes.
 func (t *Tracer) StartSpan(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
-	// Generate trace ID (or use existing from context)
-	traceID := ""
-	if existingTraceID := ctx.Value(TraceIDKey); existingTraceID != nil {
-		traceID = existingTraceID.(string)
-	} else {
-		traceID = generateID()
+	// Generate trace ID (or use existing from context). Incoming IDs in
+	// UUID or base64url form are normalized to hex; IDs in other formats,
+	// e.g. from X-Trace-ID headers, are kept as they are
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	if id, err := ParseTraceID(traceID); err == nil {
+		traceID = id.String()
+	} else if traceID == "" {
+		traceID = NewTraceID().String()
 	}
 
 	// Check sampling decision
-	if !t.sampler.ShouldSample(traceID) {
+	sampled := false
+	if cs, ok := t.sampler.(ContextSampler); ok {
+		sampled = cs.ShouldSampleContext(ctx, traceID)
+	} else {
+		sampled = t.sampler.ShouldSample(traceID)
+	}
+	if !sampled {
 		// Return a no-op span for non-sampled traces
-		return ctx, &Span{TraceID: traceID, Name: name}
+		return ctx, &Span{TraceID: traceID, Name: name, nonRecording: true}
 	}
 
 	// Get parent span ID from context
@@ -1043,20 +2010,68 @@ func (t *Tracer) StartSpan(ctx context.Context, name string, kind SpanKind) (con
 		Status:       SpanStatusUnset,
 		Attributes:   make(map[string]interface{}),
 		Events:       make([]SpanEvent, 0),
+		limits:       t.limits,
 	}
 
-	// Add service attributes
+	// Add service and resource attributes
 	span.Attributes["service.name"] = t.serviceName
 	span.Attributes["service.version"] = t.serviceVersion
+	for k, v := range t.resource.attrs {
+		span.Attributes[k] = v
+	}
+
+	// Add service and request metadata from context, if present
+	service := ServiceMetadataFromContext(ctx)
+	if span.Attributes["service.name"] == "" && service.Service != "" {
+		span.Attributes["service.name"] = service.Service
+	}
+	if span.Attributes["service.version"] == "" && service.Version != "" {
+		span.Attributes["service.version"] = service.Version
+	}
+	if service.Env != "" {
+		span.Attributes["deployment.environment"] = service.Env
+	}
+	request := RequestMetadataFromContext(ctx)
+	if request.RequestID != "" {
+		span.Attributes["request.id"] = request.RequestID
+	}
+	if request.TenantID != "" {
+		span.Attributes["tenant.id"] = request.TenantID
+	}
+	span.tracerAttributes = len(span.Attributes)
 
 	// Create new context with span information
 	ctx = context.WithValue(ctx, TraceIDKey, span.TraceID)
 	ctx = context.WithValue(ctx, SpanIDKey, span.SpanID)
 	ctx = context.WithValue(ctx, SampledKey, true)
+	ctx = context.WithValue(ctx, SpanKey, span)
 
 	return ctx, span
 }
 
+// Fork starts a span parented to the span in ctx without making it the
+// active span: ctx is not modified, so spans started from it later remain
+// siblings of the forked span rather than its children. Use it for work
+// that runs alongside the current operation, such as a background cleanup
+// goroutine:
+//
+//	cleanup := tracer.Fork(ctx, "cleanup", SpanKindInternal)
+//	go func() {
+//	    defer cleanup.End()
+//	    ...
+//	}()
+func (t *Tracer) Fork(ctx context.Context, name string, kind SpanKind) *Span {
+	_, span := t.StartSpan(ctx, name, kind)
+	return span
+}
+
+// SpanFromContext returns the active span stored by StartSpan, or nil if
+// ctx carries no sampled span.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(SpanKey).(*Span)
+	return span
+}
+
 // generateID generates a random ID for traces and spans.
 // In production, use a proper ID generator (e.g., UUID or W3C trace context format).
 func generateID() string {
@@ -1069,35 +2084,101 @@ func generateID() string {
 	return string(id)
 }
 
+// IsRecording reports whether the span records data. It is false for the
+// no-op spans StartSpan returns when a trace is not sampled, so callers can
+// skip computing expensive attributes:
+//
+//	if span.IsRecording() {
+//	    span.SetAttribute("request.body", string(body))
+//	}
+func (s *Span) IsRecording() bool {
+	return !s.nonRecording
+}
+
 // SetAttribute adds an attribute to the span.
 func (s *Span) SetAttribute(key string, value interface{}) {
+	if s.nonRecording {
+		return
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.setAttribute(key, value)
+}
+
+// setAttribute sets an attribute unless that would exceed the attribute
+// limit. Overwriting an existing key is always allowed. The caller must
+// hold s.mu or have exclusive access to s.
+func (s *Span) setAttribute(key string, value interface{}) {
+	if _, exists := s.Attributes[key]; !exists && s.limits.attributes > 0 && len(s.Attributes)-s.tracerAttributes >= s.limits.attributes {
+		s.droppedAttributes++
+		return
+	}
 	s.Attributes[key] = value
 }
 
 // SetAttributes adds multiple attributes to the span.
 func (s *Span) SetAttributes(attrs map[string]interface{}) {
+	if s.nonRecording {
+		return
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	for k, v := range attrs {
-		s.Attributes[k] = v
+		s.setAttribute(k, v)
 	}
 }
 
 // AddEvent adds an event to the span.
 func (s *Span) AddEvent(name string, attrs map[string]interface{}) {
+	if s.nonRecording {
+		return
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.Events = append(s.Events, SpanEvent{
+	s.addEvent(SpanEvent{
 		Name:       name,
 		Timestamp:  time.Now(),
 		Attributes: attrs,
 	})
 }
 
+// addEvent appends event unless the event limit has been reached.
+// The caller must hold s.mu.
+func (s *Span) addEvent(event SpanEvent) {
+	if s.limits.events > 0 && len(s.Events) >= s.limits.events {
+		s.droppedEvents++
+		return
+	}
+	s.Events = append(s.Events, event)
+}
+
+// AddLink links the span to a span in another trace.
+func (s *Span) AddLink(link SpanLink) {
+	if s.nonRecording {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.limits.links > 0 && len(s.Links) >= s.limits.links {
+		s.droppedLinks++
+		return
+	}
+	s.Links = append(s.Links, link)
+}
+
+// DroppedCounts returns how many attributes, events, and links were
+// discarded because the span had reached the tracer's limits.
+func (s *Span) DroppedCounts() (attrs, events, links int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.droppedAttributes, s.droppedEvents, s.droppedLinks
+}
+
 // SetStatus sets the span status.
 func (s *Span) SetStatus(status SpanStatus, message string) {
+	if s.nonRecording {
+		return
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.Status = status
@@ -1106,14 +2187,14 @@ func (s *Span) SetStatus(status SpanStatus, message string) {
 
 // RecordError records an error on the span.
 func (s *Span) RecordError(err error) {
-	if err == nil {
+	if err == nil || s.nonRecording {
 		return
 	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.Status = SpanStatusError
 	s.StatusMsg = err.Error()
-	s.Events = append(s.Events, SpanEvent{
+	s.addEvent(SpanEvent{
 		Name:      "exception",
 		Timestamp: time.Now(),
 		Attributes: map[string]interface{}{
@@ -1154,8 +2235,34 @@ func (t *Tracer) Export() error {
 	return t.exporter.Export(spans)
 }
 
+// ForceFlush synchronously exports every buffered span, without waiting for
+// the next periodic Export. It is useful in tests and before process exit.
+// If ctx is done before the exporter returns, ForceFlush returns ctx.Err();
+// the export itself keeps running in the background.
+func (t *Tracer) ForceFlush(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- t.Export()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // RecordSpan adds a completed span to the tracer for export.
+// Non-recording spans are dropped.
 func (t *Tracer) RecordSpan(span *Span) {
+	if !span.IsRecording() {
+		return
+	}
 	t.mu.Lock()
 	defer t.mu.Unlock()
 	t.spans = append(t.spans, span)
@@ -1172,6 +2279,8 @@ type ResponseWriter struct {
 	statusCode   int
 	bytesWritten int
 	wroteHeader  bool
+	captureLimit int    // Max body bytes to keep (0 = capture disabled)
+	captured     []byte // First captureLimit bytes of a 5xx body
 }
 
 // NewResponseWriter creates a new wrapped response writer.
@@ -1196,11 +2305,29 @@ func (rw *ResponseWriter) Write(b []byte) (int, error) {
 	if !rw.wroteHeader {
 		rw.WriteHeader(http.StatusOK)
 	}
+	if remaining := rw.captureLimit - len(rw.captured); remaining > 0 && rw.statusCode >= 500 {
+		if len(b) < remaining {
+			remaining = len(b)
+		}
+		rw.captured = append(rw.captured, b[:remaining]...)
+	}
 	n, err := rw.ResponseWriter.Write(b)
 	rw.bytesWritten += n
 	return n, err
 }
 
+// CaptureBody keeps up to maxBytes of the response body for later inspection.
+// Only server error (5xx) bodies are kept, so successful responses are not
+// copied. Must be called before the handler writes the body.
+func (rw *ResponseWriter) CaptureBody(maxBytes int) {
+	rw.captureLimit = maxBytes
+}
+
+// CapturedBody returns the captured prefix of the response body.
+func (rw *ResponseWriter) CapturedBody() []byte {
+	return rw.captured
+}
+
 // StatusCode returns the captured status code.
 func (rw *ResponseWriter) StatusCode() int {
 	return rw.statusCode
@@ -1211,6 +2338,19 @@ func (rw *ResponseWriter) BytesWritten() int {
 	return rw.bytesWritten
 }
 
+// countingReadCloser counts the bytes read from a request body.
+type countingReadCloser struct {
+	io.ReadCloser
+	n int64
+}
+
+// Read reads from the body and adds the bytes read to the count.
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
 // ObservabilityMiddleware combines metrics, logging, and tracing into a single
 // HTTP middleware. This demonstrates the integration of all three pillars.
 //
@@ -1229,6 +2369,37 @@ type ObservabilityMiddleware struct {
 	metrics *REDMetrics
 	logger  *Logger
 	tracer  *Tracer
+
+	// errorBodyCapture is the max response body bytes logged for 5xx responses
+	errorBodyCapture int
+
+	// accessLog receives one line per request when set by WithAccessLog
+	accessLog       io.Writer
+	accessLogFormat AccessLogFormat
+	accessLogMu     sync.Mutex
+}
+
+// AccessLogFormat selects the line format written by WithAccessLog.
+type AccessLogFormat int
+
+const (
+	// AccessLogJSON writes one JSON object per request
+	AccessLogJSON AccessLogFormat = iota
+	// AccessLogCombined writes the Apache combined log format, for tools
+	// that already parse web server logs
+	AccessLogCombined
+)
+
+// accessLogEntry is the JSON access log line.
+type accessLogEntry struct {
+	Timestamp  string `json:"timestamp"`
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	Status     int    `json:"status"`
+	Bytes      int    `json:"bytes"`
+	DurationMS int64  `json:"duration_ms"`
+	RemoteAddr string `json:"remote_addr"`
+	UserAgent  string `json:"user_agent"`
 }
 
 // NewObservabilityMiddleware creates a new observability middleware.
@@ -1262,6 +2433,82 @@ func (m *ObservabilityMiddleware) WithTracer(tracer *Tracer) *ObservabilityMiddl
 	return m
 }
 
+// WithErrorBodyCapture logs up to maxBytes of the response body as
+// "response_body_snippet" when a handler responds with a 5xx status.
+// The body often contains the actual failure reason (e.g., {"error":"db down"}).
+// Keep maxBytes small: the snippet is buffered for every request.
+func (m *ObservabilityMiddleware) WithErrorBodyCapture(maxBytes int) *ObservabilityMiddleware {
+	m.errorBodyCapture = maxBytes
+	return m
+}
+
+// WithAccessLog writes an access log line to w for every request, separate
+// from the application log. AccessLogJSON lines carry the timestamp,
+// method, path, status, bytes, duration, remote address, and user agent;
+// AccessLogCombined lines follow the Apache format, which has no duration
+// field:
+//
+//	192.0.2.1 - - [10/Oct/2024:13:55:36 +0000] "GET /api HTTP/1.1" 200 2326 "-" "curl/8.0"
+//
+// Writes are serialized, so w need not be safe for concurrent use.
+func (m *ObservabilityMiddleware) WithAccessLog(w io.Writer, format AccessLogFormat) *ObservabilityMiddleware {
+	m.accessLog = w
+	m.accessLogFormat = format
+	return m
+}
+
+// writeAccessLog writes the access log line for a completed request.
+func (m *ObservabilityMiddleware) writeAccessLog(r *http.Request, status, bytes int, start time.Time, duration time.Duration) {
+	var line []byte
+	switch m.accessLogFormat {
+	case AccessLogCombined:
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		user := "-"
+		if name, _, ok := r.BasicAuth(); ok && name != "" {
+			user = name
+		}
+		size := "-"
+		if bytes > 0 {
+			size = strconv.Itoa(bytes)
+		}
+		referer := r.Referer()
+		if referer == "" {
+			referer = "-"
+		}
+		userAgent := r.UserAgent()
+		if userAgent == "" {
+			userAgent = "-"
+		}
+		line = []byte(fmt.Sprintf("%s - %s [%s] %s %d %s %s %s\n",
+			host, user, start.Format("02/Jan/2006:15:04:05 -0700"),
+			strconv.Quote(r.Method+" "+r.URL.RequestURI()+" "+r.Proto),
+			status, size, strconv.Quote(referer), strconv.Quote(userAgent)))
+	default:
+		var err error
+		line, err = json.Marshal(accessLogEntry{
+			Timestamp:  start.UTC().Format(time.RFC3339Nano),
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Status:     status,
+			Bytes:      bytes,
+			DurationMS: duration.Milliseconds(),
+			RemoteAddr: r.RemoteAddr,
+			UserAgent:  r.UserAgent(),
+		})
+		if err != nil {
+			return
+		}
+		line = append(line, '\n')
+	}
+
+	m.accessLogMu.Lock()
+	defer m.accessLogMu.Unlock()
+	m.accessLog.Write(line)
+}
+
 // Handler wraps an HTTP handler with observability instrumentation.
 func (m *ObservabilityMiddleware) Handler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -1305,10 +2552,21 @@ func (m *ObservabilityMiddleware) Handler(next http.Handler) http.Handler {
 
 		// Wrap response writer to capture status code
 		wrapped := NewResponseWriter(w)
+		if m.errorBodyCapture > 0 {
+			wrapped.CaptureBody(m.errorBodyCapture)
+		}
 
 		// Inject trace context into response headers
 		m.injectTraceContext(ctx, wrapped)
 
+		// Count the request body bytes the handler reads
+		req := r.WithContext(ctx)
+		var body *countingReadCloser
+		if r.Body != nil {
+			body = &countingReadCloser{ReadCloser: r.Body}
+			req.Body = body
+		}
+
 		// Call the next handler
 		var handlerErr error
 		func() {
@@ -1319,7 +2577,19 @@ func (m *ObservabilityMiddleware) Handler(next http.Handler) http.Handler {
 					wrapped.WriteHeader(http.StatusInternalServerError)
 				}
 			}()
-			next.ServeHTTP(wrapped, r.WithContext(ctx))
+			// AppHandlers return their error, so the status code can be
+			// derived from it and the real cause recorded on the span
+			if app, ok := next.(AppHandler); ok {
+				if err := app(wrapped, req); err != nil {
+					handlerErr = err
+					span.RecordError(err)
+					if !wrapped.wroteHeader {
+						writeStatusError(wrapped, err)
+					}
+				}
+				return
+			}
+			next.ServeHTTP(wrapped, req)
 		}()
 
 		// Calculate duration
@@ -1330,7 +2600,7 @@ func (m *ObservabilityMiddleware) Handler(next http.Handler) http.Handler {
 		status := http.StatusText(statusCode)
 
 		// Check for errors
-		if statusCode >= 400 {
+		if statusCode >= 400 || handlerErr != nil {
 			if handlerErr == nil {
 				handlerErr = fmt.Errorf("HTTP %d: %s", statusCode, status)
 			}
@@ -1347,6 +2617,9 @@ func (m *ObservabilityMiddleware) Handler(next http.Handler) http.Handler {
 
 		// Record metrics
 		m.metrics.RecordRequest(method, endpoint, status, duration, handlerErr)
+		if body != nil {
+			m.metrics.RecordRequestBodySize(method, endpoint, body.n)
+		}
 
 		// Log request completion
 		logFields := map[string]interface{}{
@@ -1356,12 +2629,19 @@ func (m *ObservabilityMiddleware) Handler(next http.Handler) http.Handler {
 			"duration_ms":   duration.Milliseconds(),
 			"bytes_written": wrapped.BytesWritten(),
 		}
+		if statusCode >= 500 && len(wrapped.CapturedBody()) > 0 {
+			logFields["response_body_snippet"] = string(wrapped.CapturedBody())
+		}
 
 		if handlerErr != nil {
 			m.logger.Error(ctx, "request completed with error", handlerErr, logFields)
 		} else {
 			m.logger.Info(ctx, "request completed", logFields)
 		}
+
+		if m.accessLog != nil {
+			m.writeAccessLog(r, statusCode, wrapped.BytesWritten(), start, duration)
+		}
 	})
 }
 
@@ -1373,18 +2653,7 @@ func (m *ObservabilityMiddleware) extractTraceContext(r *http.Request) context.C
 	// Try to extract W3C traceparent header
 	// Format: version-trace_id-parent_id-flags
 	// Example: 00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01
-	traceparent := r.Header.Get("traceparent")
-	if traceparent != "" {
-		parts := splitString(traceparent, '-')
-		if len(parts) >= 4 {
-			ctx = context.WithValue(ctx, TraceIDKey, parts[1])
-			ctx = context.WithValue(ctx, ParentSpanIDKey, parts[2])
-			// Check if sampled (last character of flags)
-			if len(parts[3]) > 0 && parts[3][len(parts[3])-1] == '1' {
-				ctx = context.WithValue(ctx, SampledKey, true)
-			}
-		}
-	}
+	ctx = ExtractHTTP(ctx, r.Header)
 
 	// Also check for custom headers (common in some systems)
 	if traceID := r.Header.Get("X-Trace-ID"); traceID != "" {
@@ -1397,6 +2666,40 @@ func (m *ObservabilityMiddleware) extractTraceContext(r *http.Request) context.C
 	return ctx
 }
 
+// contextWithTraceparent stores the trace ID, parent span ID, and sampled
+// flag from a W3C traceparent value in ctx. Malformed values are ignored.
+func contextWithTraceparent(ctx context.Context, traceparent string) context.Context {
+	if traceparent == "" {
+		return ctx
+	}
+	parts := splitString(traceparent, '-')
+	if len(parts) >= 4 {
+		ctx = context.WithValue(ctx, TraceIDKey, parts[1])
+		ctx = context.WithValue(ctx, ParentSpanIDKey, parts[2])
+		// Record the sampled flag (last character of flags) so that
+		// parent-based samplers can follow an explicit "not sampled"
+		if len(parts[3]) > 0 {
+			ctx = context.WithValue(ctx, SampledKey, parts[3][len(parts[3])-1] == '1')
+		}
+	}
+	return ctx
+}
+
+// traceparentFromContext formats the trace context in ctx as a W3C
+// traceparent value. It returns false if ctx carries no trace ID.
+func traceparentFromContext(ctx context.Context) (string, bool) {
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	if traceID == "" {
+		return "", false
+	}
+	spanID, _ := ctx.Value(SpanIDKey).(string)
+	flags := "00"
+	if sampled, _ := ctx.Value(SampledKey).(bool); sampled {
+		flags = "01"
+	}
+	return "00-" + traceID + "-" + spanID + "-" + flags, true
+}
+
 // injectTraceContext injects trace context into response headers.
 func (m *ObservabilityMiddleware) injectTraceContext(ctx context.Context, w http.ResponseWriter) {
 	if traceID := ctx.Value(TraceIDKey); traceID != nil {
@@ -1449,6 +2752,88 @@ func (e *ObservabilityError) Unwrap() error {
 	return e.Err
 }
 
+// MultiError collects the failures of an operation fanned out to several
+// targets, such as the exporters of a MultiSpanExporter, one error per
+// failed target. errors.Is and errors.As match if any contained error does.
+type MultiError struct {
+	Errors []error
+}
+
+// Error lists every contained error on its own line, by index.
+func (m *MultiError) Error() string {
+	var b strings.Builder
+	if len(m.Errors) == 1 {
+		b.WriteString("1 error occurred:")
+	} else {
+		fmt.Fprintf(&b, "%d errors occurred:", len(m.Errors))
+	}
+	for i, err := range m.Errors {
+		fmt.Fprintf(&b, "\n  [%d]: %v", i, err)
+	}
+	return b.String()
+}
+
+// Unwrap returns the contained errors so errors.Is and errors.As can
+// inspect each of them.
+func (m *MultiError) Unwrap() []error {
+	return m.Errors
+}
+
+// httpStatusKeywords maps phrases found in error messages to HTTP status
+// codes. Entries are checked in order, so more specific phrases come first.
+// Phrases are kept specific so that unrelated errors do not match: a bare
+// "invalid" would turn "invalid memory address or nil pointer dereference"
+// into a 400.
+var httpStatusKeywords = []struct {
+	keyword string
+	status  int
+}{
+	{"not found", http.StatusNotFound},
+	{"unauthorized", http.StatusUnauthorized},
+	{"unauthenticated", http.StatusUnauthorized},
+	{"forbidden", http.StatusForbidden},
+	{"permission denied", http.StatusForbidden},
+	{"already exists", http.StatusConflict},
+	{"conflict", http.StatusConflict},
+	{"rate limit", http.StatusTooManyRequests},
+	{"too many requests", http.StatusTooManyRequests},
+	{"invalid argument", http.StatusBadRequest},
+	{"invalid parameter", http.StatusBadRequest},
+	{"invalid request", http.StatusBadRequest},
+	{"bad request", http.StatusBadRequest},
+	{"timeout", http.StatusGatewayTimeout},
+	{"timed out", http.StatusGatewayTimeout},
+	{"unavailable", http.StatusServiceUnavailable},
+}
+
+// HTTPStatus derives the HTTP status to respond with.
+//
+// A StatusError in the chain wins, since it records the status chosen where
+// the error was created. Otherwise context errors and phrases in the error
+// text (e.g. "not found" -> 404, "unauthorized" -> 401) are used, falling
+// back to 500. Operation names what was attempted, not what went wrong, so
+// it is not searched.
+func (e *ObservabilityError) HTTPStatus() int {
+	var statusErr *StatusError
+	if errors.As(e.Err, &statusErr) && statusErr.HTTPStatus != 0 {
+		return statusErr.HTTPStatus
+	}
+	if errors.Is(e.Err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+
+	if e.Err == nil {
+		return http.StatusInternalServerError
+	}
+	msg := strings.ToLower(e.Err.Error())
+	for _, kw := range httpStatusKeywords {
+		if strings.Contains(msg, kw.keyword) {
+			return kw.status
+		}
+	}
+	return http.StatusInternalServerError
+}
+
 // WrapError wraps an error with observability context from the given context.
 func WrapError(ctx context.Context, err error, operation string, fields map[string]interface{}) error {
 	if err == nil {
@@ -1518,6 +2903,20 @@ func (h *ErrorHandler) Handle(ctx context.Context, err error, operation string,
 	return true
 }
 
+// HandleWithSpan is like Handle, but also records err on the active span in
+// ctx (if any) and marks the span as failed.
+func (h *ErrorHandler) HandleWithSpan(ctx context.Context, err error, operation string, fields map[string]interface{}) bool {
+	if !h.Handle(ctx, err, operation, fields) {
+		return false
+	}
+
+	if span := SpanFromContext(ctx); span != nil {
+		span.RecordError(err)
+		span.SetStatus(SpanStatusError, fmt.Sprintf("%s failed: %v", operation, err))
+	}
+	return true
+}
+
 // HandleWithRecovery wraps a function with panic recovery and error handling.
 func (h *ErrorHandler) HandleWithRecovery(ctx context.Context, operation string, fn func() error) (err error) {
 	defer func() {
@@ -1680,26 +3079,129 @@ type HealthCheck struct {
 	Details   map[string]interface{} `json:"details,omitempty"`
 }
 
-// HealthChecker provides health checking with observability.
-type HealthChecker struct {
-	checks  map[string]func(context.Context) HealthCheck
-	logger  *Logger
-	metrics *Gauge
-	mu      sync.RWMutex
+// healthCheckJSON is the wire form of a HealthCheck, with the duration in
+// whole milliseconds as its name says.
+type healthCheckJSON struct {
+	Name       string                 `json:"name"`
+	Status     HealthStatus           `json:"status"`
+	Message    string                 `json:"message,omitempty"`
+	DurationMS int64                  `json:"duration_ms"`
+	Timestamp  time.Time              `json:"timestamp"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+}
+
+// MarshalJSON encodes the check as described by HealthChecker.OpenAPISchema.
+func (c HealthCheck) MarshalJSON() ([]byte, error) {
+	return json.Marshal(healthCheckJSON{
+		Name:       c.Name,
+		Status:     c.Status,
+		Message:    c.Message,
+		DurationMS: c.Duration.Milliseconds(),
+		Timestamp:  c.Timestamp,
+		Details:    c.Details,
+	})
 }
 
-// NewHealthChecker creates a new health checker.
-func NewHealthChecker(logger *Logger, namespace string) *HealthChecker {
-	return &HealthChecker{
-		checks: make(map[string]func(context.Context) HealthCheck),
-		logger: logger,
-		metrics: NewGauge(MetricOpts{
+// UnmarshalJSON decodes a check encoded by MarshalJSON.
+func (c *HealthCheck) UnmarshalJSON(data []byte) error {
+	var v healthCheckJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*c = HealthCheck{
+		Name:      v.Name,
+		Status:    v.Status,
+		Message:   v.Message,
+		Duration:  time.Duration(v.DurationMS) * time.Millisecond,
+		Timestamp: v.Timestamp,
+		Details:   v.Details,
+	}
+	return nil
+}
+
+// DefaultHealthCheckHistorySize is the number of results kept per check.
+const DefaultHealthCheckHistorySize = 10
+
+// HealthCheckerConfig configures a HealthChecker.
+type HealthCheckerConfig struct {
+	// Namespace prefixes the checker's metric names
+	Namespace string
+	// Registry receives the checker's metrics (nil = not registered)
+	Registry *MetricRegistry
+	// HealthCheckHistorySize is the number of recent results kept per
+	// check for History (0 = DefaultHealthCheckHistorySize, negative =
+	// no history)
+	HealthCheckHistorySize int
+}
+
+// HealthChecker provides health checking with observability.
+type HealthChecker struct {
+	checks      map[string]func(context.Context) HealthCheck
+	history     map[string]*healthHistory
+	historySize int // Fixed at construction; 0 disables history
+	breakers    map[string]CircuitBreaker // Set by WithCircuitBreaker
+	logger      *Logger
+	status      *Gauge     // health_status, by component
+	duration    *Histogram // health_check_duration_seconds, by component
+	mu          sync.RWMutex
+}
+
+// CircuitBreaker is the part of a circuit breaker that a HealthChecker
+// drives with check results. The CircuitBreaker in go-distributed-systems
+// satisfies it.
+type CircuitBreaker interface {
+	RecordFailure()
+	RecordSuccess()
+}
+
+// healthHistory is a ring buffer of recent results for one check.
+type healthHistory struct {
+	entries []HealthCheck
+	start   int // Index of the oldest entry once the buffer is full
+}
+
+// NewHealthChecker creates a new health checker and registers its metrics
+// on config.Registry, labelled by check name as component:
+//   - <namespace>_health_status: 1 if healthy, 0.5 if degraded, 0 if
+//     unhealthy, as of the last Check
+//   - <namespace>_health_check_duration_seconds: how long each check ran
+//
+// A nil registry leaves the metrics unregistered. It panics if the
+// registry already holds metrics with these names, as MustRegister does.
+func NewHealthChecker(logger *Logger, config HealthCheckerConfig) *HealthChecker {
+	namespace := config.Namespace
+	historySize := config.HealthCheckHistorySize
+	switch {
+	case historySize == 0:
+		historySize = DefaultHealthCheckHistorySize
+	case historySize < 0:
+		historySize = 0
+	}
+
+	h := &HealthChecker{
+		checks:      make(map[string]func(context.Context) HealthCheck),
+		history:     make(map[string]*healthHistory),
+		historySize: historySize,
+		breakers:    make(map[string]CircuitBreaker),
+		logger:      logger,
+		status: NewGauge(MetricOpts{
 			Namespace: namespace,
 			Name:      "health_status",
 			Help:      "Health status of components (1=healthy, 0.5=degraded, 0=unhealthy)",
 			Labels:    []string{"component"},
 		}),
+		duration: NewHistogram(MetricOpts{
+			Namespace: namespace,
+			Name:      "health_check_duration_seconds",
+			Help:      "Time taken by each component's health check in seconds",
+			Labels:    []string{"component"},
+			Buckets:   DefaultHistogramBuckets,
+		}),
+	}
+	if config.Registry != nil {
+		config.Registry.MustRegister(h.status, h.duration)
 	}
+	return h
 }
 
 // Register adds a health check.
@@ -1709,6 +3211,18 @@ func (h *HealthChecker) Register(name string, check func(context.Context) Health
 	h.checks[name] = check
 }
 
+// WithCircuitBreaker feeds the results of the named check into cb: an
+// unhealthy result records a failure and a healthy one a success, while
+// degraded results are ignored. This lets an external health signal, such
+// as a downstream API's status page, open a breaker before real requests
+// start failing.
+func (h *HealthChecker) WithCircuitBreaker(checkName string, cb CircuitBreaker) *HealthChecker {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.breakers[checkName] = cb
+	return h
+}
+
 // Check runs all health checks and returns the results.
 func (h *HealthChecker) Check(ctx context.Context) []HealthCheck {
 	h.mu.RLock()
@@ -1716,6 +3230,10 @@ func (h *HealthChecker) Check(ctx context.Context) []HealthCheck {
 	for k, v := range h.checks {
 		checks[k] = v
 	}
+	breakers := make(map[string]CircuitBreaker, len(h.breakers))
+	for k, v := range h.breakers {
+		breakers[k] = v
+	}
 	h.mu.RUnlock()
 
 	results := make([]HealthCheck, 0, len(checks))
@@ -1736,7 +3254,8 @@ func (h *HealthChecker) Check(ctx context.Context) []HealthCheck {
 		case HealthStatusUnhealthy:
 			metricValue = 0.0
 		}
-		h.metrics.Set(metricValue, name)
+		h.status.Set(metricValue, name)
+		h.duration.Observe(result.Duration.Seconds(), name)
 
 		// Log unhealthy checks
 		if result.Status != HealthStatusHealthy {
@@ -1747,12 +3266,73 @@ func (h *HealthChecker) Check(ctx context.Context) []HealthCheck {
 			})
 		}
 
+		if cb := breakers[name]; cb != nil {
+			switch result.Status {
+			case HealthStatusHealthy:
+				cb.RecordSuccess()
+			case HealthStatusUnhealthy:
+				cb.RecordFailure()
+			}
+		}
+
+		h.recordHistory(result)
 		results = append(results, result)
 	}
 
 	return results
 }
 
+// HealthCheckHistorySize returns the number of results kept per check,
+// 0 if history is disabled.
+func (h *HealthChecker) HealthCheckHistorySize() int {
+	return h.historySize
+}
+
+// recordHistory appends a result to its check's ring buffer.
+func (h *HealthChecker) recordHistory(result HealthCheck) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	size := h.historySize
+	if size == 0 {
+		return
+	}
+	ring := h.history[result.Name]
+	if ring == nil {
+		ring = &healthHistory{}
+		h.history[result.Name] = ring
+	}
+
+	// start only moves once the buffer is full, so appending while there
+	// is room keeps the entries in order
+	if len(ring.entries) < size {
+		ring.entries = append(ring.entries, result)
+		return
+	}
+	ring.entries[ring.start] = result
+	ring.start = (ring.start + 1) % size
+}
+
+// chronological returns the ring's entries oldest first.
+func (r *healthHistory) chronological() []HealthCheck {
+	out := make([]HealthCheck, 0, len(r.entries))
+	out = append(out, r.entries[r.start:]...)
+	return append(out, r.entries[:r.start]...)
+}
+
+// History returns the most recent results for the named check, oldest
+// first. It returns nil if the check has not run yet.
+func (h *HealthChecker) History(name string) []HealthCheck {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	ring := h.history[name]
+	if ring == nil {
+		return nil
+	}
+	return ring.chronological()
+}
+
 // OverallStatus returns the overall health status based on all checks.
 func (h *HealthChecker) OverallStatus(ctx context.Context) HealthStatus {
 	results := h.Check(ctx)
@@ -1777,3 +3357,79 @@ func (h *HealthChecker) OverallStatus(ctx context.Context) HealthStatus {
 	}
 	return HealthStatusHealthy
 }
+
+// HTTPHandler returns a handler for a /health endpoint. It runs every
+// check and responds with a JSON array of results sorted by name, as
+// described by OpenAPISchema. The status code is 503 if any check is
+// unhealthy, so load balancers can act on it without parsing the body.
+func (h *HealthChecker) HTTPHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		results := h.Check(r.Context())
+		sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+
+		status := http.StatusOK
+		for _, result := range results {
+			if result.Status == HealthStatusUnhealthy {
+				status = http.StatusServiceUnavailable
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if err := json.NewEncoder(w).Encode(results); err != nil {
+			h.logger.Error(r.Context(), "failed to write health response", err, nil)
+		}
+	})
+}
+
+// OpenAPISchema returns a JSON Schema (as used by OpenAPI 3) for the body
+// of an HTTPHandler response. Marshal it with encoding/json to embed it in
+// an API gateway or OpenAPI document:
+//
+//	responses:
+//	  "200":
+//	    content:
+//	      application/json:
+//	        schema: <OpenAPISchema>
+func (h *HealthChecker) OpenAPISchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "array",
+		"description": "Result of every registered health check, sorted by name.",
+		"items": map[string]interface{}{
+			"type":     "object",
+			"required": []interface{}{"name", "status", "duration_ms", "timestamp"},
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name the check was registered with.",
+				},
+				"status": map[string]interface{}{
+					"type": "string",
+					"enum": []interface{}{
+						string(HealthStatusHealthy),
+						string(HealthStatusDegraded),
+						string(HealthStatusUnhealthy),
+					},
+				},
+				"message": map[string]interface{}{
+					"type":        "string",
+					"description": "Human-readable detail; omitted when empty.",
+				},
+				"duration_ms": map[string]interface{}{
+					"type":        "integer",
+					"minimum":     0,
+					"description": "Time the check took, in milliseconds.",
+				},
+				"timestamp": map[string]interface{}{
+					"type":        "string",
+					"format":      "date-time",
+					"description": "When the check finished, in RFC 3339 format.",
+				},
+				"details": map[string]interface{}{
+					"type":                 "object",
+					"additionalProperties": true,
+				},
+			},
+		},
+	}
+}
@@ -6,9 +6,15 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -64,6 +70,35 @@ func TestCounter_Add(t *testing.T) {
 	}
 }
 
+func TestCounter_IncIfAddIf(t *testing.T) {
+	counter := NewCounter(MetricOpts{
+		Namespace: "test",
+		Name:      "filtered_total",
+		Help:      "Test counter",
+		Labels:    []string{"reason"},
+	})
+
+	for _, matched := range []bool{true, false, true, false, true} {
+		counter.IncIf(matched, "dropped")
+	}
+	if got := counter.Value("dropped"); got != 3 {
+		t.Errorf("IncIf: Value() = %v, want 3", got)
+	}
+
+	counter.AddIf(true, 2, "dropped")
+	counter.AddIf(false, 10, "dropped")
+	if got := counter.Value("dropped"); got != 5 {
+		t.Errorf("AddIf: Value() = %v, want 5", got)
+	}
+
+	// The negative-value guard applies whether or not the condition holds
+	counter.AddIf(false, -1, "dropped")
+	counter.AddIf(true, -1, "dropped")
+	if got := counter.Value("dropped"); got != 5 {
+		t.Errorf("AddIf with a negative value: Value() = %v, want 5", got)
+	}
+}
+
 func TestCounter_FullName(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -105,6 +140,33 @@ func TestCounter_FullName(t *testing.T) {
 	}
 }
 
+func TestCounter_Reset(t *testing.T) {
+	counter := NewCounter(MetricOpts{
+		Name:   "test_counter",
+		Help:   "Test counter",
+		Labels: []string{"method"},
+	})
+
+	counter.Inc("GET")
+	counter.Add(5, "POST")
+	counter.Reset()
+
+	if got := counter.Value("GET"); got != 0 {
+		t.Errorf("Value(GET) after Reset = %v, want 0", got)
+	}
+	if got := counter.Value("POST"); got != 0 {
+		t.Errorf("Value(POST) after Reset = %v, want 0", got)
+	}
+	if samples := counter.Collect().Samples; len(samples) != 0 {
+		t.Errorf("expected no series after Reset, got %d", len(samples))
+	}
+
+	counter.Inc("GET")
+	if got := counter.Value("GET"); got != 1 {
+		t.Errorf("Value(GET) after Reset and Inc = %v, want 1", got)
+	}
+}
+
 // =============================================================================
 // SECTION 2: Gauge Tests
 // =============================================================================
@@ -166,6 +228,66 @@ func TestGauge_Add(t *testing.T) {
 	}
 }
 
+func TestGauge_SetToCurrentTime(t *testing.T) {
+	gauge := NewGauge(MetricOpts{
+		Namespace: "test",
+		Name:      "last_successful_sync_timestamp_seconds",
+		Help:      "Test gauge",
+		Labels:    []string{"job"},
+	})
+
+	gauge.SetToCurrentTime("compactor")
+	now := float64(time.Now().Unix())
+
+	if got := gauge.Value("compactor"); math.Abs(now-got) > 1 {
+		t.Errorf("SetToCurrentTime() = %v, want within 1s of %v", got, now)
+	}
+	if got := gauge.Value("ruler"); got != 0 {
+		t.Errorf("other label values should be unaffected, got %v", got)
+	}
+}
+
+func TestGauge_Track(t *testing.T) {
+	gauge := NewGauge(MetricOpts{
+		Namespace: "test",
+		Name:      "in_flight_requests",
+		Help:      "Test gauge",
+		Labels:    []string{"method", "path"},
+	})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer gauge.Track("GET", "/api")()
+			started <- struct{}{}
+			<-release
+		}()
+	}
+	for i := 0; i < 3; i++ {
+		<-started
+	}
+
+	if got := gauge.Value("GET", "/api"); got != 3 {
+		t.Errorf("Gauge with 3 trackers = %v, want 3", got)
+	}
+	close(release)
+	wg.Wait()
+	if got := gauge.Value("GET", "/api"); got != 0 {
+		t.Errorf("Gauge after trackers finished = %v, want 0", got)
+	}
+
+	done := gauge.Track("GET", "/api")
+	done()
+	done()
+	if got := gauge.Value("GET", "/api"); got != 0 {
+		t.Errorf("Gauge after calling done twice = %v, want 0", got)
+	}
+}
+
 // =============================================================================
 // SECTION 3: Histogram Tests
 // =============================================================================
@@ -236,6 +358,145 @@ func TestHistogram_WithLabels(t *testing.T) {
 	}
 }
 
+func TestHistogram_Reset(t *testing.T) {
+	histogram := NewHistogram(MetricOpts{
+		Namespace: "test",
+		Name:      "request_duration_seconds",
+		Help:      "Test histogram",
+		Labels:    []string{"method"},
+		Buckets:   []float64{0.1, 0.5, 1.0},
+	})
+
+	labels := []string{"GET", "POST", "PUT"}
+	for i, v := range []float64{0.05, 0.2, 0.7, 1.5, 0.3} {
+		histogram.Observe(v, labels[i%len(labels)])
+	}
+
+	histogram.ResetLabels("GET")
+	if got := histogram.Count("GET"); got != 0 {
+		t.Errorf("Count(GET) after ResetLabels = %d, want 0", got)
+	}
+	if got := histogram.Count("POST"); got != 2 {
+		t.Errorf("Count(POST) after ResetLabels(GET) = %d, want 2", got)
+	}
+
+	histogram.Reset()
+	for _, method := range labels {
+		if got := histogram.Count(method); got != 0 {
+			t.Errorf("Count(%s) after Reset = %d, want 0", method, got)
+		}
+		if got := histogram.Sum(method); got != 0 {
+			t.Errorf("Sum(%s) after Reset = %v, want 0", method, got)
+		}
+	}
+	if got := len(histogram.Collect().Samples); got == 0 {
+		t.Error("Reset removed the series, want them kept at zero")
+	}
+
+	histogram.Observe(0.2, "GET")
+	if got := histogram.Count("GET"); got != 1 {
+		t.Errorf("Count(GET) after observing again = %d, want 1", got)
+	}
+}
+
+func TestHistogram_WithBucketsForLabels(t *testing.T) {
+	histogram := NewHistogram(MetricOpts{
+		Namespace: "test",
+		Name:      "request_duration_seconds",
+		Help:      "Test histogram",
+		Labels:    []string{"endpoint"},
+		Buckets:   []float64{0.1, 1.0},
+	}).
+		WithBucketsForLabels([]string{"/health"}, []float64{0.01, 0.001, 0.005}).
+		WithBucketsForLabels([]string{"/upload"}, []float64{5, 30})
+
+	histogram.Observe(0.003, "/health")
+	histogram.Observe(12, "/upload")
+	histogram.Observe(0.5, "/api/users")
+
+	want := map[string]struct {
+		buckets []float64
+		counts  []uint64
+	}{
+		// Overrides are sorted; 0.003 falls into the 0.005 and 0.01 buckets
+		"/health": {buckets: []float64{0.001, 0.005, 0.01}, counts: []uint64{0, 1, 1, 1}},
+		"/upload": {buckets: []float64{5, 30}, counts: []uint64{0, 1, 1}},
+		// Labels without an override use the default buckets
+		"/api/users": {buckets: []float64{0.1, 1.0}, counts: []uint64{0, 1, 1}},
+	}
+
+	samples := histogram.Collect().Samples
+	if len(samples) != len(want) {
+		t.Fatalf("Collect() returned %d samples, want %d", len(samples), len(want))
+	}
+	for _, s := range samples {
+		endpoint := s.LabelValues[0]
+		w, ok := want[endpoint]
+		if !ok {
+			t.Errorf("unexpected series %q", endpoint)
+			continue
+		}
+		if fmt.Sprint(s.Buckets) != fmt.Sprint(w.buckets) {
+			t.Errorf("%s buckets = %v, want %v", endpoint, s.Buckets, w.buckets)
+		}
+		if fmt.Sprint(s.BucketCounts) != fmt.Sprint(w.counts) {
+			t.Errorf("%s bucket counts = %v, want %v", endpoint, s.BucketCounts, w.counts)
+		}
+	}
+}
+
+func TestMetricOpts_MaxLabelCardinality(t *testing.T) {
+	counter := NewCounter(MetricOpts{
+		Namespace:           "test",
+		Name:                "cardinality_requests_total",
+		Labels:              []string{"user_id"},
+		MaxLabelCardinality: 5,
+	})
+	overflowBefore := CardinalityOverflowTotal.Value("test_cardinality_requests_total")
+
+	for i := 0; i < 10; i++ {
+		counter.Inc(fmt.Sprintf("user-%d", i))
+	}
+	for i := 0; i < 10; i++ {
+		got := counter.Value(fmt.Sprintf("user-%d", i))
+		if i < 5 && got != 1 {
+			t.Errorf("Value(user-%d) = %v, want 1", i, got)
+		}
+		if i >= 5 && got != 0 {
+			t.Errorf("Value(user-%d) = %v, want 0 (over the limit)", i, got)
+		}
+	}
+
+	// Existing series keep accepting observations
+	counter.Inc("user-0")
+	if got := counter.Value("user-0"); got != 2 {
+		t.Errorf("Value(user-0) = %v, want 2", got)
+	}
+
+	overflow := CardinalityOverflowTotal.Value("test_cardinality_requests_total") - overflowBefore
+	if overflow != 5 {
+		t.Errorf("overflow count = %v, want 5", overflow)
+	}
+}
+
+func TestMetricOpts_MaxLabelCardinalityGaugeAndHistogram(t *testing.T) {
+	opts := MetricOpts{Name: "cardinality_test", Labels: []string{"id"}, MaxLabelCardinality: 2}
+	gauge := NewGauge(opts)
+	histogram := NewHistogram(opts)
+
+	for _, id := range []string{"a", "b", "c"} {
+		gauge.Set(1, id)
+		histogram.Observe(1, id)
+	}
+
+	if gauge.Value("b") != 1 || gauge.Value("c") != 0 {
+		t.Errorf("gauge values b=%v c=%v, want 1 and 0", gauge.Value("b"), gauge.Value("c"))
+	}
+	if histogram.Count("b") != 1 || histogram.Count("c") != 0 {
+		t.Errorf("histogram counts b=%d c=%d, want 1 and 0", histogram.Count("b"), histogram.Count("c"))
+	}
+}
+
 // =============================================================================
 // SECTION 4: RED Metrics Tests
 // =============================================================================
@@ -263,6 +524,37 @@ func TestREDMetrics_RecordRequest(t *testing.T) {
 	}
 }
 
+func TestREDMetrics_WithNamespace(t *testing.T) {
+	base := NewREDMetrics("lib", "http")
+	red := base.WithNamespace("my_svc")
+
+	red.RecordRequest("GET", "/api/users", "OK", 100*time.Millisecond, errors.New("connection timeout"))
+
+	for _, name := range []string{
+		red.RequestsTotal.opts.FullName(),
+		red.RequestErrors.opts.FullName(),
+		red.RequestDuration.opts.FullName(),
+		red.InFlightRequests.opts.FullName(),
+	} {
+		if !strings.HasPrefix(name, "my_svc_http_") {
+			t.Errorf("metric name %q does not start with my_svc_http_", name)
+		}
+	}
+	if got := red.RequestsTotal.Value("GET", "/api/users", "OK"); got != 1 {
+		t.Errorf("RequestsTotal = %v, want 1", got)
+	}
+	if got := base.RequestsTotal.Value("GET", "/api/users", "OK"); got != 0 {
+		t.Errorf("base RequestsTotal = %v, want 0", got)
+	}
+	if got := base.RequestsTotal.opts.FullName(); got != "lib_http_requests_total" {
+		t.Errorf("base metric renamed to %q", got)
+	}
+
+	if got := base.WithSubsystem("grpc").RequestsTotal.opts.FullName(); got != "lib_grpc_requests_total" {
+		t.Errorf("WithSubsystem name = %q, want lib_grpc_requests_total", got)
+	}
+}
+
 func TestREDMetrics_InFlightRequests(t *testing.T) {
 	red := NewREDMetrics("test", "http")
 
@@ -280,6 +572,56 @@ func TestREDMetrics_InFlightRequests(t *testing.T) {
 	}
 }
 
+func TestREDMetrics_Reset(t *testing.T) {
+	red := NewREDMetrics("test", "http")
+
+	red.StartRequest("GET", "/api/users")
+	red.RecordRequest("GET", "/api/users", "500", 100*time.Millisecond, errors.New("boom"))
+	red.Reset()
+
+	if got := red.RequestsTotal.Value("GET", "/api/users", "500"); got != 0 {
+		t.Errorf("RequestsTotal after Reset = %v, want 0", got)
+	}
+	if got := red.RequestErrors.Value("GET", "/api/users", "internal"); got != 0 {
+		t.Errorf("RequestErrors after Reset = %v, want 0", got)
+	}
+	if got := red.RequestDuration.Count("GET", "/api/users"); got != 0 {
+		t.Errorf("RequestDuration count after Reset = %v, want 0", got)
+	}
+	if got := red.InFlightRequests.Value("GET", "/api/users"); got != 0 {
+		t.Errorf("InFlightRequests after Reset = %v, want 0", got)
+	}
+}
+
+func TestREDMetrics_Percentiles(t *testing.T) {
+	red := NewREDMetrics("test", "http")
+
+	// 1000 requests spread uniformly over 0-1s
+	for i := 0; i < 1000; i++ {
+		d := time.Duration((float64(i) + 0.5) / 1000 * float64(time.Second))
+		red.RecordRequest("GET", "/api/users", "200", d, nil)
+	}
+
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"P50", red.P50("GET", "/api/users"), 0.5},
+		{"P90", red.P90("GET", "/api/users"), 0.9},
+		{"P99", red.P99("GET", "/api/users"), 0.99},
+	}
+	for _, tt := range tests {
+		if math.Abs(tt.got-tt.want) > 0.05*tt.want {
+			t.Errorf("%s = %v, want %v ±5%%", tt.name, tt.got, tt.want)
+		}
+	}
+
+	if got := red.P50("GET", "/unknown"); !math.IsNaN(got) {
+		t.Errorf("P50 for unrecorded endpoint = %v, want NaN", got)
+	}
+}
+
 func TestCategorizeError(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -451,6 +793,228 @@ func TestLogger_With(t *testing.T) {
 	}
 }
 
+func TestLogger_AsyncSync(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger("test-service", WithAsync(16), WithOutput(&buf))
+	child := logger.With(map[string]interface{}{"component": "child"})
+
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		l := logger
+		if i%2 == 1 {
+			l = child
+		}
+		l.Info(ctx, "async message", map[string]interface{}{"n": i})
+	}
+
+	if err := logger.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 100 {
+		t.Fatalf("expected 100 entries after Sync, got %d", len(lines))
+	}
+	for i, line := range lines {
+		var entry LogEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("entry %d: %v", i, err)
+		}
+		if n := entry.Fields["n"]; n != float64(i) {
+			t.Errorf("entry %d has n=%v; entries should keep their order", i, n)
+		}
+	}
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestLogger_SyncReturnsWriteError(t *testing.T) {
+	logger := NewLogger("test-service", WithOutput(failingWriter{}), WithAsync(4))
+	logger.Info(context.Background(), "lost", nil)
+
+	if err := logger.Sync(); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("Sync error = %v, want the write error", err)
+	}
+	// The error is reported once
+	if err := logger.Sync(); err != nil {
+		t.Errorf("second Sync error = %v, want nil", err)
+	}
+
+	// Synchronous loggers have nothing to flush
+	if err := NewLogger("sync", WithOutput(failingWriter{})).Sync(); err != nil {
+		t.Errorf("Sync on a synchronous logger = %v, want nil", err)
+	}
+}
+
+func TestLogger_Close(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger("test-service", WithAsync(16), WithOutput(&buf))
+	child := logger.With(map[string]interface{}{"component": "child"})
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		logger.Info(ctx, "queued", nil)
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if got := strings.Count(buf.String(), "\n"); got != 10 {
+		t.Errorf("expected 10 entries written by Close, got %d", got)
+	}
+
+	// Closing stops the background goroutine for derived loggers too; later
+	// entries are written synchronously instead of being lost
+	child.Info(ctx, "after close", nil)
+	if !strings.Contains(buf.String(), "after close") {
+		t.Error("entry logged after Close was lost")
+	}
+	if err := child.Sync(); err != nil {
+		t.Errorf("Sync after Close = %v, want nil", err)
+	}
+	if err := logger.Close(); err != nil {
+		t.Errorf("second Close = %v, want nil", err)
+	}
+}
+
+func TestMultiSinkLogger(t *testing.T) {
+	var debugBuf, errorBuf bytes.Buffer
+	logger := NewMultiSinkLogger("test-service")
+	logger.AddSink(&debugBuf, DebugLevel)
+	logger.AddSink(&errorBuf, ErrorLevel)
+
+	ctx := context.Background()
+	logger.Debug(ctx, "cache miss", nil)
+	logger.Info(ctx, "request served", nil)
+	logger.Warn(ctx, "slow query", nil)
+	logger.Error(ctx, "upstream failed", errors.New("connection refused"), nil)
+	logger.Fatal(ctx, "out of memory", errors.New("oom"), nil)
+
+	if got := strings.Count(debugBuf.String(), "\n"); got != 5 {
+		t.Errorf("debug sink has %d entries, want 5:\n%s", got, debugBuf.String())
+	}
+	// FatalLevel is above ErrorLevel, so the error sink gets both
+	lines := strings.Split(strings.TrimSpace(errorBuf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("error sink has %d entries, want 2:\n%s", len(lines), errorBuf.String())
+	}
+	var entry LogEntry
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("error sink entry is not JSON: %v", err)
+	}
+	if entry.Level != "error" || entry.Message != "upstream failed" {
+		t.Errorf("error sink entry = %+v, want the error entry", entry)
+	}
+}
+
+func TestMultiSinkLogger_FatalReachesEverySink(t *testing.T) {
+	levels := []LogLevel{DebugLevel, InfoLevel, WarnLevel, ErrorLevel, FatalLevel}
+	bufs := make([]bytes.Buffer, len(levels))
+	logger := NewMultiSinkLogger("test-service")
+	for i, level := range levels {
+		logger.AddSink(&bufs[i], level)
+	}
+
+	logger.Fatal(context.Background(), "out of memory", errors.New("oom"), nil)
+
+	for i, level := range levels {
+		if !strings.Contains(bufs[i].String(), `"level":"fatal"`) {
+			t.Errorf("%s sink did not receive the fatal entry: %q", level, bufs[i].String())
+		}
+	}
+
+	// Below-fatal entries still respect each sink's level
+	logger.Error(context.Background(), "upstream failed", errors.New("refused"), nil)
+	if got := strings.Count(bufs[4].String(), "\n"); got != 1 {
+		t.Errorf("fatal sink has %d entries after an error entry, want 1", got)
+	}
+}
+
+func TestLogger_Schema(t *testing.T) {
+	logger := NewLogger("test-service",
+		WithOutput(io.Discard),
+		WithFields(map[string]interface{}{"region": "us-east-1"}),
+		WithSchema(
+			LogField{Key: "status", Type: FieldInt, Description: "HTTP status code"},
+			LogField{Key: "duration", Type: FieldDuration, Description: "Request latency"},
+			LogField{Key: "cache_hit", Type: FieldBool},
+		),
+	).With(map[string]interface{}{"ratio": 0.5})
+
+	data, err := logger.SchemaJSON()
+	if err != nil {
+		t.Fatalf("SchemaJSON: %v", err)
+	}
+	var schema []map[string]string
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("invalid schema JSON %s: %v", data, err)
+	}
+
+	want := []map[string]string{
+		{"key": "cache_hit", "type": "bool"},
+		{"key": "duration", "type": "duration", "description": "Request latency"},
+		{"key": "ratio", "type": "float"},
+		{"key": "region", "type": "string"},
+		{"key": "status", "type": "int", "description": "HTTP status code"},
+	}
+	if fmt.Sprint(schema) != fmt.Sprint(want) {
+		t.Errorf("schema = %s\nwant %v", data, want)
+	}
+}
+
+func TestLogger_DevelopmentMode(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger("test-service", WithOutput(&buf), WithLevel(DebugLevel), WithDevelopmentMode())
+
+	ctx := context.Background()
+	logger.Debug(ctx, "cache miss", map[string]interface{}{"key": "user:42"})
+	logger.Info(ctx, "request served", map[string]interface{}{"status": 200, "path": "/api/v1/push"})
+	logger.Warn(ctx, "slow query", nil)
+	logger.Error(ctx, "write failed", errors.New("disk full"), nil)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 lines, got %d: %q", len(lines), buf.String())
+	}
+
+	tests := []struct {
+		level string
+		color string
+		msg   string
+	}{
+		{"DEBUG", ansiCyan, "cache miss"},
+		{"INFO", ansiGreen, "request served"},
+		{"WARN", ansiYellow, "slow query"},
+		{"ERROR", ansiRed, "write failed"},
+	}
+	for i, tt := range tests {
+		line := lines[i]
+		if !strings.HasPrefix(line, tt.color+tt.level+ansiReset+" ") {
+			t.Errorf("line %d = %q, want prefix %q", i, line, tt.color+tt.level+ansiReset)
+		}
+		if !strings.Contains(line, " instrumentation_test.go:") {
+			t.Errorf("line %d = %q, want caller", i, line)
+		}
+		if !strings.Contains(line, " "+tt.msg) {
+			t.Errorf("line %d = %q, want message %q", i, line, tt.msg)
+		}
+		if strings.Contains(line, "{") {
+			t.Errorf("line %d = %q, should not be JSON", i, line)
+		}
+	}
+
+	if !strings.HasSuffix(lines[1], "request served path=/api/v1/push status=200") {
+		t.Errorf("fields not sorted key=value pairs: %q", lines[1])
+	}
+	if !strings.Contains(lines[3], `error="disk full"`) {
+		t.Errorf("error field not quoted: %q", lines[3])
+	}
+}
+
 // =============================================================================
 // SECTION 6: Tracer Tests
 // =============================================================================
@@ -508,6 +1072,35 @@ func TestTracer_ChildSpan(t *testing.T) {
 	}
 }
 
+func TestTracer_Fork(t *testing.T) {
+	tracer := NewTracer(TracerConfig{
+		ServiceName: "test-service",
+		Exporter:    NewInMemoryExporter(),
+	})
+
+	ctx, parent := tracer.StartSpan(context.Background(), "request", SpanKindServer)
+	first := tracer.Fork(ctx, "cleanup", SpanKindInternal)
+	second := tracer.Fork(ctx, "audit", SpanKindInternal)
+
+	for _, forked := range []*Span{first, second} {
+		if forked.ParentSpanID != parent.SpanID || forked.TraceID != parent.TraceID {
+			t.Errorf("%s: parent = %s/%s, want %s/%s", forked.Name,
+				forked.TraceID, forked.ParentSpanID, parent.TraceID, parent.SpanID)
+		}
+	}
+	if first.SpanID == second.SpanID {
+		t.Error("forked spans should have distinct span IDs")
+	}
+
+	// The context still points at the parent
+	if got := ctx.Value(SpanIDKey); got != parent.SpanID {
+		t.Errorf("context span ID = %v, want parent %s", got, parent.SpanID)
+	}
+	if SpanFromContext(ctx) != parent {
+		t.Error("SpanFromContext should still return the parent span")
+	}
+}
+
 func TestSpan_SetAttribute(t *testing.T) {
 	span := &Span{
 		Attributes: make(map[string]interface{}),
@@ -583,34 +1176,329 @@ func TestSpan_Duration(t *testing.T) {
 	}
 }
 
-func TestRatioSampler(t *testing.T) {
-	// Test 0% sampling
-	sampler0 := NewRatioSampler(0.0)
-	sampled := false
-	for i := 0; i < 100; i++ {
-		if sampler0.ShouldSample("trace-" + string(rune(i))) {
-			sampled = true
-			break
-		}
+func TestTracer_ForceFlush(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	tracer := NewTracer(TracerConfig{
+		ServiceName: "test-service",
+		Sampler:     &AlwaysSampler{},
+		Exporter:    exporter,
+	})
+
+	_, span := tracer.StartSpan(context.Background(), "flush-me", SpanKindInternal)
+	span.End()
+	tracer.RecordSpan(span)
+
+	if len(exporter.Spans()) != 0 {
+		t.Fatal("span should stay buffered until flushed")
 	}
-	if sampled {
-		t.Error("0% sampler should not sample any traces")
+	if err := tracer.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("ForceFlush: %v", err)
 	}
 
-	// Test 100% sampling
-	sampler100 := NewRatioSampler(1.0)
-	allSampled := true
-	for i := 0; i < 100; i++ {
-		if !sampler100.ShouldSample("trace-" + string(rune(i))) {
-			allSampled = false
-			break
-		}
+	spans := exporter.Spans()
+	if len(spans) != 1 || spans[0] != span {
+		t.Fatalf("expected the ended span to be exported, got %v", spans)
+	}
+
+	// Nothing is left buffered
+	if err := tracer.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("second ForceFlush: %v", err)
+	}
+	if len(exporter.Spans()) != 1 {
+		t.Errorf("expected no duplicate exports, got %d spans", len(exporter.Spans()))
+	}
+}
+
+// blockingExporter blocks every Export until release is closed.
+type blockingExporter struct {
+	release chan struct{}
+}
+
+func (e *blockingExporter) Export(spans []*Span) error {
+	<-e.release
+	return nil
+}
+
+func TestTracer_ForceFlushContextExpired(t *testing.T) {
+	exporter := &blockingExporter{release: make(chan struct{})}
+	defer close(exporter.release)
+	tracer := NewTracer(TracerConfig{Exporter: exporter})
+
+	_, span := tracer.StartSpan(context.Background(), "slow", SpanKindInternal)
+	span.End()
+	tracer.RecordSpan(span)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := tracer.ForceFlush(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ForceFlush error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+// failingExporter fails every Export and records Close calls.
+type failingExporter struct {
+	err    error
+	closed atomic.Bool
+}
+
+func (e *failingExporter) Export(spans []*Span) error { return e.err }
+
+func (e *failingExporter) Close() error {
+	e.closed.Store(true)
+	return nil
+}
+
+func TestMultiSpanExporter(t *testing.T) {
+	first, second := NewInMemoryExporter(), NewInMemoryExporter()
+	exportErr := errors.New("collector unavailable")
+	failing := &failingExporter{err: exportErr}
+	multi := NewMultiSpanExporter(first, failing, second)
+
+	spans := []*Span{{Name: "query"}, {Name: "merge"}}
+	err := multi.Export(spans)
+	var multiErr *MultiError
+	if !errors.As(err, &multiErr) {
+		t.Fatalf("Export error = %T (%v), want *MultiError", err, err)
+	}
+	if len(multiErr.Errors) != 1 || !errors.Is(multiErr.Errors[0], exportErr) {
+		t.Errorf("MultiError.Errors = %v, want only the failing exporter's error", multiErr.Errors)
+	}
+	if !strings.Contains(multiErr.Errors[0].Error(), "exporter 1") {
+		t.Errorf("error %q does not name the failing exporter", multiErr.Errors[0])
+	}
+	for i, exporter := range []*InMemoryExporter{first, second} {
+		got := exporter.Spans()
+		if len(got) != 2 || got[0] != spans[0] || got[1] != spans[1] {
+			t.Errorf("exporter %d received %v, want both spans", i, got)
+		}
+	}
+
+	if err := NewMultiSpanExporter(first, second).Export(spans); err != nil {
+		t.Errorf("Export with healthy exporters = %v, want nil", err)
+	}
+
+	if err := multi.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown = %v, want nil", err)
+	}
+	if !failing.closed.Load() {
+		t.Error("Shutdown did not close the io.Closer exporter")
+	}
+}
+
+func TestTracer_Resource(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	tracer := NewTracer(TracerConfig{
+		ServiceName: "querier",
+		Exporter:    exporter,
+		Resource: NewResource(map[string]interface{}{
+			"service.name":    "overridden",
+			"service.version": "2.0.0",
+			"host.name":       "box1",
+		}),
+	})
+
+	_, span := tracer.StartSpan(context.Background(), "query", SpanKindServer)
+	span.End()
+	tracer.RecordSpan(span)
+	if err := tracer.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("ForceFlush failed: %v", err)
+	}
+
+	spans := exporter.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("exported %d spans, want 1", len(spans))
+	}
+	want := map[string]interface{}{
+		"service.name":    "querier", // TracerConfig.ServiceName wins
+		"service.version": "2.0.0",
+		"host.name":       "box1",
+	}
+	for k, v := range want {
+		if got := spans[0].Attributes[k]; got != v {
+			t.Errorf("attribute %s = %v, want %v", k, got, v)
+		}
+	}
+
+	// Resources are immutable: changing returned attributes has no effect
+	tracer.Resource().Attributes()["host.name"] = "box2"
+	if got := tracer.Resource().Attributes()["host.name"]; got != "box1" {
+		t.Errorf("resource host.name = %v, want box1", got)
+	}
+}
+
+func TestResource_Merge(t *testing.T) {
+	base := NewResource(map[string]interface{}{"host.name": "box1", "os.type": "linux"})
+	merged := base.Merge(NewResource(map[string]interface{}{"host.name": "box2"}))
+
+	if got := merged.Attributes()["host.name"]; got != "box2" {
+		t.Errorf("merged host.name = %v, want box2", got)
+	}
+	if got := merged.Attributes()["os.type"]; got != "linux" {
+		t.Errorf("merged os.type = %v, want linux", got)
+	}
+	if got := base.Attributes()["host.name"]; got != "box1" {
+		t.Errorf("base host.name = %v, want box1 (Merge must not modify it)", got)
+	}
+}
+
+func TestSpan_Limits(t *testing.T) {
+	tracer := NewTracer(TracerConfig{
+		Exporter:             NewInMemoryExporter(),
+		MaxAttributesPerSpan: 4,
+		MaxEventsPerSpan:     2,
+		MaxLinksPerSpan:      1,
+	})
+
+	// The tracer's own attributes do not count toward the limit
+	ctx := WithRequestMetadata(context.Background(), "req-1", "")
+	_, span := tracer.StartSpan(ctx, "batch", SpanKindConsumer)
+	span.SetAttribute("a", 1)
+	span.SetAttribute("b", 2)
+	span.SetAttributes(map[string]interface{}{"c": 3, "d": 4})
+	span.SetAttributes(map[string]interface{}{"e": 5, "f": 6})
+	span.SetAttribute("a", 10) // overwriting an existing key is not dropped
+
+	span.AddEvent("first", nil)
+	span.AddEvent("second", nil)
+	span.AddEvent("third", nil)
+	span.RecordError(errors.New("boom"))
+
+	span.AddLink(SpanLink{TraceID: "trace-1", SpanID: "span-1"})
+	span.AddLink(SpanLink{TraceID: "trace-2", SpanID: "span-2"})
+
+	attrs, events, links := span.DroppedCounts()
+	if attrs != 2 || events != 2 || links != 1 {
+		t.Errorf("DroppedCounts() = (%d, %d, %d), want (2, 2, 1)", attrs, events, links)
+	}
+	// 4 set by the caller + service.name, service.version and request.id
+	if len(span.Attributes) != 7 || span.Attributes["a"] != 10 || span.Attributes["request.id"] != "req-1" {
+		t.Errorf("Attributes = %v, want 7 entries with a=10", span.Attributes)
+	}
+	if len(span.Events) != 2 || len(span.Links) != 1 {
+		t.Errorf("got %d events and %d links, want 2 and 1", len(span.Events), len(span.Links))
+	}
+	if span.Status != SpanStatusError {
+		t.Error("RecordError should set the status even when its event is dropped")
+	}
+}
+
+func TestSpan_NoLimitsByDefault(t *testing.T) {
+	tracer := NewTracer(TracerConfig{Exporter: NewInMemoryExporter()})
+	_, span := tracer.StartSpan(context.Background(), "op", SpanKindInternal)
+
+	for i := 0; i < 200; i++ {
+		span.SetAttribute(fmt.Sprintf("attr.%d", i), i)
+		span.AddEvent("event", nil)
+		span.AddLink(SpanLink{TraceID: "trace", SpanID: fmt.Sprint(i)})
+	}
+
+	if attrs, events, links := span.DroppedCounts(); attrs+events+links != 0 {
+		t.Errorf("DroppedCounts() = (%d, %d, %d), want nothing dropped", attrs, events, links)
+	}
+}
+
+func TestSpan_IsRecording(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	sampled := NewTracer(TracerConfig{Sampler: &AlwaysSampler{}, Exporter: exporter})
+	unsampled := NewTracer(TracerConfig{Sampler: NewRatioSampler(0), Exporter: exporter})
+
+	_, span := sampled.StartSpan(context.Background(), "sampled", SpanKindInternal)
+	if !span.IsRecording() {
+		t.Error("span from AlwaysSampler should be recording")
+	}
+
+	_, noop := unsampled.StartSpan(context.Background(), "unsampled", SpanKindInternal)
+	if noop.IsRecording() {
+		t.Error("span from a 0% sampler should not be recording")
+	}
+
+	// Mutating a non-recording span is a safe no-op
+	noop.SetAttribute("key", "value")
+	noop.SetAttributes(map[string]interface{}{"a": 1})
+	noop.AddEvent("event", nil)
+	noop.RecordError(errors.New("boom"))
+	noop.End()
+	if len(noop.Attributes) != 0 || len(noop.Events) != 0 || noop.Status != SpanStatusUnset {
+		t.Errorf("non-recording span should not store data, got %+v", noop)
+	}
+
+	unsampled.RecordSpan(noop)
+	if err := unsampled.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("ForceFlush: %v", err)
+	}
+	if len(exporter.Spans()) != 0 {
+		t.Error("non-recording spans should not be exported")
+	}
+}
+
+func TestRatioSampler(t *testing.T) {
+	// Test 0% sampling
+	sampler0 := NewRatioSampler(0.0)
+	sampled := false
+	for i := 0; i < 100; i++ {
+		if sampler0.ShouldSample("trace-" + string(rune(i))) {
+			sampled = true
+			break
+		}
+	}
+	if sampled {
+		t.Error("0% sampler should not sample any traces")
+	}
+
+	// Test 100% sampling
+	sampler100 := NewRatioSampler(1.0)
+	allSampled := true
+	for i := 0; i < 100; i++ {
+		if !sampler100.ShouldSample("trace-" + string(rune(i))) {
+			allSampled = false
+			break
+		}
 	}
 	if !allSampled {
 		t.Error("100% sampler should sample all traces")
 	}
 }
 
+func TestCompositeSampler(t *testing.T) {
+	sampler := NewParentOrRatioSampler(0.1)
+	sampledParent := context.WithValue(context.Background(), SampledKey, true)
+	unsampledParent := context.WithValue(context.Background(), SampledKey, false)
+
+	const trials = 10000
+	var sampled, parentSampled, parentUnsampled int
+	for i := 0; i < trials; i++ {
+		traceID := NewTraceID().String()
+		if sampler.ShouldSampleContext(sampledParent, traceID) {
+			parentSampled++
+		}
+		if sampler.ShouldSampleContext(unsampledParent, traceID) {
+			parentUnsampled++
+		}
+		if sampler.ShouldSampleContext(context.Background(), traceID) {
+			sampled++
+		}
+	}
+
+	if parentSampled != trials {
+		t.Errorf("sampled parent: %d/%d sampled, want all", parentSampled, trials)
+	}
+	if parentUnsampled != 0 {
+		t.Errorf("unsampled parent: %d/%d sampled, want none", parentUnsampled, trials)
+	}
+	if sampled < 800 || sampled > 1200 {
+		t.Errorf("no parent: %d/%d sampled, want about 10%%", sampled, trials)
+	}
+
+	// The tracer consults the parent decision carried by a traceparent
+	tracer := NewTracer(TracerConfig{ServiceName: "test", Sampler: NewParentOrRatioSampler(1.0)})
+	ctx := ExtractMap(context.Background(), map[string]string{
+		"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+	})
+	if _, span := tracer.StartSpan(ctx, "child", SpanKindServer); span.IsRecording() {
+		t.Error("span with an unsampled parent should not be recorded")
+	}
+}
+
 // =============================================================================
 // SECTION 7: HTTP Middleware Tests
 // =============================================================================
@@ -705,6 +1593,31 @@ func TestObservabilityMiddleware_TracePropagation(t *testing.T) {
 	}
 }
 
+func TestObservabilityMiddleware_RequestBodySize(t *testing.T) {
+	middleware := NewObservabilityMiddleware("test-service")
+
+	var read []byte
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		read, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	body := bytes.Repeat([]byte("x"), 1024)
+	req := httptest.NewRequest("POST", "/api/push", bytes.NewReader(body))
+	middleware.Handler(handler).ServeHTTP(httptest.NewRecorder(), req)
+
+	if !bytes.Equal(read, body) {
+		t.Errorf("handler read %d bytes, want the full 1024 byte body", len(read))
+	}
+	sizes := middleware.metrics.RequestBodySize
+	if got := sizes.Count("POST", "/api/push"); got != 1 {
+		t.Fatalf("RequestBodySize count = %d, want 1", got)
+	}
+	if got := sizes.Sum("POST", "/api/push"); got != 1024 {
+		t.Errorf("RequestBodySize observed %v, want 1024", got)
+	}
+}
+
 func TestObservabilityMiddleware_ErrorHandling(t *testing.T) {
 	middleware := NewObservabilityMiddleware("test-service")
 
@@ -725,6 +1638,135 @@ func TestObservabilityMiddleware_ErrorHandling(t *testing.T) {
 	}
 }
 
+func TestObservabilityMiddleware_ErrorBodyCapture(t *testing.T) {
+	var buf bytes.Buffer
+	middleware := NewObservabilityMiddleware("test-service").
+		WithLogger(NewLogger("test-service", WithOutput(&buf))).
+		WithErrorBodyCapture(64)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error":"db down"}`))
+	})
+
+	req := httptest.NewRequest("GET", "/api/test", nil)
+	rec := httptest.NewRecorder()
+	middleware.Handler(handler).ServeHTTP(rec, req)
+
+	// The client still receives the full body
+	if rec.Body.String() != `{"error":"db down"}` {
+		t.Errorf("Response body = %q", rec.Body.String())
+	}
+
+	var completion LogEntry
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry LogEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Failed to parse log entry: %v", err)
+		}
+		if entry.Message == "request completed with error" {
+			completion = entry
+		}
+	}
+
+	if got := completion.Fields["response_body_snippet"]; got != `{"error":"db down"}` {
+		t.Errorf("response_body_snippet = %v, want %q", got, `{"error":"db down"}`)
+	}
+}
+
+func TestObservabilityMiddleware_AccessLog(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	send := func(middleware *ObservabilityMiddleware) {
+		for _, path := range []string{"/api/query?q=up", "/missing", "/ready"} {
+			req := httptest.NewRequest("GET", path, nil)
+			req.RemoteAddr = "192.0.2.1:54321"
+			req.Header.Set("User-Agent", `curl/8.0 "test"`)
+			middleware.Handler(handler).ServeHTTP(httptest.NewRecorder(), req)
+		}
+	}
+	newMiddleware := func(w io.Writer, format AccessLogFormat) *ObservabilityMiddleware {
+		return NewObservabilityMiddleware("test-service").
+			WithLogger(NewLogger("test-service", WithOutput(io.Discard))).
+			WithAccessLog(w, format)
+	}
+
+	var jsonLog bytes.Buffer
+	send(newMiddleware(&jsonLog, AccessLogJSON))
+	lines := strings.Split(strings.TrimSpace(jsonLog.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d JSON access log lines, want 3", len(lines))
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatalf("invalid JSON access log line %q: %v", lines[1], err)
+	}
+	for _, key := range []string{"timestamp", "method", "path", "status", "bytes", "duration_ms", "remote_addr", "user_agent"} {
+		if _, ok := entry[key]; !ok {
+			t.Errorf("JSON access log line missing %q: %s", key, lines[1])
+		}
+	}
+	if entry["path"] != "/missing" || entry["status"] != float64(404) {
+		t.Errorf("JSON access log line = %s, want /missing with status 404", lines[1])
+	}
+
+	var combinedLog bytes.Buffer
+	send(newMiddleware(&combinedLog, AccessLogCombined))
+	combined := regexp.MustCompile(`^(\S+) (\S+) (\S+) \[([^\]]+)\] "(\S+) (\S+) (\S+)" (\d{3}) (\d+|-) "((?:[^"\\]|\\.)*)" "((?:[^"\\]|\\.)*)"$`)
+	lines = strings.Split(strings.TrimSpace(combinedLog.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d combined access log lines, want 3", len(lines))
+	}
+	for _, line := range lines {
+		if !combined.MatchString(line) {
+			t.Errorf("line does not match the Apache combined format: %s", line)
+		}
+	}
+	if m := combined.FindStringSubmatch(lines[0]); m != nil {
+		if m[1] != "192.0.2.1" || m[6] != "/api/query?q=up" || m[8] != "200" || m[9] != "2" {
+			t.Errorf("combined line fields = %q", m[1:])
+		}
+	}
+}
+
+func TestResponseWriter_CaptureBodyLimit(t *testing.T) {
+	wrapped := NewResponseWriter(httptest.NewRecorder())
+	wrapped.CaptureBody(5)
+
+	wrapped.WriteHeader(http.StatusBadGateway)
+	wrapped.Write([]byte("abc"))
+	wrapped.Write([]byte("defgh"))
+
+	if got := string(wrapped.CapturedBody()); got != "abcde" {
+		t.Errorf("CapturedBody() = %q, want %q", got, "abcde")
+	}
+	if wrapped.BytesWritten() != 8 {
+		t.Errorf("BytesWritten() = %d, want 8", wrapped.BytesWritten())
+	}
+}
+
+func TestResponseWriter_CaptureBodyOnlyOnServerError(t *testing.T) {
+	ok := NewResponseWriter(httptest.NewRecorder())
+	ok.CaptureBody(64)
+	ok.Write([]byte(`{"data":[]}`))
+	if got := ok.CapturedBody(); len(got) != 0 {
+		t.Errorf("CapturedBody() = %q for a 200, want empty", got)
+	}
+
+	notFound := NewResponseWriter(httptest.NewRecorder())
+	notFound.CaptureBody(64)
+	notFound.WriteHeader(http.StatusNotFound)
+	notFound.Write([]byte("no such dashboard"))
+	if got := notFound.CapturedBody(); len(got) != 0 {
+		t.Errorf("CapturedBody() = %q for a 404, want empty", got)
+	}
+}
+
 // =============================================================================
 // SECTION 8: Error Handling Tests
 // =============================================================================
@@ -766,6 +1808,38 @@ func TestWrapError_NilError(t *testing.T) {
 	}
 }
 
+func TestObservabilityError_HTTPStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		operation string
+		err       error
+		want      int
+	}{
+		{"not found", "GetUser", errors.New("user not found"), http.StatusNotFound},
+		{"unauthorized", "GetUser", errors.New("unauthorized: missing token"), http.StatusUnauthorized},
+		{"forbidden", "DeleteUser", errors.New("permission denied"), http.StatusForbidden},
+		{"conflict", "CreateUser", errors.New("user already exists"), http.StatusConflict},
+		{"rate limited", "Query", errors.New("rate limit exceeded"), http.StatusTooManyRequests},
+		{"invalid argument", "Query", errors.New("invalid argument: unknown function rate2"), http.StatusBadRequest},
+		{"nil pointer", "Query", errors.New("runtime error: invalid memory address or nil pointer dereference"), http.StatusInternalServerError},
+		{"timeout", "Query", errors.New("request timed out"), http.StatusGatewayTimeout},
+		{"deadline", "Query", fmt.Errorf("querying: %w", context.DeadlineExceeded), http.StatusGatewayTimeout},
+		{"unavailable", "Query", errors.New("ingester unavailable"), http.StatusServiceUnavailable},
+		{"operation ignored", "FindUserOrNotFound", errors.New("miss"), http.StatusInternalServerError},
+		{"status error wins", "GetUser", fmt.Errorf("wrapped: %w", Forbidden("no access", errors.New("user not found"))), http.StatusForbidden},
+		{"fallback", "GetUser", errors.New("disk full"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obsErr := &ObservabilityError{Operation: tt.operation, Err: tt.err}
+			if got := obsErr.HTTPStatus(); got != tt.want {
+				t.Errorf("HTTPStatus() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestErrorHandler_Handle(t *testing.T) {
 	var buf bytes.Buffer
 	logger := NewLogger("test-service", WithOutput(&buf))
@@ -808,6 +1882,46 @@ func TestErrorHandler_HandleNilError(t *testing.T) {
 	}
 }
 
+func TestErrorHandler_HandleWithSpan(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger("test-service", WithOutput(&buf))
+	handler := NewErrorHandler(logger, "test")
+	tracer := NewTracer(TracerConfig{
+		ServiceName: "test-service",
+		Sampler:     &AlwaysSampler{},
+	})
+
+	ctx, span := tracer.StartSpan(context.Background(), "load-user", SpanKindInternal)
+	if SpanFromContext(ctx) != span {
+		t.Fatal("SpanFromContext should return the span started by StartSpan")
+	}
+
+	if !handler.HandleWithSpan(ctx, errors.New("connection reset"), "LoadUser", nil) {
+		t.Fatal("HandleWithSpan should return true for non-nil error")
+	}
+
+	if span.Status != SpanStatusError {
+		t.Errorf("Span status = %v, want SpanStatusError", span.Status)
+	}
+	if !strings.Contains(span.StatusMsg, "LoadUser") {
+		t.Errorf("Span status message = %q, want it to mention the operation", span.StatusMsg)
+	}
+	if len(span.Events) != 1 || span.Events[0].Name != "exception" {
+		t.Errorf("Expected one exception event, got %+v", span.Events)
+	}
+	if buf.Len() == 0 {
+		t.Error("Error should still be logged")
+	}
+
+	// Without a span in context it behaves like Handle
+	if !handler.HandleWithSpan(context.Background(), errors.New("boom"), "LoadUser", nil) {
+		t.Error("HandleWithSpan should handle errors without an active span")
+	}
+	if handler.HandleWithSpan(ctx, nil, "LoadUser", nil) {
+		t.Error("HandleWithSpan should return false for nil error")
+	}
+}
+
 // =============================================================================
 // SECTION 9: Health Check Tests
 // =============================================================================
@@ -815,7 +1929,7 @@ func TestErrorHandler_HandleNilError(t *testing.T) {
 func TestHealthChecker_Check(t *testing.T) {
 	var buf bytes.Buffer
 	logger := NewLogger("test-service", WithOutput(&buf))
-	checker := NewHealthChecker(logger, "test")
+	checker := NewHealthChecker(logger, HealthCheckerConfig{Namespace: "test"})
 
 	// Register a healthy check
 	checker.Register("database", func(ctx context.Context) HealthCheck {
@@ -858,6 +1972,127 @@ func TestHealthChecker_Check(t *testing.T) {
 	}
 }
 
+func TestHealthChecker_Metrics(t *testing.T) {
+	registry := NewMetricRegistry()
+	checker := NewHealthChecker(NewLogger("test-service", WithOutput(io.Discard)), HealthCheckerConfig{
+		Namespace: "test",
+		Registry:  registry,
+	})
+	checker.Register("database", func(ctx context.Context) HealthCheck {
+		return HealthCheck{Status: HealthStatusHealthy}
+	})
+	checker.Register("cache", func(ctx context.Context) HealthCheck {
+		return HealthCheck{Status: HealthStatusDegraded}
+	})
+
+	checker.Check(context.Background())
+
+	var sb strings.Builder
+	if err := registry.WritePrometheus(&sb); err != nil {
+		t.Fatalf("WritePrometheus: %v", err)
+	}
+	out := sb.String()
+	for _, want := range []string{
+		"# TYPE test_health_status gauge",
+		`test_health_status{component="database"} 1`,
+		`test_health_status{component="cache"} 0.5`,
+		"# TYPE test_health_check_duration_seconds histogram",
+		`test_health_check_duration_seconds_count{component="database"} 1`,
+		`test_health_check_duration_seconds_bucket{component="cache",le="+Inf"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestHealthChecker_OpenAPISchema(t *testing.T) {
+	checker := NewHealthChecker(NewLogger("test-service", WithOutput(io.Discard)), HealthCheckerConfig{Namespace: "test"})
+
+	data, err := json.Marshal(checker.OpenAPISchema())
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var schema struct {
+		Type  string `json:"type"`
+		Items struct {
+			Type       string   `json:"type"`
+			Required   []string `json:"required"`
+			Properties map[string]struct {
+				Type   string   `json:"type"`
+				Format string   `json:"format"`
+				Enum   []string `json:"enum"`
+			} `json:"properties"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	if schema.Type != "array" || schema.Items.Type != "object" {
+		t.Errorf("schema is %s of %s, want array of object", schema.Type, schema.Items.Type)
+	}
+	if got := strings.Join(schema.Items.Required, ","); got != "name,status,duration_ms,timestamp" {
+		t.Errorf("required = %s", got)
+	}
+	wantTypes := map[string]string{
+		"name": "string", "status": "string", "message": "string",
+		"duration_ms": "integer", "timestamp": "string",
+	}
+	for field, want := range wantTypes {
+		if got := schema.Items.Properties[field].Type; got != want {
+			t.Errorf("%s type = %q, want %q", field, got, want)
+		}
+	}
+	if got := schema.Items.Properties["timestamp"].Format; got != "date-time" {
+		t.Errorf("timestamp format = %q, want date-time", got)
+	}
+	if got := strings.Join(schema.Items.Properties["status"].Enum, ","); got != "healthy,degraded,unhealthy" {
+		t.Errorf("status enum = %s", got)
+	}
+}
+
+func TestHealthChecker_HTTPHandler(t *testing.T) {
+	checker := NewHealthChecker(NewLogger("test-service", WithOutput(io.Discard)), HealthCheckerConfig{Namespace: "test"})
+	checker.Register("database", func(ctx context.Context) HealthCheck {
+		time.Sleep(5 * time.Millisecond)
+		return HealthCheck{Status: HealthStatusHealthy}
+	})
+	checker.Register("cache", func(ctx context.Context) HealthCheck {
+		return HealthCheck{Status: HealthStatusUnhealthy, Message: "Connection refused"}
+	})
+
+	rec := httptest.NewRecorder()
+	checker.HTTPHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/health", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503 with an unhealthy check", rec.Code)
+	}
+	var body []map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(body) != 2 || body[0]["name"] != "cache" || body[1]["name"] != "database" {
+		t.Fatalf("body = %v, want cache and database sorted by name", body)
+	}
+	// duration_ms is whole milliseconds, not nanoseconds
+	if ms := body[1]["duration_ms"].(float64); ms < 5 || ms > 1000 {
+		t.Errorf("duration_ms = %v, want about 5", ms)
+	}
+	if _, err := time.Parse(time.RFC3339, body[1]["timestamp"].(string)); err != nil {
+		t.Errorf("timestamp is not RFC 3339: %v", err)
+	}
+
+	// HealthCheck round-trips through its JSON form
+	var check HealthCheck
+	if err := json.Unmarshal([]byte(`{"name":"db","status":"healthy","duration_ms":12,"timestamp":"2024-05-01T12:00:00Z"}`), &check); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if check.Duration != 12*time.Millisecond {
+		t.Errorf("Duration = %v, want 12ms", check.Duration)
+	}
+}
+
 func TestHealthChecker_OverallStatus(t *testing.T) {
 	var buf bytes.Buffer
 	logger := NewLogger("test-service", WithOutput(&buf))
@@ -891,7 +2126,7 @@ func TestHealthChecker_OverallStatus(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			checker := NewHealthChecker(logger, "test")
+			checker := NewHealthChecker(logger, HealthCheckerConfig{Namespace: "test"})
 
 			for i, status := range tt.statuses {
 				s := status // capture for closure
@@ -908,6 +2143,131 @@ func TestHealthChecker_OverallStatus(t *testing.T) {
 	}
 }
 
+func TestHealthChecker_History(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger("test-service", WithOutput(&buf))
+	checker := NewHealthChecker(logger, HealthCheckerConfig{Namespace: "test", HealthCheckHistorySize: 10})
+
+	run := 0
+	checker.Register("db", func(ctx context.Context) HealthCheck {
+		run++
+		return HealthCheck{
+			Status:  HealthStatusHealthy,
+			Message: fmt.Sprintf("run %d", run),
+		}
+	})
+
+	if checker.History("db") != nil {
+		t.Error("History should be nil before the check runs")
+	}
+
+	for i := 0; i < 15; i++ {
+		checker.Check(context.Background())
+	}
+
+	history := checker.History("db")
+	if len(history) != 10 {
+		t.Fatalf("len(History(db)) = %d, want 10", len(history))
+	}
+	// The oldest 5 runs were overwritten
+	for i, hc := range history {
+		if want := fmt.Sprintf("run %d", i+6); hc.Message != want {
+			t.Errorf("history[%d].Message = %q, want %q", i, hc.Message, want)
+		}
+		if i > 0 && hc.Timestamp.Before(history[i-1].Timestamp) {
+			t.Errorf("history[%d] is older than history[%d]", i, i-1)
+		}
+	}
+
+	if checker.History("unknown") != nil {
+		t.Error("History of an unknown check should be nil")
+	}
+}
+
+func TestHealthChecker_HistorySizeConfig(t *testing.T) {
+	logger := NewLogger("test-service", WithOutput(io.Discard))
+	healthy := func(ctx context.Context) HealthCheck {
+		return HealthCheck{Status: HealthStatusHealthy}
+	}
+
+	tests := []struct {
+		name    string
+		size    int
+		wantCap int
+		wantLen int
+	}{
+		{"zero uses the default", 0, DefaultHealthCheckHistorySize, DefaultHealthCheckHistorySize},
+		{"positive size", 3, 3, 3},
+		{"negative disables history", -1, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewHealthChecker(logger, HealthCheckerConfig{Namespace: "test", HealthCheckHistorySize: tt.size})
+			checker.Register("db", healthy)
+			for i := 0; i < DefaultHealthCheckHistorySize+5; i++ {
+				checker.Check(context.Background())
+			}
+
+			if got := checker.HealthCheckHistorySize(); got != tt.wantCap {
+				t.Errorf("HealthCheckHistorySize() = %d, want %d", got, tt.wantCap)
+			}
+			if got := len(checker.History("db")); got != tt.wantLen {
+				t.Errorf("len(History(db)) = %d, want %d", got, tt.wantLen)
+			}
+		})
+	}
+}
+
+// fakeBreaker opens after threshold consecutive failures, like the
+// CircuitBreaker in go-distributed-systems.
+type fakeBreaker struct {
+	threshold int
+	failures  int
+	open      bool
+}
+
+func (b *fakeBreaker) RecordFailure() {
+	b.failures++
+	if b.failures >= b.threshold {
+		b.open = true
+	}
+}
+
+func (b *fakeBreaker) RecordSuccess() {
+	if !b.open {
+		b.failures = 0
+	}
+}
+
+func TestHealthChecker_WithCircuitBreaker(t *testing.T) {
+	checker := NewHealthChecker(NewLogger("test-service", WithOutput(io.Discard)), HealthCheckerConfig{Namespace: "test"})
+	status := HealthStatusHealthy
+	checker.Register("billing-api", func(ctx context.Context) HealthCheck {
+		return HealthCheck{Status: status}
+	})
+	breaker := &fakeBreaker{threshold: 3}
+	checker.WithCircuitBreaker("billing-api", breaker)
+
+	checker.Check(context.Background())
+	status = HealthStatusUnhealthy
+	for cycle := 1; cycle <= 3; cycle++ {
+		if breaker.open {
+			t.Fatalf("breaker opened after %d unhealthy cycles, want 3", cycle-1)
+		}
+		checker.Check(context.Background())
+	}
+	if !breaker.open {
+		t.Errorf("breaker not open after 3 unhealthy cycles (failures=%d)", breaker.failures)
+	}
+
+	// Degraded results neither fail nor heal the breaker
+	status = HealthStatusDegraded
+	checker.Check(context.Background())
+	if breaker.failures != 3 {
+		t.Errorf("failures = %d after a degraded check, want 3", breaker.failures)
+	}
+}
+
 // =============================================================================
 // SECTION 10: Example Service Tests
 // =============================================================================
@@ -0,0 +1,456 @@
+// Package observability provides a Jaeger agent span exporter.
+//
+// This file demonstrates:
+// - Encoding spans in the Jaeger Thrift model with the Thrift compact protocol
+// - Sending span batches to a Jaeger agent as UDP datagrams
+// - Splitting batches so each datagram stays under the agent's packet size
+//
+// Older deployments run a jaeger-agent sidecar listening on UDP port 6831.
+// Grafana Agent and the OpenTelemetry Collector can receive the same
+// protocol, so services instrumented this way can still ship to Tempo.
+package observability
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// jaegerMaxPacketSize is the default datagram limit. The protocol allows up
+// to 65507 bytes (the UDP maximum), but jaeger-agent reads into a 65000
+// byte buffer by default and truncates anything larger.
+const jaegerMaxPacketSize = 65000
+
+// jaegerUnknownService names the process of spans without a service.name
+// attribute; Jaeger requires a service name.
+const jaegerUnknownService = "unknown_service"
+
+// JaegerUDPExporter sends spans to a Jaeger agent over UDP using the
+// emitBatch call of the agent's Thrift compact protocol. It implements
+// SpanExporter.
+//
+// Spans are grouped into one batch per service.name attribute. A batch that
+// would exceed MaxPacketSize is split across several datagrams; a single
+// span larger than MaxPacketSize cannot be sent and is dropped with an error.
+type JaegerUDPExporter struct {
+	// MaxPacketSize is the largest datagram sent, in bytes (default 65000,
+	// at most 65507). Set it before the first Export.
+	MaxPacketSize int
+
+	addr string
+	conn net.Conn
+	seq  int32 // Thrift message sequence ID
+	mu   sync.Mutex
+}
+
+// NewJaegerUDPExporter creates an exporter that sends to a Jaeger agent at
+// agentHost:agentPort (usually port 6831).
+func NewJaegerUDPExporter(agentHost string, agentPort int) (*JaegerUDPExporter, error) {
+	addr := net.JoinHostPort(agentHost, strconv.Itoa(agentPort))
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("jaeger: dialing agent %s: %w", addr, err)
+	}
+	return &JaegerUDPExporter{
+		MaxPacketSize: jaegerMaxPacketSize,
+		addr:          addr,
+		conn:          conn,
+	}, nil
+}
+
+// Export encodes spans and sends them to the agent. Spans that cannot be
+// sent are reported in the returned error; the others are still sent.
+func (e *JaegerUDPExporter) Export(spans []*Span) error {
+	// Group encoded spans by service, in a stable order
+	byService := make(map[string][][]byte)
+	for _, span := range spans {
+		service, encoded := encodeJaegerSpan(span)
+		byService[service] = append(byService[service], encoded)
+	}
+	services := make([]string, 0, len(byService))
+	for service := range byService {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	maxSize := e.MaxPacketSize
+	if maxSize <= 0 || maxSize > 65507 {
+		maxSize = jaegerMaxPacketSize
+	}
+
+	var errs []error
+	for _, service := range services {
+		if err := e.sendBatches(service, byService[service], maxSize); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sendBatches packs one service's encoded spans into as few datagrams as
+// fit under maxSize. Caller must hold e.mu.
+func (e *JaegerUDPExporter) sendBatches(service string, spans [][]byte, maxSize int) error {
+	// Everything but the span list is the same for each datagram; measure
+	// it once with the sequence ID that makes it largest
+	overhead := len(e.batchHeader(service, math.MaxInt32)) + jaegerBatchTrailer
+
+	var errs []error
+	var batch [][]byte
+	size := overhead
+	for _, span := range spans {
+		if overhead+thriftListHeaderSize(1)+len(span) > maxSize {
+			errs = append(errs, fmt.Errorf("jaeger: dropping %d byte span from %s: larger than the %d byte packet limit",
+				len(span), service, maxSize))
+			continue
+		}
+		if len(batch) > 0 && size+thriftListHeaderSize(len(batch)+1)+len(span) > maxSize {
+			if err := e.write(service, batch); err != nil {
+				errs = append(errs, err)
+			}
+			batch, size = nil, overhead
+		}
+		batch = append(batch, span)
+		size += len(span)
+	}
+	if len(batch) > 0 {
+		if err := e.write(service, batch); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// jaegerBatchTrailer is the size of what follows the span list: the stop
+// fields of the Batch struct and of the emitBatch arguments.
+const jaegerBatchTrailer = 2
+
+// batchHeader encodes an emitBatch message up to the span list:
+//
+//	emitBatch(1: Batch{1: Process{1: serviceName}, 2: list<Span>})
+func (e *JaegerUDPExporter) batchHeader(service string, seq int32) []byte {
+	var w thriftCompactWriter
+	w.messageBegin("emitBatch", thriftMessageOneway, seq)
+	w.structBegin() // emitBatch arguments
+	w.fieldBegin(thriftTypeStruct, 1)
+	w.structBegin() // Batch
+	w.fieldBegin(thriftTypeStruct, 1)
+	w.structBegin() // Process
+	w.fieldBegin(thriftTypeBinary, 1)
+	w.writeString(service)
+	w.structEnd()
+	w.fieldBegin(thriftTypeList, 2)
+	return w.buf
+}
+
+// write sends spans as one datagram. Caller must hold e.mu.
+func (e *JaegerUDPExporter) write(service string, spans [][]byte) error {
+	e.seq++
+	packet := e.batchHeader(service, e.seq)
+	packet = appendThriftListHeader(packet, thriftTypeStruct, len(spans))
+	for _, span := range spans {
+		packet = append(packet, span...)
+	}
+	packet = append(packet, thriftTypeStop, thriftTypeStop) // Batch, arguments
+
+	if _, err := e.conn.Write(packet); err != nil {
+		return fmt.Errorf("jaeger: failed to send to %s: %w", e.addr, err)
+	}
+	return nil
+}
+
+// Close closes the UDP socket.
+func (e *JaegerUDPExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.conn.Close()
+}
+
+// Jaeger Thrift tag value types.
+const (
+	jaegerTagString = 0
+	jaegerTagDouble = 1
+	jaegerTagBool   = 2
+	jaegerTagLong   = 3
+)
+
+// jaegerRefFollowsFrom is the SpanRefType used for span links.
+const jaegerRefFollowsFrom = 1
+
+// encodeJaegerSpan encodes span as a Jaeger Thrift Span struct and returns
+// it with the span's service name. Attributes become tags, events become
+// logs, and links become FOLLOWS_FROM references.
+func encodeJaegerSpan(span *Span) (string, []byte) {
+	span.mu.Lock()
+	defer span.mu.Unlock()
+
+	service, _ := span.Attributes["service.name"].(string)
+	if service == "" {
+		service = jaegerUnknownService
+	}
+
+	traceHigh, traceLow := parseJaegerTraceID(span.TraceID)
+	var duration int64
+	if !span.EndTime.IsZero() {
+		duration = span.EndTime.Sub(span.StartTime).Microseconds()
+	}
+
+	var w thriftCompactWriter
+	w.structBegin()
+	w.fieldBegin(thriftTypeI64, 1)
+	w.writeI64(traceLow)
+	w.fieldBegin(thriftTypeI64, 2)
+	w.writeI64(traceHigh)
+	w.fieldBegin(thriftTypeI64, 3)
+	w.writeI64(parseJaegerID(span.SpanID))
+	w.fieldBegin(thriftTypeI64, 4)
+	w.writeI64(parseJaegerID(span.ParentSpanID))
+	w.fieldBegin(thriftTypeBinary, 5)
+	w.writeString(span.Name)
+
+	if len(span.Links) > 0 {
+		w.fieldBegin(thriftTypeList, 6)
+		w.listBegin(thriftTypeStruct, len(span.Links))
+		for _, link := range span.Links {
+			high, low := parseJaegerTraceID(link.TraceID)
+			w.structBegin()
+			w.fieldBegin(thriftTypeI32, 1)
+			w.writeI32(jaegerRefFollowsFrom)
+			w.fieldBegin(thriftTypeI64, 2)
+			w.writeI64(low)
+			w.fieldBegin(thriftTypeI64, 3)
+			w.writeI64(high)
+			w.fieldBegin(thriftTypeI64, 4)
+			w.writeI64(parseJaegerID(link.SpanID))
+			w.structEnd()
+		}
+	}
+
+	w.fieldBegin(thriftTypeI32, 7)
+	w.writeI32(1) // Sampled
+	w.fieldBegin(thriftTypeI64, 8)
+	w.writeI64(span.StartTime.UnixMicro())
+	w.fieldBegin(thriftTypeI64, 9)
+	w.writeI64(duration)
+
+	tags := make(map[string]interface{}, len(span.Attributes)+2)
+	for k, v := range span.Attributes {
+		tags[k] = v
+	}
+	if span.Kind != SpanKindInternal {
+		tags["span.kind"] = span.Kind.String()
+	}
+	if span.Status == SpanStatusError {
+		tags["error"] = true
+		if span.StatusMsg != "" {
+			tags["otel.status_description"] = span.StatusMsg
+		}
+	}
+	if len(tags) > 0 {
+		w.fieldBegin(thriftTypeList, 10)
+		writeJaegerTags(&w, tags)
+	}
+
+	if len(span.Events) > 0 {
+		w.fieldBegin(thriftTypeList, 11)
+		w.listBegin(thriftTypeStruct, len(span.Events))
+		for _, event := range span.Events {
+			fields := make(map[string]interface{}, len(event.Attributes)+1)
+			for k, v := range event.Attributes {
+				fields[k] = v
+			}
+			fields["event"] = event.Name
+
+			w.structBegin()
+			w.fieldBegin(thriftTypeI64, 1)
+			w.writeI64(event.Timestamp.UnixMicro())
+			w.fieldBegin(thriftTypeList, 2)
+			writeJaegerTags(&w, fields)
+			w.structEnd()
+		}
+	}
+
+	w.structEnd()
+	return service, w.buf
+}
+
+// writeJaegerTags writes tags as a list of Tag structs, sorted by key.
+func writeJaegerTags(w *thriftCompactWriter, tags map[string]interface{}) {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	w.listBegin(thriftTypeStruct, len(keys))
+	for _, k := range keys {
+		w.structBegin()
+		w.fieldBegin(thriftTypeBinary, 1)
+		w.writeString(k)
+		switch v := tags[k].(type) {
+		case bool:
+			w.fieldBegin(thriftTypeI32, 2)
+			w.writeI32(jaegerTagBool)
+			w.fieldBool(5, v)
+		case int:
+			writeJaegerLong(w, int64(v))
+		case int32:
+			writeJaegerLong(w, int64(v))
+		case int64:
+			writeJaegerLong(w, v)
+		case float32:
+			writeJaegerDouble(w, float64(v))
+		case float64:
+			writeJaegerDouble(w, v)
+		default:
+			w.fieldBegin(thriftTypeI32, 2)
+			w.writeI32(jaegerTagString)
+			w.fieldBegin(thriftTypeBinary, 3)
+			w.writeString(fmt.Sprint(v))
+		}
+		w.structEnd()
+	}
+}
+
+func writeJaegerLong(w *thriftCompactWriter, v int64) {
+	w.fieldBegin(thriftTypeI32, 2)
+	w.writeI32(jaegerTagLong)
+	w.fieldBegin(thriftTypeI64, 6)
+	w.writeI64(v)
+}
+
+func writeJaegerDouble(w *thriftCompactWriter, v float64) {
+	w.fieldBegin(thriftTypeI32, 2)
+	w.writeI32(jaegerTagDouble)
+	w.fieldBegin(thriftTypeDouble, 4)
+	w.writeDouble(v)
+}
+
+// parseJaegerTraceID splits a 32 (or 16) hex digit trace ID into its high
+// and low 64 bits. Invalid IDs parse as zero.
+func parseJaegerTraceID(id string) (high, low int64) {
+	if len(id) > 16 {
+		return parseJaegerID(id[:len(id)-16]), parseJaegerID(id[len(id)-16:])
+	}
+	return 0, parseJaegerID(id)
+}
+
+// parseJaegerID parses a hex span ID. Invalid or empty IDs parse as zero,
+// which Jaeger treats as "no parent".
+func parseJaegerID(id string) int64 {
+	v, err := strconv.ParseUint(id, 16, 64)
+	if err != nil {
+		return 0
+	}
+	return int64(v)
+}
+
+// Thrift compact protocol type IDs.
+const (
+	thriftTypeStop      = 0x00
+	thriftTypeBoolTrue  = 0x01
+	thriftTypeBoolFalse = 0x02
+	thriftTypeI32       = 0x05
+	thriftTypeI64       = 0x06
+	thriftTypeDouble    = 0x07
+	thriftTypeBinary    = 0x08
+	thriftTypeList      = 0x09
+	thriftTypeStruct    = 0x0c
+)
+
+// Thrift compact protocol message framing.
+const (
+	thriftCompactProtocolID = 0x82
+	thriftCompactVersion    = 1
+	thriftMessageOneway     = 4
+)
+
+// thriftCompactWriter appends values in the Thrift compact protocol. It
+// only implements the types the Jaeger model uses.
+type thriftCompactWriter struct {
+	buf []byte
+	// lastField holds the last field ID written in each open struct, since
+	// field headers store the delta from the previous field
+	lastField []int16
+}
+
+func (w *thriftCompactWriter) messageBegin(name string, messageType byte, seq int32) {
+	w.buf = append(w.buf, thriftCompactProtocolID, messageType<<5|thriftCompactVersion)
+	w.buf = binary.AppendUvarint(w.buf, uint64(uint32(seq)))
+	w.writeString(name)
+}
+
+func (w *thriftCompactWriter) structBegin() {
+	w.lastField = append(w.lastField, 0)
+}
+
+func (w *thriftCompactWriter) structEnd() {
+	w.buf = append(w.buf, thriftTypeStop)
+	w.lastField = w.lastField[:len(w.lastField)-1]
+}
+
+// fieldBegin writes a field header, using the short form (delta and type
+// in one byte) when the field ID is at most 15 above the previous one.
+func (w *thriftCompactWriter) fieldBegin(fieldType byte, id int16) {
+	last := &w.lastField[len(w.lastField)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		w.buf = append(w.buf, byte(delta)<<4|fieldType)
+	} else {
+		w.buf = append(w.buf, fieldType)
+		w.buf = binary.AppendVarint(w.buf, int64(id))
+	}
+	*last = id
+}
+
+// fieldBool writes a boolean field; the value is encoded in the header.
+func (w *thriftCompactWriter) fieldBool(id int16, v bool) {
+	if v {
+		w.fieldBegin(thriftTypeBoolTrue, id)
+	} else {
+		w.fieldBegin(thriftTypeBoolFalse, id)
+	}
+}
+
+func (w *thriftCompactWriter) listBegin(elemType byte, size int) {
+	w.buf = appendThriftListHeader(w.buf, elemType, size)
+}
+
+// writeI32 and writeI64 use zigzag varints.
+func (w *thriftCompactWriter) writeI32(v int32) {
+	w.buf = binary.AppendVarint(w.buf, int64(v))
+}
+
+func (w *thriftCompactWriter) writeI64(v int64) {
+	w.buf = binary.AppendVarint(w.buf, v)
+}
+
+func (w *thriftCompactWriter) writeDouble(v float64) {
+	w.buf = binary.LittleEndian.AppendUint64(w.buf, math.Float64bits(v))
+}
+
+func (w *thriftCompactWriter) writeString(s string) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+// appendThriftListHeader appends a list header: size and element type in
+// one byte for lists shorter than 15, otherwise followed by a varint size.
+func appendThriftListHeader(buf []byte, elemType byte, size int) []byte {
+	if size < 15 {
+		return append(buf, byte(size)<<4|elemType)
+	}
+	buf = append(buf, 0xf0|elemType)
+	return binary.AppendUvarint(buf, uint64(size))
+}
+
+// thriftListHeaderSize returns the encoded size of a list header.
+func thriftListHeaderSize(size int) int {
+	return len(appendThriftListHeader(nil, 0, size))
+}
@@ -0,0 +1,329 @@
+// Package observability provides tests for the Jaeger agent exporter.
+package observability
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listenUDP starts a UDP listener standing in for the Jaeger agent.
+func listenUDP(t *testing.T) (*net.UDPConn, *net.UDPAddr) {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, conn.LocalAddr().(*net.UDPAddr)
+}
+
+// readDatagrams reads datagrams until none arrive for 100ms.
+func readDatagrams(t *testing.T, conn *net.UDPConn) [][]byte {
+	t.Helper()
+	var packets [][]byte
+	buf := make([]byte, 65535)
+	for {
+		conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		n, err := conn.Read(buf)
+		if err != nil {
+			return packets
+		}
+		packets = append(packets, append([]byte(nil), buf[:n]...))
+	}
+}
+
+// decodeEmitBatch decodes an emitBatch datagram and returns the Batch
+// struct, with struct fields keyed by field ID.
+func decodeEmitBatch(t *testing.T, packet []byte) map[int16]interface{} {
+	t.Helper()
+	r := &thriftCompactReader{buf: packet}
+	name, msgType := r.messageBegin()
+	if name != "emitBatch" || msgType != thriftMessageOneway {
+		t.Fatalf("message = %q type %d, want oneway emitBatch", name, msgType)
+	}
+	args := r.readStruct()
+	if r.err != nil {
+		t.Fatalf("decoding: %v", r.err)
+	}
+	if len(r.buf) != 0 {
+		t.Fatalf("%d trailing bytes", len(r.buf))
+	}
+	batch, ok := args[1].(map[int16]interface{})
+	if !ok {
+		t.Fatalf("emitBatch argument 1 = %#v, want Batch struct", args[1])
+	}
+	return batch
+}
+
+// thriftCompactReader is a minimal generic Thrift compact decoder: structs
+// decode to map[int16]interface{}, lists to []interface{}, strings to
+// string, integers to int64 and booleans to bool.
+type thriftCompactReader struct {
+	buf []byte
+	err error
+}
+
+func (r *thriftCompactReader) byte() byte {
+	if len(r.buf) == 0 {
+		r.err = fmt.Errorf("unexpected end of data")
+		return 0
+	}
+	b := r.buf[0]
+	r.buf = r.buf[1:]
+	return b
+}
+
+func (r *thriftCompactReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		r.err = fmt.Errorf("bad varint")
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *thriftCompactReader) varint() int64 {
+	v, n := binary.Varint(r.buf)
+	if n <= 0 {
+		r.err = fmt.Errorf("bad varint")
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *thriftCompactReader) string() string {
+	n := int(r.uvarint())
+	if n > len(r.buf) {
+		r.err = fmt.Errorf("string longer than data")
+		return ""
+	}
+	s := string(r.buf[:n])
+	r.buf = r.buf[n:]
+	return s
+}
+
+func (r *thriftCompactReader) messageBegin() (string, byte) {
+	if r.byte() != thriftCompactProtocolID {
+		r.err = fmt.Errorf("not a compact protocol message")
+	}
+	typeAndVersion := r.byte()
+	r.uvarint() // Sequence ID
+	return r.string(), typeAndVersion >> 5
+}
+
+func (r *thriftCompactReader) readStruct() map[int16]interface{} {
+	fields := make(map[int16]interface{})
+	var last int16
+	for r.err == nil {
+		header := r.byte()
+		if header == thriftTypeStop {
+			break
+		}
+		fieldType := header & 0x0f
+		if delta := int16(header >> 4); delta != 0 {
+			last += delta
+		} else {
+			last = int16(r.varint())
+		}
+		switch fieldType {
+		case thriftTypeBoolTrue:
+			fields[last] = true
+		case thriftTypeBoolFalse:
+			fields[last] = false
+		default:
+			fields[last] = r.readValue(fieldType)
+		}
+	}
+	return fields
+}
+
+func (r *thriftCompactReader) readValue(valueType byte) interface{} {
+	switch valueType {
+	case thriftTypeI32, thriftTypeI64:
+		return r.varint()
+	case thriftTypeDouble:
+		if len(r.buf) < 8 {
+			r.err = fmt.Errorf("short double")
+			return nil
+		}
+		v := math.Float64frombits(binary.LittleEndian.Uint64(r.buf))
+		r.buf = r.buf[8:]
+		return v
+	case thriftTypeBinary:
+		return r.string()
+	case thriftTypeList:
+		header := r.byte()
+		size := int(header >> 4)
+		if size == 15 {
+			size = int(r.uvarint())
+		}
+		list := make([]interface{}, 0, size)
+		for i := 0; i < size && r.err == nil; i++ {
+			list = append(list, r.readValue(header&0x0f))
+		}
+		return list
+	case thriftTypeStruct:
+		return r.readStruct()
+	default:
+		r.err = fmt.Errorf("unsupported type %d", valueType)
+		return nil
+	}
+}
+
+// jaegerTags converts a decoded list of Tag structs to a key -> value map.
+func jaegerTags(list interface{}) map[string]interface{} {
+	tags := make(map[string]interface{})
+	items, _ := list.([]interface{})
+	for _, item := range items {
+		tag := item.(map[int16]interface{})
+		key := tag[1].(string)
+		for _, field := range []int16{3, 4, 5, 6} {
+			if v, ok := tag[field]; ok {
+				tags[key] = v
+			}
+		}
+	}
+	return tags
+}
+
+func TestJaegerUDPExporter_Export(t *testing.T) {
+	conn, addr := listenUDP(t)
+	exporter, err := NewJaegerUDPExporter(addr.IP.String(), addr.Port)
+	if err != nil {
+		t.Fatalf("NewJaegerUDPExporter: %v", err)
+	}
+	defer exporter.Close()
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	span := &Span{
+		TraceID:      "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanID:       "00f067aa0ba902b7",
+		ParentSpanID: "b7ad6b7169203331",
+		Name:         "GET /api/v1/query",
+		Kind:         SpanKindServer,
+		StartTime:    start,
+		EndTime:      start.Add(150 * time.Millisecond),
+		Status:       SpanStatusError,
+		Attributes: map[string]interface{}{
+			"service.name":     "querier",
+			"http.status_code": 504,
+			"cache.hit_ratio":  0.5,
+		},
+		Events: []SpanEvent{{Name: "cache miss", Timestamp: start.Add(10 * time.Millisecond)}},
+		Links:  []SpanLink{{TraceID: "0af7651916cd43dd8448eb211c80319c", SpanID: "ffffffffffffffff"}},
+	}
+	if err := exporter.Export([]*Span{span}); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	packets := readDatagrams(t, conn)
+	if len(packets) != 1 {
+		t.Fatalf("received %d datagrams, want 1", len(packets))
+	}
+	batch := decodeEmitBatch(t, packets[0])
+
+	process := batch[1].(map[int16]interface{})
+	if process[1] != "querier" {
+		t.Errorf("serviceName = %v, want querier", process[1])
+	}
+	spans := batch[2].([]interface{})
+	if len(spans) != 1 {
+		t.Fatalf("batch has %d spans, want 1", len(spans))
+	}
+	got := spans[0].(map[int16]interface{})
+
+	if got[5] != "GET /api/v1/query" {
+		t.Errorf("operationName = %v, want GET /api/v1/query", got[5])
+	}
+	// IDs above 2^63 are sent as negative i64s
+	traceLow, parentID := uint64(0xa3ce929d0e0e4736), uint64(0xb7ad6b7169203331)
+	wantInts := map[int16]int64{
+		1: int64(traceLow),    // traceIdLow
+		2: 0x4bf92f3577b34da6, // traceIdHigh
+		3: 0x00f067aa0ba902b7, // spanId
+		4: int64(parentID),    // parentSpanId
+		7: 1,                  // flags: sampled
+		8: start.UnixMicro(),  // startTime
+		9: 150000,             // duration in µs
+	}
+	for field, want := range wantInts {
+		if got[field] != want {
+			t.Errorf("field %d = %v, want %d", field, got[field], want)
+		}
+	}
+
+	tags := jaegerTags(got[10])
+	if tags["http.status_code"] != int64(504) || tags["cache.hit_ratio"] != 0.5 ||
+		tags["span.kind"] != "server" || tags["error"] != true {
+		t.Errorf("tags = %v", tags)
+	}
+
+	logs := got[11].([]interface{})
+	if len(logs) != 1 || jaegerTags(logs[0].(map[int16]interface{})[2])["event"] != "cache miss" {
+		t.Errorf("logs = %v, want one cache miss event", logs)
+	}
+	refs := got[6].([]interface{})
+	if ref := refs[0].(map[int16]interface{}); ref[1] != int64(jaegerRefFollowsFrom) || ref[4] != int64(-1) {
+		t.Errorf("reference = %v, want FOLLOWS_FROM span ffffffffffffffff", ref)
+	}
+}
+
+func TestJaegerUDPExporter_SplitsLargeBatches(t *testing.T) {
+	conn, addr := listenUDP(t)
+	exporter, err := NewJaegerUDPExporter(addr.IP.String(), addr.Port)
+	if err != nil {
+		t.Fatalf("NewJaegerUDPExporter: %v", err)
+	}
+	defer exporter.Close()
+	exporter.MaxPacketSize = 512
+
+	var spans []*Span
+	for i := 0; i < 20; i++ {
+		spans = append(spans, &Span{
+			TraceID:    "4bf92f3577b34da6a3ce929d0e0e4736",
+			SpanID:     fmt.Sprintf("%016x", i+1),
+			Name:       fmt.Sprintf("chunk-%02d", i),
+			StartTime:  time.Now(),
+			EndTime:    time.Now(),
+			Attributes: map[string]interface{}{"service.name": "ingester", "padding": strings.Repeat("x", 40)},
+		})
+	}
+	// A span too large for any datagram is dropped with an error
+	spans = append(spans, &Span{
+		Name:       "huge",
+		Attributes: map[string]interface{}{"service.name": "ingester", "payload": strings.Repeat("x", 1024)},
+	})
+
+	if err := exporter.Export(spans); err == nil || !strings.Contains(err.Error(), "packet limit") {
+		t.Errorf("Export error = %v, want oversized span error", err)
+	}
+
+	packets := readDatagrams(t, conn)
+	if len(packets) < 2 {
+		t.Fatalf("received %d datagrams, want the batch split across several", len(packets))
+	}
+	var names []string
+	for _, packet := range packets {
+		if len(packet) > 512 {
+			t.Errorf("datagram of %d bytes exceeds MaxPacketSize", len(packet))
+		}
+		for _, s := range decodeEmitBatch(t, packet)[2].([]interface{}) {
+			names = append(names, s.(map[int16]interface{})[5].(string))
+		}
+	}
+	if len(names) != 20 {
+		t.Fatalf("received %d spans, want 20", len(names))
+	}
+	for i, name := range names {
+		if want := fmt.Sprintf("chunk-%02d", i); name != want {
+			t.Errorf("span %d = %s, want %s", i, name, want)
+		}
+	}
+}
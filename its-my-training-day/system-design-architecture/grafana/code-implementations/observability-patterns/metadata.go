@@ -0,0 +1,70 @@
+// Package observability provides context helpers that carry service and
+// request metadata to logs and spans.
+//
+// This file demonstrates:
+// - Storing typed metadata in context.Context
+// - Extracting metadata with zero-value fallbacks
+//
+// Logger and Tracer read these values automatically, so a handler only has
+// to enrich the context once:
+//
+//	ctx = WithServiceMetadata(ctx, "query-frontend", "v1.4.2", "prod")
+//	ctx = WithRequestMetadata(ctx, r.Header.Get("X-Request-ID"), r.Header.Get("X-Scope-OrgID"))
+//	logger.Info(ctx, "query started", nil) // includes version, env, request_id, tenant_id
+package observability
+
+import "context"
+
+const (
+	// serviceMetadataKey is the context key for ServiceMetadata
+	serviceMetadataKey contextKey = "service_metadata"
+	// requestMetadataKey is the context key for RequestMetadata
+	requestMetadataKey contextKey = "request_metadata"
+)
+
+// ServiceMetadata describes the running service.
+// These values map to the OpenTelemetry resource attributes service.name,
+// service.version, and deployment.environment.
+type ServiceMetadata struct {
+	Service string
+	Version string
+	Env     string
+}
+
+// RequestMetadata identifies a single request.
+// TenantID corresponds to the X-Scope-OrgID header used by Loki, Mimir, and Tempo.
+type RequestMetadata struct {
+	RequestID string
+	TenantID  string
+}
+
+// WithServiceMetadata returns a context carrying service metadata.
+func WithServiceMetadata(ctx context.Context, service, version, env string) context.Context {
+	return context.WithValue(ctx, serviceMetadataKey, ServiceMetadata{
+		Service: service,
+		Version: version,
+		Env:     env,
+	})
+}
+
+// ServiceMetadataFromContext returns the service metadata stored in ctx,
+// or the zero value if none is present.
+func ServiceMetadataFromContext(ctx context.Context) ServiceMetadata {
+	md, _ := ctx.Value(serviceMetadataKey).(ServiceMetadata)
+	return md
+}
+
+// WithRequestMetadata returns a context carrying request metadata.
+func WithRequestMetadata(ctx context.Context, requestID, tenantID string) context.Context {
+	return context.WithValue(ctx, requestMetadataKey, RequestMetadata{
+		RequestID: requestID,
+		TenantID:  tenantID,
+	})
+}
+
+// RequestMetadataFromContext returns the request metadata stored in ctx,
+// or the zero value if none is present.
+func RequestMetadataFromContext(ctx context.Context) RequestMetadata {
+	md, _ := ctx.Value(requestMetadataKey).(RequestMetadata)
+	return md
+}
@@ -0,0 +1,99 @@
+// Package observability provides tests for context metadata helpers.
+package observability
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestMetadataFromContext(t *testing.T) {
+	ctx := context.Background()
+
+	if md := ServiceMetadataFromContext(ctx); md != (ServiceMetadata{}) {
+		t.Errorf("expected zero ServiceMetadata, got %+v", md)
+	}
+	if md := RequestMetadataFromContext(ctx); md != (RequestMetadata{}) {
+		t.Errorf("expected zero RequestMetadata, got %+v", md)
+	}
+
+	ctx = WithServiceMetadata(ctx, "querier", "v2.9.0", "prod")
+	ctx = WithRequestMetadata(ctx, "req-1", "tenant-a")
+
+	want := ServiceMetadata{Service: "querier", Version: "v2.9.0", Env: "prod"}
+	if md := ServiceMetadataFromContext(ctx); md != want {
+		t.Errorf("ServiceMetadataFromContext = %+v, want %+v", md, want)
+	}
+	wantReq := RequestMetadata{RequestID: "req-1", TenantID: "tenant-a"}
+	if md := RequestMetadataFromContext(ctx); md != wantReq {
+		t.Errorf("RequestMetadataFromContext = %+v, want %+v", md, wantReq)
+	}
+}
+
+func TestLogger_ContextMetadata(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger("", WithOutput(&buf))
+
+	ctx := WithServiceMetadata(context.Background(), "querier", "v2.9.0", "prod")
+	ctx = WithRequestMetadata(ctx, "req-1", "tenant-a")
+
+	logger.Info(ctx, "query started", nil)
+
+	var entry LogEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Failed to parse log entry: %v", err)
+	}
+
+	if entry.Service != "querier" {
+		t.Errorf("Log service = %q, want 'querier'", entry.Service)
+	}
+	if entry.Version != "v2.9.0" || entry.Env != "prod" {
+		t.Errorf("Log version/env = %q/%q, want 'v2.9.0'/'prod'", entry.Version, entry.Env)
+	}
+	if entry.RequestID != "req-1" || entry.TenantID != "tenant-a" {
+		t.Errorf("Log request_id/tenant_id = %q/%q, want 'req-1'/'tenant-a'", entry.RequestID, entry.TenantID)
+	}
+}
+
+func TestLogger_ContextMetadataKeepsLoggerService(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger("api-gateway", WithOutput(&buf))
+
+	ctx := WithServiceMetadata(context.Background(), "other", "v1", "dev")
+	logger.Info(ctx, "hello", nil)
+
+	var entry LogEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Failed to parse log entry: %v", err)
+	}
+	if entry.Service != "api-gateway" {
+		t.Errorf("Log service = %q, want the logger's own 'api-gateway'", entry.Service)
+	}
+}
+
+func TestTracer_ContextMetadata(t *testing.T) {
+	tracer := NewTracer(TracerConfig{
+		ServiceName: "querier",
+		Sampler:     &AlwaysSampler{},
+		Exporter:    NewConsoleExporter(&bytes.Buffer{}),
+	})
+
+	ctx := WithServiceMetadata(context.Background(), "querier", "v2.9.0", "prod")
+	ctx = WithRequestMetadata(ctx, "req-1", "tenant-a")
+
+	_, span := tracer.StartSpan(ctx, "query", SpanKindServer)
+
+	tests := map[string]interface{}{
+		"service.name":           "querier",
+		"service.version":        "v2.9.0",
+		"deployment.environment": "prod",
+		"request.id":             "req-1",
+		"tenant.id":              "tenant-a",
+	}
+	for key, want := range tests {
+		if got := span.Attributes[key]; got != want {
+			t.Errorf("span attribute %s = %v, want %v", key, got, want)
+		}
+	}
+}
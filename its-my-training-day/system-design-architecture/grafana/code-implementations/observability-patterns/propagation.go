@@ -0,0 +1,53 @@
+// Package observability provides carrier-agnostic trace context propagation.
+//
+// This file demonstrates:
+// - Injecting the active trace into a plain string map
+// - Extracting a remote trace from a plain string map
+// - Adapting the map form to HTTP headers and gRPC metadata
+//
+// Any transport with string key/value metadata can carry a trace: Kafka
+// record headers, AMQP message properties, or custom RPC envelopes. The map
+// functions are the single implementation of the W3C traceparent format;
+// the HTTP and gRPC helpers only copy the value between carriers.
+package observability
+
+import (
+	"context"
+	"net/http"
+)
+
+// traceparentKey is the W3C Trace Context key. It is lowercase, as gRPC
+// metadata and most message headers require.
+const traceparentKey = "traceparent"
+
+// InjectMap stores the trace context from ctx (trace ID, current span ID,
+// and sampled flag) in carrier under the "traceparent" key. If ctx carries
+// no trace, carrier is left unchanged.
+func InjectMap(ctx context.Context, carrier map[string]string) {
+	if traceparent, ok := traceparentFromContext(ctx); ok {
+		carrier[traceparentKey] = traceparent
+	}
+}
+
+// ExtractMap returns a context carrying the trace context found in carrier:
+// the trace ID, the remote span ID under ParentSpanIDKey, and the sampled
+// flag. A missing or malformed traceparent leaves ctx unchanged. carrier
+// may be nil.
+func ExtractMap(ctx context.Context, carrier map[string]string) context.Context {
+	return contextWithTraceparent(ctx, carrier[traceparentKey])
+}
+
+// InjectHTTP sets the traceparent header on an outgoing request's headers.
+func InjectHTTP(ctx context.Context, header http.Header) {
+	carrier := make(map[string]string, 1)
+	InjectMap(ctx, carrier)
+	for k, v := range carrier {
+		header.Set(k, v)
+	}
+}
+
+// ExtractHTTP returns a context carrying the trace context from an incoming
+// request's traceparent header.
+func ExtractHTTP(ctx context.Context, header http.Header) context.Context {
+	return ExtractMap(ctx, map[string]string{traceparentKey: header.Get(traceparentKey)})
+}
@@ -0,0 +1,70 @@
+// Package observability provides tests for carrier-agnostic propagation.
+package observability
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestInjectExtractMap_RoundTrip(t *testing.T) {
+	tracer := NewTracer(TracerConfig{
+		ServiceName: "producer",
+		Sampler:     &AlwaysSampler{},
+		Exporter:    NewInMemoryExporter(),
+	})
+	ctx, span := tracer.StartSpan(context.Background(), "publish", SpanKindProducer)
+
+	// e.g. Kafka record headers
+	carrier := map[string]string{"content-type": "application/json"}
+	InjectMap(ctx, carrier)
+
+	want := "00-" + span.TraceID + "-" + span.SpanID + "-01"
+	if carrier["traceparent"] != want {
+		t.Errorf("traceparent = %q, want %q", carrier["traceparent"], want)
+	}
+	if carrier["content-type"] != "application/json" {
+		t.Error("existing carrier entries should be kept")
+	}
+
+	consumerCtx := ExtractMap(context.Background(), carrier)
+	if got := consumerCtx.Value(TraceIDKey); got != span.TraceID {
+		t.Errorf("trace ID = %v, want %v", got, span.TraceID)
+	}
+	if got := consumerCtx.Value(ParentSpanIDKey); got != span.SpanID {
+		t.Errorf("parent span ID = %v, want %v", got, span.SpanID)
+	}
+	if sampled, _ := consumerCtx.Value(SampledKey).(bool); !sampled {
+		t.Error("sampled flag should propagate")
+	}
+}
+
+func TestInjectExtractMap_NoTrace(t *testing.T) {
+	carrier := map[string]string{}
+	InjectMap(context.Background(), carrier)
+	if len(carrier) != 0 {
+		t.Errorf("carrier = %v, want nothing injected without a trace", carrier)
+	}
+
+	for _, carrier := range []map[string]string{nil, {"traceparent": "garbage"}} {
+		if ctx := ExtractMap(context.Background(), carrier); ctx.Value(TraceIDKey) != nil {
+			t.Errorf("ExtractMap(%v) should not set a trace ID", carrier)
+		}
+	}
+}
+
+func TestInjectExtractHTTP(t *testing.T) {
+	ctx := context.WithValue(context.Background(), TraceIDKey, "0af7651916cd43dd8448eb211c80319c")
+	ctx = context.WithValue(ctx, SpanIDKey, "b7ad6b7169203331")
+
+	header := http.Header{}
+	InjectHTTP(ctx, header)
+	if got := header.Get("Traceparent"); got != "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00" {
+		t.Errorf("traceparent header = %q", got)
+	}
+
+	extracted := ExtractHTTP(context.Background(), header)
+	if got := extracted.Value(ParentSpanIDKey); got != "b7ad6b7169203331" {
+		t.Errorf("parent span ID = %v, want b7ad6b7169203331", got)
+	}
+}
@@ -0,0 +1,125 @@
+// Package observability provides a Prometheus Pushgateway client.
+//
+// This file demonstrates:
+// - Pushing a registry in the Prometheus text format over HTTP
+// - Building Pushgateway grouping-key URLs, including base64 label values
+// - Retrying transient failures with a fixed backoff
+//
+// Batch and cron jobs often exit before Prometheus scrapes them. Pushing
+// their final metrics to a Pushgateway, which Prometheus scrapes instead,
+// keeps the results of the last run visible.
+package observability
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// pushgatewayMaxRetries is the number of retries after a failed push
+	pushgatewayMaxRetries = 3
+	// pushgatewayRetryBackoff is the pause between attempts
+	pushgatewayRetryBackoff = 100 * time.Millisecond
+)
+
+// PushgatewayClient pushes metrics to a Prometheus Pushgateway under a job
+// name and optional grouping labels.
+type PushgatewayClient struct {
+	url          string
+	client       *http.Client
+	retryBackoff time.Duration
+}
+
+// NewPushgatewayClient creates a client for the Pushgateway at endpoint
+// (e.g. "http://pushgateway:9091"). labels are added to the grouping key,
+// so pushes with different labels do not overwrite each other.
+func NewPushgatewayClient(endpoint, job string, labels map[string]string) *PushgatewayClient {
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(endpoint, "/"))
+	b.WriteString("/metrics/job")
+	writeGroupingPair(&b, "job", job)
+	for _, name := range sortedKeys(labels) {
+		writeGroupingPair(&b, name, labels[name])
+	}
+
+	return &PushgatewayClient{
+		url:          b.String(),
+		client:       &http.Client{Timeout: 10 * time.Second},
+		retryBackoff: pushgatewayRetryBackoff,
+	}
+}
+
+// writeGroupingPair appends one grouping-key segment. The job segment is
+// written as /value after /metrics/job; other labels as /name/value. Values
+// that are empty or contain a slash use the name@base64 form, since
+// neither can appear in a plain path segment.
+func writeGroupingPair(b *strings.Builder, name, value string) {
+	if name != "job" {
+		b.WriteString("/")
+		b.WriteString(url.PathEscape(name))
+	}
+	if value == "" || strings.Contains(value, "/") {
+		b.WriteString("@base64/")
+		if value == "" {
+			// Pushgateway's spelling of an empty base64 value
+			b.WriteString("=")
+			return
+		}
+		b.WriteString(base64.RawURLEncoding.EncodeToString([]byte(value)))
+		return
+	}
+	b.WriteString("/")
+	b.WriteString(url.PathEscape(value))
+}
+
+// Push replaces the metrics stored under this client's grouping key with
+// everything in registry, using PUT as Pushgateway defines it. Network
+// errors and 5xx responses are retried up to 3 times; 4xx responses are
+// returned immediately because repeating the request will not help.
+func (c *PushgatewayClient) Push(registry *MetricRegistry) error {
+	var body bytes.Buffer
+	if err := registry.WritePrometheus(&body); err != nil {
+		return fmt.Errorf("encoding metrics: %w", err)
+	}
+
+	var err error
+	for attempt := 0; attempt <= pushgatewayMaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(c.retryBackoff)
+		}
+		var retryable bool
+		retryable, err = c.put(body.Bytes())
+		if err == nil || !retryable {
+			return err
+		}
+	}
+	return fmt.Errorf("push failed after %d retries: %w", pushgatewayMaxRetries, err)
+}
+
+// put sends one request and reports whether a failure is worth retrying.
+func (c *PushgatewayClient) put(body []byte) (retryable bool, err error) {
+	req, err := http.NewRequest(http.MethodPut, c.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("pushgateway returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	return resp.StatusCode >= 500, err
+}
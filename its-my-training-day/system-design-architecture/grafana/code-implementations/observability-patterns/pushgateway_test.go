@@ -0,0 +1,119 @@
+// Package observability provides tests for the Pushgateway client.
+package observability
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPushgatewayClient_Push(t *testing.T) {
+	var method, path, contentType, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		method, path, contentType, body = r.Method, r.URL.EscapedPath(), r.Header.Get("Content-Type"), string(b)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	processed := NewCounter(MetricOpts{Name: "batch_records_processed_total", Help: "Records processed"})
+	failed := NewCounter(MetricOpts{Name: "batch_records_failed_total", Help: "Records failed"})
+	processed.Add(42)
+	failed.Add(3)
+
+	registry := NewMetricRegistry()
+	registry.MustRegister(processed, failed)
+
+	client := NewPushgatewayClient(server.URL+"/", "nightly-compaction", map[string]string{"tenant": "team-a"})
+	if err := client.Push(registry); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	if method != http.MethodPut {
+		t.Errorf("method = %s, want PUT", method)
+	}
+	if want := "/metrics/job/nightly-compaction/tenant/team-a"; path != want {
+		t.Errorf("path = %s, want %s", path, want)
+	}
+	if !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q, want Prometheus text format", contentType)
+	}
+	for _, want := range []string{"batch_records_processed_total 42", "batch_records_failed_total 3"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestPushgatewayClient_GroupingKeyBase64(t *testing.T) {
+	client := NewPushgatewayClient("http://pushgateway:9091", "backup", map[string]string{
+		"path":     "/var/lib/loki",
+		"instance": "",
+	})
+
+	want := "http://pushgateway:9091/metrics/job/backup/instance@base64/=/path@base64/L3Zhci9saWIvbG9raQ"
+	if client.url != want {
+		t.Errorf("url = %s, want %s", client.url, want)
+	}
+}
+
+func TestPushgatewayClient_Retry(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewPushgatewayClient(server.URL, "job", nil)
+	client.retryBackoff = 0
+	if err := client.Push(NewMetricRegistry()); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if got := atomic.LoadInt32(&attempts); got != 3 {
+		t.Errorf("attempts = %d, want 3", got)
+	}
+}
+
+func TestPushgatewayClient_RetriesExhausted(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewPushgatewayClient(server.URL, "job", nil)
+	client.retryBackoff = 0
+	if err := client.Push(NewMetricRegistry()); err == nil {
+		t.Fatal("expected error after retries are exhausted")
+	}
+	if got := atomic.LoadInt32(&attempts); got != 1+pushgatewayMaxRetries {
+		t.Errorf("attempts = %d, want %d", got, 1+pushgatewayMaxRetries)
+	}
+}
+
+func TestPushgatewayClient_NoRetryOnClientError(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		http.Error(w, "bad metric name", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := NewPushgatewayClient(server.URL, "job", nil)
+	client.retryBackoff = 0
+	err := client.Push(NewMetricRegistry())
+	if err == nil || !strings.Contains(err.Error(), "bad metric name") {
+		t.Errorf("expected 400 error with response body, got %v", err)
+	}
+	if got := atomic.LoadInt32(&attempts); got != 1 {
+		t.Errorf("attempts = %d, want 1", got)
+	}
+}
@@ -0,0 +1,112 @@
+// Package observability provides quantile estimation from histogram buckets.
+//
+// This file demonstrates:
+// - Estimating quantiles from cumulative bucket counts
+// - Linear interpolation within a bucket, as PromQL's histogram_quantile does
+// - Working on exported or scraped data without a live Histogram
+//
+// The estimate assumes observations are spread evenly within each bucket, so
+// its accuracy depends on how well the bucket boundaries fit the data.
+package observability
+
+import (
+	"math"
+	"sort"
+)
+
+// Bucket is one cumulative histogram bucket: the number of observations less
+// than or equal to UpperBound. The last bucket should have an UpperBound of
+// +Inf and a CumulativeCount equal to the total count.
+type Bucket struct {
+	UpperBound      float64
+	CumulativeCount uint64
+}
+
+// EstimateQuantile estimates the q-quantile (0 <= q <= 1) of the series with
+// the given label values. It returns NaN if the series has no observations.
+func (h *Histogram) EstimateQuantile(q float64, labelValues ...string) float64 {
+	key := h.labelKey(labelValues)
+	h.mu.RLock()
+	data, exists := h.counts[key]
+	if !exists {
+		h.mu.RUnlock()
+		return math.NaN()
+	}
+	buckets := make([]Bucket, len(data.bucketCounts))
+	for i, count := range data.bucketCounts {
+		bound := math.Inf(1)
+		if i < len(data.buckets) {
+			bound = data.buckets[i]
+		}
+		buckets[i] = Bucket{UpperBound: bound, CumulativeCount: count}
+	}
+	sum, count := data.sum, data.count
+	h.mu.RUnlock()
+
+	return EstimateQuantileFromBuckets(buckets, sum, count, q)
+}
+
+// EstimateQuantileFromBuckets estimates the q-quantile from cumulative
+// buckets, following the same rules as PromQL's histogram_quantile:
+// - The quantile is linearly interpolated within the bucket containing it
+// - The lowest bucket is assumed to start at 0 if its upper bound is positive
+// - A quantile in the +Inf bucket returns the highest finite upper bound
+// - q < 0 returns -Inf, q > 1 returns +Inf, and no observations returns NaN
+//
+// count is the total number of observations; if it is 0, the largest
+// cumulative count is used. sum is not needed for the estimate but is
+// accepted so complete histogram data can be passed through unchanged.
+func EstimateQuantileFromBuckets(buckets []Bucket, sum float64, count uint64, q float64) float64 {
+	switch {
+	case math.IsNaN(q):
+		return math.NaN()
+	case q < 0:
+		return math.Inf(-1)
+	case q > 1:
+		return math.Inf(1)
+	}
+
+	sorted := append([]Bucket(nil), buckets...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].UpperBound < sorted[j].UpperBound
+	})
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	if count == 0 {
+		count = sorted[len(sorted)-1].CumulativeCount
+	}
+	if count == 0 {
+		return math.NaN()
+	}
+
+	rank := q * float64(count)
+	i := sort.Search(len(sorted), func(i int) bool {
+		return float64(sorted[i].CumulativeCount) >= rank
+	})
+	if i == len(sorted) {
+		i = len(sorted) - 1
+	}
+
+	if math.IsInf(sorted[i].UpperBound, 1) {
+		// No upper limit to interpolate towards
+		if i == 0 {
+			return math.NaN()
+		}
+		return sorted[i-1].UpperBound
+	}
+
+	lowerBound, lowerCount := 0.0, uint64(0)
+	if i > 0 {
+		lowerBound = sorted[i-1].UpperBound
+		lowerCount = sorted[i-1].CumulativeCount
+	} else if sorted[0].UpperBound <= 0 {
+		return sorted[0].UpperBound
+	}
+
+	inBucket := float64(sorted[i].CumulativeCount - lowerCount)
+	if inBucket == 0 {
+		return sorted[i].UpperBound
+	}
+	return lowerBound + (sorted[i].UpperBound-lowerBound)*(rank-float64(lowerCount))/inBucket
+}
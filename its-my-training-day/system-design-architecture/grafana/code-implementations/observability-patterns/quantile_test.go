@@ -0,0 +1,102 @@
+// Package observability provides tests for histogram quantile estimation.
+package observability
+
+import (
+	"math"
+	"testing"
+)
+
+// uniformBuckets returns cumulative buckets for n observations spread evenly
+// over [0, max], with the given number of equal-width buckets.
+func uniformBuckets(n uint64, max float64, buckets int) []Bucket {
+	out := make([]Bucket, 0, buckets+1)
+	for i := 1; i <= buckets; i++ {
+		out = append(out, Bucket{
+			UpperBound:      max * float64(i) / float64(buckets),
+			CumulativeCount: n * uint64(i) / uint64(buckets),
+		})
+	}
+	return append(out, Bucket{UpperBound: math.Inf(1), CumulativeCount: n})
+}
+
+func TestEstimateQuantileFromBuckets_Uniform(t *testing.T) {
+	buckets := uniformBuckets(10000, 10, 10)
+
+	for _, q := range []float64{0.25, 0.5, 0.9, 0.99} {
+		want := q * 10 // Analytical quantile of U(0, 10)
+		got := EstimateQuantileFromBuckets(buckets, 50000, 10000, q)
+		if math.Abs(got-want) > 0.05*want {
+			t.Errorf("q=%v: got %v, want %v ±5%%", q, got, want)
+		}
+	}
+}
+
+func TestEstimateQuantileFromBuckets_Exponential(t *testing.T) {
+	// Cumulative counts of 100000 draws from Exp(1), computed from the CDF
+	bounds := []float64{0.1, 0.25, 0.5, 1, 2, 4, 8}
+	const n = 100000
+	var buckets []Bucket
+	for _, b := range bounds {
+		buckets = append(buckets, Bucket{
+			UpperBound:      b,
+			CumulativeCount: uint64(n * (1 - math.Exp(-b))),
+		})
+	}
+	buckets = append(buckets, Bucket{UpperBound: math.Inf(1), CumulativeCount: n})
+
+	// Quantile function of Exp(1) is -ln(1-q); pick quantiles that fall
+	// where the buckets are narrow enough for linear interpolation to hold
+	for _, q := range []float64{0.1, 0.2, 0.35} {
+		want := -math.Log(1 - q)
+		got := EstimateQuantileFromBuckets(buckets, n, n, q)
+		if math.Abs(got-want) > 0.05*want {
+			t.Errorf("q=%v: got %v, want %v ±5%%", q, got, want)
+		}
+	}
+}
+
+func TestEstimateQuantileFromBuckets_EdgeCases(t *testing.T) {
+	buckets := []Bucket{
+		{UpperBound: 1, CumulativeCount: 5},
+		{UpperBound: 2, CumulativeCount: 10},
+		{UpperBound: math.Inf(1), CumulativeCount: 20},
+	}
+
+	if got := EstimateQuantileFromBuckets(buckets, 0, 20, 0.99); got != 2 {
+		t.Errorf("quantile in +Inf bucket = %v, want highest finite bound 2", got)
+	}
+	if got := EstimateQuantileFromBuckets(buckets, 0, 20, -0.1); !math.IsInf(got, -1) {
+		t.Errorf("q < 0 = %v, want -Inf", got)
+	}
+	if got := EstimateQuantileFromBuckets(buckets, 0, 20, 1.1); !math.IsInf(got, 1) {
+		t.Errorf("q > 1 = %v, want +Inf", got)
+	}
+	if got := EstimateQuantileFromBuckets(nil, 0, 0, 0.5); !math.IsNaN(got) {
+		t.Errorf("empty buckets = %v, want NaN", got)
+	}
+
+	// Unsorted input and count inferred from the buckets
+	unsorted := []Bucket{buckets[2], buckets[0], buckets[1]}
+	if got := EstimateQuantileFromBuckets(unsorted, 0, 0, 0.25); got != 1 {
+		t.Errorf("q=0.25 on unsorted buckets = %v, want 1", got)
+	}
+}
+
+func TestHistogram_EstimateQuantile(t *testing.T) {
+	h := NewHistogram(MetricOpts{
+		Name:    "latency_seconds",
+		Help:    "Latency",
+		Labels:  []string{"route"},
+		Buckets: []float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9, 1},
+	})
+	for i := 0; i < 1000; i++ {
+		h.Observe(float64(i)/1000, "/api")
+	}
+
+	if got := h.EstimateQuantile(0.5, "/api"); math.Abs(got-0.5) > 0.025 {
+		t.Errorf("p50 = %v, want 0.5 ±5%%", got)
+	}
+	if got := h.EstimateQuantile(0.5, "/missing"); !math.IsNaN(got) {
+		t.Errorf("p50 of an unobserved series = %v, want NaN", got)
+	}
+}
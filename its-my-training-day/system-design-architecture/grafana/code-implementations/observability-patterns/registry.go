@@ -0,0 +1,506 @@
+// Package observability provides a metric registry with Prometheus text
+// exposition for the metrics defined in instrumentation.go.
+//
+// This file demonstrates:
+// - Registering metrics in a registry (mirrors prometheus.Registry)
+// - Collecting metric families as a backend-neutral snapshot
+// - Writing the Prometheus and OpenMetrics text exposition formats
+// - Serving a /metrics endpoint with Accept-header negotiation
+//
+// In production, you would use github.com/prometheus/client_golang, which
+// implements the same Register/Gather/Handler flow.
+package observability
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Collector is a metric that can be registered with a MetricRegistry.
+// Counter, Gauge, and Histogram all implement Collector.
+type Collector interface {
+	// Describe returns the HELP and TYPE lines for the metric.
+	Describe() string
+	// Collect returns a snapshot of the metric's current values.
+	Collect() MetricFamily
+}
+
+// MetricFamily is a snapshot of all label combinations of a single metric.
+type MetricFamily struct {
+	Name       string
+	Help       string
+	Type       MetricType
+	LabelNames []string
+	Samples    []MetricSample
+}
+
+// MetricSample is the value of a metric for one label combination.
+type MetricSample struct {
+	// LabelValues are ordered to match MetricFamily.LabelNames
+	LabelValues []string
+	// Value holds the counter or gauge value
+	Value float64
+	// Buckets are histogram upper bounds, excluding +Inf
+	Buckets []float64
+	// BucketCounts are cumulative counts per bucket; the last entry is +Inf
+	BucketCounts []uint64
+	// Sum and Count are the histogram sum and observation count
+	Sum   float64
+	Count uint64
+	// Created is when the series was first seen (counters and histograms)
+	Created time.Time
+}
+
+// MetricRegistry holds a set of metrics and exposes them for scraping.
+type MetricRegistry struct {
+	collectors []Collector
+	names      map[string]bool
+	// Labels added to every sample at gather time, sorted by name
+	constLabelNames  []string
+	constLabelValues []string
+	mu               sync.RWMutex
+}
+
+// NewMetricRegistry creates an empty metric registry.
+func NewMetricRegistry() *MetricRegistry {
+	return &MetricRegistry{
+		names: make(map[string]bool),
+	}
+}
+
+// WithConstLabels sets labels that are attached to every sample gathered from
+// this registry, such as {service="my-svc", env="prod"}. Metrics do not
+// declare these in MetricOpts.Labels, and callers do not pass them to Inc or
+// Observe. A metric label with the same name takes precedence.
+func (r *MetricRegistry) WithConstLabels(labels map[string]string) *MetricRegistry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.constLabelNames = sortedKeys(labels)
+	r.constLabelValues = make([]string, len(r.constLabelNames))
+	for i, name := range r.constLabelNames {
+		r.constLabelValues[i] = labels[name]
+	}
+	return r
+}
+
+// Register adds a metric to the registry.
+// Returns an error if a metric with the same fully qualified name exists.
+func (r *MetricRegistry) Register(c Collector) error {
+	name := c.Collect().Name
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.names[name] {
+		return fmt.Errorf("metric %q is already registered", name)
+	}
+	r.names[name] = true
+	r.collectors = append(r.collectors, c)
+	return nil
+}
+
+// MustRegister registers the given metrics and panics on error.
+// This is intended for package-level metric setup.
+func (r *MetricRegistry) MustRegister(cs ...Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Gather collects a snapshot of every registered metric, sorted by name.
+func (r *MetricRegistry) Gather() []MetricFamily {
+	r.mu.RLock()
+	collectors := make([]Collector, len(r.collectors))
+	copy(collectors, r.collectors)
+	constNames, constValues := r.constLabelNames, r.constLabelValues
+	r.mu.RUnlock()
+
+	families := make([]MetricFamily, 0, len(collectors))
+	for _, c := range collectors {
+		families = append(families, withConstLabels(c.Collect(), constNames, constValues))
+	}
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].Name < families[j].Name
+	})
+	return families
+}
+
+// withConstLabels appends the registry's constant labels to every sample in
+// mf, skipping any the metric already declares.
+func withConstLabels(mf MetricFamily, names, values []string) MetricFamily {
+	if len(names) == 0 {
+		return mf
+	}
+
+	declared := make(map[string]bool, len(mf.LabelNames))
+	for _, name := range mf.LabelNames {
+		declared[name] = true
+	}
+	labelNames := append([]string{}, mf.LabelNames...)
+	var extra []string
+	for i, name := range names {
+		if !declared[name] {
+			labelNames = append(labelNames, name)
+			extra = append(extra, values[i])
+		}
+	}
+	if len(extra) == 0 {
+		return mf
+	}
+
+	mf.LabelNames = labelNames
+	for i := range mf.Samples {
+		mf.Samples[i].LabelValues = append(append([]string{}, mf.Samples[i].LabelValues...), extra...)
+	}
+	return mf
+}
+
+// WritePrometheus writes all registered metrics in the Prometheus text
+// exposition format (version 0.0.4).
+func (r *MetricRegistry) WritePrometheus(w io.Writer) error {
+	for _, mf := range r.Gather() {
+		if err := writePrometheusFamily(w, mf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteOpenMetrics writes all registered metrics in the OpenMetrics 1.0
+// text format. It differs from the Prometheus format in that:
+// - Counter families are named without the _total suffix
+// - Counters and histograms expose a _created timestamp per series
+// - The exposition ends with "# EOF"
+func (r *MetricRegistry) WriteOpenMetrics(w io.Writer) error {
+	for _, mf := range r.Gather() {
+		if err := writeOpenMetricsFamily(w, mf); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "# EOF\n")
+	return err
+}
+
+// Handler returns an HTTP handler that serves the registered metrics.
+// Mount it at /metrics for Prometheus to scrape. Scrapers that send
+// Accept: application/openmetrics-text get the OpenMetrics format.
+func (r *MetricRegistry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var err error
+		if strings.Contains(req.Header.Get("Accept"), "application/openmetrics-text") {
+			w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+			err = r.WriteOpenMetrics(w)
+		} else {
+			w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+			err = r.WritePrometheus(w)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// ExportedMetric is a single metric series prepared for a push-based backend.
+// Unlike MetricFamily, each ExportedMetric carries its own labels, which
+// suits exporters that emit one line per series (StatsD, Graphite).
+type ExportedMetric struct {
+	Name   string
+	Type   MetricType
+	Labels map[string]string
+	// Value holds the counter or gauge value
+	Value float64
+	// Buckets, BucketCounts, Sum, and Count hold histogram data with the same
+	// meaning as in MetricSample
+	Buckets      []float64
+	BucketCounts []uint64
+	Sum          float64
+	Count        uint64
+}
+
+// MetricExporter pushes metrics to a backend.
+// Exporters receive cumulative values and are responsible for converting
+// them to whatever the backend expects (e.g., deltas for StatsD counters).
+type MetricExporter interface {
+	Flush(metrics []ExportedMetric) error
+}
+
+// Export flattens every registered metric into one ExportedMetric per series.
+// Pass the result to a MetricExporter on a timer:
+//
+//	for range ticker.C {
+//	    exporter.Flush(registry.Export())
+//	}
+func (r *MetricRegistry) Export() []ExportedMetric {
+	var metrics []ExportedMetric
+	for _, mf := range r.Gather() {
+		for _, s := range mf.Samples {
+			labels := make(map[string]string, len(mf.LabelNames))
+			for i, name := range mf.LabelNames {
+				if i < len(s.LabelValues) {
+					labels[name] = s.LabelValues[i]
+				}
+			}
+			metrics = append(metrics, ExportedMetric{
+				Name:         mf.Name,
+				Type:         mf.Type,
+				Labels:       labels,
+				Value:        s.Value,
+				Buckets:      s.Buckets,
+				BucketCounts: s.BucketCounts,
+				Sum:          s.Sum,
+				Count:        s.Count,
+			})
+		}
+	}
+	return metrics
+}
+
+// writePrometheusFamily writes a single metric family in text format.
+func writePrometheusFamily(w io.Writer, mf MetricFamily) error {
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n",
+		mf.Name, escapeHelp(mf.Help), mf.Name, mf.Type); err != nil {
+		return err
+	}
+
+	for _, s := range mf.Samples {
+		if mf.Type != HistogramMetric {
+			if _, err := fmt.Fprintf(w, "%s%s %s\n",
+				mf.Name, formatLabels(mf.LabelNames, s.LabelValues), formatFloat(s.Value)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for i, count := range s.BucketCounts {
+			le := math.Inf(1)
+			if i < len(s.Buckets) {
+				le = s.Buckets[i]
+			}
+			names := append(append([]string{}, mf.LabelNames...), "le")
+			values := append(append([]string{}, s.LabelValues...), formatFloat(le))
+			if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n",
+				mf.Name, formatLabels(names, values), count); err != nil {
+				return err
+			}
+		}
+		labels := formatLabels(mf.LabelNames, s.LabelValues)
+		if _, err := fmt.Fprintf(w, "%s_sum%s %s\n%s_count%s %d\n",
+			mf.Name, labels, formatFloat(s.Sum), mf.Name, labels, s.Count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeOpenMetricsFamily writes a single metric family in OpenMetrics format.
+func writeOpenMetricsFamily(w io.Writer, mf MetricFamily) error {
+	name := mf.Name
+	if mf.Type == CounterMetric {
+		name = strings.TrimSuffix(name, "_total")
+	}
+	if _, err := fmt.Fprintf(w, "# TYPE %s %s\n# HELP %s %s\n",
+		name, mf.Type, name, escapeLabelValue(mf.Help)); err != nil {
+		return err
+	}
+
+	for _, s := range mf.Samples {
+		labels := formatLabels(mf.LabelNames, s.LabelValues)
+
+		switch mf.Type {
+		case CounterMetric:
+			if _, err := fmt.Fprintf(w, "%s_total%s %s\n",
+				name, labels, formatFloat(s.Value)); err != nil {
+				return err
+			}
+
+		case HistogramMetric:
+			for i, count := range s.BucketCounts {
+				le := "+Inf"
+				if i < len(s.Buckets) {
+					le = formatOpenMetricsFloat(s.Buckets[i])
+				}
+				names := append(append([]string{}, mf.LabelNames...), "le")
+				values := append(append([]string{}, s.LabelValues...), le)
+				if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n",
+					name, formatLabels(names, values), count); err != nil {
+					return err
+				}
+			}
+			if _, err := fmt.Fprintf(w, "%s_count%s %d\n%s_sum%s %s\n",
+				name, labels, s.Count, name, labels, formatFloat(s.Sum)); err != nil {
+				return err
+			}
+
+		default:
+			if _, err := fmt.Fprintf(w, "%s%s %s\n",
+				name, labels, formatFloat(s.Value)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !s.Created.IsZero() {
+			created := float64(s.Created.UnixNano()) / 1e9
+			if _, err := fmt.Fprintf(w, "%s_created%s %s\n",
+				name, labels, strconv.FormatFloat(created, 'f', -1, 64)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// formatOpenMetricsFloat renders a bucket bound in the canonical OpenMetrics
+// form, which always includes a decimal point (le="1.0", not le="1").
+func formatOpenMetricsFloat(v float64) string {
+	s := formatFloat(v)
+	if !strings.ContainsAny(s, ".eEIN") {
+		s += ".0"
+	}
+	return s
+}
+
+// formatLabels renders a label set as {name="value",...}.
+// Label values beyond the declared names are ignored; missing values are empty.
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabelValue(value))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// formatFloat renders a sample value the way Prometheus expects.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// escapeLabelValue escapes backslashes, quotes, and newlines in label values.
+func escapeLabelValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// escapeHelp escapes backslashes and newlines in HELP text.
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+// splitLabelKey converts a series key built by joinLabelValues back into
+// exactly numLabels label values.
+func splitLabelKey(key string, numLabels int) []string {
+	if numLabels == 0 {
+		return nil
+	}
+	return strings.SplitN(key, labelKeySeparator, numLabels)
+}
+
+// sortedKeys returns map keys in sorted order for deterministic exposition.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Collect returns a snapshot of the counter's values.
+func (c *Counter) Collect() MetricFamily {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	mf := MetricFamily{
+		Name:       c.opts.FullName(),
+		Help:       c.opts.Help,
+		Type:       CounterMetric,
+		LabelNames: c.opts.Labels,
+	}
+	for _, key := range sortedKeys(c.values) {
+		mf.Samples = append(mf.Samples, MetricSample{
+			LabelValues: splitLabelKey(key, len(c.opts.Labels)),
+			Value:       c.values[key],
+			Created:     c.created[key],
+		})
+	}
+	return mf
+}
+
+// Collect returns a snapshot of the gauge's values.
+func (g *Gauge) Collect() MetricFamily {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	mf := MetricFamily{
+		Name:       g.opts.FullName(),
+		Help:       g.opts.Help,
+		Type:       GaugeMetric,
+		LabelNames: g.opts.Labels,
+	}
+	for _, key := range sortedKeys(g.values) {
+		mf.Samples = append(mf.Samples, MetricSample{
+			LabelValues: splitLabelKey(key, len(g.opts.Labels)),
+			Value:       g.values[key],
+		})
+	}
+	return mf
+}
+
+// Collect returns a snapshot of the histogram's buckets, sums, and counts.
+func (h *Histogram) Collect() MetricFamily {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	mf := MetricFamily{
+		Name:       h.opts.FullName(),
+		Help:       h.opts.Help,
+		Type:       HistogramMetric,
+		LabelNames: h.opts.Labels,
+	}
+	for _, key := range sortedKeys(h.counts) {
+		data := h.counts[key]
+		counts := make([]uint64, len(data.bucketCounts))
+		copy(counts, data.bucketCounts)
+		mf.Samples = append(mf.Samples, MetricSample{
+			LabelValues:  splitLabelKey(key, len(h.opts.Labels)),
+			Buckets:      data.buckets,
+			BucketCounts: counts,
+			Sum:          data.sum,
+			Count:        data.count,
+			Created:      data.created,
+		})
+	}
+	return mf
+}
@@ -0,0 +1,202 @@
+// Package observability provides tests for the metric registry and its
+// exposition formats.
+package observability
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricRegistry_Exposition(t *testing.T) {
+	registry := NewMetricRegistry()
+
+	requests := NewCounter(MetricOpts{
+		Namespace: "api",
+		Name:      "requests_total",
+		Help:      "Total requests",
+		Labels:    []string{"method", "path"},
+	})
+	latency := NewHistogram(MetricOpts{
+		Namespace: "api",
+		Name:      "latency_seconds",
+		Help:      "Request latency",
+		Buckets:   []float64{0.1, 1},
+	})
+	registry.MustRegister(requests, latency)
+
+	requests.Inc("GET", `/a"b`)
+	latency.Observe(0.05)
+	latency.Observe(0.5)
+	latency.Observe(5)
+
+	var sb strings.Builder
+	if err := registry.WritePrometheus(&sb); err != nil {
+		t.Fatalf("WritePrometheus: %v", err)
+	}
+	out := sb.String()
+
+	for _, want := range []string{
+		`api_requests_total{method="GET",path="/a\"b"} 1`,
+		`api_latency_seconds_bucket{le="0.1"} 1`,
+		`api_latency_seconds_bucket{le="1"} 2`,
+		`api_latency_seconds_bucket{le="+Inf"} 3`,
+		`api_latency_seconds_sum 5.55`,
+		`api_latency_seconds_count 3`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected exposition to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	// Families are sorted by name
+	if strings.Index(out, "api_latency_seconds") > strings.Index(out, "api_requests_total") {
+		t.Errorf("expected families sorted by name, got:\n%s", out)
+	}
+}
+
+func TestMetricRegistry_OpenMetrics(t *testing.T) {
+	registry := NewMetricRegistry()
+
+	requests := NewCounter(MetricOpts{
+		Namespace: "api",
+		Name:      "requests_total",
+		Help:      "Total requests",
+		Labels:    []string{"method"},
+	})
+	latency := NewHistogram(MetricOpts{
+		Namespace: "api",
+		Name:      "latency_seconds",
+		Help:      "Request latency",
+		Buckets:   []float64{0.1, 1},
+	})
+	registry.MustRegister(requests, latency)
+
+	requests.Inc("GET")
+	latency.Observe(0.05)
+	latency.Observe(5)
+
+	var sb strings.Builder
+	if err := registry.WriteOpenMetrics(&sb); err != nil {
+		t.Fatalf("WriteOpenMetrics: %v", err)
+	}
+	out := sb.String()
+
+	for _, want := range []string{
+		"# TYPE api_requests counter",
+		`api_requests_total{method="GET"} 1`,
+		`api_requests_created{method="GET"} `,
+		"# TYPE api_latency_seconds histogram",
+		`api_latency_seconds_bucket{le="0.1"} 1`,
+		`api_latency_seconds_bucket{le="1.0"} 1`,
+		`api_latency_seconds_bucket{le="+Inf"} 2`,
+		"api_latency_seconds_count 2",
+		"api_latency_seconds_sum 5.05",
+		"api_latency_seconds_created ",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected exposition to contain %q, got:\n%s", want, out)
+		}
+	}
+	if !strings.HasSuffix(out, "# EOF\n") {
+		t.Errorf("expected exposition to end with # EOF, got:\n%s", out)
+	}
+}
+
+func TestMetricRegistry_HandlerNegotiation(t *testing.T) {
+	registry := NewMetricRegistry()
+	registry.MustRegister(NewCounter(MetricOpts{Name: "events_total", Help: "Events"}))
+
+	tests := []struct {
+		accept      string
+		contentType string
+		eof         bool
+	}{
+		{"", "text/plain; version=0.0.4; charset=utf-8", false},
+		{"application/openmetrics-text; version=1.0.0,text/plain;q=0.5", "application/openmetrics-text; version=1.0.0; charset=utf-8", true},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		rec := httptest.NewRecorder()
+		registry.Handler().ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Content-Type"); got != tt.contentType {
+			t.Errorf("Accept %q: Content-Type = %q, want %q", tt.accept, got, tt.contentType)
+		}
+		if got := strings.HasSuffix(rec.Body.String(), "# EOF\n"); got != tt.eof {
+			t.Errorf("Accept %q: ends with # EOF = %v, want %v", tt.accept, got, tt.eof)
+		}
+	}
+}
+
+func TestMetricRegistry_ConstLabels(t *testing.T) {
+	registry := NewMetricRegistry().WithConstLabels(map[string]string{
+		"service": "my-svc",
+		"env":     "prod",
+	})
+
+	requests := NewCounter(MetricOpts{
+		Name:   "requests_total",
+		Help:   "Total requests",
+		Labels: []string{"method"},
+	})
+	inflight := NewGauge(MetricOpts{Name: "inflight", Help: "In-flight requests"})
+	latency := NewHistogram(MetricOpts{
+		Name:    "latency_seconds",
+		Help:    "Request latency",
+		Buckets: []float64{1},
+	})
+	registry.MustRegister(requests, inflight, latency)
+
+	requests.Inc("GET")
+	inflight.Set(3)
+	latency.Observe(0.5)
+
+	var sb strings.Builder
+	if err := registry.WritePrometheus(&sb); err != nil {
+		t.Fatalf("WritePrometheus: %v", err)
+	}
+
+	samples := 0
+	for _, line := range strings.Split(strings.TrimSpace(sb.String()), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		samples++
+		if !strings.Contains(line, `env="prod"`) || !strings.Contains(line, `service="my-svc"`) {
+			t.Errorf("sample line missing constant labels: %s", line)
+		}
+	}
+	// 1 counter + 1 gauge + 2 buckets, _sum and _count
+	if samples != 6 {
+		t.Errorf("expected 6 sample lines, got %d:\n%s", samples, sb.String())
+	}
+	if !strings.Contains(sb.String(), `requests_total{method="GET",env="prod",service="my-svc"} 1`) {
+		t.Errorf("expected constant labels after declared labels, got:\n%s", sb.String())
+	}
+}
+
+func TestMetricRegistry_LabelValuesWithCommas(t *testing.T) {
+	registry := NewMetricRegistry().WithConstLabels(map[string]string{"env": "prod"})
+	requests := NewCounter(MetricOpts{
+		Name:   "requests_total",
+		Help:   "Total requests",
+		Labels: []string{"path", "method"},
+	})
+	registry.MustRegister(requests)
+
+	requests.Inc("/search?q=a,b", "GET")
+
+	var sb strings.Builder
+	if err := registry.WritePrometheus(&sb); err != nil {
+		t.Fatalf("WritePrometheus: %v", err)
+	}
+	want := `requests_total{path="/search?q=a,b",method="GET",env="prod"} 1`
+	if !strings.Contains(sb.String(), want) {
+		t.Errorf("expected %s in output:\n%s", want, sb.String())
+	}
+}
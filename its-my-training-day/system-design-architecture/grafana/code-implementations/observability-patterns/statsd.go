@@ -0,0 +1,269 @@
+// Package observability provides a StatsD exporter for metrics and spans.
+//
+// This file demonstrates:
+// - Encoding counters, gauges, and histograms in the StatsD line protocol
+// - Converting cumulative Prometheus-style values into StatsD deltas
+// - Exporting span durations as StatsD timers
+// - A UDP client that reconnects after write errors
+//
+// Labels are sent as DogStatsD-style tags (|#key:value), which Telegraf's
+// statsd input and the Datadog agent both understand.
+package observability
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// statsdMaxPacketSize keeps datagrams under the typical Ethernet MTU
+// (1500 bytes minus IP and UDP headers) to avoid fragmentation.
+const statsdMaxPacketSize = 1432
+
+// StatsDExporter sends metrics and span durations to a StatsD server over UDP.
+// It implements both MetricExporter and SpanExporter.
+//
+// Counter and histogram values are cumulative in this package, while StatsD
+// expects per-flush increments, so the exporter remembers the last value it
+// sent for each series and only sends the difference.
+type StatsDExporter struct {
+	addr   string
+	prefix string
+	conn   net.Conn
+
+	// Previously flushed cumulative values, keyed by series
+	lastCounters   map[string]float64
+	lastHistograms map[string][]uint64
+
+	mu sync.Mutex
+}
+
+// NewStatsDExporter creates an exporter that sends to addr (host:port).
+// Every metric name is prefixed with prefix and a dot, unless prefix is empty.
+// The UDP socket is opened lazily on the first flush.
+func NewStatsDExporter(addr, prefix string) *StatsDExporter {
+	return &StatsDExporter{
+		addr:           addr,
+		prefix:         prefix,
+		lastCounters:   make(map[string]float64),
+		lastHistograms: make(map[string][]uint64),
+	}
+}
+
+// Flush encodes metrics in the StatsD line protocol and sends them:
+// - Counters as prefix.name:delta|c
+// - Gauges as prefix.name:value|g
+// - Histograms as one prefix.name:boundary_ms|ms line per bucket boundary
+//
+// Histogram lines carry a sample rate of 1/n, where n is the number of new
+// observations in that bucket, so the server counts each bucket n times.
+// Bucket boundaries are assumed to be in seconds (as in
+// DefaultHistogramBuckets); observations above the largest boundary are
+// reported at that boundary.
+func (e *StatsDExporter) Flush(metrics []ExportedMetric) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var lines []string
+	for _, m := range metrics {
+		name := e.metricName(m.Name)
+		tags := formatStatsDTags(m.Labels)
+		key := seriesKey(m.Name, m.Labels)
+
+		switch m.Type {
+		case CounterMetric:
+			delta := m.Value - e.lastCounters[key]
+			if delta < 0 {
+				// Counter was reset; everything since is new
+				delta = m.Value
+			}
+			e.lastCounters[key] = m.Value
+			if delta == 0 {
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("%s:%s|c%s", name, formatFloat(delta), tags))
+
+		case GaugeMetric:
+			lines = append(lines, fmt.Sprintf("%s:%s|g%s", name, formatFloat(m.Value), tags))
+
+		case HistogramMetric:
+			lines = append(lines, e.histogramLines(name, tags, key, m)...)
+		}
+	}
+
+	return e.send(lines)
+}
+
+// histogramLines converts new histogram observations into timer lines.
+// Caller must hold e.mu.
+func (e *StatsDExporter) histogramLines(name, tags, key string, m ExportedMetric) []string {
+	if len(m.Buckets) == 0 {
+		return nil
+	}
+
+	last := e.lastHistograms[key]
+	if len(last) != len(m.BucketCounts) || (len(last) > 0 && m.BucketCounts[len(last)-1] < last[len(last)-1]) {
+		// First flush or histogram was reset
+		last = make([]uint64, len(m.BucketCounts))
+	}
+	e.lastHistograms[key] = append([]uint64(nil), m.BucketCounts...)
+
+	// Convert cumulative deltas into per-bucket counts
+	perBucket := make([]uint64, len(m.Buckets))
+	var prev uint64
+	for i, cum := range m.BucketCounts {
+		delta := cum - last[i]
+		n := delta - prev
+		prev = delta
+		if i >= len(m.Buckets) {
+			// +Inf bucket is folded into the largest boundary
+			perBucket[len(m.Buckets)-1] += n
+			continue
+		}
+		perBucket[i] += n
+	}
+
+	var lines []string
+	for i, n := range perBucket {
+		if n == 0 {
+			continue
+		}
+		line := fmt.Sprintf("%s:%s|ms", name, formatFloat(m.Buckets[i]*1000))
+		if n > 1 {
+			line += "|@" + strconv.FormatFloat(1/float64(n), 'g', -1, 64)
+		}
+		lines = append(lines, line+tags)
+	}
+	return lines
+}
+
+// Export sends the duration of each span as a StatsD timer named
+// prefix.span.<span name>, tagged with the span kind and status.
+func (e *StatsDExporter) Export(spans []*Span) error {
+	lines := make([]string, 0, len(spans))
+	for _, span := range spans {
+		// Snapshot everything in one critical section so the duration
+		// matches the name and status even if the span is still ending
+		span.mu.Lock()
+		name, kind, status := span.Name, span.Kind, span.Status
+		start, end := span.StartTime, span.EndTime
+		span.mu.Unlock()
+
+		if end.IsZero() {
+			end = time.Now()
+		}
+		ms := float64(end.Sub(start).Microseconds()) / 1000
+		tags := formatStatsDTags(map[string]string{
+			"kind":   kind.String(),
+			"status": status.String(),
+		})
+		lines = append(lines, fmt.Sprintf("%s:%s|ms%s",
+			e.metricName("span."+sanitizeStatsD(name)), formatFloat(ms), tags))
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.send(lines)
+}
+
+// Close closes the underlying UDP socket.
+func (e *StatsDExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.conn == nil {
+		return nil
+	}
+	err := e.conn.Close()
+	e.conn = nil
+	return err
+}
+
+// send packs lines into datagrams and writes them. Caller must hold e.mu.
+func (e *StatsDExporter) send(lines []string) error {
+	var packet strings.Builder
+	for _, line := range lines {
+		if packet.Len() > 0 && packet.Len()+1+len(line) > statsdMaxPacketSize {
+			if err := e.write([]byte(packet.String())); err != nil {
+				return err
+			}
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	if packet.Len() == 0 {
+		return nil
+	}
+	return e.write([]byte(packet.String()))
+}
+
+// write sends a single datagram, redialing once if the write fails.
+// UDP writes fail when, for example, the previous send triggered an ICMP
+// port-unreachable while the StatsD agent was restarting.
+// Caller must hold e.mu.
+func (e *StatsDExporter) write(packet []byte) error {
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if e.conn == nil {
+			e.conn, err = net.Dial("udp", e.addr)
+			if err != nil {
+				e.conn = nil
+				continue
+			}
+		}
+		if _, err = e.conn.Write(packet); err == nil {
+			return nil
+		}
+		e.conn.Close()
+		e.conn = nil
+	}
+	return fmt.Errorf("statsd: failed to send to %s: %w", e.addr, err)
+}
+
+// metricName applies the exporter prefix to a sanitized metric name.
+func (e *StatsDExporter) metricName(name string) string {
+	name = sanitizeStatsD(name)
+	if e.prefix == "" {
+		return name
+	}
+	return e.prefix + "." + name
+}
+
+// formatStatsDTags renders labels as a DogStatsD tag suffix, sorted by key.
+func formatStatsDTags(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	keys := sortedKeys(labels)
+	tags := make([]string, len(keys))
+	for i, k := range keys {
+		tags[i] = sanitizeStatsD(k) + ":" + sanitizeStatsD(labels[k])
+	}
+	return "|#" + strings.Join(tags, ",")
+}
+
+// sanitizeStatsD replaces characters that are part of the line protocol.
+func sanitizeStatsD(s string) string {
+	return strings.NewReplacer(
+		":", "_", "|", "_", "@", "_", "#", "_", ",", "_", " ", "_", "\n", "_",
+	).Replace(s)
+}
+
+// seriesKey identifies a metric series for delta tracking.
+func seriesKey(name string, labels map[string]string) string {
+	var b strings.Builder
+	b.WriteString(name)
+	for _, k := range sortedKeys(labels) {
+		b.WriteByte(0)
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(labels[k])
+	}
+	return b.String()
+}
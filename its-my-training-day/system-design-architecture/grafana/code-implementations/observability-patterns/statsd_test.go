@@ -0,0 +1,157 @@
+// Package observability provides tests for the StatsD exporter.
+package observability
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listenStatsD starts a UDP listener and returns its address and a function
+// that reads all lines received within the timeout.
+func listenStatsD(t *testing.T) (string, func() []string) {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	read := func() []string {
+		var lines []string
+		buf := make([]byte, 65536)
+		for {
+			conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				break
+			}
+			lines = append(lines, strings.Split(string(buf[:n]), "\n")...)
+		}
+		sort.Strings(lines)
+		return lines
+	}
+	return conn.LocalAddr().String(), read
+}
+
+func TestStatsDExporter_Flush(t *testing.T) {
+	addr, read := listenStatsD(t)
+	exporter := NewStatsDExporter(addr, "grafana")
+	defer exporter.Close()
+
+	registry := NewMetricRegistry()
+	requests := NewCounter(MetricOpts{Name: "requests_total", Labels: []string{"method"}})
+	inflight := NewGauge(MetricOpts{Name: "in_flight"})
+	latency := NewHistogram(MetricOpts{Name: "latency_seconds", Buckets: []float64{0.1, 0.5}})
+	registry.MustRegister(requests, inflight, latency)
+
+	requests.Add(3, "GET")
+	inflight.Set(7)
+	latency.Observe(0.05)
+	latency.Observe(0.07)
+	latency.Observe(0.3)
+
+	if err := exporter.Flush(registry.Export()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	got := read()
+	want := []string{
+		"grafana.in_flight:7|g",
+		"grafana.latency_seconds:100|ms|@0.5",
+		"grafana.latency_seconds:500|ms",
+		"grafana.requests_total:3|c|#method:GET",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected datagrams:\ngot:  %q\nwant: %q", got, want)
+	}
+}
+
+func TestStatsDExporter_SendsDeltas(t *testing.T) {
+	addr, read := listenStatsD(t)
+	exporter := NewStatsDExporter(addr, "")
+	defer exporter.Close()
+
+	registry := NewMetricRegistry()
+	requests := NewCounter(MetricOpts{Name: "requests_total"})
+	registry.MustRegister(requests)
+
+	requests.Add(5)
+	if err := exporter.Flush(registry.Export()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	read()
+
+	requests.Add(2)
+	if err := exporter.Flush(registry.Export()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	got := read()
+	if len(got) != 1 || got[0] != "requests_total:2|c" {
+		t.Errorf("expected delta of 2, got %q", got)
+	}
+
+	// Nothing changed, so nothing is sent
+	if err := exporter.Flush(registry.Export()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if got := read(); len(got) != 0 {
+		t.Errorf("expected no datagrams for unchanged counter, got %q", got)
+	}
+}
+
+func TestStatsDExporter_ExportSpans(t *testing.T) {
+	addr, read := listenStatsD(t)
+	exporter := NewStatsDExporter(addr, "app")
+	defer exporter.Close()
+
+	start := time.Now()
+	span := &Span{
+		Name:      "db query",
+		Kind:      SpanKindClient,
+		Status:    SpanStatusError,
+		StartTime: start,
+		EndTime:   start.Add(25 * time.Millisecond),
+	}
+
+	if err := exporter.Export([]*Span{span}); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	got := read()
+	want := "app.span.db_query:25|ms|#kind:client,status:error"
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestStatsDExporter_Reconnect(t *testing.T) {
+	addr, read := listenStatsD(t)
+	exporter := NewStatsDExporter(addr, "")
+	defer exporter.Close()
+
+	registry := NewMetricRegistry()
+	gauge := NewGauge(MetricOpts{Name: "up"})
+	registry.MustRegister(gauge)
+	gauge.Set(1)
+
+	if err := exporter.Flush(registry.Export()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	read()
+
+	// Simulate a broken socket; the next flush should redial
+	exporter.mu.Lock()
+	exporter.conn.Close()
+	exporter.mu.Unlock()
+
+	if err := exporter.Flush(registry.Export()); err != nil {
+		t.Fatalf("Flush after connection loss: %v", err)
+	}
+	if got := read(); len(got) != 1 || got[0] != "up:1|g" {
+		t.Errorf("expected gauge after reconnect, got %q", got)
+	}
+}
@@ -0,0 +1,225 @@
+// Package observability provides errors that carry HTTP and gRPC status codes.
+//
+// This file demonstrates:
+// - A StatusError type that maps one failure to HTTP, gRPC, and application codes
+// - Constructors for the common failure classes
+// - Deriving a response status by unwrapping the error chain
+//
+// Deciding the status code where the error is created (e.g., the storage layer
+// knows a tenant was not found) and deriving it at the edge keeps handlers free
+// of status-code switch statements.
+package observability
+
+import (
+	"errors"
+	"net/http"
+)
+
+// GRPCCode is a gRPC status code. The values match
+// google.golang.org/grpc/codes.Code, so they can be converted directly with
+// codes.Code(c) in services that depend on gRPC.
+type GRPCCode uint32
+
+const (
+	GRPCOK                 GRPCCode = 0
+	GRPCCanceled           GRPCCode = 1
+	GRPCUnknown            GRPCCode = 2
+	GRPCInvalidArgument    GRPCCode = 3
+	GRPCDeadlineExceeded   GRPCCode = 4
+	GRPCNotFound           GRPCCode = 5
+	GRPCAlreadyExists      GRPCCode = 6
+	GRPCPermissionDenied   GRPCCode = 7
+	GRPCResourceExhausted  GRPCCode = 8
+	GRPCFailedPrecondition GRPCCode = 9
+	GRPCAborted            GRPCCode = 10
+	GRPCOutOfRange         GRPCCode = 11
+	GRPCUnimplemented      GRPCCode = 12
+	GRPCInternal           GRPCCode = 13
+	GRPCUnavailable        GRPCCode = 14
+	GRPCDataLoss           GRPCCode = 15
+	GRPCUnauthenticated    GRPCCode = 16
+)
+
+// String returns the canonical gRPC code name.
+func (c GRPCCode) String() string {
+	switch c {
+	case GRPCOK:
+		return "OK"
+	case GRPCCanceled:
+		return "Canceled"
+	case GRPCUnknown:
+		return "Unknown"
+	case GRPCInvalidArgument:
+		return "InvalidArgument"
+	case GRPCDeadlineExceeded:
+		return "DeadlineExceeded"
+	case GRPCNotFound:
+		return "NotFound"
+	case GRPCAlreadyExists:
+		return "AlreadyExists"
+	case GRPCPermissionDenied:
+		return "PermissionDenied"
+	case GRPCResourceExhausted:
+		return "ResourceExhausted"
+	case GRPCFailedPrecondition:
+		return "FailedPrecondition"
+	case GRPCAborted:
+		return "Aborted"
+	case GRPCOutOfRange:
+		return "OutOfRange"
+	case GRPCUnimplemented:
+		return "Unimplemented"
+	case GRPCInternal:
+		return "Internal"
+	case GRPCUnavailable:
+		return "Unavailable"
+	case GRPCDataLoss:
+		return "DataLoss"
+	case GRPCUnauthenticated:
+		return "Unauthenticated"
+	default:
+		return "Unknown"
+	}
+}
+
+// StatusError is an error that knows how it should be reported to clients.
+//
+// Message is safe to return to the caller; Err holds the underlying cause,
+// which is logged but typically not exposed.
+type StatusError struct {
+	// Code is an application-specific error code (0 if unused)
+	Code int
+	// HTTPStatus is the HTTP status code to respond with
+	HTTPStatus int
+	// GRPCCode is the gRPC status code to respond with
+	GRPCCode GRPCCode
+	// Message is the client-facing error message
+	Message string
+	// Err is the underlying cause
+	Err error
+}
+
+// Error implements the error interface.
+func (e *StatusError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// Unwrap returns the underlying cause.
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// newStatusError creates a StatusError with the given status codes.
+func newStatusError(httpStatus int, grpcCode GRPCCode, msg string, err error) *StatusError {
+	return &StatusError{
+		HTTPStatus: httpStatus,
+		GRPCCode:   grpcCode,
+		Message:    msg,
+		Err:        err,
+	}
+}
+
+// BadRequest returns an error for invalid client input (400, InvalidArgument).
+func BadRequest(msg string, err error) *StatusError {
+	return newStatusError(http.StatusBadRequest, GRPCInvalidArgument, msg, err)
+}
+
+// Unauthorized returns an error for missing or invalid credentials
+// (401, Unauthenticated).
+func Unauthorized(msg string, err error) *StatusError {
+	return newStatusError(http.StatusUnauthorized, GRPCUnauthenticated, msg, err)
+}
+
+// Forbidden returns an error for authenticated callers lacking permission
+// (403, PermissionDenied).
+func Forbidden(msg string, err error) *StatusError {
+	return newStatusError(http.StatusForbidden, GRPCPermissionDenied, msg, err)
+}
+
+// NotFound returns an error for a missing resource (404, NotFound).
+func NotFound(msg string, err error) *StatusError {
+	return newStatusError(http.StatusNotFound, GRPCNotFound, msg, err)
+}
+
+// Conflict returns an error for a resource that already exists (409, AlreadyExists).
+func Conflict(msg string, err error) *StatusError {
+	return newStatusError(http.StatusConflict, GRPCAlreadyExists, msg, err)
+}
+
+// TooManyRequests returns an error for a rate-limited or over-quota caller
+// (429, ResourceExhausted).
+func TooManyRequests(msg string, err error) *StatusError {
+	return newStatusError(http.StatusTooManyRequests, GRPCResourceExhausted, msg, err)
+}
+
+// Internal returns an error for an unexpected server failure (500, Internal).
+func Internal(msg string, err error) *StatusError {
+	return newStatusError(http.StatusInternalServerError, GRPCInternal, msg, err)
+}
+
+// Unavailable returns an error for a temporarily unavailable dependency
+// (503, Unavailable). Clients are expected to retry.
+func Unavailable(msg string, err error) *StatusError {
+	return newStatusError(http.StatusServiceUnavailable, GRPCUnavailable, msg, err)
+}
+
+// Timeout returns an error for an operation that exceeded its deadline
+// (504, DeadlineExceeded).
+func Timeout(msg string, err error) *StatusError {
+	return newStatusError(http.StatusGatewayTimeout, GRPCDeadlineExceeded, msg, err)
+}
+
+// HTTPStatusFromError returns the HTTP status of the first StatusError in
+// err's chain. It returns 200 for a nil error and 500 if no StatusError is found.
+func HTTPStatusFromError(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	var se *StatusError
+	if errors.As(err, &se) && se.HTTPStatus != 0 {
+		return se.HTTPStatus
+	}
+	return http.StatusInternalServerError
+}
+
+// GRPCCodeFromError returns the gRPC code of the first StatusError in err's
+// chain. It returns OK for a nil error and Unknown if no StatusError is found.
+func GRPCCodeFromError(err error) GRPCCode {
+	if err == nil {
+		return GRPCOK
+	}
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.GRPCCode
+	}
+	return GRPCUnknown
+}
+
+// AppHandler is an HTTP handler that returns an error instead of writing
+// error responses itself. When wrapped by ObservabilityMiddleware, the
+// returned error is recorded on the request span and its status code is
+// derived with HTTPStatusFromError.
+type AppHandler func(w http.ResponseWriter, r *http.Request) error
+
+// ServeHTTP implements http.Handler for use without the middleware.
+func (h AppHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := h(w, r); err != nil {
+		writeStatusError(w, err)
+	}
+}
+
+// writeStatusError writes the client-facing message for err.
+// Errors without a StatusError in their chain get a generic message so
+// internal details are not leaked.
+func writeStatusError(w http.ResponseWriter, err error) {
+	status := HTTPStatusFromError(err)
+	msg := http.StatusText(status)
+	var se *StatusError
+	if errors.As(err, &se) && se.Message != "" {
+		msg = se.Message
+	}
+	http.Error(w, msg, status)
+}
@@ -0,0 +1,107 @@
+// Package observability provides tests for status-code carrying errors.
+package observability
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPStatusFromError(t *testing.T) {
+	cause := errors.New("row not found")
+
+	tests := []struct {
+		name     string
+		err      error
+		wantHTTP int
+		wantGRPC GRPCCode
+	}{
+		{name: "nil", err: nil, wantHTTP: http.StatusOK, wantGRPC: GRPCOK},
+		{name: "plain error falls back", err: cause, wantHTTP: http.StatusInternalServerError, wantGRPC: GRPCUnknown},
+		{name: "not found", err: NotFound("dashboard not found", cause), wantHTTP: http.StatusNotFound, wantGRPC: GRPCNotFound},
+		{name: "unauthorized", err: Unauthorized("missing token", nil), wantHTTP: http.StatusUnauthorized, wantGRPC: GRPCUnauthenticated},
+		{name: "internal", err: Internal("query failed", cause), wantHTTP: http.StatusInternalServerError, wantGRPC: GRPCInternal},
+		{name: "too many requests", err: TooManyRequests("tenant over limit", nil), wantHTTP: http.StatusTooManyRequests, wantGRPC: GRPCResourceExhausted},
+		{
+			name:     "wrapped with fmt.Errorf",
+			err:      fmt.Errorf("loading dashboard: %w", NotFound("dashboard not found", cause)),
+			wantHTTP: http.StatusNotFound,
+			wantGRPC: GRPCNotFound,
+		},
+		{
+			name:     "wrapped in ObservabilityError",
+			err:      WrapError(context.Background(), Forbidden("read-only tenant", nil), "SaveDashboard", nil),
+			wantHTTP: http.StatusForbidden,
+			wantGRPC: GRPCPermissionDenied,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTTPStatusFromError(tt.err); got != tt.wantHTTP {
+				t.Errorf("HTTPStatusFromError() = %d, want %d", got, tt.wantHTTP)
+			}
+			if got := GRPCCodeFromError(tt.err); got != tt.wantGRPC {
+				t.Errorf("GRPCCodeFromError() = %v, want %v", got, tt.wantGRPC)
+			}
+		})
+	}
+}
+
+func TestStatusError_Unwrap(t *testing.T) {
+	cause := errors.New("connection refused")
+	err := Unavailable("ingester unavailable", cause)
+
+	if !errors.Is(err, cause) {
+		t.Error("StatusError should unwrap to its cause")
+	}
+	if err.Error() != "ingester unavailable: connection refused" {
+		t.Errorf("Error() = %q", err.Error())
+	}
+	if NotFound("missing", nil).Error() != "missing" {
+		t.Error("Error() without a cause should be the message")
+	}
+}
+
+func TestObservabilityMiddleware_AppHandlerStatus(t *testing.T) {
+	middleware := NewObservabilityMiddleware("test-service")
+
+	handler := AppHandler(func(w http.ResponseWriter, r *http.Request) error {
+		return fmt.Errorf("lookup: %w", NotFound("dashboard not found", errors.New("sql: no rows")))
+	})
+
+	req := httptest.NewRequest("GET", "/api/dashboards/42", nil)
+	rec := httptest.NewRecorder()
+	middleware.Handler(handler).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Response code = %v, want %v", rec.Code, http.StatusNotFound)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "dashboard not found") {
+		t.Errorf("expected client-facing message in body, got %q", body)
+	}
+	if strings.Contains(body, "sql: no rows") {
+		t.Errorf("underlying cause should not be exposed, got %q", body)
+	}
+}
+
+func TestAppHandler_PlainErrorIs500(t *testing.T) {
+	handler := AppHandler(func(w http.ResponseWriter, r *http.Request) error {
+		return errors.New("secret internal detail")
+	})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Response code = %v, want %v", rec.Code, http.StatusInternalServerError)
+	}
+	if strings.Contains(rec.Body.String(), "secret") {
+		t.Errorf("plain errors should not be exposed, got %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,106 @@
+//go:build !windows && !plan9
+
+// Package observability provides a syslog writer for the structured Logger.
+//
+// This file demonstrates:
+// - Adapting log/syslog to the io.Writer the Logger writes to
+// - Mapping LogLevel to RFC 5424 severities
+// - Parsing syslog facility names
+//
+// log/syslog is not available on Windows or Plan 9, hence the build tag.
+package observability
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/syslog"
+	"strings"
+)
+
+// syslogFacilities maps facility names, as used in syslog.conf, to priorities.
+var syslogFacilities = map[string]syslog.Priority{
+	"kern":     syslog.LOG_KERN,
+	"user":     syslog.LOG_USER,
+	"mail":     syslog.LOG_MAIL,
+	"daemon":   syslog.LOG_DAEMON,
+	"auth":     syslog.LOG_AUTH,
+	"syslog":   syslog.LOG_SYSLOG,
+	"lpr":      syslog.LOG_LPR,
+	"news":     syslog.LOG_NEWS,
+	"uucp":     syslog.LOG_UUCP,
+	"cron":     syslog.LOG_CRON,
+	"authpriv": syslog.LOG_AUTHPRIV,
+	"ftp":      syslog.LOG_FTP,
+	"local0":   syslog.LOG_LOCAL0,
+	"local1":   syslog.LOG_LOCAL1,
+	"local2":   syslog.LOG_LOCAL2,
+	"local3":   syslog.LOG_LOCAL3,
+	"local4":   syslog.LOG_LOCAL4,
+	"local5":   syslog.LOG_LOCAL5,
+	"local6":   syslog.LOG_LOCAL6,
+	"local7":   syslog.LOG_LOCAL7,
+}
+
+// syslogWriter sends each log entry to syslog with the severity matching
+// the entry's level.
+type syslogWriter struct {
+	w *syslog.Writer
+}
+
+// NewSyslogWriter connects to the syslog daemon at addr over network
+// ("udp", "tcp", or "" with an empty addr for the local daemon) and returns
+// a writer to pass to WithOutput. Entries are sent under facility
+// (e.g. "daemon", "local0") with a severity derived from their level:
+//
+//	DebugLevel -> LOG_DEBUG
+//	InfoLevel  -> LOG_INFO
+//	WarnLevel  -> LOG_WARNING
+//	ErrorLevel -> LOG_ERR
+//	FatalLevel -> LOG_CRIT
+func NewSyslogWriter(network, addr, facility string) (io.Writer, error) {
+	priority, ok := syslogFacilities[strings.ToLower(facility)]
+	if !ok {
+		return nil, fmt.Errorf("unknown syslog facility %q", facility)
+	}
+
+	w, err := syslog.Dial(network, addr, priority|syslog.LOG_INFO, "")
+	if err != nil {
+		return nil, err
+	}
+	return &syslogWriter{w: w}, nil
+}
+
+// Write sends one JSON log entry. Input that is not a log entry is sent
+// at LOG_INFO.
+func (s *syslogWriter) Write(p []byte) (int, error) {
+	var entry struct {
+		Level string `json:"level"`
+	}
+	_ = json.Unmarshal(p, &entry)
+
+	msg := strings.TrimSuffix(string(p), "\n")
+
+	var err error
+	switch entry.Level {
+	case DebugLevel.String():
+		err = s.w.Debug(msg)
+	case WarnLevel.String():
+		err = s.w.Warning(msg)
+	case ErrorLevel.String():
+		err = s.w.Err(msg)
+	case FatalLevel.String():
+		err = s.w.Crit(msg)
+	default:
+		err = s.w.Info(msg)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close closes the connection to the syslog daemon.
+func (s *syslogWriter) Close() error {
+	return s.w.Close()
+}
@@ -0,0 +1,70 @@
+//go:build !windows && !plan9
+
+// Package observability provides tests for the syslog writer.
+package observability
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/syslog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogWriter_PriorityMapping(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	w, err := NewSyslogWriter("udp", conn.LocalAddr().String(), "local0")
+	if err != nil {
+		t.Fatalf("NewSyslogWriter failed: %v", err)
+	}
+	defer w.(io.Closer).Close()
+
+	logger := NewLogger("test-service", WithOutput(w), WithLevel(DebugLevel))
+	ctx := context.Background()
+
+	// The packet starts with <PRI>, where PRI = facility|severity
+	tests := []struct {
+		log      func(msg string)
+		severity syslog.Priority
+	}{
+		{func(msg string) { logger.Error(ctx, msg, nil, nil) }, syslog.LOG_ERR},
+		{func(msg string) { logger.Info(ctx, msg, nil) }, syslog.LOG_INFO},
+		{func(msg string) { logger.Warn(ctx, msg, nil) }, syslog.LOG_WARNING},
+		{func(msg string) { logger.Debug(ctx, msg, nil) }, syslog.LOG_DEBUG},
+	}
+
+	buf := make([]byte, 4096)
+	for i, tt := range tests {
+		msg := fmt.Sprintf("message %d", i)
+		tt.log(msg)
+
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("no syslog packet for %q: %v", msg, err)
+		}
+		packet := string(buf[:n])
+
+		wantPrefix := fmt.Sprintf("<%d>", syslog.LOG_LOCAL0|tt.severity)
+		if !strings.HasPrefix(packet, wantPrefix) {
+			t.Errorf("packet %q: want priority prefix %s", packet, wantPrefix)
+		}
+		if !strings.Contains(packet, `"message":"`+msg+`"`) {
+			t.Errorf("packet %q does not contain the JSON entry", packet)
+		}
+	}
+}
+
+func TestNewSyslogWriter_UnknownFacility(t *testing.T) {
+	if _, err := NewSyslogWriter("udp", "127.0.0.1:514", "local9"); err == nil {
+		t.Error("expected error for unknown facility")
+	}
+}
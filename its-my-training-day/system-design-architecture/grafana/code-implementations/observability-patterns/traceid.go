@@ -0,0 +1,90 @@
+// Package observability provides a trace ID type with the encodings used
+// across tracing systems.
+//
+// This file demonstrates:
+// - Generating random 128-bit trace IDs
+// - Formatting them as hex (W3C, Tempo), base64url, or UUID
+// - Parsing any of those formats back into the same ID
+//
+// Tempo and the W3C traceparent header use 32 lowercase hex characters, but
+// some log pipelines and vendor APIs store trace IDs as UUIDs or compact
+// base64url strings. Converting through TraceID keeps them correlatable.
+package observability
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// TraceID is a 128-bit W3C trace ID.
+type TraceID [16]byte
+
+// ErrInvalidTraceID is returned by ParseTraceID for malformed or all-zero IDs.
+var ErrInvalidTraceID = errors.New("invalid trace ID")
+
+// NewTraceID returns a random, non-zero trace ID.
+func NewTraceID() TraceID {
+	var id TraceID
+	for id.IsZero() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+// IsZero reports whether id is the all-zero ID, which W3C Trace Context
+// reserves as invalid.
+func (id TraceID) IsZero() bool {
+	return id == TraceID{}
+}
+
+// String returns the ID as 32 lowercase hex characters.
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// Base64 returns the ID as 22 characters of unpadded base64url.
+func (id TraceID) Base64() string {
+	return base64.RawURLEncoding.EncodeToString(id[:])
+}
+
+// UUID returns the ID in the 8-4-4-4-12 UUID layout.
+func (id TraceID) UUID() string {
+	h := id.String()
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+// ParseTraceID parses a trace ID in any format produced by TraceID,
+// detected from its length: 32 hex characters, a 36-character UUID, or 22
+// characters of unpadded base64url. Hex digits may be upper or lower case.
+func ParseTraceID(s string) (TraceID, error) {
+	var id TraceID
+	var raw []byte
+	var err error
+
+	switch len(s) {
+	case 32:
+		raw, err = hex.DecodeString(s)
+	case 36:
+		if s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+			return id, fmt.Errorf("%w: %q is not a UUID", ErrInvalidTraceID, s)
+		}
+		raw, err = hex.DecodeString(strings.ReplaceAll(s, "-", ""))
+	case 22:
+		raw, err = base64.RawURLEncoding.DecodeString(s)
+	default:
+		return id, fmt.Errorf("%w: %q has unrecognized length %d", ErrInvalidTraceID, s, len(s))
+	}
+	if err != nil || len(raw) != len(id) {
+		return id, fmt.Errorf("%w: %q", ErrInvalidTraceID, s)
+	}
+
+	copy(id[:], raw)
+	if id.IsZero() {
+		return id, fmt.Errorf("%w: all-zero ID", ErrInvalidTraceID)
+	}
+	return id, nil
+}
@@ -0,0 +1,72 @@
+// Package observability provides tests for the TraceID type.
+package observability
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTraceID_Formats(t *testing.T) {
+	id, err := ParseTraceID("4bf92f3577b34da6a3ce929d0e0e4736")
+	if err != nil {
+		t.Fatalf("ParseTraceID: %v", err)
+	}
+	if got := id.UUID(); got != "4bf92f35-77b3-4da6-a3ce-929d0e0e4736" {
+		t.Errorf("UUID() = %s", got)
+	}
+	if got := id.Base64(); got != "S_kvNXezTaajzpKdDg5HNg" {
+		t.Errorf("Base64() = %s", got)
+	}
+}
+
+func TestTraceID_RoundTrip(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		id := NewTraceID()
+		for format, s := range map[string]string{
+			"hex":       id.String(),
+			"uppercase": strings.ToUpper(id.String()),
+			"base64url": id.Base64(),
+			"uuid":      id.UUID(),
+		} {
+			parsed, err := ParseTraceID(s)
+			if err != nil {
+				t.Fatalf("ParseTraceID(%s %q): %v", format, s, err)
+			}
+			if parsed != id {
+				t.Fatalf("ParseTraceID(%s %q) = %s, want %s", format, s, parsed, id)
+			}
+		}
+	}
+}
+
+func TestParseTraceID_RejectsInvalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"4bf92f3577b34da6",                     // 64-bit ID
+		"4bf92f3577b34da6a3ce929d0e0e473g",     // non-hex digit
+		"00000000000000000000000000000000",     // all zeros
+		"4bf92f35-77b34da6-a3ce-929d0e0e4736-", // dashes in the wrong places
+		"4bf92f35-77b3-4da6-a3ce-929d0e0e47-6", // extra dash
+		"S_kvNXezTaajzpKdDg5H+g",               // standard base64 alphabet
+	} {
+		if _, err := ParseTraceID(s); !errors.Is(err, ErrInvalidTraceID) {
+			t.Errorf("ParseTraceID(%q) error = %v, want ErrInvalidTraceID", s, err)
+		}
+	}
+}
+
+func TestTracer_NormalizesTraceID(t *testing.T) {
+	tracer := NewTracer(TracerConfig{ServiceName: "test"})
+
+	_, root := tracer.StartSpan(context.Background(), "root", SpanKindServer)
+	if _, err := ParseTraceID(root.TraceID); err != nil || len(root.TraceID) != 32 {
+		t.Errorf("new trace ID %q is not 32 hex characters", root.TraceID)
+	}
+
+	ctx := context.WithValue(context.Background(), TraceIDKey, "4bf92f35-77b3-4da6-a3ce-929d0e0e4736")
+	if _, span := tracer.StartSpan(ctx, "child", SpanKindServer); span.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("TraceID = %q, want UUID normalized to hex", span.TraceID)
+	}
+}
@@ -0,0 +1,167 @@
+// Package observability provides a Zipkin span exporter.
+//
+// This file demonstrates:
+// - Converting spans to the Zipkin JSON v2 model
+// - Sending span batches over HTTP to a Zipkin-compatible collector
+//
+// Besides Zipkin itself, Grafana Tempo and the OpenTelemetry Collector both
+// accept this format on /api/v2/spans, which makes it a simple way to ship
+// traces from services that cannot use OTLP.
+package observability
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ZipkinOptions configures a ZipkinExporter.
+type ZipkinOptions struct {
+	// ServiceName is reported as the localEndpoint of every span. When
+	// empty, each span's service.name attribute is used instead.
+	ServiceName string
+	// Timeout bounds each export request (default 10s)
+	Timeout time.Duration
+}
+
+// ZipkinExporter sends spans to a Zipkin collector in the JSON v2 format.
+// It implements SpanExporter.
+type ZipkinExporter struct {
+	url         string
+	serviceName string
+	client      *http.Client
+}
+
+// NewZipkinExporter creates an exporter that posts to
+// {endpoint}/api/v2/spans (e.g. "http://tempo:9411").
+func NewZipkinExporter(endpoint string, opts ZipkinOptions) *ZipkinExporter {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	return &ZipkinExporter{
+		url:         strings.TrimRight(endpoint, "/") + "/api/v2/spans",
+		serviceName: opts.ServiceName,
+		client:      &http.Client{Timeout: opts.Timeout},
+	}
+}
+
+// zipkinSpan is a span in the Zipkin JSON v2 model.
+// Timestamps and durations are in microseconds since the epoch.
+type zipkinSpan struct {
+	TraceID       string             `json:"traceId"`
+	ID            string             `json:"id"`
+	ParentID      string             `json:"parentId,omitempty"`
+	Name          string             `json:"name"`
+	Kind          string             `json:"kind,omitempty"`
+	Timestamp     int64              `json:"timestamp"`
+	Duration      int64              `json:"duration,omitempty"`
+	LocalEndpoint *zipkinEndpoint    `json:"localEndpoint,omitempty"`
+	Tags          map[string]string  `json:"tags,omitempty"`
+	Annotations   []zipkinAnnotation `json:"annotations,omitempty"`
+}
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName"`
+}
+
+type zipkinAnnotation struct {
+	Timestamp int64  `json:"timestamp"`
+	Value     string `json:"value"`
+}
+
+// zipkinKinds maps span kinds to Zipkin's. Internal spans have no kind.
+var zipkinKinds = map[SpanKind]string{
+	SpanKindServer:   "SERVER",
+	SpanKindClient:   "CLIENT",
+	SpanKindProducer: "PRODUCER",
+	SpanKindConsumer: "CONSUMER",
+}
+
+// Export converts spans to Zipkin JSON v2 and posts them in one request.
+func (e *ZipkinExporter) Export(spans []*Span) error {
+	if len(spans) == 0 {
+		return nil
+	}
+
+	batch := make([]zipkinSpan, 0, len(spans))
+	for _, span := range spans {
+		batch = append(batch, e.toZipkin(span))
+	}
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("encoding spans: %w", err)
+	}
+
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("zipkin returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// toZipkin converts one span. Attributes become string tags, events become
+// annotations, and an error status sets the "error" tag as Zipkin expects.
+func (e *ZipkinExporter) toZipkin(span *Span) zipkinSpan {
+	span.mu.Lock()
+	defer span.mu.Unlock()
+
+	zs := zipkinSpan{
+		TraceID:   span.TraceID,
+		ID:        span.SpanID,
+		ParentID:  span.ParentSpanID,
+		Name:      span.Name,
+		Kind:      zipkinKinds[span.Kind],
+		Timestamp: span.StartTime.UnixMicro(),
+	}
+
+	// Zipkin treats a zero duration as unknown, so round up to 1µs
+	if !span.EndTime.IsZero() {
+		zs.Duration = span.EndTime.Sub(span.StartTime).Microseconds()
+		if zs.Duration < 1 {
+			zs.Duration = 1
+		}
+	}
+	serviceName := e.serviceName
+	if serviceName == "" {
+		serviceName, _ = span.Attributes["service.name"].(string)
+	}
+	if serviceName != "" {
+		zs.LocalEndpoint = &zipkinEndpoint{ServiceName: serviceName}
+	}
+
+	if len(span.Attributes) > 0 || span.Status == SpanStatusError {
+		zs.Tags = make(map[string]string, len(span.Attributes)+1)
+		for k, v := range span.Attributes {
+			zs.Tags[k] = fmt.Sprint(v)
+		}
+		if span.Status == SpanStatusError {
+			zs.Tags["error"] = span.StatusMsg
+			if span.StatusMsg == "" {
+				zs.Tags["error"] = "true"
+			}
+		}
+	}
+
+	for _, event := range span.Events {
+		zs.Annotations = append(zs.Annotations, zipkinAnnotation{
+			Timestamp: event.Timestamp.UnixMicro(),
+			Value:     event.Name,
+		})
+	}
+	sort.SliceStable(zs.Annotations, func(i, j int) bool {
+		return zs.Annotations[i].Timestamp < zs.Annotations[j].Timestamp
+	})
+
+	return zs
+}
@@ -0,0 +1,150 @@
+// Package observability provides tests for the Zipkin exporter.
+package observability
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestZipkinExporter_Export(t *testing.T) {
+	var (
+		path        string
+		contentType string
+		received    []map[string]interface{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, contentType = r.URL.Path, r.Header.Get("Content-Type")
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &received); err != nil {
+			t.Errorf("invalid JSON body: %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	span := &Span{
+		TraceID:      "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanID:       "00f067aa0ba902b7",
+		ParentSpanID: "b7ad6b7169203331",
+		Name:         "GET /api/v1/query",
+		Kind:         SpanKindServer,
+		StartTime:    start,
+		EndTime:      start.Add(150 * time.Millisecond),
+		Status:       SpanStatusError,
+		StatusMsg:    "upstream timeout",
+		Attributes:   map[string]interface{}{"http.status_code": 504},
+		Events:       []SpanEvent{{Name: "cache miss", Timestamp: start.Add(10 * time.Millisecond)}},
+	}
+	internal := &Span{
+		TraceID:   span.TraceID,
+		SpanID:    "1111111111111111",
+		Name:      "parse",
+		StartTime: start,
+		EndTime:   start,
+	}
+
+	exporter := NewZipkinExporter(server.URL, ZipkinOptions{ServiceName: "querier"})
+	if err := exporter.Export([]*Span{span, internal}); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	if path != "/api/v2/spans" {
+		t.Errorf("path = %s, want /api/v2/spans", path)
+	}
+	if contentType != "application/json" {
+		t.Errorf("Content-Type = %s, want application/json", contentType)
+	}
+	if len(received) != 2 {
+		t.Fatalf("received %d spans, want 2", len(received))
+	}
+
+	got := received[0]
+	wantStrings := map[string]string{
+		"traceId":  "4bf92f3577b34da6a3ce929d0e0e4736",
+		"id":       "00f067aa0ba902b7",
+		"parentId": "b7ad6b7169203331",
+		"name":     "GET /api/v1/query",
+		"kind":     "SERVER",
+	}
+	for field, want := range wantStrings {
+		if v, ok := got[field].(string); !ok || v != want {
+			t.Errorf("%s = %#v, want %q", field, got[field], want)
+		}
+	}
+
+	// JSON numbers decode as float64
+	if v, ok := got["timestamp"].(float64); !ok || int64(v) != start.UnixMicro() {
+		t.Errorf("timestamp = %#v, want %d", got["timestamp"], start.UnixMicro())
+	}
+	if v, ok := got["duration"].(float64); !ok || v != 150000 {
+		t.Errorf("duration = %#v, want 150000", got["duration"])
+	}
+
+	endpoint, _ := got["localEndpoint"].(map[string]interface{})
+	if endpoint["serviceName"] != "querier" {
+		t.Errorf("localEndpoint = %#v, want serviceName querier", got["localEndpoint"])
+	}
+
+	tags, _ := got["tags"].(map[string]interface{})
+	if tags["http.status_code"] != "504" {
+		t.Errorf("tags[http.status_code] = %#v, want \"504\"", tags["http.status_code"])
+	}
+	if tags["error"] != "upstream timeout" {
+		t.Errorf("tags[error] = %#v, want \"upstream timeout\"", tags["error"])
+	}
+
+	annotations, _ := got["annotations"].([]interface{})
+	if len(annotations) != 1 {
+		t.Fatalf("annotations = %#v, want 1 entry", got["annotations"])
+	}
+	annotation := annotations[0].(map[string]interface{})
+	if annotation["value"] != "cache miss" || int64(annotation["timestamp"].(float64)) != start.Add(10*time.Millisecond).UnixMicro() {
+		t.Errorf("annotation = %#v", annotation)
+	}
+
+	// Internal spans have no kind or parent, and a zero duration becomes 1µs
+	second := received[1]
+	for _, field := range []string{"kind", "parentId", "tags", "annotations"} {
+		if _, ok := second[field]; ok {
+			t.Errorf("internal span should omit %s, got %#v", field, second[field])
+		}
+	}
+	if second["duration"] != float64(1) {
+		t.Errorf("duration = %#v, want 1", second["duration"])
+	}
+}
+
+func TestZipkinExporter_ServiceNameFromSpan(t *testing.T) {
+	exporter := NewZipkinExporter("http://zipkin:9411", ZipkinOptions{})
+
+	zs := exporter.toZipkin(&Span{
+		Name:       "op",
+		StartTime:  time.Now(),
+		Attributes: map[string]interface{}{"service.name": "distributor"},
+	})
+	if zs.LocalEndpoint == nil || zs.LocalEndpoint.ServiceName != "distributor" {
+		t.Errorf("LocalEndpoint = %+v, want serviceName distributor", zs.LocalEndpoint)
+	}
+
+	// Without either name there is no endpoint
+	if zs := exporter.toZipkin(&Span{Name: "op", StartTime: time.Now()}); zs.LocalEndpoint != nil {
+		t.Errorf("LocalEndpoint = %+v, want none", zs.LocalEndpoint)
+	}
+}
+
+func TestZipkinExporter_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "malformed span", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	exporter := NewZipkinExporter(server.URL, ZipkinOptions{})
+	if err := exporter.Export([]*Span{{Name: "op", StartTime: time.Now()}}); err == nil {
+		t.Error("expected error for 400 response")
+	}
+}